@@ -2,10 +2,10 @@ module cidtracker
 
 go 1.21
 
-require github.com/fsnotify/fsnotify v1.7.0
-
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/sirupsen/logrus v1.9.3 // indirect
-	golang.org/x/sys v0.4.0 // indirect
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/google/uuid v1.6.0
+	github.com/sirupsen/logrus v1.9.3
 )
+
+require golang.org/x/sys v0.4.0 // indirect
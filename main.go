@@ -3,37 +3,154 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
+	"cidtracker/pkg/config"
+	"cidtracker/pkg/sink"
+	"cidtracker/pkg/tracker"
 	log "github.com/sirupsen/logrus"
 )
 
+// version is the application version reported in logs and by the HTTP server
+var version = "0.1.0"
+
+// options holds the parsed command line flags
+type options struct {
+	configPath   string
+	logPath      string
+	outputFormat string
+	outputSchema string
+	printSchema  bool
+	verbose      bool
+	httpAddr     string
+	stateDir     string
+	startFrom    string
+	set          map[string]bool
+}
+
+// formatList names the registered output formats, e.g. "csv, json or logfmt"
+func formatList() string {
+	formats := sink.Formats()
+	last := len(formats) - 1
+	return strings.Join(formats[:last], ", ") + " or " + formats[last]
+}
+
+// parseFlags parses command line arguments and records which flags were set explicitly
+func parseFlags(args []string) (*options, error) {
+	opts := &options{set: make(map[string]bool)}
+
+	fs := flag.NewFlagSet("cidtracker", flag.ContinueOnError)
+	fs.StringVar(&opts.configPath, "config", "", "Path to JSON configuration file")
+	fs.StringVar(&opts.logPath, "log-path", "/var/log/app", "Path to mounted docker logs directory")
+	fs.StringVar(&opts.outputFormat, "output", "json", "Output format: "+formatList())
+	fs.StringVar(&opts.outputSchema, "output-schema", "v1", "Schema of json output: v1 or legacy")
+	fs.BoolVar(&opts.printSchema, "print-schema", false, "Print the JSON Schema of the json output record and exit")
+	fs.BoolVar(&opts.verbose, "verbose", false, "Enable verbose logging")
+	fs.StringVar(&opts.httpAddr, "http-addr", ":8080", "Address for the health/status HTTP server (empty to disable)")
+	fs.StringVar(&opts.stateDir, "state-dir", "", "Directory for read checkpoints (empty to disable)")
+	fs.StringVar(&opts.startFrom, "start-from", "", "Where to start existing files: beginning, end or checkpoint (default checkpoint with -state-dir, otherwise end)")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		opts.set[f.Name] = true
+	})
+
+	return opts, nil
+}
+
+// loadConfig builds the effective configuration with precedence flag > env > file > default
+func loadConfig(opts *options, lookup func(string) (string, bool)) (*config.Config, error) {
+	cfg := config.DefaultConfig()
+
+	if opts.configPath != "" {
+		loaded, err := config.LoadFromFile(opts.configPath)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	}
+
+	if err := cfg.ApplyEnv(lookup); err != nil {
+		return nil, err
+	}
+
+	if opts.set["log-path"] {
+		cfg.SetLogDir(opts.logPath)
+	}
+	if opts.set["output"] {
+		cfg.OutputFormat = opts.outputFormat
+	}
+	if opts.set["output-schema"] {
+		cfg.OutputSchema = opts.outputSchema
+	}
+	if opts.set["state-dir"] {
+		cfg.StateDir = opts.stateDir
+	}
+	if opts.set["start-from"] {
+		cfg.StartFrom = opts.startFrom
+	}
+	if opts.set["verbose"] && opts.verbose {
+		cfg.LogLevel = "debug"
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.ActiveSources()) == 0 {
+		return nil, fmt.Errorf("no active log sources configured")
+	}
+
+	return cfg, nil
+}
+
 func main() {
-	// Command line flags
-	logPath := flag.String("log-path", "/var/log/app", "Path to mounted docker logs directory")
-	outputFormat := flag.String("output", "json", "Output format: json or structured")
-	verbose := flag.Bool("verbose", false, "Enable verbose logging")
-	flag.Parse()
+	opts, err := parseFlags(os.Args[1:])
+	if err != nil {
+		os.Exit(2)
+	}
 
-	// Configure logging
-	if *verbose {
-		log.SetLevel(log.DebugLevel)
-	} else {
-		log.SetLevel(log.InfoLevel)
+	if opts.printSchema {
+		schema, err := sink.RecordSchema()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(schema)
+		return
 	}
+
 	log.SetFormatter(&log.JSONFormatter{})
 
+	cfg, err := loadConfig(opts, os.LookupEnv)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid configuration")
+	}
+
+	// Configure logging
+	level, err := log.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		level = log.InfoLevel
+	}
+	log.SetLevel(level)
+
 	log.WithFields(log.Fields{
-		"version": "0.1.0",
-		"log_path": *logPath,
-		"output_format": *outputFormat,
-	}).Info("Starting CID Tracker")
+		"version":   version,
+		"http_addr": opts.httpAddr,
+	}).WithFields(cfg.LogFields()).Info("Starting CID Tracker")
 
-	// Validate log path exists
-	if _, err := os.Stat(*logPath); os.IsNotExist(err) {
-		log.WithField("path", *logPath).Fatal("Log path does not exist")
+	// Validate log paths exist
+	for _, source := range cfg.ActiveSources() {
+		if _, err := os.Stat(source.Path); os.IsNotExist(err) {
+			log.WithField("path", source.Path).Fatal("Log path does not exist")
+		}
 	}
 
 	// Create context for graceful shutdown
@@ -41,7 +158,13 @@ func main() {
 	defer cancel()
 
 	// Initialize tracker
-	tracker := NewCIDTracker(*logPath, *outputFormat)
+	cidTracker, err := tracker.New(cfg)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create CID tracker")
+	}
+	if opts.httpAddr != "" {
+		cidTracker.EnableHTTPServer(opts.httpAddr, version)
+	}
 
 	// Handle shutdown signals
 	sigChan := make(chan os.Signal, 1)
@@ -54,9 +177,9 @@ func main() {
 	}()
 
 	// Start monitoring
-	if err := tracker.Start(ctx); err != nil {
+	if err := cidTracker.Start(ctx); err != nil {
 		log.WithError(err).Fatal("Failed to start CID tracker")
 	}
 
 	log.Info("CID Tracker stopped")
-}
\ No newline at end of file
+}
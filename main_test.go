@@ -0,0 +1,267 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/sink"
+)
+
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestParseFlags_RecordsExplicitFlags(t *testing.T) {
+	opts, err := parseFlags([]string{"-output=structured", "-config", "/etc/cidtracker/config.json"})
+	if err != nil {
+		t.Fatalf("parseFlags() error = %v", err)
+	}
+
+	if !opts.set["output"] {
+		t.Error("output should be recorded as set")
+	}
+	if opts.set["log-path"] {
+		t.Error("log-path should not be recorded as set")
+	}
+	if opts.configPath != "/etc/cidtracker/config.json" {
+		t.Errorf("configPath = %v, want /etc/cidtracker/config.json", opts.configPath)
+	}
+	if opts.httpAddr != ":8080" {
+		t.Errorf("httpAddr = %v, want :8080", opts.httpAddr)
+	}
+}
+
+func TestParseFlags_UnknownFlag(t *testing.T) {
+	if _, err := parseFlags([]string{"-no-such-flag"}); err == nil {
+		t.Error("expected error for unknown flag")
+	}
+}
+
+func TestFormatList(t *testing.T) {
+	list := formatList()
+	for _, format := range sink.Formats() {
+		if !strings.Contains(list, format) {
+			t.Errorf("formatList() = %q, want it to name %s", list, format)
+		}
+	}
+	if !strings.Contains(list, " or ") {
+		t.Errorf("formatList() = %q, want the last format after \"or\"", list)
+	}
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	opts, _ := parseFlags(nil)
+
+	cfg, err := loadConfig(opts, envLookup(nil))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	if cfg.LogSources[0].Path != "/var/log/app" {
+		t.Errorf("LogSources[0].Path = %v, want /var/log/app", cfg.LogSources[0].Path)
+	}
+	if cfg.OutputFormat != "json" {
+		t.Errorf("OutputFormat = %v, want json", cfg.OutputFormat)
+	}
+	if cfg.LogLevel != "info" {
+		t.Errorf("LogLevel = %v, want info", cfg.LogLevel)
+	}
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	configPath := writeConfigFile(t, `{
+		"log_sources": [{"path": "/from/file", "name": "file", "patterns": ["*.log"], "active": true}],
+		"output_format": "structured",
+		"buffer_size": 250,
+		"log_level": "warn"
+	}`)
+
+	env := map[string]string{
+		"CIDTRACKER_LOG_DIR":       "/from/env",
+		"CIDTRACKER_OUTPUT_FORMAT": "json",
+		"CIDTRACKER_POLL_INTERVAL": "250ms",
+	}
+
+	opts, err := parseFlags([]string{"-config", configPath, "-log-path", "/from/flag"})
+	if err != nil {
+		t.Fatalf("parseFlags() error = %v", err)
+	}
+
+	cfg, err := loadConfig(opts, envLookup(env))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	// Flag beats env and file
+	if cfg.LogSources[0].Path != "/from/flag" {
+		t.Errorf("LogSources[0].Path = %v, want /from/flag", cfg.LogSources[0].Path)
+	}
+	// Env beats file
+	if cfg.OutputFormat != "json" {
+		t.Errorf("OutputFormat = %v, want json", cfg.OutputFormat)
+	}
+	if cfg.WatchInterval != 250*time.Millisecond {
+		t.Errorf("WatchInterval = %v, want 250ms", cfg.WatchInterval)
+	}
+	// File beats default
+	if cfg.BufferSize != 250 {
+		t.Errorf("BufferSize = %d, want 250", cfg.BufferSize)
+	}
+	if cfg.LogLevel != "warn" {
+		t.Errorf("LogLevel = %v, want warn", cfg.LogLevel)
+	}
+	// Default fills the rest
+	if cfg.FlushInterval != 5*time.Second {
+		t.Errorf("FlushInterval = %v, want 5s", cfg.FlushInterval)
+	}
+}
+
+func TestLoadConfig_FlagFixesInvalidLayer(t *testing.T) {
+	stateDir := t.TempDir()
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+	}{
+		{
+			name: "invalid env format replaced by flag",
+			env:  map[string]string{"CIDTRACKER_OUTPUT_FORMAT": "bogus"},
+			args: []string{"-output", "csv"},
+		},
+		{
+			name: "file checkpoint start with state dir flag",
+			file: `{"start_from": "checkpoint"}`,
+			args: []string{"-state-dir", stateDir},
+		},
+		{
+			name: "env checkpoint start with state dir flag",
+			env:  map[string]string{"CIDTRACKER_START_FROM": "checkpoint"},
+			args: []string{"-state-dir", stateDir},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfigFile(t, tt.file)}, args...)
+			}
+			opts, err := parseFlags(args)
+			if err != nil {
+				t.Fatalf("parseFlags() error = %v", err)
+			}
+			// Only the merged configuration is validated
+			if _, err := loadConfig(opts, envLookup(tt.env)); err != nil {
+				t.Errorf("loadConfig() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_OutputSchema(t *testing.T) {
+	opts, _ := parseFlags([]string{"-output-schema", "legacy"})
+
+	cfg, err := loadConfig(opts, envLookup(map[string]string{"CIDTRACKER_OUTPUT_SCHEMA": "v1"}))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if cfg.OutputSchema != "legacy" {
+		t.Errorf("OutputSchema = %v, want legacy", cfg.OutputSchema)
+	}
+
+	opts, _ = parseFlags([]string{"-output-schema", "v0"})
+	if _, err := loadConfig(opts, envLookup(nil)); err == nil {
+		t.Error("expected error for unknown output schema")
+	}
+}
+
+func TestLoadConfig_VerboseOverridesLogLevel(t *testing.T) {
+	opts, _ := parseFlags([]string{"-verbose"})
+
+	cfg, err := loadConfig(opts, envLookup(map[string]string{"CIDTRACKER_LOG_LEVEL": "error"}))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	if cfg.LogLevel != "debug" {
+		t.Errorf("LogLevel = %v, want debug", cfg.LogLevel)
+	}
+}
+
+func TestLoadConfig_InvalidEnv(t *testing.T) {
+	opts, _ := parseFlags(nil)
+
+	if _, err := loadConfig(opts, envLookup(map[string]string{"CIDTRACKER_BUFFER_SIZE": "lots"})); err == nil {
+		t.Error("expected error for invalid buffer size")
+	}
+}
+
+func TestLoadConfig_MissingConfigFile(t *testing.T) {
+	opts, _ := parseFlags([]string{"-config", "/nonexistent/config.json"})
+
+	if _, err := loadConfig(opts, envLookup(nil)); err == nil {
+		t.Error("expected error for missing config file")
+	}
+}
+
+func TestLoadConfig_NoActiveSources(t *testing.T) {
+	configPath := writeConfigFile(t, `{
+		"log_sources": [{"path": "/var/log/app", "name": "app", "active": false}]
+	}`)
+
+	opts, _ := parseFlags([]string{"-config", configPath})
+
+	if _, err := loadConfig(opts, envLookup(nil)); err == nil {
+		t.Error("expected error when no sources are active")
+	}
+}
+
+func TestLoadConfig_StartFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: "end"},
+		{name: "state dir flag", args: []string{"-state-dir", "/var/lib/cidtracker"}, want: "checkpoint"},
+		{name: "state dir env", env: map[string]string{"CIDTRACKER_STATE_DIR": "/var/lib/cidtracker"}, want: "checkpoint"},
+		{name: "flag beats env", args: []string{"-start-from", "beginning"}, env: map[string]string{"CIDTRACKER_START_FROM": "end"}, want: "beginning"},
+		{name: "checkpoint without state dir", args: []string{"-start-from", "checkpoint"}, wantErr: true},
+		{name: "invalid", args: []string{"-start-from", "later"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseFlags(tt.args)
+			if err != nil {
+				t.Fatalf("parseFlags() error = %v", err)
+			}
+
+			cfg, err := loadConfig(opts, envLookup(tt.env))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.StartPosition() != tt.want {
+				t.Errorf("StartPosition() = %v, want %v", cfg.StartPosition(), tt.want)
+			}
+		})
+	}
+}
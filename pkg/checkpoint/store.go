@@ -0,0 +1,233 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"cidtracker/pkg/tailer"
+	log "github.com/sirupsen/logrus"
+)
+
+// FileName is the name of the checkpoint file inside the state directory
+const FileName = "checkpoints.json"
+
+// stateVersion is the version of the checkpoint file format
+const stateVersion = 1
+
+// state is the on-disk layout of the checkpoint file
+type state struct {
+	Version   int                        `json:"version"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+	Files     map[string]tailer.Position `json:"files"`
+}
+
+// Store keeps the read position of every followed file and persists them to
+// a state directory so that a restart resumes where the previous run stopped.
+// A position can be staged until the lines before it have been written out,
+// and only committed positions are persisted.
+type Store struct {
+	path      string
+	mu        sync.Mutex
+	positions map[string]tailer.Position
+	staged    map[string]tailer.Position
+	dirty     bool
+}
+
+// Open loads the checkpoints from dir, creating the directory if needed
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory %s: %w", dir, err)
+	}
+
+	s := &Store{
+		path:      filepath.Join(dir, FileName),
+		positions: make(map[string]tailer.Position),
+		staged:    make(map[string]tailer.Position),
+	}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoints %s: %w", s.path, err)
+	}
+	if st.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d in %s", st.Version, s.path)
+	}
+	for path, pos := range st.Files {
+		s.positions[path] = pos
+	}
+
+	return s, nil
+}
+
+// Path returns the location of the checkpoint file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Get returns the saved position for a file
+func (s *Store) Get(path string) (tailer.Position, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pos, ok := s.positions[path]
+	return pos, ok
+}
+
+// Set records the position for a file. It is persisted on the next Flush.
+func (s *Store) Set(path string, pos tailer.Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.positions[path]; ok && current == pos {
+		return
+	}
+	s.positions[path] = pos
+	s.dirty = true
+}
+
+// Stage records a position whose lines may not have been written out yet.
+// It replaces the saved position once committed with Sync.
+func (s *Store) Stage(path string, pos tailer.Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.staged[path] = pos
+}
+
+// Delete forgets a file that no longer exists
+func (s *Store) Delete(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.staged, path)
+	if _, ok := s.positions[path]; ok {
+		delete(s.positions, path)
+		s.dirty = true
+	}
+}
+
+// Paths returns the files with a saved position, sorted
+func (s *Store) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths := make([]string, 0, len(s.positions))
+	for path := range s.positions {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Flush writes the checkpoints to disk if they changed since the last flush.
+// The file is replaced atomically so a crash never leaves a partial checkpoint.
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(state{
+		Version:   stateVersion,
+		UpdatedAt: time.Now().UTC(),
+		Files:     s.positions,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoints: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), FileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync checkpoints: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close checkpoint file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace checkpoints: %w", err)
+	}
+
+	s.dirty = false
+	return nil
+}
+
+// Sync commits the positions staged so far once written reports that the
+// lines before them are out, then flushes the checkpoints. Positions staged
+// while written runs are left for the next Sync, and so are all of them if
+// written fails.
+func (s *Store) Sync(ctx context.Context, written func(context.Context) error) error {
+	s.mu.Lock()
+	staged := make(map[string]tailer.Position, len(s.staged))
+	for path, pos := range s.staged {
+		staged[path] = pos
+	}
+	s.mu.Unlock()
+
+	if err := written(ctx); err != nil {
+		return fmt.Errorf("checkpoints not advanced: %w", err)
+	}
+
+	s.mu.Lock()
+	for path, pos := range staged {
+		current, ok := s.staged[path]
+		if !ok {
+			// Deleted in the meantime
+			continue
+		}
+		if current == pos {
+			delete(s.staged, path)
+		}
+		if saved, ok := s.positions[path]; !ok || saved != pos {
+			s.positions[path] = pos
+			s.dirty = true
+		}
+	}
+	s.mu.Unlock()
+
+	return s.Flush()
+}
+
+// Run syncs the checkpoints every interval until ctx is cancelled, with
+// written as the condition for committing staged positions. The owner is
+// expected to stage final positions and Sync once more at shutdown.
+func (s *Store) Run(ctx context.Context, interval time.Duration, written func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sync(ctx, written); err != nil && ctx.Err() == nil {
+				log.WithError(err).Warn("Failed to flush checkpoints")
+			}
+		}
+	}
+}
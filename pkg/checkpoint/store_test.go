@@ -0,0 +1,214 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/tailer"
+)
+
+func TestOpen_CreatesStateDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state", "nested")
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("state directory was not created: %v", err)
+	}
+	if s.Path() != filepath.Join(dir, FileName) {
+		t.Errorf("Path() = %v, want %v", s.Path(), filepath.Join(dir, FileName))
+	}
+	if len(s.Paths()) != 0 {
+		t.Errorf("Paths() = %v, want empty", s.Paths())
+	}
+}
+
+func TestStore_FlushAndReload(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	pos := tailer.Position{
+		Offset:          42,
+		FileID:          tailer.FileID{Dev: 1, Ino: 99},
+		Fingerprint:     "abc",
+		FingerprintSize: 42,
+	}
+	s.Set("/var/log/app/b.log", pos)
+	s.Set("/var/log/app/a.log", tailer.Position{Offset: 7})
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	reloaded, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	got, ok := reloaded.Get("/var/log/app/b.log")
+	if !ok {
+		t.Fatal("expected checkpoint for b.log")
+	}
+	if got != pos {
+		t.Errorf("Get() = %+v, want %+v", got, pos)
+	}
+
+	want := []string{"/var/log/app/a.log", "/var/log/app/b.log"}
+	if !reflect.DeepEqual(reloaded.Paths(), want) {
+		t.Errorf("Paths() = %v, want %v", reloaded.Paths(), want)
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	dir := t.TempDir()
+
+	s, _ := Open(dir)
+	s.Set("/var/log/app/a.log", tailer.Position{Offset: 7})
+	s.Flush()
+
+	s.Delete("/var/log/app/a.log")
+	if _, ok := s.Get("/var/log/app/a.log"); ok {
+		t.Error("expected checkpoint to be deleted")
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	reloaded, _ := Open(dir)
+	if len(reloaded.Paths()) != 0 {
+		t.Errorf("Paths() = %v, want empty after delete", reloaded.Paths())
+	}
+}
+
+func TestStore_FlushSkipsUnchanged(t *testing.T) {
+	dir := t.TempDir()
+
+	s, _ := Open(dir)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if _, err := os.Stat(s.Path()); !os.IsNotExist(err) {
+		t.Error("Flush() without changes should not write a file")
+	}
+
+	s.Set("/var/log/app/a.log", tailer.Position{Offset: 7})
+	s.Flush()
+	first, _ := os.Stat(s.Path())
+
+	// Setting the same position again does not mark the store dirty
+	s.Set("/var/log/app/a.log", tailer.Position{Offset: 7})
+	if s.dirty {
+		t.Error("store should not be dirty after setting an unchanged position")
+	}
+
+	second, _ := os.Stat(s.Path())
+	if !first.ModTime().Equal(second.ModTime()) {
+		t.Error("checkpoint file should not be rewritten")
+	}
+}
+
+func TestStore_Sync(t *testing.T) {
+	s, _ := Open(t.TempDir())
+	s.Stage("/var/log/app/a.log", tailer.Position{Offset: 7})
+
+	// Positions whose lines were not written out stay staged
+	if err := s.Sync(context.Background(), func(context.Context) error {
+		return errors.New("sink unreachable")
+	}); err == nil {
+		t.Error("Sync() error = nil when the lines were not written")
+	}
+	if _, ok := s.Get("/var/log/app/a.log"); ok {
+		t.Error("Sync() committed a position whose lines were not written")
+	}
+	if _, err := os.Stat(s.Path()); !os.IsNotExist(err) {
+		t.Error("Sync() wrote checkpoints when the lines were not written")
+	}
+
+	// A position staged while waiting is left for the next Sync
+	err := s.Sync(context.Background(), func(context.Context) error {
+		s.Stage("/var/log/app/a.log", tailer.Position{Offset: 9})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if pos, _ := s.Get("/var/log/app/a.log"); pos.Offset != 7 {
+		t.Errorf("Get() offset = %d after Sync(), want 7", pos.Offset)
+	}
+	reloaded, _ := Open(filepath.Dir(s.Path()))
+	if pos, _ := reloaded.Get("/var/log/app/a.log"); pos.Offset != 7 {
+		t.Errorf("reloaded offset = %d, want 7", pos.Offset)
+	}
+
+	s.Sync(context.Background(), func(context.Context) error { return nil })
+	if pos, _ := s.Get("/var/log/app/a.log"); pos.Offset != 9 {
+		t.Errorf("Get() offset = %d after the next Sync(), want 9", pos.Offset)
+	}
+}
+
+func TestOpen_InvalidState(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "malformed json", content: "{not json"},
+		{name: "unsupported version", content: `{"version": 99, "files": {}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, FileName), []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write state: %v", err)
+			}
+
+			if _, err := Open(dir); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestStore_Run(t *testing.T) {
+	dir := t.TempDir()
+
+	s, _ := Open(dir)
+	s.Stage("/var/log/app/a.log", tailer.Position{Offset: 7})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx, 10*time.Millisecond, func(context.Context) error { return nil })
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(s.Path()); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for periodic flush")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Run() did not return after cancel")
+	}
+}
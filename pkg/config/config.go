@@ -5,9 +5,49 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"runtime"
+	"strconv"
 	"time"
 
+	"cidtracker/pkg/decoder"
+	"cidtracker/pkg/extractor"
 	"cidtracker/pkg/models"
+	"cidtracker/pkg/multiline"
+	"cidtracker/pkg/sink"
+	"cidtracker/pkg/source"
+	"cidtracker/pkg/tailer"
+	"cidtracker/pkg/timestamp"
+	"cidtracker/pkg/watcher"
+	log "github.com/sirupsen/logrus"
+)
+
+// Environment variables recognised by ApplyEnv
+const (
+	EnvLogDir              = "CIDTRACKER_LOG_DIR"
+	EnvOutputFormat        = "CIDTRACKER_OUTPUT_FORMAT"
+	EnvOutputSchema        = "CIDTRACKER_OUTPUT_SCHEMA"
+	EnvBufferSize          = "CIDTRACKER_BUFFER_SIZE"
+	EnvPollInterval        = "CIDTRACKER_POLL_INTERVAL"
+	EnvCIDPattern          = "CIDTRACKER_CID_PATTERN"
+	EnvLogLevel            = "CIDTRACKER_LOG_LEVEL"
+	EnvEnableU5Only        = "CIDTRACKER_ENABLE_U5_ONLY"
+	EnvStateDir            = "CIDTRACKER_STATE_DIR"
+	EnvStartFrom           = "CIDTRACKER_START_FROM"
+	EnvMaxLineSize         = "CIDTRACKER_MAX_LINE_SIZE"
+	EnvOversizedLinePolicy = "CIDTRACKER_OVERSIZED_LINE_POLICY"
+	EnvWatchMode           = "CIDTRACKER_WATCH_MODE"
+	EnvWorkers             = "CIDTRACKER_WORKERS"
+)
+
+// Start positions for files found at startup
+const (
+	// StartFromBeginning reads every existing file from the beginning
+	StartFromBeginning = "beginning"
+	// StartFromEnd reads only lines appended after startup
+	StartFromEnd = "end"
+	// StartFromCheckpoint resumes from the saved checkpoint and reads files
+	// without one from the beginning
+	StartFromCheckpoint = "checkpoint"
 )
 
 // Config holds the application configuration
@@ -22,6 +62,28 @@ type Config struct {
 	EnableU5Only    bool                 `json:"enable_u5_only"`
 	CorrelationTTL  time.Duration        `json:"correlation_ttl"`
 	LogLevel        string               `json:"log_level"`
+	StateDir        string               `json:"state_dir"`
+	StartFrom       string               `json:"start_from"`
+	// MaxLineSize bounds the bytes kept per line; longer lines are handled
+	// according to OversizedLinePolicy, "truncate" or "skip"
+	MaxLineSize         int    `json:"max_line_size"`
+	OversizedLinePolicy string `json:"oversized_line_policy"`
+	// PartialLineTimeout is how long a final line without a newline is held
+	// back for the rest of it to be written
+	PartialLineTimeout time.Duration `json:"partial_line_timeout"`
+	// WatchMode is how sources without their own watch mode are watched:
+	// "auto", "fsnotify", "poll" or "hybrid". Polling uses WatchInterval.
+	WatchMode string `json:"watch_mode"`
+	// Workers is the number of goroutines extracting and validating CIDs; at
+	// most BufferSize lines wait for them before reading pauses
+	Workers int `json:"workers"`
+	// OutputSchema is the schema of the json format for sinks without one
+	// of their own: "v1", the default, or "legacy" for the document written
+	// before the output record was versioned
+	OutputSchema string `json:"output_schema,omitempty"`
+	// Sinks are the outputs records are written to, each with its own format
+	// and filter. Without any, records go to stdout in OutputFormat.
+	Sinks []models.SinkConfig `json:"sinks,omitempty"`
 }
 
 // DefaultConfig returns a default configuration
@@ -36,44 +98,305 @@ func DefaultConfig() *Config {
 				Description: "Main application logs",
 			},
 		},
-		CIDPatterns: []models.CIDPattern{
-			{
-				Name:        "standard_cid",
-				RegexString: `CID\s*[=:]\s*([a-fA-F0-9]{8}-[a-fA-F0-9]{4}-[a-fA-F0-9]{4}-[a-fA-F0-9]{4}-[a-fA-F0-9]{12})`,
-				UUIDGroup:   1,
-				Enabled:     true,
-			},
-			{
-				Name:        "json_cid",
-				RegexString: `"cid"\s*:\s*"([a-fA-F0-9]{8}-[a-fA-F0-9]{4}-[a-fA-F0-9]{4}-[a-fA-F0-9]{4}-[a-fA-F0-9]{12})"`,
-				UUIDGroup:   1,
-				Enabled:     true,
-			},
-		},
-		OutputFormat:   "json",
-		OutputPath:     "/var/output/cid-tracker.json",
-		BufferSize:     1000,
-		FlushInterval:  5 * time.Second,
-		WatchInterval:  100 * time.Millisecond,
-		EnableU5Only:   true,
-		CorrelationTTL: 1 * time.Hour,
-		LogLevel:       "info",
+		CIDPatterns:         extractor.DefaultPatterns(),
+		OutputFormat:        "json",
+		OutputPath:          "/var/output/cid-tracker.json",
+		BufferSize:          1000,
+		FlushInterval:       5 * time.Second,
+		WatchInterval:       100 * time.Millisecond,
+		EnableU5Only:        true,
+		CorrelationTTL:      1 * time.Hour,
+		LogLevel:            "info",
+		MaxLineSize:         tailer.DefaultMaxLineSize,
+		OversizedLinePolicy: tailer.TruncateLines,
+		PartialLineTimeout:  tailer.DefaultPartialLineTimeout,
+		WatchMode:           watcher.ModeAuto,
+		Workers:             runtime.NumCPU(),
 	}
 }
 
-// LoadFromFile loads configuration from JSON file
+// LoadFromFile loads configuration from JSON file, using defaults for omitted fields.
+// Only parse errors are reported; call Validate once every layer is applied.
 func LoadFromFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	config := DefaultConfig()
+	defaultSources, defaultPatterns := config.LogSources, config.CIDPatterns
+	config.LogSources, config.CIDPatterns = nil, nil
+
+	if err := json.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	return &config, config.validate()
+	if config.LogSources == nil {
+		config.LogSources = defaultSources
+	}
+	if config.CIDPatterns == nil {
+		config.CIDPatterns = defaultPatterns
+	}
+
+	return config, nil
+}
+
+// ApplyEnv overrides configuration values from CIDTRACKER_* environment variables.
+// Only values that fail to parse are reported; call Validate once every layer
+// is applied.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	if v, ok := lookup(EnvLogDir); ok && v != "" {
+		c.SetLogDir(v)
+	}
+
+	if v, ok := lookup(EnvOutputFormat); ok && v != "" {
+		c.OutputFormat = v
+	}
+
+	if v, ok := lookup(EnvOutputSchema); ok && v != "" {
+		c.OutputSchema = v
+	}
+
+	if v, ok := lookup(EnvBufferSize); ok && v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", EnvBufferSize, v, err)
+		}
+		c.BufferSize = size
+	}
+
+	if v, ok := lookup(EnvPollInterval); ok && v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", EnvPollInterval, v, err)
+		}
+		c.WatchInterval = interval
+	}
+
+	if v, ok := lookup(EnvCIDPattern); ok && v != "" {
+		c.SetCIDPattern(v)
+	}
+
+	if v, ok := lookup(EnvLogLevel); ok && v != "" {
+		c.LogLevel = v
+	}
+
+	if v, ok := lookup(EnvEnableU5Only); ok && v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", EnvEnableU5Only, v, err)
+		}
+		c.EnableU5Only = enabled
+	}
+
+	if v, ok := lookup(EnvStateDir); ok && v != "" {
+		c.StateDir = v
+	}
+
+	if v, ok := lookup(EnvStartFrom); ok && v != "" {
+		c.StartFrom = v
+	}
+
+	if v, ok := lookup(EnvMaxLineSize); ok && v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", EnvMaxLineSize, v, err)
+		}
+		c.MaxLineSize = size
+	}
+
+	if v, ok := lookup(EnvOversizedLinePolicy); ok && v != "" {
+		c.OversizedLinePolicy = v
+	}
+
+	if v, ok := lookup(EnvWatchMode); ok && v != "" {
+		c.WatchMode = v
+	}
+
+	if v, ok := lookup(EnvWorkers); ok && v != "" {
+		workers, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", EnvWorkers, v, err)
+		}
+		c.Workers = workers
+	}
+
+	return nil
+}
+
+// SetLogDir replaces the configured log sources with a single directory
+func (c *Config) SetLogDir(dir string) {
+	patterns := []string{"*.log"}
+	if len(c.LogSources) > 0 && len(c.LogSources[0].Patterns) > 0 {
+		patterns = c.LogSources[0].Patterns
+	}
+
+	c.LogSources = []models.LogSource{
+		{
+			Path:        dir,
+			Name:        "application",
+			Patterns:    patterns,
+			Active:      true,
+			Description: "Log directory from command line or environment",
+		},
+	}
+}
+
+// SetCIDPattern replaces the configured CID patterns with a single custom regex.
+// The first capture group holds the UUID; without one the whole match is used.
+func (c *Config) SetCIDPattern(regex string) {
+	group := 0
+	if compiled, err := regexp.Compile(regex); err == nil && compiled.NumSubexp() > 0 {
+		group = 1
+	}
+
+	c.CIDPatterns = []models.CIDPattern{
+		{
+			Name:        "custom",
+			RegexString: regex,
+			UUIDGroup:   group,
+			Enabled:     true,
+		},
+	}
+}
+
+// ActiveSources returns the log sources that are enabled
+func (c *Config) ActiveSources() []models.LogSource {
+	var active []models.LogSource
+	for _, source := range c.LogSources {
+		if source.Active {
+			active = append(active, source)
+		}
+	}
+	return active
+}
+
+// Validate compiles regex patterns and fills in defaults after programmatic changes
+func (c *Config) Validate() error {
+	return c.validate()
+}
+
+// StartPosition returns where files found at startup are read from. Unless set
+// explicitly, checkpoints are used whenever a state directory is configured.
+func (c *Config) StartPosition() string {
+	if c.StartFrom != "" {
+		return c.StartFrom
+	}
+	if c.StateDir != "" {
+		return StartFromCheckpoint
+	}
+	return StartFromEnd
+}
+
+// SourceWatchMode returns the watch mode of a log source, falling back to the
+// configured default
+func (c *Config) SourceWatchMode(logSource models.LogSource) string {
+	if logSource.Watch != "" {
+		return logSource.Watch
+	}
+	return c.WatchMode
+}
+
+// OutputSinks returns the configured sinks with their defaults applied: a
+// sink without a name is named after its type and one without a format uses
+// OutputFormat. Sinks writing json without an encoder schema use
+// OutputSchema. File sinks write to OutputPath and buffer BufferSize records
+// unless set otherwise. Without configured sinks, a single stdout sink is
+// returned.
+func (c *Config) OutputSinks() []models.SinkConfig {
+	if len(c.Sinks) == 0 {
+		return []models.SinkConfig{c.withOutputSchema(models.SinkConfig{Name: sink.TypeStdout, Type: sink.TypeStdout, Format: c.OutputFormat})}
+	}
+
+	sinks := make([]models.SinkConfig, len(c.Sinks))
+	for i, cfg := range c.Sinks {
+		if cfg.Name == "" {
+			cfg.Name = cfg.Type
+		}
+		if cfg.Format == "" {
+			cfg.Format = c.OutputFormat
+		}
+		cfg = c.withOutputSchema(cfg)
+		if cfg.Type == sink.TypeFile {
+			file := models.FileSinkConfig{}
+			if cfg.File != nil {
+				file = *cfg.File
+			}
+			if file.Path == "" {
+				file.Path = c.OutputPath
+			}
+			if file.BufferSize <= 0 {
+				file.BufferSize = c.BufferSize
+			}
+			cfg.File = &file
+		}
+		sinks[i] = cfg
+	}
+	return sinks
+}
+
+// withOutputSchema sets OutputSchema on a json sink whose encoder has no
+// schema. OTLP sinks send the raw line unless they have an encoder, so they
+// are only given the schema if they do.
+func (c *Config) withOutputSchema(cfg models.SinkConfig) models.SinkConfig {
+	if c.OutputSchema == "" || cfg.Format != sink.FormatJSON {
+		return cfg
+	}
+	if cfg.Encoder == nil && cfg.Type == sink.TypeOTLP {
+		return cfg
+	}
+
+	encoder := models.EncoderConfig{}
+	if cfg.Encoder != nil {
+		encoder = *cfg.Encoder
+	}
+	if encoder.Schema == "" {
+		encoder.Schema = c.OutputSchema
+	}
+	cfg.Encoder = &encoder
+	return cfg
+}
+
+// LogFields returns the effective configuration as structured log fields
+func (c *Config) LogFields() log.Fields {
+	sources := make([]string, 0, len(c.LogSources))
+	for _, source := range c.ActiveSources() {
+		sources = append(sources, source.Path)
+	}
+
+	patterns := make([]string, 0, len(c.CIDPatterns))
+	for _, pattern := range c.CIDPatterns {
+		if pattern.Enabled {
+			patterns = append(patterns, pattern.Name)
+		}
+	}
+
+	sinks := make([]string, 0, len(c.Sinks))
+	for _, cfg := range c.OutputSinks() {
+		sinks = append(sinks, cfg.Name)
+	}
+
+	return log.Fields{
+		"log_sources":           sources,
+		"cid_patterns":          patterns,
+		"output_format":         c.OutputFormat,
+		"output_schema":         c.OutputSchema,
+		"output_path":           c.OutputPath,
+		"buffer_size":           c.BufferSize,
+		"flush_interval":        c.FlushInterval.String(),
+		"watch_interval":        c.WatchInterval.String(),
+		"enable_u5_only":        c.EnableU5Only,
+		"correlation_ttl":       c.CorrelationTTL.String(),
+		"log_level":             c.LogLevel,
+		"state_dir":             c.StateDir,
+		"start_from":            c.StartPosition(),
+		"max_line_size":         c.MaxLineSize,
+		"oversized_line_policy": c.OversizedLinePolicy,
+		"partial_line_timeout":  c.PartialLineTimeout.String(),
+		"watch_mode":            c.WatchMode,
+		"workers":               c.Workers,
+		"sinks":                 sinks,
+	}
 }
 
 // validate compiles regex patterns and validates configuration
@@ -83,16 +406,108 @@ func (c *Config) validate() error {
 		if err != nil {
 			return fmt.Errorf("invalid regex pattern '%s': %w", c.CIDPatterns[i].Name, err)
 		}
+		if group := c.CIDPatterns[i].UUIDGroup; group < 0 || group > regex.NumSubexp() {
+			return fmt.Errorf("pattern '%s' has no capture group %d", c.CIDPatterns[i].Name, group)
+		}
 		c.CIDPatterns[i].Regex = regex
 	}
 
+	for _, logSource := range c.LogSources {
+		if _, err := source.NewMatcher(logSource); err != nil {
+			return err
+		}
+		if err := decoder.Validate(logSource.Format); err != nil {
+			return fmt.Errorf("log source '%s': %w", logSource.Name, err)
+		}
+		if _, err := timestamp.NewParser(logSource.TimestampLayouts, logSource.Timezone); err != nil {
+			return fmt.Errorf("log source '%s': %w", logSource.Name, err)
+		}
+		if logSource.Multiline != nil {
+			if _, err := multiline.New(*logSource.Multiline); err != nil {
+				return fmt.Errorf("log source '%s': %w", logSource.Name, err)
+			}
+		}
+		if err := watcher.ValidateMode(logSource.Watch); err != nil {
+			return fmt.Errorf("log source '%s': %w", logSource.Name, err)
+		}
+	}
+
 	if c.BufferSize <= 0 {
 		c.BufferSize = 1000
 	}
 
+	if c.Workers <= 0 {
+		c.Workers = runtime.NumCPU()
+	}
+
 	if c.FlushInterval <= 0 {
 		c.FlushInterval = 5 * time.Second
 	}
 
+	if c.WatchInterval <= 0 {
+		c.WatchInterval = 100 * time.Millisecond
+	}
+
+	if c.CorrelationTTL <= 0 {
+		c.CorrelationTTL = time.Hour
+	}
+
+	if c.MaxLineSize <= 0 {
+		c.MaxLineSize = tailer.DefaultMaxLineSize
+	}
+
+	if c.PartialLineTimeout <= 0 {
+		c.PartialLineTimeout = tailer.DefaultPartialLineTimeout
+	}
+
+	if c.OversizedLinePolicy == "" {
+		c.OversizedLinePolicy = tailer.TruncateLines
+	}
+	if err := tailer.ValidateLinePolicy(c.OversizedLinePolicy); err != nil {
+		return err
+	}
+
+	if c.WatchMode == "" {
+		c.WatchMode = watcher.ModeAuto
+	}
+	if err := watcher.ValidateMode(c.WatchMode); err != nil {
+		return err
+	}
+
+	if c.OutputFormat == "" {
+		c.OutputFormat = sink.FormatJSON
+	}
+	if err := sink.ValidateSchema(c.OutputSchema); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for _, cfg := range c.OutputSinks() {
+		if names[cfg.Name] {
+			return fmt.Errorf("duplicate sink name '%s'", cfg.Name)
+		}
+		names[cfg.Name] = true
+		if err := sink.Validate(cfg); err != nil {
+			return err
+		}
+	}
+
+	if c.LogLevel != "" {
+		if _, err := log.ParseLevel(c.LogLevel); err != nil {
+			return fmt.Errorf("invalid log level '%s': %w", c.LogLevel, err)
+		}
+	}
+
+	switch c.StartFrom {
+	case "", StartFromBeginning, StartFromEnd:
+	case StartFromCheckpoint:
+		if c.StateDir == "" {
+			return fmt.Errorf("start_from '%s' requires a state directory", c.StartFrom)
+		}
+	default:
+		return fmt.Errorf("invalid start_from '%s': must be %s, %s or %s",
+			c.StartFrom, StartFromBeginning, StartFromEnd, StartFromCheckpoint)
+	}
+
 	return nil
 }
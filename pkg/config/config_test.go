@@ -3,8 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
+
+	"cidtracker/pkg/models"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -105,6 +108,9 @@ func TestLoadFromFile_ValidConfig(t *testing.T) {
 	if err != nil {
 		t.Fatalf("LoadFromFile() error = %v", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
 
 	if len(cfg.LogSources) != 1 {
 		t.Errorf("LogSources length = %d, want 1", len(cfg.LogSources))
@@ -179,9 +185,12 @@ func TestLoadFromFile_InvalidRegex(t *testing.T) {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 
-	_, err := LoadFromFile(configPath)
-	if err == nil {
-		t.Error("LoadFromFile() should return error for invalid regex")
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() should return error for invalid regex")
 	}
 }
 
@@ -281,6 +290,9 @@ func TestConfigValidate_MultiplePatterns(t *testing.T) {
 	if err != nil {
 		t.Fatalf("LoadFromFile() error = %v", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
 
 	if len(cfg.CIDPatterns) != 2 {
 		t.Errorf("CIDPatterns length = %d, want 2", len(cfg.CIDPatterns))
@@ -323,6 +335,56 @@ func TestDefaultConfig_CIDPatterns(t *testing.T) {
 	}
 }
 
+func TestDefaultConfig_CIDPatternsMatchFormats(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	uuid := "550e8400-e29b-51d4-a716-446655440000"
+	tests := []struct {
+		line    string
+		pattern string
+	}{
+		{"CID:" + uuid, "standard_cid"},
+		{"CID=" + uuid, "standard_cid"},
+		{"cid: " + uuid, "standard_cid"},
+		{`{"cid":"` + uuid + `"}`, "json_cid"},
+		{`{"CID": "` + uuid + `"}`, "json_cid"},
+		{"[CID " + uuid + "]", "standard_cid"},
+		{"CID[" + uuid + "]", "standard_cid"},
+		{"CID[user-42]", ""},
+		{"[CID session " + uuid + "]", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			var matched string
+			for _, p := range cfg.CIDPatterns {
+				if m := p.Regex.FindStringSubmatch(tt.line); m != nil && m[p.UUIDGroup] == uuid {
+					matched = p.Name
+					break
+				}
+			}
+			if matched != tt.pattern {
+				t.Errorf("matched pattern = %q, want %q", matched, tt.pattern)
+			}
+		})
+	}
+}
+
+func TestConfigValidate_UUIDGroupOutOfRange(t *testing.T) {
+	cfg := &Config{
+		CIDPatterns: []models.CIDPattern{
+			{Name: "no_group", RegexString: "CID:[0-9a-f-]+", UUIDGroup: 1, Enabled: true},
+		},
+	}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("validate() should return error when UUIDGroup exceeds capture groups")
+	}
+}
+
 func TestLoadFromFile_EmptyConfig(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "empty.json")
@@ -370,3 +432,708 @@ func TestLoadFromFile_PartialConfig(t *testing.T) {
 		t.Errorf("LogLevel = %v, want warn", cfg.LogLevel)
 	}
 }
+
+func TestLoadFromFile_OmittedFieldsUseDefaults(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "partial.json")
+
+	configContent := `{
+		"buffer_size": 50
+	}`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+
+	if cfg.BufferSize != 50 {
+		t.Errorf("BufferSize = %d, want 50", cfg.BufferSize)
+	}
+	if len(cfg.LogSources) != 1 || cfg.LogSources[0].Path != "/var/log/app" {
+		t.Errorf("LogSources = %v, want default source", cfg.LogSources)
+	}
+	if len(cfg.CIDPatterns) != 2 {
+		t.Errorf("CIDPatterns length = %d, want 2", len(cfg.CIDPatterns))
+	}
+	if !cfg.EnableU5Only {
+		t.Error("EnableU5Only should keep its default of true")
+	}
+	if cfg.OutputFormat != "json" {
+		t.Errorf("OutputFormat = %v, want json", cfg.OutputFormat)
+	}
+}
+
+func TestLoadFromFile_InvalidLogLevel(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	if err := os.WriteFile(configPath, []byte(`{"log_level": "loud"}`), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() should return error for invalid log level")
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		EnvLogDir:       "/data/logs",
+		EnvOutputFormat: "structured",
+		EnvOutputSchema: "legacy",
+		EnvBufferSize:   "250",
+		EnvPollInterval: "2s",
+		EnvCIDPattern:   `REQ=([0-9a-f-]{36})`,
+		EnvLogLevel:     "debug",
+		EnvEnableU5Only: "false",
+	}
+
+	cfg := DefaultConfig()
+	err := cfg.ApplyEnv(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if len(cfg.LogSources) != 1 || cfg.LogSources[0].Path != "/data/logs" {
+		t.Errorf("LogSources = %v, want single /data/logs source", cfg.LogSources)
+	}
+	if !cfg.LogSources[0].Active {
+		t.Error("log source from environment should be active")
+	}
+	if cfg.OutputFormat != "structured" {
+		t.Errorf("OutputFormat = %v, want structured", cfg.OutputFormat)
+	}
+	if cfg.OutputSchema != "legacy" {
+		t.Errorf("OutputSchema = %v, want legacy", cfg.OutputSchema)
+	}
+	if cfg.BufferSize != 250 {
+		t.Errorf("BufferSize = %d, want 250", cfg.BufferSize)
+	}
+	if cfg.WatchInterval != 2*time.Second {
+		t.Errorf("WatchInterval = %v, want 2s", cfg.WatchInterval)
+	}
+	if len(cfg.CIDPatterns) != 1 || cfg.CIDPatterns[0].Regex == nil {
+		t.Fatalf("CIDPatterns = %v, want one compiled custom pattern", cfg.CIDPatterns)
+	}
+	if cfg.CIDPatterns[0].UUIDGroup != 1 {
+		t.Errorf("UUIDGroup = %d, want 1", cfg.CIDPatterns[0].UUIDGroup)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("LogLevel = %v, want debug", cfg.LogLevel)
+	}
+	if cfg.EnableU5Only {
+		t.Error("EnableU5Only should be false")
+	}
+}
+
+func TestApplyEnv_Unset(t *testing.T) {
+	cfg := DefaultConfig()
+	err := cfg.ApplyEnv(func(string) (string, bool) { return "", false })
+	if err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+
+	if cfg.LogSources[0].Path != "/var/log/app" {
+		t.Errorf("LogSources[0].Path = %v, want /var/log/app", cfg.LogSources[0].Path)
+	}
+	if len(cfg.CIDPatterns) != 2 {
+		t.Errorf("CIDPatterns length = %d, want 2", len(cfg.CIDPatterns))
+	}
+}
+
+func TestApplyEnv_InvalidValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"buffer size", EnvBufferSize, "big"},
+		{"poll interval", EnvPollInterval, "often"},
+		{"u5 only", EnvEnableU5Only, "maybe"},
+		{"cid pattern", EnvCIDPattern, "[unclosed"},
+		{"log level", EnvLogLevel, "loud"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			err := cfg.ApplyEnv(func(key string) (string, bool) {
+				if key == tt.key {
+					return tt.value, true
+				}
+				return "", false
+			})
+			if err == nil {
+				// Values that parse are checked once every layer is applied
+				err = cfg.Validate()
+			}
+			if err == nil {
+				t.Errorf("expected error for %s=%s", tt.key, tt.value)
+			}
+		})
+	}
+}
+
+func TestSetCIDPattern_WithoutGroup(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SetCIDPattern(`[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}`)
+
+	if cfg.CIDPatterns[0].UUIDGroup != 0 {
+		t.Errorf("UUIDGroup = %d, want 0 for pattern without capture group", cfg.CIDPatterns[0].UUIDGroup)
+	}
+}
+
+func TestActiveSources(t *testing.T) {
+	cfg := &Config{
+		LogSources: []models.LogSource{
+			{Path: "/a", Active: true},
+			{Path: "/b", Active: false},
+			{Path: "/c", Active: true},
+		},
+	}
+
+	active := cfg.ActiveSources()
+	if len(active) != 2 {
+		t.Fatalf("ActiveSources length = %d, want 2", len(active))
+	}
+	if active[0].Path != "/a" || active[1].Path != "/c" {
+		t.Errorf("ActiveSources = %v, want /a and /c", active)
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	fields := DefaultConfig().LogFields()
+
+	if fields["buffer_size"] != 1000 {
+		t.Errorf("buffer_size = %v, want 1000", fields["buffer_size"])
+	}
+	if fields["watch_interval"] != "100ms" {
+		t.Errorf("watch_interval = %v, want 100ms", fields["watch_interval"])
+	}
+	if sources, ok := fields["log_sources"].([]string); !ok || len(sources) != 1 {
+		t.Errorf("log_sources = %v, want one source", fields["log_sources"])
+	}
+}
+
+func TestConfig_StartPosition(t *testing.T) {
+	tests := []struct {
+		name      string
+		stateDir  string
+		startFrom string
+		want      string
+		wantErr   bool
+	}{
+		{name: "default without state dir", want: StartFromEnd},
+		{name: "default with state dir", stateDir: "/var/lib/cidtracker", want: StartFromCheckpoint},
+		{name: "beginning", startFrom: "beginning", want: StartFromBeginning},
+		{name: "end with state dir", stateDir: "/var/lib/cidtracker", startFrom: "end", want: StartFromEnd},
+		{name: "checkpoint with state dir", stateDir: "/var/lib/cidtracker", startFrom: "checkpoint", want: StartFromCheckpoint},
+		{name: "checkpoint without state dir", startFrom: "checkpoint", wantErr: true},
+		{name: "unknown", startFrom: "middle", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.StateDir = tt.stateDir
+			cfg.StartFrom = tt.startFrom
+
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.StartPosition() != tt.want {
+				t.Errorf("StartPosition() = %v, want %v", cfg.StartPosition(), tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEnv_StateDir(t *testing.T) {
+	env := map[string]string{
+		EnvStateDir:  "/var/lib/cidtracker",
+		EnvStartFrom: "beginning",
+	}
+
+	cfg := DefaultConfig()
+	err := cfg.ApplyEnv(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+
+	if cfg.StateDir != "/var/lib/cidtracker" {
+		t.Errorf("StateDir = %v, want /var/lib/cidtracker", cfg.StateDir)
+	}
+	if cfg.StartFrom != StartFromBeginning {
+		t.Errorf("StartFrom = %v, want beginning", cfg.StartFrom)
+	}
+}
+
+func TestConfigValidate_InvalidSourcePattern(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LogSources[0].Exclude = []string{"[unclosed"}
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() should return error for an invalid glob pattern")
+	}
+}
+
+func TestConfigValidate_SourceTimestamps(t *testing.T) {
+	tests := []struct {
+		name     string
+		layouts  []string
+		timezone string
+		wantErr  bool
+	}{
+		{name: "defaults"},
+		{name: "named and Go layouts", layouts: []string{"unix_ms", "2006-01-02 15:04:05.000"}, timezone: "America/New_York"},
+		{name: "local timezone", timezone: "Local"},
+		{name: "unknown timezone", timezone: "Nowhere/City", wantErr: true},
+		{name: "invalid layout", layouts: []string{"iso"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.LogSources[0].TimestampLayouts = tt.layouts
+			cfg.LogSources[0].Timezone = tt.timezone
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidate_SourceMultiline(t *testing.T) {
+	tests := []struct {
+		name      string
+		multiline *models.MultilineConfig
+		wantErr   bool
+	}{
+		{name: "none"},
+		{name: "start pattern", multiline: &models.MultilineConfig{StartPattern: `^\d{4}-`}},
+		{name: "no patterns", multiline: &models.MultilineConfig{}, wantErr: true},
+		{name: "invalid pattern", multiline: &models.MultilineConfig{ContinuationPattern: "("}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.LogSources[0].Multiline = tt.multiline
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyEnv_LineLimit(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.MaxLineSize != 1<<20 || cfg.OversizedLinePolicy != "truncate" {
+		t.Errorf("defaults = %d, %s, want 1MiB, truncate", cfg.MaxLineSize, cfg.OversizedLinePolicy)
+	}
+
+	env := map[string]string{
+		EnvMaxLineSize:         "65536",
+		EnvOversizedLinePolicy: "skip",
+	}
+	err := cfg.ApplyEnv(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+	if cfg.MaxLineSize != 65536 {
+		t.Errorf("MaxLineSize = %d, want 65536", cfg.MaxLineSize)
+	}
+	if cfg.OversizedLinePolicy != "skip" {
+		t.Errorf("OversizedLinePolicy = %s, want skip", cfg.OversizedLinePolicy)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "invalid size", env: map[string]string{EnvMaxLineSize: "big"}},
+		{name: "invalid policy", env: map[string]string{EnvOversizedLinePolicy: "wrap"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			err := cfg.ApplyEnv(func(key string) (string, bool) {
+				v, ok := tt.env[key]
+				return v, ok
+			})
+			if err == nil {
+				err = cfg.Validate()
+			}
+			if err == nil {
+				t.Error("ApplyEnv() or Validate() expected error")
+			}
+		})
+	}
+}
+
+func TestConfigValidate_SourceFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{"", false},
+		{"raw", false},
+		{"docker", false},
+		{"cri", false},
+		{"fluentd", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.LogSources[0].Format = tt.format
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_SourceWatchMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		watchMode   string
+		sourceWatch string
+		want        string
+		wantErr     bool
+	}{
+		{name: "default", want: "auto"},
+		{name: "global poll", watchMode: "poll", want: "poll"},
+		{name: "source overrides global", watchMode: "fsnotify", sourceWatch: "hybrid", want: "hybrid"},
+		{name: "invalid global", watchMode: "inotify", wantErr: true},
+		{name: "invalid source", sourceWatch: "stat", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.WatchMode = tt.watchMode
+			cfg.LogSources[0].Watch = tt.sourceWatch
+
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.SourceWatchMode(cfg.LogSources[0]) != tt.want {
+				t.Errorf("SourceWatchMode() = %v, want %v", cfg.SourceWatchMode(cfg.LogSources[0]), tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEnv_WatchMode(t *testing.T) {
+	cfg := DefaultConfig()
+	err := cfg.ApplyEnv(func(key string) (string, bool) {
+		if key == EnvWatchMode {
+			return "poll", true
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+	if cfg.WatchMode != "poll" {
+		t.Errorf("WatchMode = %v, want poll", cfg.WatchMode)
+	}
+}
+
+func TestApplyEnv_Workers(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{name: "explicit", value: "4", want: 4},
+		{name: "zero uses the number of CPUs", value: "0", want: runtime.NumCPU()},
+		{name: "invalid", value: "many", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			err := cfg.ApplyEnv(func(key string) (string, bool) {
+				if key == EnvWorkers {
+					return tt.value, true
+				}
+				return "", false
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				cfg.Validate()
+			}
+			if !tt.wantErr && cfg.Workers != tt.want {
+				t.Errorf("Workers = %d, want %d", cfg.Workers, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_OutputSinks(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OutputFormat = "structured"
+
+	sinks := cfg.OutputSinks()
+	if len(sinks) != 1 || sinks[0].Name != "stdout" || sinks[0].Type != "stdout" || sinks[0].Format != "structured" {
+		t.Errorf("OutputSinks() = %+v, want a single structured stdout sink", sinks)
+	}
+
+	cfg.Sinks = []models.SinkConfig{
+		{Type: "stdout"},
+		{Name: "errors", Type: "stdout", Format: "json"},
+	}
+	sinks = cfg.OutputSinks()
+	if sinks[0].Name != "stdout" || sinks[0].Format != "structured" {
+		t.Errorf("OutputSinks()[0] = %+v, want name and format defaulted", sinks[0])
+	}
+	if sinks[1].Name != "errors" || sinks[1].Format != "json" {
+		t.Errorf("OutputSinks()[1] = %+v, want explicit name and format kept", sinks[1])
+	}
+	if cfg.Sinks[0].Name != "" {
+		t.Error("OutputSinks() modified the configured sinks")
+	}
+}
+
+func TestConfig_OutputSchema(t *testing.T) {
+	cfg := DefaultConfig()
+	if sinks := cfg.OutputSinks(); sinks[0].Encoder != nil {
+		t.Errorf("OutputSinks()[0].Encoder = %+v, want none without an output schema", sinks[0].Encoder)
+	}
+
+	cfg.OutputSchema = "legacy"
+	if sinks := cfg.OutputSinks(); sinks[0].Encoder == nil || sinks[0].Encoder.Schema != "legacy" {
+		t.Errorf("OutputSinks()[0].Encoder = %+v, want the legacy schema", sinks[0].Encoder)
+	}
+
+	cfg.Sinks = []models.SinkConfig{
+		{Name: "fields", Type: "stdout", Encoder: &models.EncoderConfig{Fields: []string{"cid"}}},
+		{Name: "v1", Type: "stdout", Encoder: &models.EncoderConfig{Schema: "v1"}},
+		{Name: "csv", Type: "stdout", Format: "csv"},
+		{Name: "otlp", Type: "otlp", OTLP: &models.OTLPSinkConfig{URL: "http://collector:4318/v1/logs"}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	sinks := cfg.OutputSinks()
+	if enc := sinks[0].Encoder; enc.Schema != "legacy" || len(enc.Fields) != 1 {
+		t.Errorf("fields sink encoder = %+v, want its fields with the legacy schema", enc)
+	}
+	if cfg.Sinks[0].Encoder.Schema != "" {
+		t.Error("OutputSinks() modified the configured encoder")
+	}
+	if enc := sinks[1].Encoder; enc.Schema != "v1" {
+		t.Errorf("v1 sink encoder = %+v, want its own schema kept", enc)
+	}
+	if sinks[2].Encoder != nil || sinks[3].Encoder != nil {
+		t.Errorf("csv and otlp encoders = %+v, %+v, want none", sinks[2].Encoder, sinks[3].Encoder)
+	}
+
+	cfg.OutputSchema = "v2"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() error = nil, want error for an unknown output schema")
+	}
+}
+
+func TestConfigValidate_Sinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		sinks   []models.SinkConfig
+		wantErr bool
+	}{
+		{name: "default stdout"},
+		{name: "unknown output format", format: "xml", wantErr: true},
+		{
+			name: "several sinks",
+			sinks: []models.SinkConfig{
+				{Name: "all", Type: "stdout"},
+				{Name: "payments", Type: "stdout", Format: "structured",
+					Filter: &models.SinkFilter{Metadata: map[string]string{"namespace": "payments"}}},
+			},
+		},
+		{name: "unknown type", sinks: []models.SinkConfig{{Type: "tape"}}, wantErr: true},
+		{name: "unknown format", sinks: []models.SinkConfig{{Type: "stdout", Format: "xml"}}, wantErr: true},
+		{name: "duplicate names", sinks: []models.SinkConfig{{Type: "stdout"}, {Type: "stdout"}}, wantErr: true},
+		{
+			name:    "invalid filter glob",
+			sinks:   []models.SinkConfig{{Type: "stdout", Filter: &models.SinkFilter{Files: []string{"[x"}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			if tt.format != "" {
+				cfg.OutputFormat = tt.format
+			}
+			cfg.Sinks = tt.sinks
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFromFile_Sinks(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configContent := `{
+		"sinks": [
+			{"name": "console", "type": "stdout", "format": "structured"},
+			{"type": "stdout", "filter": {"patterns": ["json_cid"], "files": ["*.log"]}}
+		]
+	}`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+
+	sinks := cfg.OutputSinks()
+	if len(sinks) != 2 {
+		t.Fatalf("OutputSinks() = %d sinks, want 2", len(sinks))
+	}
+	if sinks[1].Filter == nil || sinks[1].Filter.Patterns[0] != "json_cid" {
+		t.Errorf("second sink filter = %+v, want the json_cid pattern", sinks[1].Filter)
+	}
+}
+
+func TestConfig_OutputSinks_File(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.BufferSize = 250
+	cfg.Sinks = []models.SinkConfig{
+		{Type: "file"},
+		{Name: "archive", Type: "file", File: &models.FileSinkConfig{Path: "/data/cids.json", BufferSize: 10, MaxBackups: 3}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	sinks := cfg.OutputSinks()
+	if file := sinks[0].File; file == nil || file.Path != cfg.OutputPath || file.BufferSize != 250 {
+		t.Errorf("default file options = %+v, want OutputPath and BufferSize", file)
+	}
+	if file := sinks[1].File; file.Path != "/data/cids.json" || file.BufferSize != 10 || file.MaxBackups != 3 {
+		t.Errorf("explicit file options = %+v, want them kept", file)
+	}
+	if cfg.Sinks[0].File != nil {
+		t.Error("OutputSinks() modified the configured sinks")
+	}
+
+	cfg.Sinks = []models.SinkConfig{{Type: "file", File: &models.FileSinkConfig{MaxAge: -time.Hour}}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() error = nil for a negative max age")
+	}
+}
+
+func TestLoadFromFile_WebhookSink(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configContent := `{
+		"sinks": [{
+			"name": "collector",
+			"type": "webhook",
+			"flush_interval": 2000000000,
+			"webhook": {
+				"url": "https://collector.internal/cids",
+				"encoding": "array",
+				"batch_size": 500,
+				"headers": {"X-Tenant": "payments"},
+				"dead_letter_path": "/var/output/webhook-failed.ndjson"
+			}
+		}]
+	}`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+
+	s := cfg.OutputSinks()[0]
+	if s.FlushInterval != 2*time.Second {
+		t.Errorf("FlushInterval = %v, want 2s", s.FlushInterval)
+	}
+	if s.Webhook == nil || s.Webhook.BatchSize != 500 || s.Webhook.Headers["X-Tenant"] != "payments" {
+		t.Errorf("Webhook = %+v, want the configured options", s.Webhook)
+	}
+
+	cfg.Sinks[0].Webhook.URL = "collector.internal"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() error = nil for a URL without scheme")
+	}
+}
+
+func TestLoadFromFile_SinkEncoder(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configContent := `{
+		"output_format": "logfmt",
+		"sinks": [
+			{"name": "console", "type": "stdout"},
+			{
+				"name": "report",
+				"type": "stdout",
+				"format": "csv",
+				"encoder": {
+					"fields": ["timestamp", "cid", "pod"],
+					"rename": {"cid": "correlation_id"},
+					"delimiter": ";"
+				}
+			}
+		]
+	}`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+
+	sinks := cfg.OutputSinks()
+	if sinks[0].Format != "logfmt" {
+		t.Errorf("Format = %s, want the output format logfmt", sinks[0].Format)
+	}
+	e := sinks[1].Encoder
+	if e == nil || len(e.Fields) != 3 || e.Rename["cid"] != "correlation_id" || e.Delimiter != ";" {
+		t.Errorf("Encoder = %+v, want the configured options", e)
+	}
+
+	cfg.Sinks[1].Encoder.Template = "{{.cid}}"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() error = nil for a template on a csv sink")
+	}
+}
@@ -0,0 +1,123 @@
+package decoder
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Kubernetes metadata keys set from log file paths
+const (
+	MetaNamespace    = "namespace"
+	MetaPod          = "pod"
+	MetaPodUID       = "pod_uid"
+	MetaContainer    = "container"
+	MetaRestartCount = "restart_count"
+)
+
+// CRI tags marking whether a line is complete
+const (
+	criTagPartial = "P"
+	criTagFull    = "F"
+)
+
+var (
+	// podLogPattern matches /var/log/pods/<namespace>_<pod>_<uid>/<container>/<restart>.log
+	podLogPattern = regexp.MustCompile(`(?:^|/)([^/_]+)_([^/_]+)_([^/_]+)/([^/]+)/(\d+)\.log(?:\.[^/]*)?$`)
+	// containerLogPattern matches /var/log/containers/<pod>_<namespace>_<container>-<id>.log
+	containerLogPattern = regexp.MustCompile(`(?:^|/)([^/_]+)_([^/_]+)_(.+)-([0-9a-f]{64})\.log$`)
+)
+
+// CRIDecoder decodes logs written by containerd and CRI-O:
+// "<RFC3339Nano time> <stream> <tag> <message>", where tag P marks a partial
+// chunk and F the chunk that completes the line. Chunks are stitched per
+// stream, up to the line limit set with SetLineLimit.
+type CRIDecoder struct {
+	stitcher
+	podMetadata map[string]string
+}
+
+// NewCRIDecoder creates a decoder for a CRI log, taking pod metadata from its path
+func NewCRIDecoder(path string) Decoder {
+	return &CRIDecoder{
+		stitcher:    newStitcher(),
+		podMetadata: PodMetadataFromPath(path),
+	}
+}
+
+// PodMetadataFromPath returns the namespace, pod name, pod UID, container name
+// and restart count encoded in a kubelet log path, or nil if path is not one
+func PodMetadataFromPath(path string) map[string]string {
+	path = filepath.ToSlash(path)
+
+	if m := podLogPattern.FindStringSubmatch(path); m != nil {
+		return map[string]string{
+			MetaNamespace:    m[1],
+			MetaPod:          m[2],
+			MetaPodUID:       m[3],
+			MetaContainer:    m[4],
+			MetaRestartCount: m[5],
+		}
+	}
+
+	if m := containerLogPattern.FindStringSubmatch(path); m != nil {
+		return map[string]string{
+			MetaPod:         m[1],
+			MetaNamespace:   m[2],
+			MetaContainer:   m[3],
+			MetaContainerID: m[4],
+		}
+	}
+
+	return nil
+}
+
+// Decode parses one CRI log line
+func (d *CRIDecoder) Decode(raw string) (Line, bool, error) {
+	fields := strings.SplitN(raw, " ", 4)
+	if len(fields) < 3 {
+		return Line{Message: raw}, true, fmt.Errorf("invalid CRI log line: expected time, stream and tag")
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+	if err != nil {
+		return Line{Message: raw}, true, fmt.Errorf("invalid CRI timestamp '%s': %w", fields[0], err)
+	}
+
+	stream := fields[1]
+	// The tag may carry further flags after a colon; the first one is P or F
+	tag := strings.SplitN(fields[2], ":", 2)[0]
+
+	var message string
+	if len(fields) == 4 {
+		message = fields[3]
+	}
+
+	switch tag {
+	case criTagPartial:
+		d.add(stream, message, timestamp)
+		return Line{}, false, nil
+	case criTagFull:
+	default:
+		return Line{Message: raw}, true, fmt.Errorf("invalid CRI tag '%s'", fields[2])
+	}
+
+	message, timestamp, ok := d.finish(stream, message, timestamp)
+	if !ok {
+		return Line{}, false, nil
+	}
+
+	metadata := make(map[string]string, len(d.podMetadata)+1)
+	for key, value := range d.podMetadata {
+		metadata[key] = value
+	}
+	metadata[MetaStream] = stream
+
+	return Line{
+		Message:   message,
+		Timestamp: timestamp,
+		Metadata:  metadata,
+	}, true, nil
+}
@@ -0,0 +1,206 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testPodPath = "/var/log/pods/payments_checkout-7d9f8b6c5-x2x4z_0b7c6a4e-1f2d-4c3b-9a8e-7f6d5c4b3a21/api/3.log"
+
+func TestCRIDecoder_Decode(t *testing.T) {
+	d := NewCRIDecoder(testPodPath)
+
+	line, ok, err := d.Decode("2024-01-15T10:30:00.123456789Z stdout F request CID=abc done")
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !ok {
+		t.Fatal("Decode() should return a complete line")
+	}
+
+	if line.Message != "request CID=abc done" {
+		t.Errorf("Message = %q, want message without CRI prefix", line.Message)
+	}
+	if want := time.Date(2024, 1, 15, 10, 30, 0, 123456789, time.UTC); !line.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", line.Timestamp, want)
+	}
+
+	want := map[string]string{
+		MetaStream:       "stdout",
+		MetaNamespace:    "payments",
+		MetaPod:          "checkout-7d9f8b6c5-x2x4z",
+		MetaPodUID:       "0b7c6a4e-1f2d-4c3b-9a8e-7f6d5c4b3a21",
+		MetaContainer:    "api",
+		MetaRestartCount: "3",
+	}
+	if !reflect.DeepEqual(line.Metadata, want) {
+		t.Errorf("Metadata = %v, want %v", line.Metadata, want)
+	}
+}
+
+func TestCRIDecoder_PartialLines(t *testing.T) {
+	d := NewCRIDecoder("")
+
+	chunks := []string{
+		"2024-01-15T10:30:00Z stdout P first ",
+		"2024-01-15T10:30:01Z stderr F error line",
+		"2024-01-15T10:30:02Z stdout P second ",
+		"2024-01-15T10:30:03Z stdout F end",
+		"2024-01-15T10:30:04Z stdout F",
+	}
+
+	var lines []Line
+	for _, chunk := range chunks {
+		line, ok, err := d.Decode(chunk)
+		if err != nil {
+			t.Fatalf("Decode(%q) error = %v", chunk, err)
+		}
+		if ok {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 complete lines, got %d", len(lines))
+	}
+	if lines[0].Message != "error line" || lines[0].Metadata[MetaStream] != "stderr" {
+		t.Errorf("lines[0] = %+v, want stderr line", lines[0])
+	}
+	if lines[1].Message != "first second end" {
+		t.Errorf("lines[1].Message = %q, want stitched line", lines[1].Message)
+	}
+	if want := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC); !lines[1].Timestamp.Equal(want) {
+		t.Errorf("lines[1].Timestamp = %v, want time of first chunk %v", lines[1].Timestamp, want)
+	}
+	if lines[2].Message != "" {
+		t.Errorf("lines[2].Message = %q, want empty line", lines[2].Message)
+	}
+}
+
+func TestCRIDecoder_LineLimit(t *testing.T) {
+	chunks := []string{
+		"2024-01-15T10:30:00Z stdout P 0123456789",
+		"2024-01-15T10:30:01Z stdout P 0123456789",
+		"2024-01-15T10:30:02Z stdout F end",
+		"2024-01-15T10:30:03Z stdout F next",
+	}
+
+	tests := []struct {
+		name          string
+		skip          bool
+		want          []string
+		wantTruncated int
+		wantSkipped   int
+	}{
+		{name: "truncate", want: []string{"012345678901", "next"}, wantTruncated: 1},
+		{name: "skip", skip: true, want: []string{"next"}, wantSkipped: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewCRIDecoder("").(*CRIDecoder)
+			d.SetLineLimit(12, tt.skip)
+
+			var got []string
+			for _, chunk := range chunks {
+				line, ok, err := d.Decode(chunk)
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				if ok {
+					got = append(got, line.Message)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+			if truncated, skipped := d.TakeOversized(); truncated != tt.wantTruncated || skipped != tt.wantSkipped {
+				t.Errorf("TakeOversized() = %d, %d, want %d, %d", truncated, skipped, tt.wantTruncated, tt.wantSkipped)
+			}
+			if d.Stitching() {
+				t.Error("Stitching() = true after the line was completed")
+			}
+		})
+	}
+}
+
+func TestCRIDecoder_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{name: "too few fields", raw: "2024-01-15T10:30:00Z stdout"},
+		{name: "bad timestamp", raw: "yesterday stdout F message"},
+		{name: "bad tag", raw: "2024-01-15T10:30:00Z stdout X message"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewCRIDecoder("")
+			line, ok, err := d.Decode(tt.raw)
+			if err == nil {
+				t.Error("expected error")
+			}
+			if !ok || line.Message != tt.raw {
+				t.Errorf("Decode() = %+v, %v; want raw line returned", line, ok)
+			}
+		})
+	}
+}
+
+func TestPodMetadataFromPath(t *testing.T) {
+	containerID := "3f4e5d6c7b8a9f0e1d2c3b4a5f6e7d8c9b0a1f2e3d4c5b6a7f8e9d0c1b2a3f4e"
+
+	tests := []struct {
+		name string
+		path string
+		want map[string]string
+	}{
+		{
+			name: "pod log",
+			path: "/var/log/pods/default_web-0_1234-5678/nginx/0.log",
+			want: map[string]string{
+				MetaNamespace:    "default",
+				MetaPod:          "web-0",
+				MetaPodUID:       "1234-5678",
+				MetaContainer:    "nginx",
+				MetaRestartCount: "0",
+			},
+		},
+		{
+			name: "rotated pod log",
+			path: "/var/log/pods/default_web-0_1234-5678/nginx/2.log.20240115-103000",
+			want: map[string]string{
+				MetaNamespace:    "default",
+				MetaPod:          "web-0",
+				MetaPodUID:       "1234-5678",
+				MetaContainer:    "nginx",
+				MetaRestartCount: "2",
+			},
+		},
+		{
+			name: "container symlink",
+			path: "/var/log/containers/web-0_default_nginx-" + containerID + ".log",
+			want: map[string]string{
+				MetaPod:         "web-0",
+				MetaNamespace:   "default",
+				MetaContainer:   "nginx",
+				MetaContainerID: containerID,
+			},
+		},
+		{
+			name: "plain log",
+			path: "/var/log/app/app.log",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PodMetadataFromPath(tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PodMetadataFromPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
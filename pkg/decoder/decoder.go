@@ -0,0 +1,96 @@
+package decoder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Log source formats
+const (
+	// FormatRaw passes lines through unchanged
+	FormatRaw = "raw"
+	// FormatDocker decodes the Docker json-file log driver format
+	FormatDocker = "docker"
+	// FormatCRI decodes the containerd / CRI-O log format
+	FormatCRI = "cri"
+)
+
+// Metadata keys set by decoders
+const (
+	MetaStream      = "stream"
+	MetaContainerID = "container_id"
+)
+
+// Line is a log line with its container runtime envelope removed
+type Line struct {
+	Message string
+	// Timestamp is the time recorded by the runtime, zero if unknown
+	Timestamp time.Time
+	Metadata  map[string]string
+	// Offset is the byte offset in the log file at which the line starts,
+	// LineNumber its 1-based line number and Source the name of the log
+	// source the file belongs to; all are set by the reader of the file
+	Offset     int64
+	LineNumber int64
+	Source     string
+}
+
+// Decoder turns raw lines read from one file into log lines. Implementations
+// keep per-file state to reassemble lines the runtime split into chunks, so a
+// decoder must not be shared between files.
+type Decoder interface {
+	// Decode consumes one raw line. ok is false while a split line is still
+	// incomplete, or if a stitched line is skipped for exceeding the line
+	// limit of a LineLimiter. On error the raw line is returned as the message.
+	Decode(raw string) (line Line, ok bool, err error)
+}
+
+// Factory creates a decoder for the file at path
+type Factory func(path string) Decoder
+
+var factories = map[string]Factory{
+	"":           newRawDecoder,
+	FormatRaw:    newRawDecoder,
+	FormatDocker: NewDockerDecoder,
+	FormatCRI:    NewCRIDecoder,
+}
+
+// New creates a decoder for a log source format
+func New(format, path string) (Decoder, error) {
+	factory, ok := factories[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown log format '%s': must be one of %s", format, strings.Join(Formats(), ", "))
+	}
+	return factory(path), nil
+}
+
+// Validate reports whether format is a known log source format
+func Validate(format string) error {
+	_, err := New(format, "")
+	return err
+}
+
+// Formats returns the names of the supported formats
+func Formats() []string {
+	var formats []string
+	for format := range factories {
+		if format != "" {
+			formats = append(formats, format)
+		}
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// rawDecoder returns every line as is
+type rawDecoder struct{}
+
+func newRawDecoder(string) Decoder {
+	return rawDecoder{}
+}
+
+func (rawDecoder) Decode(raw string) (Line, bool, error) {
+	return Line{Message: raw}, true, nil
+}
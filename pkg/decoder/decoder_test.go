@@ -0,0 +1,56 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{"", false},
+		{FormatRaw, false},
+		{FormatDocker, false},
+		{FormatCRI, false},
+		{"syslog-ng", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			d, err := New(tt.format, "/var/log/app/app.log")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && d == nil {
+				t.Error("New() returned nil decoder")
+			}
+			if err := Validate(tt.format); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	want := []string{FormatCRI, FormatDocker, FormatRaw}
+	if got := Formats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Formats() = %v, want %v", got, want)
+	}
+}
+
+func TestRawDecoder(t *testing.T) {
+	d, _ := New(FormatRaw, "")
+
+	line, ok, err := d.Decode(`{"log":"left as is"}`)
+	if err != nil || !ok {
+		t.Fatalf("Decode() ok = %v, err = %v", ok, err)
+	}
+	if line.Message != `{"log":"left as is"}` {
+		t.Errorf("Message = %v, want raw line", line.Message)
+	}
+	if !line.Timestamp.IsZero() {
+		t.Error("Timestamp should be zero for raw lines")
+	}
+}
@@ -0,0 +1,86 @@
+package decoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// containerIDPattern matches a full Docker container ID
+var containerIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// dockerEntry is one line written by the json-file log driver
+type dockerEntry struct {
+	Log    string    `json:"log"`
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
+}
+
+// DockerDecoder decodes /var/lib/docker/containers/<id>/<id>-json.log files.
+// Docker splits lines longer than 16KB into several entries, only the last of
+// which ends in a newline; chunks are reassembled per stream, up to the line
+// limit set with SetLineLimit.
+type DockerDecoder struct {
+	stitcher
+	containerID string
+}
+
+// NewDockerDecoder creates a decoder for a json-file log, taking the container ID from its path
+func NewDockerDecoder(path string) Decoder {
+	return &DockerDecoder{
+		stitcher:    newStitcher(),
+		containerID: containerIDFromPath(path),
+	}
+}
+
+// containerIDFromPath returns the container ID from the log file name or its directory
+func containerIDFromPath(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	if id := strings.TrimSuffix(filepath.Base(path), "-json.log"); containerIDPattern.MatchString(id) {
+		return id
+	}
+	if id := filepath.Base(filepath.Dir(path)); containerIDPattern.MatchString(id) {
+		return id
+	}
+	return ""
+}
+
+// Decode unwraps the log field of a json-file entry
+func (d *DockerDecoder) Decode(raw string) (Line, bool, error) {
+	var entry dockerEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return Line{Message: raw}, true, fmt.Errorf("invalid docker json-file entry: %w", err)
+	}
+
+	stream := entry.Stream
+	if !strings.HasSuffix(entry.Log, "\n") {
+		d.add(stream, entry.Log, entry.Time)
+		return Line{}, false, nil
+	}
+
+	chunk := strings.TrimSuffix(strings.TrimSuffix(entry.Log, "\n"), "\r")
+	message, timestamp, ok := d.finish(stream, chunk, entry.Time)
+	if !ok {
+		return Line{}, false, nil
+	}
+
+	metadata := map[string]string{}
+	if stream != "" {
+		metadata[MetaStream] = stream
+	}
+	if d.containerID != "" {
+		metadata[MetaContainerID] = d.containerID
+	}
+
+	return Line{
+		Message:   message,
+		Timestamp: timestamp,
+		Metadata:  metadata,
+	}, true, nil
+}
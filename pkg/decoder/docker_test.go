@@ -0,0 +1,155 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testContainerID = "3f4e5d6c7b8a9f0e1d2c3b4a5f6e7d8c9b0a1f2e3d4c5b6a7f8e9d0c1b2a3f4e"
+
+func TestDockerDecoder_Decode(t *testing.T) {
+	d := NewDockerDecoder("/var/lib/docker/containers/" + testContainerID + "/" + testContainerID + "-json.log")
+
+	line, ok, err := d.Decode(`{"log":"request CID=\"abc\" done\n","stream":"stderr","time":"2024-03-01T12:30:45.123456789Z"}`)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !ok {
+		t.Fatal("Decode() should return a complete line")
+	}
+
+	if line.Message != `request CID="abc" done` {
+		t.Errorf("Message = %q, want unescaped log field without newline", line.Message)
+	}
+	want := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	if !line.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", line.Timestamp, want)
+	}
+	if line.Metadata[MetaStream] != "stderr" {
+		t.Errorf("stream = %v, want stderr", line.Metadata[MetaStream])
+	}
+	if line.Metadata[MetaContainerID] != testContainerID {
+		t.Errorf("container_id = %v, want %v", line.Metadata[MetaContainerID], testContainerID)
+	}
+}
+
+func TestDockerDecoder_PartialLines(t *testing.T) {
+	d := NewDockerDecoder("")
+
+	chunks := []string{
+		`{"log":"first half ","stream":"stdout","time":"2024-03-01T12:00:00Z"}`,
+		`{"log":"err line\n","stream":"stderr","time":"2024-03-01T12:00:01Z"}`,
+		`{"log":"second half","stream":"stdout","time":"2024-03-01T12:00:02Z"}`,
+		`{"log":" end\n","stream":"stdout","time":"2024-03-01T12:00:03Z"}`,
+	}
+
+	var lines []Line
+	for _, chunk := range chunks {
+		line, ok, err := d.Decode(chunk)
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		if ok {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 complete lines, got %d", len(lines))
+	}
+
+	// Chunks of another stream do not interrupt reassembly
+	if lines[0].Message != "err line" || lines[0].Metadata[MetaStream] != "stderr" {
+		t.Errorf("lines[0] = %+v, want stderr line", lines[0])
+	}
+	if lines[1].Message != "first half second half end" {
+		t.Errorf("lines[1].Message = %q, want reassembled line", lines[1].Message)
+	}
+	if want := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC); !lines[1].Timestamp.Equal(want) {
+		t.Errorf("lines[1].Timestamp = %v, want time of first chunk %v", lines[1].Timestamp, want)
+	}
+	if _, ok := lines[1].Metadata[MetaContainerID]; ok {
+		t.Error("container_id should be omitted when the path has none")
+	}
+}
+
+func TestDockerDecoder_LineLimit(t *testing.T) {
+	chunks := []string{
+		`{"log":"0123456789","stream":"stdout","time":"2024-03-01T12:00:00Z"}`,
+		`{"log":"0123456789","stream":"stdout","time":"2024-03-01T12:00:01Z"}`,
+		`{"log":"end\n","stream":"stdout","time":"2024-03-01T12:00:02Z"}`,
+		`{"log":"next\n","stream":"stdout","time":"2024-03-01T12:00:03Z"}`,
+	}
+
+	tests := []struct {
+		name          string
+		skip          bool
+		want          []string
+		wantTruncated int
+		wantSkipped   int
+	}{
+		{name: "truncate", want: []string{"012345678901", "next"}, wantTruncated: 1},
+		{name: "skip", skip: true, want: []string{"next"}, wantSkipped: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDockerDecoder("").(*DockerDecoder)
+			d.SetLineLimit(12, tt.skip)
+
+			var got []string
+			for _, chunk := range chunks {
+				line, ok, err := d.Decode(chunk)
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				if ok {
+					got = append(got, line.Message)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+			if truncated, skipped := d.TakeOversized(); truncated != tt.wantTruncated || skipped != tt.wantSkipped {
+				t.Errorf("TakeOversized() = %d, %d, want %d, %d", truncated, skipped, tt.wantTruncated, tt.wantSkipped)
+			}
+			if d.Stitching() {
+				t.Error("Stitching() = true after the line was completed")
+			}
+		})
+	}
+}
+
+func TestDockerDecoder_InvalidJSON(t *testing.T) {
+	d := NewDockerDecoder("")
+
+	line, ok, err := d.Decode("plain text CID:abc")
+	if err == nil {
+		t.Error("expected error for non-JSON line")
+	}
+	if !ok || line.Message != "plain text CID:abc" {
+		t.Errorf("Decode() = %+v, %v; want raw line returned", line, ok)
+	}
+}
+
+func TestContainerIDFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/var/lib/docker/containers/" + testContainerID + "/" + testContainerID + "-json.log", testContainerID},
+		{"/var/lib/docker/containers/" + testContainerID + "/" + testContainerID + "-json.log.1", testContainerID},
+		{"/mnt/docker/" + testContainerID + "-json.log", testContainerID},
+		{"/var/log/app/app.log", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := containerIDFromPath(tt.path); got != tt.want {
+				t.Errorf("containerIDFromPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package decoder
+
+import (
+	"strings"
+	"time"
+)
+
+// LineLimiter is implemented by decoders that stitch lines the runtime split
+// into chunks. SetLineLimit bounds stitched lines like the follower bounds raw
+// lines: a line longer than maxSize bytes is truncated, or dropped if skip is
+// set, and the rest of it is never buffered. TakeOversized returns the number
+// of lines truncated and skipped since the previous call. Stitching reports
+// whether a split line is still incomplete.
+type LineLimiter interface {
+	SetLineLimit(maxSize int, skip bool)
+	TakeOversized() (truncated, skipped int)
+	Stitching() bool
+}
+
+// partialLine is a line being reassembled from chunks of one stream
+type partialLine struct {
+	message strings.Builder
+	time    time.Time
+	// oversized is set once the line exceeded the maximum size
+	oversized bool
+}
+
+// stitcher reassembles split lines per stream, keeping at most maxSize bytes
+// of each. A maxSize of zero or less keeps lines whole.
+type stitcher struct {
+	partial map[string]*partialLine
+	maxSize int
+	skip    bool
+
+	truncated int
+	skipped   int
+}
+
+func newStitcher() stitcher {
+	return stitcher{partial: make(map[string]*partialLine)}
+}
+
+// SetLineLimit sets the maximum size of a stitched line and whether longer
+// lines are skipped rather than truncated
+func (s *stitcher) SetLineLimit(maxSize int, skip bool) {
+	s.maxSize, s.skip = maxSize, skip
+}
+
+// TakeOversized returns the number of stitched lines truncated and skipped
+// since the previous call
+func (s *stitcher) TakeOversized() (truncated, skipped int) {
+	truncated, skipped = s.truncated, s.skipped
+	s.truncated, s.skipped = 0, 0
+	return truncated, skipped
+}
+
+// Stitching reports whether a split line is incomplete on any stream
+func (s *stitcher) Stitching() bool {
+	return len(s.partial) > 0
+}
+
+// add appends a chunk to the line pending on stream; t is kept from the first
+// chunk
+func (s *stitcher) add(stream, chunk string, t time.Time) {
+	p, ok := s.partial[stream]
+	if !ok {
+		p = &partialLine{time: t}
+		s.partial[stream] = p
+	}
+	s.append(p, chunk)
+}
+
+// finish completes the line pending on stream with its last chunk, returning
+// the whole line and the time of its first chunk. A line that was not split
+// is returned as is. ok is false if the line is skipped as oversized.
+func (s *stitcher) finish(stream, chunk string, t time.Time) (message string, timestamp time.Time, ok bool) {
+	p, split := s.partial[stream]
+	if !split {
+		return chunk, t, true
+	}
+	delete(s.partial, stream)
+
+	s.append(p, chunk)
+	if p.oversized {
+		if s.skip {
+			s.skipped++
+			return "", time.Time{}, false
+		}
+		s.truncated++
+	}
+	// The line started with the first chunk
+	return p.message.String(), p.time, true
+}
+
+// append adds a chunk to p, keeping at most maxSize bytes
+func (s *stitcher) append(p *partialLine, chunk string) {
+	if p.oversized {
+		return
+	}
+	if room := s.maxSize - p.message.Len(); s.maxSize > 0 && len(chunk) > room {
+		p.message.WriteString(chunk[:room])
+		p.oversized = true
+		return
+	}
+	p.message.WriteString(chunk)
+}
@@ -0,0 +1,38 @@
+package decoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStitcher_BoundsPendingLine(t *testing.T) {
+	s := newStitcher()
+	s.SetLineLimit(64, false)
+
+	// A stream that never completes its line keeps at most maxSize bytes
+	for i := 0; i < 1000; i++ {
+		s.add("stdout", strings.Repeat("x", 100), time.Time{})
+	}
+	if !s.Stitching() {
+		t.Fatal("Stitching() = false with a pending line")
+	}
+	if n := s.partial["stdout"].message.Len(); n != 64 {
+		t.Errorf("pending line holds %d bytes, want 64", n)
+	}
+
+	message, _, ok := s.finish("stdout", "end", time.Time{})
+	if !ok || message != strings.Repeat("x", 64) {
+		t.Errorf("finish() = %d bytes, %v, want the first 64 bytes", len(message), ok)
+	}
+
+	// Lines that were not split, and limits of zero, are left alone
+	s.SetLineLimit(0, false)
+	s.add("stderr", strings.Repeat("y", 100), time.Time{})
+	if message, _, _ := s.finish("stderr", "z", time.Time{}); len(message) != 101 {
+		t.Errorf("finish() without a limit = %d bytes, want 101", len(message))
+	}
+	if message, _, _ := s.finish("stderr", "whole", time.Time{}); message != "whole" {
+		t.Errorf("finish() of an unsplit line = %q, want whole", message)
+	}
+}
@@ -1,46 +1,179 @@
 package extractor
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"cidtracker/pkg/models"
+	"cidtracker/pkg/timestamp"
 	"cidtracker/pkg/validator"
 )
 
+// uuidRegex matches the canonical textual form of a UUID
+const uuidRegex = `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`
+
+// uuidPattern matches any UUID-shaped token
+var uuidPattern = regexp.MustCompile(uuidRegex)
+
+// Match is a single CID found in a log line by a named pattern
+type Match struct {
+	Pattern string
+	Value   string
+	Start   int
+	End     int
+}
+
+// CIDExtractor evaluates an ordered list of CID patterns against log lines
 type CIDExtractor struct {
-	cidPattern *regexp.Regexp
+	patterns      []models.CIDPattern
 	uuidValidator *validator.UUIDValidator
+	timestamps    *timestamp.Parser
 }
 
+// DefaultPatterns returns the patterns used unless others are configured. The
+// standard_cid pattern matches CID= or CID: followed by a UUID, also written
+// as CID[<uuid>] or [CID <uuid>], and json_cid matches a JSON "cid" field.
+func DefaultPatterns() []models.CIDPattern {
+	return []models.CIDPattern{
+		{
+			Name:        "standard_cid",
+			RegexString: `(?i)(?:\bCID\s*[=:]\s*|\bCID\[\s*|\[CID\s+)(` + uuidRegex + `)`,
+			UUIDGroup:   1,
+			Enabled:     true,
+		},
+		{
+			Name:        "json_cid",
+			RegexString: `(?i)"cid"\s*:\s*"(` + uuidRegex + `)"`,
+			UUIDGroup:   1,
+			Enabled:     true,
+		},
+	}
+}
+
+// NewCIDExtractor creates an extractor using the default patterns and U5
+// validation. Like the extractor always has, it also matches CID[<value>] for
+// any value, leaving it to the validator to reject values that are no UUID.
 func NewCIDExtractor() *CIDExtractor {
+	patterns := append(DefaultPatterns(), models.CIDPattern{
+		Name:        "bracket_cid",
+		RegexString: `\bCID\[([^\]\s]+)\]`,
+		UUIDGroup:   1,
+		Enabled:     true,
+	})
+	e, err := NewPatternExtractor(patterns, validator.NewUUIDValidator(true))
+	if err != nil {
+		panic(fmt.Sprintf("invalid default CID pattern: %v", err))
+	}
+	return e
+}
+
+// NewPatternExtractor creates an extractor for the enabled patterns, compiling any
+// that have not been compiled yet
+func NewPatternExtractor(patterns []models.CIDPattern, uuidValidator *validator.UUIDValidator) (*CIDExtractor, error) {
+	var enabled []models.CIDPattern
+	for _, pattern := range patterns {
+		if !pattern.Enabled {
+			continue
+		}
+
+		if pattern.Regex == nil {
+			regex, err := regexp.Compile(pattern.RegexString)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex pattern '%s': %w", pattern.Name, err)
+			}
+			pattern.Regex = regex
+		}
+
+		if pattern.UUIDGroup < 0 || pattern.UUIDGroup > pattern.Regex.NumSubexp() {
+			return nil, fmt.Errorf("pattern '%s' has no capture group %d", pattern.Name, pattern.UUIDGroup)
+		}
+
+		enabled = append(enabled, pattern)
+	}
+
 	return &CIDExtractor{
-		cidPattern:    regexp.MustCompile(`CID\[(\S+)\]`),
-		uuidValidator: validator.NewUUIDValidator(true),
+		patterns:      enabled,
+		uuidValidator: uuidValidator,
+		timestamps:    timestamp.Default(),
+	}, nil
+}
+
+// Patterns returns the enabled patterns in evaluation order
+func (e *CIDExtractor) Patterns() []models.CIDPattern {
+	return e.patterns
+}
+
+// FindMatches evaluates every pattern in order and returns the matches sorted by
+// position. When patterns overlap, the earlier pattern wins.
+func (e *CIDExtractor) FindMatches(logLine string) []Match {
+	var matches []Match
+	for _, pattern := range e.patterns {
+		for _, loc := range pattern.Regex.FindAllStringSubmatchIndex(logLine, -1) {
+			start, end := loc[2*pattern.UUIDGroup], loc[2*pattern.UUIDGroup+1]
+			if start < 0 || overlaps(matches, start, end) {
+				continue
+			}
+
+			matches = append(matches, Match{
+				Pattern: pattern.Name,
+				Value:   logLine[start:end],
+				Start:   start,
+				End:     end,
+			})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+
+	return matches
+}
+
+// overlaps reports whether [start, end) intersects an existing match
+func overlaps(matches []Match, start, end int) bool {
+	for _, m := range matches {
+		if start < m.End && m.Start < end {
+			return true
+		}
 	}
+	return false
 }
 
+// ExtractCIDs returns an entry per CID in the line, timestamped with the time
+// the default layouts find in the line or, failing that, the current time
 func (e *CIDExtractor) ExtractCIDs(logLine string) []models.CIDEntry {
-	matches := e.cidPattern.FindAllStringSubmatch(logLine, -1)
+	return e.ExtractCIDsWithParser(logLine, e.timestamps)
+}
+
+// ExtractCIDsWithParser is ExtractCIDs using a log source's timestamp parser.
+// The line is only parsed when it contains a CID; a nil parser always uses
+// the current time.
+func (e *CIDExtractor) ExtractCIDsWithParser(logLine string, parser *timestamp.Parser) []models.CIDEntry {
+	matches := e.FindMatches(logLine)
 	if matches == nil {
 		return nil
 	}
 
-	var entries []models.CIDEntry
-	for _, match := range matches {
-		if len(match) < 2 {
-			continue
+	ts, parsed := time.Now(), false
+	if parser != nil {
+		if t, ok := parser.Parse(logLine); ok {
+			ts, parsed = t, true
 		}
+	}
 
-		cidValue := match[1]
-		uuids := e.extractUUIDs(cidValue)
-
+	var entries []models.CIDEntry
+	for _, match := range matches {
 		entry := models.CIDEntry{
-			CID: cidValue,
-			Timestamp: time.Now(),
-			LogLine: strings.TrimSpace(logLine),
-			UUIDs: uuids,
+			CID:             match.Value,
+			Pattern:         match.Pattern,
+			Timestamp:       ts,
+			TimestampParsed: parsed,
+			LogLine:         strings.TrimSpace(logLine),
+			UUIDs:           e.extractUUIDs(match.Value),
 		}
 
 		entries = append(entries, entry)
@@ -52,15 +185,15 @@ func (e *CIDExtractor) ExtractCIDs(logLine string) []models.CIDEntry {
 func (e *CIDExtractor) extractUUIDs(cidValue string) []models.UUID {
 	var uuids []models.UUID
 
-	// Extract U5 UUIDs from CID value
-	u5Pattern := regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-5[0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}`)
-	matches := u5Pattern.FindAllString(cidValue, -1)
+	// Extract UUIDs accepted by the validator from the CID value
+	matches := uuidPattern.FindAllString(cidValue, -1)
 
 	for _, match := range matches {
-		if e.uuidValidator.IsValidU5UUID(match) {
+		result := e.uuidValidator.ValidateUUID(match)
+		if result.Valid {
 			uuid := models.UUID{
-				Value: match,
-				Version: 5,
+				Value:       match,
+				Version:     result.Version,
 				ExtractedAt: time.Now(),
 			}
 			uuids = append(uuids, uuid)
@@ -75,9 +208,9 @@ func (e *CIDExtractor) CorrelateEntries(entries []models.CIDEntry) []models.Corr
 
 	for _, entry := range entries {
 		corr := models.CorrelatedEntry{
-			CIDEntry: entry,
+			CIDEntry:      entry,
 			CorrelationID: e.generateCorrelationID(entry),
-			ProcessedAt: time.Now(),
+			ProcessedAt:   time.Now(),
 		}
 		correlated = append(correlated, corr)
 	}
@@ -88,4 +221,4 @@ func (e *CIDExtractor) CorrelateEntries(entries []models.CIDEntry) []models.Corr
 func (e *CIDExtractor) generateCorrelationID(entry models.CIDEntry) string {
 	// Simple correlation ID based on CID and timestamp
 	return entry.CID + "_" + entry.Timestamp.Format("20060102150405")
-}
\ No newline at end of file
+}
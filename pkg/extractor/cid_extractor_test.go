@@ -2,8 +2,11 @@ package extractor
 
 import (
 	"testing"
+	"time"
 
 	"cidtracker/pkg/models"
+	"cidtracker/pkg/timestamp"
+	"cidtracker/pkg/validator"
 )
 
 func TestNewCIDExtractor(t *testing.T) {
@@ -11,14 +14,51 @@ func TestNewCIDExtractor(t *testing.T) {
 	if e == nil {
 		t.Fatal("expected non-nil extractor")
 	}
-	if e.cidPattern == nil {
-		t.Error("cidPattern should not be nil")
+	if len(e.patterns) == 0 {
+		t.Error("patterns should not be empty")
 	}
 	if e.uuidValidator == nil {
 		t.Error("uuidValidator should not be nil")
 	}
 }
 
+func TestDefaultPatterns(t *testing.T) {
+	e, err := NewPatternExtractor(DefaultPatterns(), validator.NewUUIDValidator(true))
+	if err != nil {
+		t.Fatalf("NewPatternExtractor() error = %v", err)
+	}
+
+	uuid := "550e8400-e29b-51d4-a716-446655440000"
+	tests := []struct {
+		line        string
+		wantPattern string
+	}{
+		{"CID=" + uuid, "standard_cid"},
+		{"INFO CID[" + uuid + "] done", "standard_cid"},
+		{"INFO [CID " + uuid + "] done", "standard_cid"},
+		{`{"cid":"` + uuid + `"}`, "json_cid"},
+		// Only UUIDs are captured, not any bracketed value
+		{"INFO CID[user-42] done", ""},
+		{"INFO [CID session-" + uuid + "] done", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			matches := e.FindMatches(tt.line)
+			var got string
+			if len(matches) > 0 {
+				got = matches[0].Pattern
+				if matches[0].Value != uuid {
+					t.Errorf("Value = %q, want %q", matches[0].Value, uuid)
+				}
+			}
+			if got != tt.wantPattern {
+				t.Errorf("pattern = %q, want %q", got, tt.wantPattern)
+			}
+		})
+	}
+}
+
 func TestExtractCIDs(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -299,4 +339,162 @@ func TestExtractCIDs_SpecialCharacters(t *testing.T) {
 	}
 }
 
-var _ = models.CIDEntry{}
+func TestNewPatternExtractor(t *testing.T) {
+	patterns := []models.CIDPattern{
+		{Name: "first", RegexString: `A=(\w+)`, UUIDGroup: 1, Enabled: true},
+		{Name: "disabled", RegexString: `B=(\w+)`, UUIDGroup: 1, Enabled: false},
+		{Name: "whole", RegexString: `C-\d+`, UUIDGroup: 0, Enabled: true},
+	}
+
+	e, err := NewPatternExtractor(patterns, validator.NewUUIDValidator(false))
+	if err != nil {
+		t.Fatalf("NewPatternExtractor() error = %v", err)
+	}
+
+	if len(e.Patterns()) != 2 {
+		t.Fatalf("Patterns() length = %d, want 2", len(e.Patterns()))
+	}
+
+	matches := e.FindMatches("C-42 B=skip A=first")
+	if len(matches) != 2 {
+		t.Fatalf("FindMatches() returned %d matches, want 2", len(matches))
+	}
+
+	// Matches are ordered by position in the line
+	if matches[0].Pattern != "whole" || matches[0].Value != "C-42" {
+		t.Errorf("matches[0] = %+v, want whole/C-42", matches[0])
+	}
+	if matches[1].Pattern != "first" || matches[1].Value != "first" {
+		t.Errorf("matches[1] = %+v, want first/first", matches[1])
+	}
+}
+
+func TestNewPatternExtractor_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern models.CIDPattern
+	}{
+		{
+			name:    "invalid regex",
+			pattern: models.CIDPattern{Name: "bad", RegexString: "[unclosed", Enabled: true},
+		},
+		{
+			name:    "group out of range",
+			pattern: models.CIDPattern{Name: "bad", RegexString: `CID:\w+`, UUIDGroup: 1, Enabled: true},
+		},
+		{
+			name:    "negative group",
+			pattern: models.CIDPattern{Name: "bad", RegexString: `CID:(\w+)`, UUIDGroup: -1, Enabled: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPatternExtractor([]models.CIDPattern{tt.pattern}, validator.NewUUIDValidator(true)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestFindMatches_EarlierPatternWinsOverlap(t *testing.T) {
+	patterns := []models.CIDPattern{
+		{Name: "specific", RegexString: `CID=(\w+)`, UUIDGroup: 1, Enabled: true},
+		{Name: "generic", RegexString: `=(\w+)`, UUIDGroup: 1, Enabled: true},
+	}
+
+	e, err := NewPatternExtractor(patterns, validator.NewUUIDValidator(false))
+	if err != nil {
+		t.Fatalf("NewPatternExtractor() error = %v", err)
+	}
+
+	matches := e.FindMatches("CID=abc other=def")
+	if len(matches) != 2 {
+		t.Fatalf("FindMatches() returned %d matches, want 2", len(matches))
+	}
+	if matches[0].Pattern != "specific" || matches[0].Value != "abc" {
+		t.Errorf("matches[0] = %+v, want specific/abc", matches[0])
+	}
+	if matches[1].Pattern != "generic" || matches[1].Value != "def" {
+		t.Errorf("matches[1] = %+v, want generic/def", matches[1])
+	}
+}
+
+func TestExtractCIDs_RecordsPatternName(t *testing.T) {
+	e := NewCIDExtractor()
+
+	entries := e.ExtractCIDs(`CID=550e8400-e29b-51d4-a716-446655440000 {"cid":"660e8400-e29b-51d4-a716-446655440000"}`)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	if entries[0].Pattern != "standard_cid" {
+		t.Errorf("entries[0].Pattern = %v, want standard_cid", entries[0].Pattern)
+	}
+	if entries[1].Pattern != "json_cid" {
+		t.Errorf("entries[1].Pattern = %v, want json_cid", entries[1].Pattern)
+	}
+}
+
+func TestExtractUUIDs_WithoutU5Enforcement(t *testing.T) {
+	e, err := NewPatternExtractor(nil, validator.NewUUIDValidator(false))
+	if err != nil {
+		t.Fatalf("NewPatternExtractor() error = %v", err)
+	}
+
+	uuids := e.extractUUIDs("550e8400-e29b-41d4-a716-446655440000")
+	if len(uuids) != 1 {
+		t.Fatalf("extractUUIDs() returned %d UUIDs, want 1", len(uuids))
+	}
+	if uuids[0].Version != 4 {
+		t.Errorf("UUID version = %d, want 4", uuids[0].Version)
+	}
+}
+
+func TestExtractCIDs_Timestamp(t *testing.T) {
+	e := NewCIDExtractor()
+
+	parsed := e.ExtractCIDs("2024-03-05 10:11:12.345 INFO [api] CID:550e8400-e29b-51d4-a716-446655440000 login")
+	if len(parsed) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(parsed))
+	}
+	if want := time.Date(2024, 3, 5, 10, 11, 12, 345000000, time.UTC); !parsed[0].Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", parsed[0].Timestamp, want)
+	}
+	if !parsed[0].TimestampParsed {
+		t.Error("TimestampParsed = false, want true")
+	}
+
+	before := time.Now()
+	inferred := e.ExtractCIDs("INFO CID:550e8400-e29b-51d4-a716-446655440000 login")
+	if len(inferred) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(inferred))
+	}
+	if inferred[0].TimestampParsed {
+		t.Error("TimestampParsed = true, want false")
+	}
+	if inferred[0].Timestamp.Before(before) {
+		t.Errorf("Timestamp = %v, want ingest time after %v", inferred[0].Timestamp, before)
+	}
+}
+
+func TestExtractCIDsWithParser(t *testing.T) {
+	e := NewCIDExtractor()
+	parser, err := timestamp.NewParser([]string{timestamp.LayoutUnixMilli}, "")
+	if err != nil {
+		t.Fatalf("NewParser() error = %v", err)
+	}
+
+	entries := e.ExtractCIDsWithParser("1709633472250 CID:550e8400-e29b-51d4-a716-446655440000", parser)
+	if len(entries) != 1 || !entries[0].TimestampParsed {
+		t.Fatalf("entries = %+v, want one entry with a parsed timestamp", entries)
+	}
+	if want := time.UnixMilli(1709633472250); !entries[0].Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", entries[0].Timestamp, want)
+	}
+
+	entries = e.ExtractCIDsWithParser("2024-03-05 10:11:12.345 CID:550e8400-e29b-51d4-a716-446655440000", nil)
+	if len(entries) != 1 || entries[0].TimestampParsed {
+		t.Errorf("entries = %+v, want one entry with the ingest time", entries)
+	}
+}
@@ -0,0 +1,192 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, used for duration histograms
+var DefaultBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// Label is a single metric label
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a labelled metric value
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+	mu      sync.Mutex
+}
+
+// HistogramSnapshot is a point-in-time copy of a histogram
+type HistogramSnapshot struct {
+	Buckets []float64
+	Counts  []uint64
+	Sum     float64
+	Count   uint64
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds
+func NewHistogram(buckets []float64) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &Histogram{
+		buckets: sorted,
+		counts:  make([]uint64, len(sorted)),
+	}
+}
+
+// Observe records a single value
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Snapshot returns the cumulative bucket counts, sum and total count
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return HistogramSnapshot{
+		Buckets: append([]float64(nil), h.buckets...),
+		Counts:  append([]uint64(nil), h.counts...),
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+}
+
+// TextWriter writes metric families in the Prometheus text exposition format
+type TextWriter struct {
+	w   io.Writer
+	err error
+}
+
+// NewTextWriter creates a writer that emits to w
+func NewTextWriter(w io.Writer) *TextWriter {
+	return &TextWriter{w: w}
+}
+
+// Counter writes a single unlabelled counter
+func (tw *TextWriter) Counter(name, help string, value float64) {
+	tw.header(name, help, "counter")
+	tw.sample(name, nil, value)
+}
+
+// CounterVec writes a labelled counter family
+func (tw *TextWriter) CounterVec(name, help string, samples []Sample) {
+	tw.header(name, help, "counter")
+	for _, s := range samples {
+		tw.sample(name, s.Labels, s.Value)
+	}
+}
+
+// Gauge writes a single unlabelled gauge
+func (tw *TextWriter) Gauge(name, help string, value float64) {
+	tw.header(name, help, "gauge")
+	tw.sample(name, nil, value)
+}
+
+// GaugeVec writes a labelled gauge family
+func (tw *TextWriter) GaugeVec(name, help string, samples []Sample) {
+	tw.header(name, help, "gauge")
+	for _, s := range samples {
+		tw.sample(name, s.Labels, s.Value)
+	}
+}
+
+// Histogram writes a histogram family from a snapshot
+func (tw *TextWriter) Histogram(name, help string, snap HistogramSnapshot) {
+	tw.header(name, help, "histogram")
+	for i, bound := range snap.Buckets {
+		tw.sample(name+"_bucket", []Label{{Name: "le", Value: formatFloat(bound)}}, float64(snap.Counts[i]))
+	}
+	tw.sample(name+"_bucket", []Label{{Name: "le", Value: "+Inf"}}, float64(snap.Count))
+	tw.sample(name+"_sum", nil, snap.Sum)
+	tw.sample(name+"_count", nil, float64(snap.Count))
+}
+
+// Err returns the first write error encountered
+func (tw *TextWriter) Err() error {
+	return tw.err
+}
+
+func (tw *TextWriter) header(name, help, kind string) {
+	tw.printf("# HELP %s %s\n", name, escapeHelp(help))
+	tw.printf("# TYPE %s %s\n", name, kind)
+}
+
+func (tw *TextWriter) sample(name string, labels []Label, value float64) {
+	if len(labels) == 0 {
+		tw.printf("%s %s\n", name, formatFloat(value))
+		return
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", l.Name, escapeLabelValue(l.Value)))
+	}
+	tw.printf("%s{%s} %s\n", name, strings.Join(pairs, ","), formatFloat(value))
+}
+
+func (tw *TextWriter) printf(format string, args ...interface{}) {
+	if tw.err != nil {
+		return
+	}
+	_, tw.err = fmt.Fprintf(tw.w, format, args...)
+}
+
+// formatFloat renders a value the way Prometheus expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	case v == math.Trunc(v) && math.Abs(v) < 1e15:
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
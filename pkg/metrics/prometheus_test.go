@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHistogram_Observe(t *testing.T) {
+	h := NewHistogram([]float64{0.1, 0.01, 1})
+
+	h.Observe(0.005)
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	snap := h.Snapshot()
+
+	wantBuckets := []float64{0.01, 0.1, 1}
+	wantCounts := []uint64{1, 2, 3}
+
+	for i := range wantBuckets {
+		if snap.Buckets[i] != wantBuckets[i] {
+			t.Errorf("Buckets[%d] = %v, want %v", i, snap.Buckets[i], wantBuckets[i])
+		}
+		if snap.Counts[i] != wantCounts[i] {
+			t.Errorf("Counts[%d] = %d, want %d", i, snap.Counts[i], wantCounts[i])
+		}
+	}
+
+	if snap.Count != 4 {
+		t.Errorf("Count = %d, want 4", snap.Count)
+	}
+
+	if math.Abs(snap.Sum-5.555) > 1e-9 {
+		t.Errorf("Sum = %v, want 5.555", snap.Sum)
+	}
+}
+
+func TestHistogram_ConcurrentObserve(t *testing.T) {
+	h := NewHistogram(DefaultBuckets)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Observe(0.002)
+		}()
+	}
+	wg.Wait()
+
+	if snap := h.Snapshot(); snap.Count != 100 {
+		t.Errorf("Count = %d, want 100", snap.Count)
+	}
+}
+
+func TestTextWriter_Counter(t *testing.T) {
+	var buf bytes.Buffer
+	tw := NewTextWriter(&buf)
+
+	tw.Counter("test_total", "A test counter", 42)
+
+	want := "# HELP test_total A test counter\n# TYPE test_total counter\ntest_total 42\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestTextWriter_GaugeVec(t *testing.T) {
+	var buf bytes.Buffer
+	tw := NewTextWriter(&buf)
+
+	tw.GaugeVec("file_size_bytes", "File sizes", []Sample{
+		{Labels: []Label{{Name: "file", Value: "/var/log/a.log"}}, Value: 100},
+		{Labels: []Label{{Name: "file", Value: `C:\logs\"b".log`}}, Value: 200},
+	})
+
+	output := buf.String()
+
+	if !strings.Contains(output, "# TYPE file_size_bytes gauge\n") {
+		t.Errorf("missing TYPE line in %q", output)
+	}
+	if !strings.Contains(output, `file_size_bytes{file="/var/log/a.log"} 100`) {
+		t.Errorf("missing first sample in %q", output)
+	}
+	if !strings.Contains(output, `file_size_bytes{file="C:\\logs\\\"b\".log"} 200`) {
+		t.Errorf("label value not escaped in %q", output)
+	}
+}
+
+func TestTextWriter_Histogram(t *testing.T) {
+	var buf bytes.Buffer
+	tw := NewTextWriter(&buf)
+
+	tw.Histogram("duration_seconds", "Durations", HistogramSnapshot{
+		Buckets: []float64{0.001, 0.01},
+		Counts:  []uint64{3, 5},
+		Sum:     0.025,
+		Count:   6,
+	})
+
+	want := strings.Join([]string{
+		"# HELP duration_seconds Durations",
+		"# TYPE duration_seconds histogram",
+		`duration_seconds_bucket{le="0.001"} 3`,
+		`duration_seconds_bucket{le="0.01"} 5`,
+		`duration_seconds_bucket{le="+Inf"} 6`,
+		"duration_seconds_sum 0.025",
+		"duration_seconds_count 6",
+	}, "\n") + "\n"
+
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestTextWriter_EscapeHelp(t *testing.T) {
+	var buf bytes.Buffer
+	tw := NewTextWriter(&buf)
+
+	tw.Gauge("g", "line one\nline two", 1)
+
+	if !strings.Contains(buf.String(), `# HELP g line one\nline two`) {
+		t.Errorf("help not escaped in %q", buf.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestTextWriter_Err(t *testing.T) {
+	tw := NewTextWriter(failingWriter{})
+
+	tw.Counter("c_total", "Counter", 1)
+
+	if tw.Err() == nil {
+		t.Error("expected write error to be recorded")
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		input float64
+		want  string
+	}{
+		{0, "0"},
+		{1.5, "1.5"},
+		{1e-4, "0.0001"},
+		{104857600, "104857600"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		if got := formatFloat(tt.input); got != tt.want {
+			t.Errorf("formatFloat(%v) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
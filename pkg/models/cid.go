@@ -12,15 +12,27 @@ type CID struct {
 	UUID    string `json:"uuid,omitempty"`
 }
 
-// CIDRecord represents a processed log entry with extracted CID information
+// CIDRecord represents a processed log entry with extracted CID information.
+// Timestamp is the time the line was logged when TimestampParsed is set and
+// the ingest time otherwise; ExtractedAt is always the ingest time. Source is
+// the path of the log file the line was read from, when known, SourceName the
+// name of its log source, Offset the byte offset at which the line starts and
+// LineNumber its 1-based line number, or 0 if unknown. CIDRecord is internal
+// to the pipeline; sinks write the versioned output record built from it.
 type CIDRecord struct {
-	CID         string            `json:"cid"`
-	UUID        string            `json:"uuid,omitempty"`
-	Timestamp   time.Time         `json:"timestamp"`
-	RawLogLine  string            `json:"raw_log_line"`
-	IsValid     bool              `json:"is_valid"`
-	ExtractedAt time.Time         `json:"extracted_at"`
-	Metadata    map[string]string `json:"metadata,omitempty"`
+	CID             string            `json:"cid"`
+	UUID            string            `json:"uuid,omitempty"`
+	Timestamp       time.Time         `json:"timestamp"`
+	TimestampParsed bool              `json:"timestamp_parsed"`
+	RawLogLine      string            `json:"raw_log_line"`
+	IsValid         bool              `json:"is_valid"`
+	PatternName     string            `json:"pattern_name,omitempty"`
+	ExtractedAt     time.Time         `json:"extracted_at"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Source          string            `json:"source,omitempty"`
+	SourceName      string            `json:"source_name,omitempty"`
+	Offset          int64             `json:"offset"`
+	LineNumber      int64             `json:"line_number,omitempty"`
 }
 
 // LogEntry represents a structured log entry for processing
@@ -40,13 +52,215 @@ type ProcessingResult struct {
 	Error     string    `json:"error,omitempty"`
 }
 
-// LogSource represents a log file source configuration
+// LogSource represents a log file source configuration. Patterns and Exclude
+// are globs relative to Path; "**" matches any number of directories and a
+// pattern without a slash matches the file name at any depth. Format selects
+// how lines are decoded, e.g. "docker" for the json-file log driver.
+// TimestampLayouts are tried in order to find the time a line was logged, and
+// Timezone applies to layouts without a zone. Multiline groups lines such as
+// stack traces into one event before CIDs are extracted. Watch overrides the
+// configured watch mode, e.g. "poll" for NFS or FUSE mounts.
 type LogSource struct {
-	Path        string   `json:"path"`
-	Name        string   `json:"name"`
-	Patterns    []string `json:"patterns"`
-	Active      bool     `json:"active"`
-	Description string   `json:"description"`
+	Path             string           `json:"path"`
+	Name             string           `json:"name"`
+	Patterns         []string         `json:"patterns"`
+	Exclude          []string         `json:"exclude,omitempty"`
+	Format           string           `json:"format,omitempty"`
+	TimestampLayouts []string         `json:"timestamp_layouts,omitempty"`
+	Timezone         string           `json:"timezone,omitempty"`
+	Multiline        *MultilineConfig `json:"multiline,omitempty"`
+	Watch            string           `json:"watch,omitempty"`
+	Active           bool             `json:"active"`
+	Description      string           `json:"description"`
+}
+
+// MultilineConfig groups physical lines into logical events. A line matching
+// StartPattern begins an event and a line matching ContinuationPattern
+// extends it. A pending event is emitted after FlushTimeout without new lines
+// or once it holds MaxLines lines.
+type MultilineConfig struct {
+	StartPattern        string        `json:"start_pattern,omitempty"`
+	ContinuationPattern string        `json:"continuation_pattern,omitempty"`
+	FlushTimeout        time.Duration `json:"flush_timeout,omitempty"`
+	MaxLines            int           `json:"max_lines,omitempty"`
+}
+
+// SinkConfig configures one output. Type selects the implementation, e.g.
+// "stdout", and Format how records are encoded; it defaults to the configured
+// output format, and Encoder tunes the encoding. Filter limits the records
+// the sink receives, and buffered records are written at least every
+// FlushInterval. Overflow decides what happens to records while the sink's
+// queue is full: "block", the default, waits for room and "drop" discards
+// them. The options of the selected type are set in the field named after
+// it, e.g. File.
+type SinkConfig struct {
+	Name          string                   `json:"name"`
+	Type          string                   `json:"type"`
+	Format        string                   `json:"format,omitempty"`
+	Encoder       *EncoderConfig           `json:"encoder,omitempty"`
+	Filter        *SinkFilter              `json:"filter,omitempty"`
+	FlushInterval time.Duration            `json:"flush_interval,omitempty"`
+	Overflow      string                   `json:"overflow,omitempty"`
+	File          *FileSinkConfig          `json:"file,omitempty"`
+	Webhook       *WebhookSinkConfig       `json:"webhook,omitempty"`
+	Elasticsearch *ElasticsearchSinkConfig `json:"elasticsearch,omitempty"`
+	Loki          *LokiSinkConfig          `json:"loki,omitempty"`
+	OTLP          *OTLPSinkConfig          `json:"otlp,omitempty"`
+	Syslog        *SyslogSinkConfig        `json:"syslog,omitempty"`
+}
+
+// EncoderConfig tunes how a sink encodes records. Fields selects the record
+// fields written and their order, and Rename maps a field to the name it is
+// written under. Template is the text/template of the "template" format, or
+// the name of a built-in one. Delimiter separates the columns of the "csv"
+// format, whose first line is a header unless NoHeader is set. Schema selects
+// the document the "json" format writes when no fields are selected: "v1",
+// the default, or "legacy".
+type EncoderConfig struct {
+	Schema    string            `json:"schema,omitempty"`
+	Fields    []string          `json:"fields,omitempty"`
+	Rename    map[string]string `json:"rename,omitempty"`
+	Template  string            `json:"template,omitempty"`
+	Delimiter string            `json:"delimiter,omitempty"`
+	NoHeader  bool              `json:"no_header,omitempty"`
+}
+
+// SinkFilter selects records for a sink. Patterns lists CID pattern names,
+// Files holds globs matched against the source file path, and Metadata holds
+// values such as a Kubernetes namespace that must all be present. Conditions
+// that are left empty match every record.
+type SinkFilter struct {
+	Patterns []string          `json:"patterns,omitempty"`
+	Files    []string          `json:"files,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// FileSinkConfig configures a file sink. The file is rotated once it would
+// exceed MaxSize bytes or has been open for RotateInterval, and rotated files
+// are compressed with gzip. At most MaxBackups rotated files younger than
+// MaxAge are kept; zero keeps them all. BufferSize records are buffered
+// before they are written to the file.
+type FileSinkConfig struct {
+	Path           string        `json:"path,omitempty"`
+	MaxSize        int64         `json:"max_size,omitempty"`
+	RotateInterval time.Duration `json:"rotate_interval,omitempty"`
+	MaxBackups     int           `json:"max_backups,omitempty"`
+	MaxAge         time.Duration `json:"max_age,omitempty"`
+	BufferSize     int           `json:"buffer_size,omitempty"`
+}
+
+// WebhookSinkConfig configures a webhook sink. Records are POSTed to URL in
+// batches of up to BatchSize, encoded as "ndjson" or as a JSON "array", with
+// the given Headers and BearerToken. Failed requests are retried MaxRetries
+// times, waiting from InitialBackoff up to MaxBackoff or as long as the
+// server asks in Retry-After, again at most MaxBackoff. MaxRetries is a
+// pointer so that an explicit 0 turns retries off, while nil selects the
+// default of 5. Batches that still fail are appended to DeadLetterPath, one
+// JSON record per line whatever the format of the sink.
+type WebhookSinkConfig struct {
+	URL            string            `json:"url"`
+	Encoding       string            `json:"encoding,omitempty"`
+	BatchSize      int               `json:"batch_size,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	BearerToken    string            `json:"bearer_token,omitempty"`
+	Timeout        time.Duration     `json:"timeout,omitempty"`
+	MaxRetries     *int              `json:"max_retries,omitempty"`
+	InitialBackoff time.Duration     `json:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration     `json:"max_backoff,omitempty"`
+	DeadLetterPath string            `json:"dead_letter_path,omitempty"`
+}
+
+// ElasticsearchSinkConfig configures a sink indexing records into
+// Elasticsearch or OpenSearch through the _bulk API at URL. Index is the
+// index name, in which %Y, %m and %d are replaced by the date of the record.
+// Requests authenticate with Username and Password or with APIKey. Documents
+// that fail are retried MaxRetries times (5 if nil, none if 0), waiting from
+// InitialBackoff up to MaxBackoff, and are then appended to DeadLetterPath as
+// a _bulk request body.
+type ElasticsearchSinkConfig struct {
+	URL            string            `json:"url"`
+	Index          string            `json:"index,omitempty"`
+	BatchSize      int               `json:"batch_size,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Username       string            `json:"username,omitempty"`
+	Password       string            `json:"password,omitempty"`
+	APIKey         string            `json:"api_key,omitempty"`
+	Timeout        time.Duration     `json:"timeout,omitempty"`
+	MaxRetries     *int              `json:"max_retries,omitempty"`
+	InitialBackoff time.Duration     `json:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration     `json:"max_backoff,omitempty"`
+	DeadLetterPath string            `json:"dead_letter_path,omitempty"`
+}
+
+// LokiSinkConfig configures a sink pushing records to Grafana Loki at URL,
+// encoded as "json" or snappy-compressed "protobuf". Labels maps stream label
+// names to record fields, e.g. "pod" to the pod metadata, and StaticLabels
+// are added to every stream. TenantID is sent as X-Scope-OrgID. Failed
+// pushes are retried MaxRetries times (5 if nil, none if 0), waiting from
+// InitialBackoff up to MaxBackoff, and are then appended to DeadLetterPath as
+// JSON push requests.
+type LokiSinkConfig struct {
+	URL            string            `json:"url"`
+	Encoding       string            `json:"encoding,omitempty"`
+	BatchSize      int               `json:"batch_size,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	StaticLabels   map[string]string `json:"static_labels,omitempty"`
+	TenantID       string            `json:"tenant_id,omitempty"`
+	Username       string            `json:"username,omitempty"`
+	Password       string            `json:"password,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Timeout        time.Duration     `json:"timeout,omitempty"`
+	MaxRetries     *int              `json:"max_retries,omitempty"`
+	InitialBackoff time.Duration     `json:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration     `json:"max_backoff,omitempty"`
+	DeadLetterPath string            `json:"dead_letter_path,omitempty"`
+}
+
+// OTLPSinkConfig configures a sink exporting records as OpenTelemetry log
+// records over OTLP/HTTP to URL, encoded as "protobuf" or "json".
+// ResourceAttributes are added to the resource of every record, e.g.
+// service.name. Failed exports are retried MaxRetries times (5 if nil, none
+// if 0), waiting from InitialBackoff up to MaxBackoff, and are then appended
+// to DeadLetterPath as JSON export requests.
+type OTLPSinkConfig struct {
+	URL                string            `json:"url"`
+	Encoding           string            `json:"encoding,omitempty"`
+	BatchSize          int               `json:"batch_size,omitempty"`
+	Headers            map[string]string `json:"headers,omitempty"`
+	ResourceAttributes map[string]string `json:"resource_attributes,omitempty"`
+	Timeout            time.Duration     `json:"timeout,omitempty"`
+	MaxRetries         *int              `json:"max_retries,omitempty"`
+	InitialBackoff     time.Duration     `json:"initial_backoff,omitempty"`
+	MaxBackoff         time.Duration     `json:"max_backoff,omitempty"`
+	DeadLetterPath     string            `json:"dead_letter_path,omitempty"`
+}
+
+// SyslogSinkConfig configures a sink sending records as RFC 5424 syslog
+// messages to Address over "udp", "tcp" or "tls". Facility and AppName set
+// the header fields of every message and Hostname defaults to the host name.
+// The severity is looked up in Severities by the value of the record field
+// SeverityField, e.g. "stderr" of the stream, falling back to Severity.
+// CAFile, CertFile, KeyFile, ServerName and InsecureSkipVerify configure TLS.
+// Failed writes reconnect and are retried MaxRetries times (5 if nil, none if
+// 0), waiting from InitialBackoff up to MaxBackoff.
+type SyslogSinkConfig struct {
+	Network            string            `json:"network,omitempty"`
+	Address            string            `json:"address"`
+	Facility           string            `json:"facility,omitempty"`
+	Severity           string            `json:"severity,omitempty"`
+	SeverityField      string            `json:"severity_field,omitempty"`
+	Severities         map[string]string `json:"severities,omitempty"`
+	AppName            string            `json:"app_name,omitempty"`
+	Hostname           string            `json:"hostname,omitempty"`
+	CAFile             string            `json:"ca_file,omitempty"`
+	CertFile           string            `json:"cert_file,omitempty"`
+	KeyFile            string            `json:"key_file,omitempty"`
+	ServerName         string            `json:"server_name,omitempty"`
+	InsecureSkipVerify bool              `json:"insecure_skip_verify,omitempty"`
+	Timeout            time.Duration     `json:"timeout,omitempty"`
+	MaxRetries         *int              `json:"max_retries,omitempty"`
+	InitialBackoff     time.Duration     `json:"initial_backoff,omitempty"`
+	MaxBackoff         time.Duration     `json:"max_backoff,omitempty"`
 }
 
 // CIDPattern represents a pattern for extracting CIDs
@@ -60,10 +274,12 @@ type CIDPattern struct {
 
 // CIDEntry represents an extracted CID entry with associated UUIDs
 type CIDEntry struct {
-	CID       string    `json:"cid"`
-	Timestamp time.Time `json:"timestamp"`
-	LogLine   string    `json:"log_line"`
-	UUIDs     []UUID    `json:"uuids"`
+	CID             string    `json:"cid"`
+	Pattern         string    `json:"pattern,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+	TimestampParsed bool      `json:"timestamp_parsed"`
+	LogLine         string    `json:"log_line"`
+	UUIDs           []UUID    `json:"uuids"`
 }
 
 // UUID represents an extracted UUID with metadata
@@ -1,49 +1,63 @@
 package monitor
 
 import (
-	"bufio"
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"cidtracker/pkg/checkpoint"
+	"cidtracker/pkg/tailer"
+	"cidtracker/pkg/timestamp"
+	"cidtracker/pkg/watcher"
 	"github.com/fsnotify/fsnotify"
 )
 
+// LogEntry is a line read from a monitored file. Timestamp is the time parsed
+// from the line if TimestampParsed is set, otherwise the time it was read.
 type LogEntry struct {
-	Timestamp time.Time `json:"timestamp"`
-	Line      string    `json:"line"`
-	Source    string    `json:"source"`
+	Timestamp       time.Time `json:"timestamp"`
+	TimestampParsed bool      `json:"timestamp_parsed"`
+	Line            string    `json:"line"`
+	Source          string    `json:"source"`
 }
 
 type LogMonitor struct {
-	watcher   *fsnotify.Watcher
-	logPaths  []string
-	outputCh  chan LogEntry
-	ctx       context.Context
-	cancel    context.CancelFunc
-	mu        sync.RWMutex
-	fileTails map[string]*os.File
+	watcher    *watcher.Watcher
+	logPaths   []string
+	outputCh   chan LogEntry
+	ctx        context.Context
+	cancel     context.CancelFunc
+	mu         sync.RWMutex
+	fileTails  map[string]*tailer.Follower
+	tails      sync.WaitGroup
+	store      *checkpoint.Store
+	timestamps *timestamp.Parser
 }
 
 func NewLogMonitor(logPaths []string) (*LogMonitor, error) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create file watcher: %w", err)
-	}
+	return NewCheckpointedLogMonitor(logPaths, nil)
+}
 
+// NewCheckpointedLogMonitor creates a monitor that resumes each file from its
+// checkpoint in store and records positions as lines are delivered to the
+// output channel. Files without a checkpoint are read from the beginning.
+// The caller flushes the store periodically, e.g. with store.Run; Stop flushes
+// it once more. Paths are polled where fsnotify cannot watch them.
+func NewCheckpointedLogMonitor(logPaths []string, store *checkpoint.Store) (*LogMonitor, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	lm := &LogMonitor{
-		watcher:   watcher,
-		logPaths:  logPaths,
-		outputCh:  make(chan LogEntry, 1000),
-		ctx:       ctx,
-		cancel:    cancel,
-		fileTails: make(map[string]*os.File),
+		watcher:    watcher.New(watcher.DefaultInterval),
+		logPaths:   logPaths,
+		outputCh:   make(chan LogEntry, 1000),
+		ctx:        ctx,
+		cancel:     cancel,
+		fileTails:  make(map[string]*tailer.Follower),
+		store:      store,
+		timestamps: timestamp.Default(),
 	}
 
 	for _, path := range logPaths {
@@ -63,65 +77,128 @@ func (lm *LogMonitor) addWatchPath(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		// Watch directory for file creation
 		dir := filepath.Dir(path)
-		if err := lm.watcher.Add(dir); err != nil {
+		if _, err := lm.watcher.Add(dir, watcher.ModeAuto); err != nil {
 			return fmt.Errorf("failed to watch directory %s: %w", dir, err)
 		}
 		return nil
 	}
 
 	// File exists, start tailing it
-	if err := lm.startTailing(path); err != nil {
+	if err := lm.startTailing(path, true); err != nil {
 		return fmt.Errorf("failed to start tailing %s: %w", path, err)
 	}
 
-	return lm.watcher.Add(path)
+	_, err := lm.watcher.Add(path, watcher.ModeAuto)
+	return err
 }
 
-func (lm *LogMonitor) startTailing(path string) error {
-	file, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", path, err)
+func (lm *LogMonitor) startTailing(path string, fromEnd bool) error {
+	if lm.ctx.Err() != nil {
+		return nil
+	}
+	if _, exists := lm.fileTails[path]; exists {
+		return nil
 	}
 
-	// Seek to end of file to only read new entries
-	if _, err := file.Seek(0, io.SeekEnd); err != nil {
-		return fmt.Errorf("failed to seek to end of file %s: %w", path, err)
+	follower, err := lm.openFollower(path, fromEnd)
+	if err != nil {
+		return err
 	}
 
-	lm.fileTails[path] = file
+	lm.fileTails[path] = follower
+	lm.saveCheckpoint(follower)
 
 	// Start reading goroutine
-	go lm.tailFile(path, file)
+	lm.tails.Add(1)
+	go lm.tailFile(path, follower)
 
 	return nil
 }
 
-func (lm *LogMonitor) tailFile(path string, file *os.File) {
-	scanner := bufio.NewScanner(file)
+// openFollower opens a file at its checkpoint when a store is configured
+func (lm *LogMonitor) openFollower(path string, fromEnd bool) (*tailer.Follower, error) {
+	if lm.store == nil {
+		return tailer.Open(path, fromEnd)
+	}
+
+	pos, ok := lm.store.Get(path)
+	if !ok {
+		return tailer.Open(path, false)
+	}
+
+	follower, _, err := tailer.Resume(path, pos)
+	return follower, err
+}
+
+// saveCheckpoint records the position of a follower when a store is configured
+func (lm *LogMonitor) saveCheckpoint(follower *tailer.Follower) {
+	if lm.store == nil {
+		return
+	}
+
+	pos, err := follower.Position()
+	if err != nil {
+		fmt.Printf("Failed to record checkpoint for %s: %v\n", follower.Path(), err)
+		return
+	}
+	lm.store.Set(follower.Path(), pos)
+}
+
+// tailFile polls the file for new lines, following it across rename and copytruncate
+func (lm *LogMonitor) tailFile(path string, follower *tailer.Follower) {
+	defer lm.tails.Done()
+
 	for {
 		select {
 		case <-lm.ctx.Done():
 			return
 		default:
-			if scanner.Scan() {
-				entry := LogEntry{
-					Timestamp: time.Now(),
-					Line:      scanner.Text(),
-					Source:    path,
-				}
-				select {
-				case lm.outputCh <- entry:
-				case <-lm.ctx.Done():
-					return
-				}
-			} else {
-				// No new lines, wait a bit
-				time.Sleep(100 * time.Millisecond)
+		}
+
+		sent, dropped := 0, false
+		_, err := follower.Poll(func(line string) {
+			entry := LogEntry{
+				Timestamp: time.Now(),
+				Line:      line,
+				Source:    path,
+			}
+			if ts, ok := lm.timestamps.Parse(line); ok {
+				entry.Timestamp = ts
+				entry.TimestampParsed = true
+			}
+			select {
+			case lm.outputCh <- entry:
+				sent++
+			case <-lm.ctx.Done():
+				dropped = true
+			}
+		})
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", path, err)
+		}
+
+		// A line read but not delivered must be read again after a restart
+		if sent > 0 && !dropped {
+			lm.saveCheckpoint(follower)
+		}
+
+		if sent == 0 {
+			// No new lines, wait a bit
+			select {
+			case <-lm.ctx.Done():
+				return
+			case <-time.After(100 * time.Millisecond):
 			}
 		}
 	}
 }
 
+// SetTimestampParser replaces the parser used to timestamp lines, which
+// defaults to timestamp.Default. It must be called before Start.
+func (lm *LogMonitor) SetTimestampParser(parser *timestamp.Parser) {
+	lm.timestamps = parser
+}
+
 func (lm *LogMonitor) Start() <-chan LogEntry {
 	go lm.watchEvents()
 	return lm.outputCh
@@ -132,7 +209,7 @@ func (lm *LogMonitor) watchEvents() {
 		select {
 		case <-lm.ctx.Done():
 			return
-		case event, ok := <-lm.watcher.Events:
+		case event, ok := <-lm.watcher.Events():
 			if !ok {
 				return
 			}
@@ -145,7 +222,7 @@ func (lm *LogMonitor) watchEvents() {
 				continue
 			}
 
-		case err, ok := <-lm.watcher.Errors:
+		case err, ok := <-lm.watcher.Errors():
 			if !ok {
 				return
 			}
@@ -162,7 +239,8 @@ func (lm *LogMonitor) handleFileCreation(fileName string) {
 	// Check if this file matches any of our log paths
 	for _, logPath := range lm.logPaths {
 		if fileName == logPath {
-			if err := lm.startTailing(fileName); err != nil {
+			// Files created after start are read from the beginning
+			if err := lm.startTailing(fileName, false); err != nil {
 				fmt.Printf("Failed to start tailing new file %s: %v\n", fileName, err)
 			}
 			break
@@ -176,6 +254,9 @@ func (lm *LogMonitor) Stop() error {
 	lm.mu.Lock()
 	defer lm.mu.Unlock()
 
+	// Wait for tail goroutines so no entry is sent after the channel is closed
+	lm.tails.Wait()
+
 	// Close all file handles
 	for path, file := range lm.fileTails {
 		if err := file.Close(); err != nil {
@@ -183,6 +264,12 @@ func (lm *LogMonitor) Stop() error {
 		}
 	}
 
+	if lm.store != nil {
+		if err := lm.store.Flush(); err != nil {
+			fmt.Printf("Error flushing checkpoints: %v\n", err)
+		}
+	}
+
 	close(lm.outputCh)
 	return lm.watcher.Close()
-}
\ No newline at end of file
+}
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"cidtracker/pkg/checkpoint"
 )
 
 func TestNewLogMonitor(t *testing.T) {
@@ -381,3 +383,154 @@ func TestLogMonitor_ConcurrentAccess(t *testing.T) {
 
 	monitor.Stop()
 }
+
+func TestLogMonitor_FollowsRotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "app.log")
+
+	if err := os.WriteFile(logFile, []byte("initial\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	monitor, err := NewLogMonitor([]string{logFile})
+	if err != nil {
+		t.Fatalf("NewLogMonitor() error = %v", err)
+	}
+	defer monitor.Stop()
+
+	outputCh := monitor.Start()
+
+	if err := os.Rename(logFile, logFile+".1"); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	f, err := os.OpenFile(logFile+".1", os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open rotated file: %v", err)
+	}
+	f.WriteString("before rotation\n")
+	f.Close()
+
+	if err := os.WriteFile(logFile, []byte("after rotation\n"), 0644); err != nil {
+		t.Fatalf("failed to recreate log file: %v", err)
+	}
+
+	want := []string{"before rotation", "after rotation"}
+	for _, line := range want {
+		select {
+		case entry := <-outputCh:
+			if entry.Line != line {
+				t.Errorf("Line = %v, want %v", entry.Line, line)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %q", line)
+		}
+	}
+}
+
+func TestCheckpointedLogMonitor_ResumesAfterRestart(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "app.log")
+
+	if err := os.WriteFile(logFile, []byte("first\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	store, err := checkpoint.Open(filepath.Join(tmpDir, "state"))
+	if err != nil {
+		t.Fatalf("checkpoint.Open() error = %v", err)
+	}
+
+	monitor, err := NewCheckpointedLogMonitor([]string{logFile}, store)
+	if err != nil {
+		t.Fatalf("NewCheckpointedLogMonitor() error = %v", err)
+	}
+	outputCh := monitor.Start()
+
+	// Without a checkpoint the existing line is read
+	select {
+	case entry := <-outputCh:
+		if entry.Line != "first" {
+			t.Errorf("Line = %v, want first", entry.Line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for existing line")
+	}
+	monitor.Stop()
+
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	f.WriteString("second\n")
+	f.Close()
+
+	store, err = checkpoint.Open(filepath.Join(tmpDir, "state"))
+	if err != nil {
+		t.Fatalf("checkpoint.Open() error = %v", err)
+	}
+	monitor, err = NewCheckpointedLogMonitor([]string{logFile}, store)
+	if err != nil {
+		t.Fatalf("NewCheckpointedLogMonitor() error = %v", err)
+	}
+	defer monitor.Stop()
+	outputCh = monitor.Start()
+
+	select {
+	case entry := <-outputCh:
+		if entry.Line != "second" {
+			t.Errorf("Line = %v, want second", entry.Line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for line written during restart")
+	}
+}
+
+func TestLogMonitor_ParsesTimestamps(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "app.log")
+
+	if err := os.WriteFile(logFile, nil, 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	monitor, err := NewLogMonitor([]string{logFile})
+	if err != nil {
+		t.Fatalf("NewLogMonitor() error = %v", err)
+	}
+	defer monitor.Stop()
+
+	outputCh := monitor.Start()
+
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	f.WriteString("2024-03-05 10:11:12.345 INFO parsed\nno timestamp\n")
+	f.Close()
+
+	tests := []struct {
+		line       string
+		wantParsed bool
+	}{
+		{line: "2024-03-05 10:11:12.345 INFO parsed", wantParsed: true},
+		{line: "no timestamp", wantParsed: false},
+	}
+	for _, tt := range tests {
+		select {
+		case entry := <-outputCh:
+			if entry.Line != tt.line {
+				t.Fatalf("Line = %v, want %v", entry.Line, tt.line)
+			}
+			if entry.TimestampParsed != tt.wantParsed {
+				t.Errorf("%q: TimestampParsed = %v, want %v", tt.line, entry.TimestampParsed, tt.wantParsed)
+			}
+			if tt.wantParsed {
+				if want := time.Date(2024, 3, 5, 10, 11, 12, 345000000, time.UTC); !entry.Timestamp.Equal(want) {
+					t.Errorf("Timestamp = %v, want %v", entry.Timestamp, want)
+				}
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %q", tt.line)
+		}
+	}
+}
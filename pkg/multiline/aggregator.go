@@ -0,0 +1,135 @@
+package multiline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cidtracker/pkg/decoder"
+	"cidtracker/pkg/models"
+)
+
+// Defaults for settings a multiline rule leaves unset
+const (
+	DefaultFlushTimeout = time.Second
+	DefaultMaxLines     = 500
+)
+
+// Aggregator groups the physical lines of one file into logical events, such
+// as a log message followed by its stack trace. A line matching the start
+// pattern begins a new event and a line matching the continuation pattern is
+// appended to the pending one. With only a start pattern every other line is a
+// continuation; with only a continuation pattern every other line is a start.
+//
+// An event is complete when the next one starts, when it reaches the line
+// limit, or when no line arrived for the flush timeout. An aggregator keeps
+// per-file state and must not be shared between files.
+type Aggregator struct {
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	flushTimeout time.Duration
+	maxLines     int
+
+	pending  []string
+	first    decoder.Line
+	lastSeen time.Time
+}
+
+// New creates an aggregator for a log source's multiline rule
+func New(cfg models.MultilineConfig) (*Aggregator, error) {
+	if cfg.StartPattern == "" && cfg.ContinuationPattern == "" {
+		return nil, fmt.Errorf("multiline rule needs a start_pattern or continuation_pattern")
+	}
+
+	a := &Aggregator{
+		flushTimeout: cfg.FlushTimeout,
+		maxLines:     cfg.MaxLines,
+	}
+
+	var err error
+	if cfg.StartPattern != "" {
+		if a.start, err = regexp.Compile(cfg.StartPattern); err != nil {
+			return nil, fmt.Errorf("invalid multiline start_pattern: %w", err)
+		}
+	}
+	if cfg.ContinuationPattern != "" {
+		if a.continuation, err = regexp.Compile(cfg.ContinuationPattern); err != nil {
+			return nil, fmt.Errorf("invalid multiline continuation_pattern: %w", err)
+		}
+	}
+
+	if a.flushTimeout < 0 {
+		return nil, fmt.Errorf("multiline flush_timeout must not be negative")
+	}
+	if a.flushTimeout == 0 {
+		a.flushTimeout = DefaultFlushTimeout
+	}
+	if a.maxLines < 0 {
+		return nil, fmt.Errorf("multiline max_lines must not be negative")
+	}
+	if a.maxLines == 0 {
+		a.maxLines = DefaultMaxLines
+	}
+
+	return a, nil
+}
+
+// isContinuation reports whether a line belongs to the pending event
+func (a *Aggregator) isContinuation(message string) bool {
+	if a.start != nil && a.start.MatchString(message) {
+		return false
+	}
+	if a.continuation != nil {
+		return a.continuation.MatchString(message)
+	}
+	return true
+}
+
+// Add consumes a decoded line received at now and returns the events it completed
+func (a *Aggregator) Add(line decoder.Line, now time.Time) []decoder.Line {
+	var events []decoder.Line
+
+	if len(a.pending) > 0 && !a.isContinuation(line.Message) {
+		events = append(events, a.take())
+	}
+
+	if len(a.pending) == 0 {
+		a.first = line
+	}
+	a.pending = append(a.pending, line.Message)
+	a.lastSeen = now
+
+	if len(a.pending) >= a.maxLines {
+		events = append(events, a.take())
+	}
+
+	return events
+}
+
+// FlushExpired returns the pending event if no line arrived for the flush timeout
+func (a *Aggregator) FlushExpired(now time.Time) (decoder.Line, bool) {
+	if len(a.pending) == 0 || now.Sub(a.lastSeen) < a.flushTimeout {
+		return decoder.Line{}, false
+	}
+	return a.take(), true
+}
+
+// Flush returns the pending event regardless of its age, e.g. when the file is closed
+func (a *Aggregator) Flush() (decoder.Line, bool) {
+	if len(a.pending) == 0 {
+		return decoder.Line{}, false
+	}
+	return a.take(), true
+}
+
+// take joins the pending lines into one event carrying the timestamp and
+// metadata of its first line
+func (a *Aggregator) take() decoder.Line {
+	event := a.first
+	event.Message = strings.Join(a.pending, "\n")
+
+	a.pending = nil
+	a.first = decoder.Line{}
+	return event
+}
@@ -0,0 +1,168 @@
+package multiline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/decoder"
+	"cidtracker/pkg/models"
+)
+
+func messages(lines []decoder.Line) []string {
+	var out []string
+	for _, line := range lines {
+		out = append(out, line.Message)
+	}
+	return out
+}
+
+func TestAggregator_Add(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   models.MultilineConfig
+		lines []string
+		want  []string
+	}{
+		{
+			name: "start pattern",
+			cfg:  models.MultilineConfig{StartPattern: `^\d{4}-\d{2}-\d{2} `},
+			lines: []string{
+				"2024-03-05 10:11:12.345 ERROR CID:abc failed",
+				"java.lang.IllegalStateException: boom",
+				"\tat com.example.Service.run(Service.java:42)",
+				"2024-03-05 10:11:13.000 INFO next",
+			},
+			want: []string{
+				"2024-03-05 10:11:12.345 ERROR CID:abc failed\njava.lang.IllegalStateException: boom\n\tat com.example.Service.run(Service.java:42)",
+			},
+		},
+		{
+			name: "continuation pattern",
+			cfg:  models.MultilineConfig{ContinuationPattern: `^(\s|goroutine |panic:)`},
+			lines: []string{
+				"CID:abc handler crashed",
+				"panic: runtime error",
+				"    main.go:10",
+				"second event",
+				"third event",
+			},
+			want: []string{
+				"CID:abc handler crashed\npanic: runtime error\n    main.go:10",
+				"second event",
+			},
+		},
+		{
+			name: "start wins over continuation",
+			cfg:  models.MultilineConfig{StartPattern: `^START`, ContinuationPattern: `^S`},
+			lines: []string{
+				"START one",
+				"S more",
+				"START two",
+			},
+			want: []string{"START one\nS more"},
+		},
+		{
+			name: "line limit",
+			cfg:  models.MultilineConfig{StartPattern: `^START`, MaxLines: 2},
+			lines: []string{
+				"START one",
+				"a",
+				"b",
+			},
+			want: []string{"START one\na"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(tt.cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			var events []decoder.Line
+			now := time.Now()
+			for _, line := range tt.lines {
+				events = append(events, a.Add(decoder.Line{Message: line}, now)...)
+			}
+
+			if got := messages(events); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregator_FirstLineMetadata(t *testing.T) {
+	a, err := New(models.MultilineConfig{StartPattern: `^\S`})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	first := time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC)
+	a.Add(decoder.Line{Message: "head", Timestamp: first, Metadata: map[string]string{"stream": "stderr"}}, time.Now())
+	a.Add(decoder.Line{Message: "  tail", Timestamp: first.Add(time.Second)}, time.Now())
+
+	event, ok := a.Flush()
+	if !ok {
+		t.Fatal("Flush() returned no event")
+	}
+	if event.Message != "head\n  tail" {
+		t.Errorf("Message = %q, want %q", event.Message, "head\n  tail")
+	}
+	if !event.Timestamp.Equal(first) {
+		t.Errorf("Timestamp = %v, want %v", event.Timestamp, first)
+	}
+	if event.Metadata["stream"] != "stderr" {
+		t.Errorf("Metadata = %v, want stream of first line", event.Metadata)
+	}
+
+	if _, ok := a.Flush(); ok {
+		t.Error("Flush() returned an event after the pending one was taken")
+	}
+}
+
+func TestAggregator_FlushExpired(t *testing.T) {
+	a, err := New(models.MultilineConfig{StartPattern: `^\S`, FlushTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	start := time.Now()
+	a.Add(decoder.Line{Message: "head"}, start)
+	a.Add(decoder.Line{Message: "  tail"}, start.Add(500*time.Millisecond))
+
+	if _, ok := a.FlushExpired(start.Add(time.Second)); ok {
+		t.Error("FlushExpired() flushed before the timeout since the last line")
+	}
+
+	event, ok := a.FlushExpired(start.Add(1500 * time.Millisecond))
+	if !ok {
+		t.Fatal("FlushExpired() did not flush after the timeout")
+	}
+	if event.Message != "head\n  tail" {
+		t.Errorf("Message = %q, want %q", event.Message, "head\n  tail")
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  models.MultilineConfig
+	}{
+		{name: "no patterns", cfg: models.MultilineConfig{}},
+		{name: "invalid start pattern", cfg: models.MultilineConfig{StartPattern: "("}},
+		{name: "invalid continuation pattern", cfg: models.MultilineConfig{ContinuationPattern: "["}},
+		{name: "negative timeout", cfg: models.MultilineConfig{StartPattern: "^x", FlushTimeout: -time.Second}},
+		{name: "negative line limit", cfg: models.MultilineConfig{StartPattern: "^x", MaxLines: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); err == nil {
+				t.Error("New() expected error")
+			}
+		})
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"sync"
 	"time"
 
+	"cidtracker/pkg/decoder"
 	"cidtracker/pkg/extractor"
+	"cidtracker/pkg/metrics"
 	"cidtracker/pkg/models"
+	"cidtracker/pkg/timestamp"
 )
 
 type Metrics struct {
@@ -16,12 +19,19 @@ type Metrics struct {
 	ValidCIDs        int64
 	InvalidCIDs      int64
 	ProcessingErrors int64
+	TruncatedLines   int64
+	SkippedLines     int64
+	TrackedCIDs      int64
+	CorrelatedCIDs   int64
+	LastProcessed    time.Time
+	duration         *metrics.Histogram
 	mu               sync.RWMutex
 }
 
 func (m *Metrics) IncrementProcessed() {
 	m.mu.Lock()
 	m.ProcessedLogs++
+	m.LastProcessed = time.Now()
 	m.mu.Unlock()
 }
 
@@ -49,12 +59,90 @@ func (m *Metrics) IncrementErrors() {
 	m.mu.Unlock()
 }
 
+// AddOversized counts lines that exceeded the maximum line size and were
+// truncated or skipped
+func (m *Metrics) AddOversized(truncated, skipped int) {
+	m.mu.Lock()
+	m.TruncatedLines += int64(truncated)
+	m.SkippedLines += int64(skipped)
+	m.mu.Unlock()
+}
+
+// OversizedStats returns the number of truncated and skipped lines
+func (m *Metrics) OversizedStats() (truncated, skipped int64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.TruncatedLines, m.SkippedLines
+}
+
+// SetTrackedCIDs records how many distinct CIDs the correlator currently holds
+func (m *Metrics) SetTrackedCIDs(n int) {
+	m.mu.Lock()
+	m.TrackedCIDs = int64(n)
+	m.mu.Unlock()
+}
+
+// IncrementCorrelated counts a CID seen in a second log file
+func (m *Metrics) IncrementCorrelated() {
+	m.mu.Lock()
+	m.CorrelatedCIDs++
+	m.mu.Unlock()
+}
+
+// CorrelationStats returns the number of tracked CIDs and of CIDs seen in more
+// than one log file
+func (m *Metrics) CorrelationStats() (tracked, correlated int64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.TrackedCIDs, m.CorrelatedCIDs
+}
+
 func (m *Metrics) GetStats() (int64, int64, int64, int64, int64) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	return m.ProcessedLogs, m.ExtractedCIDs, m.ValidCIDs, m.InvalidCIDs, m.ProcessingErrors
 }
 
+// LastProcessedAt returns when the most recent log line was processed
+func (m *Metrics) LastProcessedAt() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.LastProcessed
+}
+
+// ObserveDuration records the time spent processing a single log line
+func (m *Metrics) ObserveDuration(d time.Duration) {
+	m.durationHistogram().Observe(d.Seconds())
+}
+
+// durationHistogram lazily creates the processing duration histogram
+func (m *Metrics) durationHistogram() *metrics.Histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.duration == nil {
+		m.duration = metrics.NewHistogram(metrics.DefaultBuckets)
+	}
+	return m.duration
+}
+
+// WritePrometheus writes the counters and duration histogram in text exposition format
+func (m *Metrics) WritePrometheus(tw *metrics.TextWriter) {
+	processed, extracted, valid, invalid, errors := m.GetStats()
+	truncated, skipped := m.OversizedStats()
+	tracked, correlated := m.CorrelationStats()
+
+	tw.Counter("cidtracker_logs_processed_total", "Total number of log lines processed", float64(processed))
+	tw.Counter("cidtracker_cids_extracted_total", "Total number of CIDs extracted", float64(extracted))
+	tw.Counter("cidtracker_cids_valid_total", "Total number of extracted CIDs that passed validation", float64(valid))
+	tw.Counter("cidtracker_cids_invalid_total", "Total number of extracted CIDs that failed validation", float64(invalid))
+	tw.Counter("cidtracker_errors_total", "Total number of processing errors", float64(errors))
+	tw.Counter("cidtracker_lines_truncated_total", "Total number of lines truncated to the maximum line size", float64(truncated))
+	tw.Counter("cidtracker_lines_skipped_total", "Total number of lines skipped for exceeding the maximum line size", float64(skipped))
+	tw.Gauge("cidtracker_cids_tracked", "Number of distinct CIDs seen within the correlation TTL", float64(tracked))
+	tw.Counter("cidtracker_cids_correlated_total", "Total number of CIDs seen in more than one log file", float64(correlated))
+	tw.Histogram("cidtracker_processing_duration_seconds", "Time spent processing logs", m.durationHistogram().Snapshot())
+}
+
 type Processor struct {
 	extractor *extractor.CIDExtractor
 	metrics   *Metrics
@@ -65,9 +153,16 @@ type Processor struct {
 }
 
 func NewProcessor(outputCh chan<- models.CIDRecord) *Processor {
+	return NewExtractorProcessor(extractor.NewCIDExtractor(), outputCh)
+}
+
+// NewExtractorProcessor creates a processor that extracts CIDs with the given
+// extractor. The output channel may be nil when records are only taken with
+// Records.
+func NewExtractorProcessor(cidExtractor *extractor.CIDExtractor, outputCh chan<- models.CIDRecord) *Processor {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Processor{
-		extractor: extractor.NewCIDExtractor(),
+		extractor: cidExtractor,
 		metrics:   &Metrics{},
 		outputCh:  outputCh,
 		ctx:       ctx,
@@ -76,26 +171,63 @@ func NewProcessor(outputCh chan<- models.CIDRecord) *Processor {
 }
 
 func (p *Processor) ProcessLogLine(logLine string) error {
-	p.metrics.IncrementProcessed()
-
-	entries := p.extractor.ExtractCIDs(logLine)
+	return p.ProcessLine(decoder.Line{Message: logLine})
+}
 
-	if len(entries) == 0 {
-		return nil
+// ProcessLine extracts CIDs from a decoded line and sends a record for each to
+// the output channel
+func (p *Processor) ProcessLine(line decoder.Line) error {
+	for _, record := range p.Records(line, timestamp.Default()) {
+		select {
+		case p.outputCh <- record:
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		}
 	}
 
-	p.metrics.IncrementExtracted()
+	return nil
+}
+
+// Records extracts and validates the CIDs of a decoded line and counts them in
+// the metrics. A record is returned for every CID; IsValid tells whether it
+// contains a UUID accepted by the validator. Records are timestamped with the
+// time parser finds in the message, else the runtime timestamp, else the
+// ingest time. Metadata of the line, such as the Kubernetes pod, is copied to
+// each record.
+func (p *Processor) Records(line decoder.Line, parser *timestamp.Parser) []models.CIDRecord {
+	start := time.Now()
+	defer func() { p.metrics.ObserveDuration(time.Since(start)) }()
+
+	p.metrics.IncrementProcessed()
+
+	entries := p.extractor.ExtractCIDsWithParser(line.Message, parser)
 
+	records := make([]models.CIDRecord, 0, len(entries))
 	for _, entry := range entries {
+		p.metrics.IncrementExtracted()
+
 		// Check if any valid UUIDs were extracted
 		isValid := len(entry.UUIDs) > 0
 
 		record := models.CIDRecord{
-			CID:         entry.CID,
-			Timestamp:   entry.Timestamp,
-			RawLogLine:  entry.LogLine,
-			IsValid:     isValid,
-			ExtractedAt: time.Now(),
+			CID:             entry.CID,
+			Timestamp:       entry.Timestamp,
+			TimestampParsed: entry.TimestampParsed,
+			RawLogLine:      entry.LogLine,
+			IsValid:         isValid,
+			PatternName:     entry.Pattern,
+			ExtractedAt:     time.Now(),
+			Metadata:        line.Metadata,
+			SourceName:      line.Source,
+			Offset:          line.Offset,
+			LineNumber:      line.LineNumber,
+		}
+		if !entry.TimestampParsed && !line.Timestamp.IsZero() {
+			record.Timestamp = line.Timestamp
+			record.TimestampParsed = true
+		}
+		if isValid {
+			record.UUID = entry.UUIDs[0].Value
 		}
 
 		if isValid {
@@ -104,14 +236,10 @@ func (p *Processor) ProcessLogLine(logLine string) error {
 			p.metrics.IncrementInvalid()
 		}
 
-		select {
-		case p.outputCh <- record:
-		case <-p.ctx.Done():
-			return p.ctx.Err()
-		}
+		records = append(records, record)
 	}
 
-	return nil
+	return records
 }
 
 func (p *Processor) Start() {
@@ -143,4 +271,4 @@ func (p *Processor) metricsReporter() {
 
 func (p *Processor) GetMetrics() *Metrics {
 	return p.metrics
-}
\ No newline at end of file
+}
@@ -1,11 +1,18 @@
 package processor
 
 import (
+	"bytes"
 	"context"
+	"strings"
 	"testing"
 	"time"
 
+	"cidtracker/pkg/decoder"
+	"cidtracker/pkg/extractor"
+	"cidtracker/pkg/metrics"
 	"cidtracker/pkg/models"
+	"cidtracker/pkg/timestamp"
+	"cidtracker/pkg/validator"
 )
 
 func TestNewProcessor(t *testing.T) {
@@ -372,3 +379,210 @@ func TestProcessor_InvalidUUID(t *testing.T) {
 		t.Error("IsValid should be false for CID without valid UUID")
 	}
 }
+
+func TestProcessor_PatternNameAndUUID(t *testing.T) {
+	outputCh := make(chan models.CIDRecord, 100)
+	p := NewProcessor(outputCh)
+
+	p.ProcessLogLine(`{"cid":"550e8400-e29b-51d4-a716-446655440000","msg":"ok"}`)
+
+	record := <-outputCh
+	if record.PatternName != "json_cid" {
+		t.Errorf("PatternName = %v, want json_cid", record.PatternName)
+	}
+	if record.UUID != "550e8400-e29b-51d4-a716-446655440000" {
+		t.Errorf("UUID = %v, want 550e8400-e29b-51d4-a716-446655440000", record.UUID)
+	}
+}
+
+func TestMetrics_LastProcessedAt(t *testing.T) {
+	m := &Metrics{}
+
+	if !m.LastProcessedAt().IsZero() {
+		t.Error("LastProcessedAt should be zero before any processing")
+	}
+
+	before := time.Now()
+	m.IncrementProcessed()
+
+	if m.LastProcessedAt().Before(before) {
+		t.Errorf("LastProcessedAt = %v, want after %v", m.LastProcessedAt(), before)
+	}
+}
+
+func TestMetrics_WritePrometheus(t *testing.T) {
+	m := &Metrics{}
+
+	m.IncrementProcessed()
+	m.IncrementExtracted()
+	m.IncrementValid()
+	m.AddOversized(2, 1)
+	m.SetTrackedCIDs(3)
+	m.IncrementCorrelated()
+	m.ObserveDuration(500 * time.Microsecond)
+
+	var buf bytes.Buffer
+	tw := metrics.NewTextWriter(&buf)
+	m.WritePrometheus(tw)
+
+	if err := tw.Err(); err != nil {
+		t.Fatalf("WritePrometheus() error = %v", err)
+	}
+
+	output := buf.String()
+	want := []string{
+		"cidtracker_logs_processed_total 1",
+		"cidtracker_cids_extracted_total 1",
+		"cidtracker_cids_valid_total 1",
+		"cidtracker_cids_invalid_total 0",
+		"cidtracker_errors_total 0",
+		"cidtracker_lines_truncated_total 2",
+		"cidtracker_lines_skipped_total 1",
+		"cidtracker_cids_tracked 3",
+		"cidtracker_cids_correlated_total 1",
+		"cidtracker_processing_duration_seconds_count 1",
+	}
+
+	for _, w := range want {
+		if !strings.Contains(output, w) {
+			t.Errorf("output missing %q", w)
+		}
+	}
+}
+
+func TestProcessor_ObservesDuration(t *testing.T) {
+	outputCh := make(chan models.CIDRecord, 100)
+	p := NewProcessor(outputCh)
+
+	p.ProcessLogLine("CID[cid1] line")
+	p.ProcessLogLine("regular line")
+
+	snap := p.GetMetrics().durationHistogram().Snapshot()
+	if snap.Count != 2 {
+		t.Errorf("duration observations = %d, want 2", snap.Count)
+	}
+}
+
+func TestProcessor_ProcessLine_CRIMetadata(t *testing.T) {
+	outputCh := make(chan models.CIDRecord, 100)
+	p := NewProcessor(outputCh)
+
+	d := decoder.NewCRIDecoder("/var/log/pods/payments_checkout-0_0b7c6a4e/api/1.log")
+	line, ok, err := d.Decode("2024-01-15T10:30:00.5Z stdout F CID=550e8400-e29b-51d4-a716-446655440000 charged")
+	if err != nil || !ok {
+		t.Fatalf("Decode() ok = %v, err = %v", ok, err)
+	}
+
+	if err := p.ProcessLine(line); err != nil {
+		t.Fatalf("ProcessLine() error = %v", err)
+	}
+
+	record := <-outputCh
+	if want := time.Date(2024, 1, 15, 10, 30, 0, 500000000, time.UTC); !record.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", record.Timestamp, want)
+	}
+	if !record.TimestampParsed {
+		t.Error("TimestampParsed = false, want true for a runtime timestamp")
+	}
+
+	want := map[string]string{
+		"stream":        "stdout",
+		"namespace":     "payments",
+		"pod":           "checkout-0",
+		"pod_uid":       "0b7c6a4e",
+		"container":     "api",
+		"restart_count": "1",
+	}
+	for key, value := range want {
+		if record.Metadata[key] != value {
+			t.Errorf("Metadata[%s] = %v, want %v", key, record.Metadata[key], value)
+		}
+	}
+}
+
+func TestProcessor_ProcessLine_TimestampPrecedence(t *testing.T) {
+	runtime := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		line       decoder.Line
+		want       time.Time
+		wantParsed bool
+	}{
+		{
+			name:       "message timestamp wins over runtime",
+			line:       decoder.Line{Message: "2024-01-15 10:29:59.750 CID[abc]", Timestamp: runtime},
+			want:       time.Date(2024, 1, 15, 10, 29, 59, 750000000, time.UTC),
+			wantParsed: true,
+		},
+		{
+			name:       "runtime timestamp",
+			line:       decoder.Line{Message: "CID[abc]", Timestamp: runtime},
+			want:       runtime,
+			wantParsed: true,
+		},
+		{
+			name: "ingest time",
+			line: decoder.Line{Message: "CID[abc]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputCh := make(chan models.CIDRecord, 1)
+			p := NewProcessor(outputCh)
+
+			before := time.Now()
+			if err := p.ProcessLine(tt.line); err != nil {
+				t.Fatalf("ProcessLine() error = %v", err)
+			}
+
+			record := <-outputCh
+			if record.TimestampParsed != tt.wantParsed {
+				t.Errorf("TimestampParsed = %v, want %v", record.TimestampParsed, tt.wantParsed)
+			}
+			if tt.wantParsed && !record.Timestamp.Equal(tt.want) {
+				t.Errorf("Timestamp = %v, want %v", record.Timestamp, tt.want)
+			}
+			if !tt.wantParsed && record.Timestamp.Before(before) {
+				t.Errorf("Timestamp = %v, want ingest time", record.Timestamp)
+			}
+			if record.ExtractedAt.Before(before) {
+				t.Errorf("ExtractedAt = %v, want ingest time", record.ExtractedAt)
+			}
+		})
+	}
+}
+
+func TestProcessor_Records(t *testing.T) {
+	cidExtractor, err := extractor.NewPatternExtractor(extractor.NewCIDExtractor().Patterns(), validator.NewUUIDValidator(false))
+	if err != nil {
+		t.Fatalf("NewPatternExtractor() error = %v", err)
+	}
+	p := NewExtractorProcessor(cidExtractor, nil)
+
+	parser, err := timestamp.NewParser([]string{"unix"}, "")
+	if err != nil {
+		t.Fatalf("NewParser() error = %v", err)
+	}
+
+	records := p.Records(decoder.Line{Message: "1709633472 CID:550e8400-e29b-41d4-a716-446655440000 CID[simple]"}, parser)
+	if len(records) != 2 {
+		t.Fatalf("Records() returned %d records, want 2", len(records))
+	}
+
+	if !records[0].IsValid || records[0].UUID != "550e8400-e29b-41d4-a716-446655440000" {
+		t.Errorf("records[0] = %+v, want valid v4 UUID", records[0])
+	}
+	if !records[0].TimestampParsed || records[0].Timestamp.Unix() != 1709633472 {
+		t.Errorf("records[0].Timestamp = %v, want parsed unix time", records[0].Timestamp)
+	}
+	if records[1].IsValid {
+		t.Errorf("records[1] = %+v, want invalid", records[1])
+	}
+
+	processed, extracted, valid, invalid, _ := p.GetMetrics().GetStats()
+	if processed != 1 || extracted != 2 || valid != 1 || invalid != 1 {
+		t.Errorf("GetStats() = %d, %d, %d, %d, want 1, 2, 1, 1", processed, extracted, valid, invalid)
+	}
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"cidtracker/pkg/metrics"
+	"cidtracker/pkg/processor"
+	log "github.com/sirupsen/logrus"
+)
+
+// Error codes returned in the error envelope
+const (
+	CodeInvalidRequest     = "INVALID_REQUEST"
+	CodeNotFound           = "NOT_FOUND"
+	CodeInternalError      = "INTERNAL_ERROR"
+	CodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+)
+
+// Statistics holds the processing counters reported by /status
+type Statistics struct {
+	LogsProcessed int64      `json:"logs_processed"`
+	CIDsExtracted int64      `json:"cids_extracted"`
+	Errors        int64      `json:"errors"`
+	LastProcessed *time.Time `json:"last_processed,omitempty"`
+}
+
+// Configuration holds the non-sensitive configuration reported by /status and /config
+type Configuration struct {
+	LogDirectory      string   `json:"log_directory"`
+	OutputFormat      string   `json:"output_format"`
+	BufferSize        int      `json:"buffer_size,omitempty"`
+	PollInterval      string   `json:"poll_interval,omitempty"`
+	CIDPattern        string   `json:"cid_pattern,omitempty"`
+	CIDPatterns       []string `json:"cid_patterns,omitempty"`
+	OutputDestination string   `json:"output_destination"`
+}
+
+// FileStatus describes a single monitored log file
+type FileStatus struct {
+	Path           string    `json:"path"`
+	Size           int64     `json:"size"`
+	LastModified   time.Time `json:"last_modified"`
+	LinesProcessed int64     `json:"lines_processed"`
+}
+
+// SinkStatus holds the counters of one output sink
+type SinkStatus struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Written int64  `json:"written"`
+	Errors  int64  `json:"errors"`
+	Dropped int64  `json:"dropped"`
+	Queued  int    `json:"queued"`
+}
+
+// StatusProvider supplies the runtime state exposed by the server
+type StatusProvider interface {
+	Healthy() bool
+	Statistics() Statistics
+	Configuration() Configuration
+	MonitoredFiles() []FileStatus
+	Sinks() []SinkStatus
+	Metrics() *processor.Metrics
+}
+
+// HealthResponse is the body returned by /health
+type HealthResponse struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	Version   string    `json:"version"`
+}
+
+// StatusResponse is the body returned by /status
+type StatusResponse struct {
+	Status         string        `json:"status"`
+	Timestamp      time.Time     `json:"timestamp"`
+	Version        string        `json:"version"`
+	Uptime         string        `json:"uptime"`
+	Statistics     Statistics    `json:"statistics"`
+	Configuration  Configuration `json:"configuration"`
+	MonitoredFiles []FileStatus  `json:"monitored_files"`
+	Sinks          []SinkStatus  `json:"sinks"`
+}
+
+// ErrorResponse is the error envelope returned by all endpoints
+type ErrorResponse struct {
+	Error     string    `json:"error"`
+	Timestamp time.Time `json:"timestamp"`
+	Code      string    `json:"code"`
+}
+
+// Server serves the admin HTTP endpoints
+type Server struct {
+	addr       string
+	version    string
+	provider   StatusProvider
+	startedAt  time.Time
+	httpServer *http.Server
+}
+
+// NewServer creates a new admin server bound to addr
+func NewServer(addr, version string, provider StatusProvider) *Server {
+	s := &Server{
+		addr:      addr,
+		version:   version,
+		provider:  provider,
+		startedAt: time.Now(),
+	}
+
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return s
+}
+
+// Handler returns the HTTP handler serving all admin endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.getOnly(s.handleHealth))
+	mux.HandleFunc("/status", s.getOnly(s.handleStatus))
+	mux.HandleFunc("/config", s.getOnly(s.handleConfig))
+	mux.HandleFunc("/metrics", s.getOnly(s.handleMetrics))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, CodeNotFound, fmt.Sprintf("no such endpoint: %s", r.URL.Path))
+	})
+	return mux
+}
+
+// Start begins listening and serves requests in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithError(err).Error("HTTP server stopped unexpectedly")
+		}
+	}()
+
+	log.WithField("addr", listener.Addr().String()).Info("Started HTTP server")
+	return nil
+}
+
+// Shutdown gracefully stops the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+// getOnly rejects requests that are not GET or HEAD
+func (s *Server) getOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, CodeInvalidRequest, fmt.Sprintf("method %s not allowed", r.Method))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	resp := HealthResponse{
+		Status:    "healthy",
+		Timestamp: time.Now().UTC(),
+		Version:   s.version,
+	}
+
+	code := http.StatusOK
+	if !s.provider.Healthy() {
+		resp.Status = "unhealthy"
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, resp)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := "running"
+	if !s.provider.Healthy() {
+		status = "stopped"
+	}
+
+	files := s.provider.MonitoredFiles()
+	if files == nil {
+		files = []FileStatus{}
+	}
+
+	sinks := s.provider.Sinks()
+	if sinks == nil {
+		sinks = []SinkStatus{}
+	}
+
+	writeJSON(w, http.StatusOK, StatusResponse{
+		Status:         status,
+		Timestamp:      time.Now().UTC(),
+		Version:        s.version,
+		Uptime:         time.Since(s.startedAt).Truncate(time.Second).String(),
+		Statistics:     s.provider.Statistics(),
+		Configuration:  s.provider.Configuration(),
+		MonitoredFiles: files,
+		Sinks:          sinks,
+	})
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.provider.Configuration())
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	tw := metrics.NewTextWriter(&buf)
+
+	s.provider.Metrics().WritePrometheus(tw)
+
+	files := s.provider.MonitoredFiles()
+	sizes := make([]metrics.Sample, 0, len(files))
+	lines := make([]metrics.Sample, 0, len(files))
+	for _, f := range files {
+		labels := []metrics.Label{{Name: "file", Value: f.Path}}
+		sizes = append(sizes, metrics.Sample{Labels: labels, Value: float64(f.Size)})
+		lines = append(lines, metrics.Sample{Labels: labels, Value: float64(f.LinesProcessed)})
+	}
+	tw.Gauge("cidtracker_monitored_files", "Number of log files currently monitored", float64(len(files)))
+	tw.GaugeVec("cidtracker_file_size_bytes", "Current size of monitored log files", sizes)
+	tw.CounterVec("cidtracker_file_lines_processed_total", "Log lines processed per monitored file", lines)
+
+	sinks := s.provider.Sinks()
+	written := make([]metrics.Sample, 0, len(sinks))
+	failed := make([]metrics.Sample, 0, len(sinks))
+	dropped := make([]metrics.Sample, 0, len(sinks))
+	queued := make([]metrics.Sample, 0, len(sinks))
+	for _, sink := range sinks {
+		labels := []metrics.Label{{Name: "sink", Value: sink.Name}}
+		written = append(written, metrics.Sample{Labels: labels, Value: float64(sink.Written)})
+		failed = append(failed, metrics.Sample{Labels: labels, Value: float64(sink.Errors)})
+		dropped = append(dropped, metrics.Sample{Labels: labels, Value: float64(sink.Dropped)})
+		queued = append(queued, metrics.Sample{Labels: labels, Value: float64(sink.Queued)})
+	}
+	tw.CounterVec("cidtracker_sink_records_written_total", "CID records written per sink", written)
+	tw.CounterVec("cidtracker_sink_errors_total", "Write and flush errors per sink", failed)
+	tw.CounterVec("cidtracker_sink_records_dropped_total", "CID records dropped per sink because its queue was full", dropped)
+	tw.GaugeVec("cidtracker_sink_queue_length", "CID records waiting to be written per sink", queued)
+	tw.Gauge("cidtracker_uptime_seconds", "Seconds since the server started", time.Since(s.startedAt).Seconds())
+
+	if err := tw.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternalError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// writeJSON encodes v as the response body
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternalError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// writeError writes the documented error envelope
+func writeError(w http.ResponseWriter, code int, errCode, message string) {
+	data, _ := json.Marshal(ErrorResponse{
+		Error:     message,
+		Timestamp: time.Now().UTC(),
+		Code:      errCode,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
@@ -0,0 +1,320 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/processor"
+)
+
+type fakeProvider struct {
+	healthy bool
+	stats   Statistics
+	config  Configuration
+	files   []FileStatus
+	sinks   []SinkStatus
+	metrics *processor.Metrics
+}
+
+func (f *fakeProvider) Healthy() bool                { return f.healthy }
+func (f *fakeProvider) Statistics() Statistics       { return f.stats }
+func (f *fakeProvider) Configuration() Configuration { return f.config }
+func (f *fakeProvider) MonitoredFiles() []FileStatus { return f.files }
+func (f *fakeProvider) Sinks() []SinkStatus          { return f.sinks }
+func (f *fakeProvider) Metrics() *processor.Metrics  { return f.metrics }
+
+func newFakeProvider() *fakeProvider {
+	last := time.Date(2024, 1, 15, 10, 29, 55, 0, time.UTC)
+	return &fakeProvider{
+		healthy: true,
+		stats: Statistics{
+			LogsProcessed: 15420,
+			CIDsExtracted: 8934,
+			Errors:        12,
+			LastProcessed: &last,
+		},
+		config: Configuration{
+			LogDirectory:      "/var/log/app",
+			OutputFormat:      "json",
+			BufferSize:        1000,
+			PollInterval:      "100ms",
+			OutputDestination: "stdout",
+		},
+		files: []FileStatus{
+			{
+				Path:           "/var/log/app/application.log",
+				Size:           104857600,
+				LastModified:   last,
+				LinesProcessed: 8934,
+			},
+		},
+		sinks: []SinkStatus{
+			{Name: "stdout", Type: "stdout", Written: 8930, Errors: 1, Dropped: 2, Queued: 3},
+		},
+		metrics: &processor.Metrics{},
+	}
+}
+
+func TestServer_Health(t *testing.T) {
+	srv := NewServer(":0", "1.0.0", newFakeProvider())
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %v, want application/json", ct)
+	}
+
+	var resp HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.Status != "healthy" {
+		t.Errorf("Status = %v, want healthy", resp.Status)
+	}
+	if resp.Version != "1.0.0" {
+		t.Errorf("Version = %v, want 1.0.0", resp.Version)
+	}
+	if resp.Timestamp.IsZero() {
+		t.Error("Timestamp should not be zero")
+	}
+}
+
+func TestServer_Health_Unhealthy(t *testing.T) {
+	provider := newFakeProvider()
+	provider.healthy = false
+	srv := NewServer(":0", "1.0.0", provider)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status code = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	var resp HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.Status != "unhealthy" {
+		t.Errorf("Status = %v, want unhealthy", resp.Status)
+	}
+}
+
+func TestServer_Status(t *testing.T) {
+	srv := NewServer(":0", "1.0.0", newFakeProvider())
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var resp StatusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.Status != "running" {
+		t.Errorf("Status = %v, want running", resp.Status)
+	}
+	if resp.Uptime == "" {
+		t.Error("Uptime should not be empty")
+	}
+	if resp.Statistics.LogsProcessed != 15420 {
+		t.Errorf("LogsProcessed = %d, want 15420", resp.Statistics.LogsProcessed)
+	}
+	if resp.Statistics.CIDsExtracted != 8934 {
+		t.Errorf("CIDsExtracted = %d, want 8934", resp.Statistics.CIDsExtracted)
+	}
+	if resp.Statistics.Errors != 12 {
+		t.Errorf("Errors = %d, want 12", resp.Statistics.Errors)
+	}
+	if resp.Configuration.LogDirectory != "/var/log/app" {
+		t.Errorf("LogDirectory = %v, want /var/log/app", resp.Configuration.LogDirectory)
+	}
+	if len(resp.MonitoredFiles) != 1 {
+		t.Fatalf("MonitoredFiles length = %d, want 1", len(resp.MonitoredFiles))
+	}
+	if resp.MonitoredFiles[0].Size != 104857600 {
+		t.Errorf("Size = %d, want 104857600", resp.MonitoredFiles[0].Size)
+	}
+	if resp.MonitoredFiles[0].LinesProcessed != 8934 {
+		t.Errorf("LinesProcessed = %d, want 8934", resp.MonitoredFiles[0].LinesProcessed)
+	}
+	if len(resp.Sinks) != 1 || resp.Sinks[0].Name != "stdout" || resp.Sinks[0].Dropped != 2 {
+		t.Errorf("Sinks = %+v, want the stdout sink with 2 dropped", resp.Sinks)
+	}
+}
+
+func TestServer_Status_NoFiles(t *testing.T) {
+	provider := newFakeProvider()
+	provider.files = nil
+	provider.sinks = nil
+	srv := NewServer(":0", "1.0.0", provider)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if string(raw["monitored_files"]) != "[]" {
+		t.Errorf("monitored_files = %s, want []", raw["monitored_files"])
+	}
+	if string(raw["sinks"]) != "[]" {
+		t.Errorf("sinks = %s, want []", raw["sinks"])
+	}
+}
+
+func TestServer_Config(t *testing.T) {
+	srv := NewServer(":0", "1.0.0", newFakeProvider())
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var resp Configuration
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.OutputFormat != "json" {
+		t.Errorf("OutputFormat = %v, want json", resp.OutputFormat)
+	}
+	if resp.BufferSize != 1000 {
+		t.Errorf("BufferSize = %d, want 1000", resp.BufferSize)
+	}
+	if resp.OutputDestination != "stdout" {
+		t.Errorf("OutputDestination = %v, want stdout", resp.OutputDestination)
+	}
+}
+
+func TestServer_ErrorEnvelope(t *testing.T) {
+	srv := NewServer(":0", "1.0.0", newFakeProvider())
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+		wantErr  string
+	}{
+		{"unknown path", http.MethodGet, "/unknown", http.StatusNotFound, CodeNotFound},
+		{"post to health", http.MethodPost, "/health", http.StatusMethodNotAllowed, CodeInvalidRequest},
+		{"delete config", http.MethodDelete, "/config", http.StatusMethodNotAllowed, CodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", rec.Code, tt.wantCode)
+			}
+
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if resp.Code != tt.wantErr {
+				t.Errorf("Code = %v, want %v", resp.Code, tt.wantErr)
+			}
+			if resp.Error == "" {
+				t.Error("Error should not be empty")
+			}
+			if resp.Timestamp.IsZero() {
+				t.Error("Timestamp should not be zero")
+			}
+		})
+	}
+}
+
+func TestServer_StartShutdown(t *testing.T) {
+	srv := NewServer("127.0.0.1:0", "1.0.0", newFakeProvider())
+
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
+
+func TestServer_Start_InvalidAddr(t *testing.T) {
+	srv := NewServer("invalid-address", "1.0.0", newFakeProvider())
+
+	if err := srv.Start(); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
+
+func TestServer_Metrics(t *testing.T) {
+	provider := newFakeProvider()
+	provider.metrics.IncrementProcessed()
+	provider.metrics.IncrementProcessed()
+	provider.metrics.IncrementExtracted()
+	provider.metrics.IncrementErrors()
+	provider.metrics.ObserveDuration(2 * time.Millisecond)
+
+	srv := NewServer(":0", "1.0.0", provider)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %v, want text/plain", ct)
+	}
+
+	body := rec.Body.String()
+	want := []string{
+		"# TYPE cidtracker_logs_processed_total counter",
+		"cidtracker_logs_processed_total 2",
+		"cidtracker_cids_extracted_total 1",
+		"cidtracker_errors_total 1",
+		"# TYPE cidtracker_file_size_bytes gauge",
+		`cidtracker_file_size_bytes{file="/var/log/app/application.log"} 104857600`,
+		"# TYPE cidtracker_processing_duration_seconds histogram",
+		`cidtracker_processing_duration_seconds_bucket{le="0.001"} 0`,
+		`cidtracker_processing_duration_seconds_bucket{le="0.005"} 1`,
+		`cidtracker_processing_duration_seconds_bucket{le="+Inf"} 1`,
+		"cidtracker_processing_duration_seconds_count 1",
+		`cidtracker_sink_records_written_total{sink="stdout"} 8930`,
+		`cidtracker_sink_errors_total{sink="stdout"} 1`,
+		`cidtracker_sink_records_dropped_total{sink="stdout"} 2`,
+		`cidtracker_sink_queue_length{sink="stdout"} 3`,
+	}
+
+	for _, w := range want {
+		if !strings.Contains(body, w) {
+			t.Errorf("metrics output missing %q", w)
+		}
+	}
+}
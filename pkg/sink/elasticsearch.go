@@ -0,0 +1,335 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// TypeElasticsearch is the sink type indexing records into Elasticsearch or
+// OpenSearch
+const TypeElasticsearch = "elasticsearch"
+
+// DefaultIndex is the index pattern used unless configured otherwise
+const DefaultIndex = "cidtracker-%Y.%m.%d"
+
+// invalidIndexChars are the characters Elasticsearch rejects in index names
+const invalidIndexChars = `\/*?"<>| ,#:`
+
+func init() {
+	Register(TypeElasticsearch, func(cfg models.SinkConfig) (Sink, error) {
+		enc, err := NewEncoder(cfg.Format, cfg.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		return NewElasticsearch(enc, *cfg.Elasticsearch)
+	}, validateElasticsearch)
+}
+
+// validateElasticsearch checks the options of an Elasticsearch sink
+func validateElasticsearch(cfg models.SinkConfig) error {
+	if cfg.Elasticsearch == nil {
+		return fmt.Errorf("elasticsearch sink requires a url")
+	}
+	es := cfg.Elasticsearch
+	if err := validateURL(es.URL); err != nil {
+		return fmt.Errorf("elasticsearch sink: %w", err)
+	}
+	if cfg.Format != FormatJSON {
+		return fmt.Errorf("elasticsearch sink requires the %s format", FormatJSON)
+	}
+
+	index := es.Index
+	if index == "" {
+		index = DefaultIndex
+	}
+	if _, err := expandIndex(index, time.Now()); err != nil {
+		return err
+	}
+
+	if es.APIKey != "" && es.Username != "" {
+		return fmt.Errorf("elasticsearch sink takes either a username and password or an api key")
+	}
+	if es.BatchSize < 0 || es.Timeout < 0 || !validRetries(es.MaxRetries) || es.InitialBackoff < 0 || es.MaxBackoff < 0 {
+		return fmt.Errorf("elasticsearch batch size, timeout and retry settings must not be negative")
+	}
+	return nil
+}
+
+// expandIndex replaces %Y, %m and %d in an index pattern with the UTC date of
+// t, and %% with a percent sign
+func expandIndex(pattern string, t time.Time) (string, error) {
+	t = t.UTC()
+
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		if i == len(pattern) {
+			return "", fmt.Errorf("invalid index '%s': ends with %%", pattern)
+		}
+		switch pattern[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case '%':
+			b.WriteByte('%')
+		default:
+			return "", fmt.Errorf("invalid index '%s': unknown directive %%%c (use %%Y, %%m or %%d)", pattern, pattern[i])
+		}
+	}
+
+	index := b.String()
+	if index == "" || index != strings.ToLower(index) || strings.ContainsAny(index, invalidIndexChars) ||
+		strings.ContainsAny(index[:1], "-_+") {
+		return "", fmt.Errorf("invalid index '%s': must be lowercase, must not start with -, _ or + and must not contain %s",
+			pattern, invalidIndexChars)
+	}
+	return index, nil
+}
+
+// documentID derives the ID of a record's document from its CID, file and
+// offset, so a record sent again after a timeout replaces the first copy
+// instead of duplicating it
+func documentID(record models.CIDRecord) string {
+	h := sha256.New()
+	h.Write([]byte(record.CID))
+	h.Write([]byte{0})
+	h.Write([]byte(record.Source))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(record.Offset, 10)))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// bulkDoc is one document of a _bulk request: its action line and its source
+type bulkDoc struct {
+	action []byte
+	source []byte
+}
+
+// bulkResponse is the part of a _bulk response the sink reads. Every item
+// holds the result of one action, keyed by the action's name.
+type bulkResponse struct {
+	Errors bool                  `json:"errors"`
+	Items  []map[string]bulkItem `json:"items"`
+}
+
+// bulkItem is the result of one action of a _bulk request
+type bulkItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+}
+
+// elasticsearchSink collects records into batches and indexes each batch with
+// one _bulk request. Documents the cluster rejects temporarily, e.g. because
+// its write queue is full, are sent again on their own.
+type elasticsearchSink struct {
+	cfg     models.ElasticsearchSinkConfig
+	encoder Encoder
+	sender  *httpSender
+
+	mu    sync.Mutex
+	batch []bulkDoc
+}
+
+// NewElasticsearch creates a sink indexing records encoded by enc into the
+// configured cluster. enc must be a json encoder.
+func NewElasticsearch(enc Encoder, cfg models.ElasticsearchSinkConfig) (Sink, error) {
+	return newElasticsearchSink(enc, cfg)
+}
+
+// newElasticsearchSink creates an Elasticsearch sink with its defaults applied
+func newElasticsearchSink(enc Encoder, cfg models.ElasticsearchSinkConfig) (*elasticsearchSink, error) {
+	if err := validateElasticsearch(models.SinkConfig{Format: formatOf(enc), Elasticsearch: &cfg}); err != nil {
+		return nil, err
+	}
+	if cfg.Index == "" {
+		cfg.Index = DefaultIndex
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	headers := make(map[string]string, len(cfg.Headers)+1)
+	for name, value := range cfg.Headers {
+		headers[name] = value
+	}
+	switch {
+	case cfg.APIKey != "":
+		headers["Authorization"] = "ApiKey " + cfg.APIKey
+	case cfg.Username != "":
+		headers["Authorization"] = basicAuth(cfg.Username, cfg.Password)
+	}
+
+	// Only the status and error of each item are needed from the response
+	endpoint := strings.TrimSuffix(cfg.URL, "/") + "/_bulk?filter_path=errors,items.*.status,items.*.error"
+	return &elasticsearchSink{
+		cfg:     cfg,
+		encoder: enc,
+		sender: newHTTPSender(endpoint, headers, "", cfg.Timeout,
+			cfg.MaxRetries, cfg.InitialBackoff, cfg.MaxBackoff),
+	}, nil
+}
+
+// Write adds a record to the current batch and indexes the batch once full
+func (s *elasticsearchSink) Write(ctx context.Context, record models.CIDRecord) error {
+	source, err := s.encoder.Encode(record)
+	if err != nil {
+		return err
+	}
+	index, err := expandIndex(s.cfg.Index, record.Timestamp)
+	if err != nil {
+		return err
+	}
+	action, err := json.Marshal(map[string]map[string]string{
+		"index": {"_index": index, "_id": documentID(record)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode bulk action: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.batch = append(s.batch, bulkDoc{action: action, source: source})
+	if len(s.batch) < s.cfg.BatchSize {
+		return nil
+	}
+	return s.send(ctx)
+}
+
+// Flush indexes the current batch, however small
+func (s *elasticsearchSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.send(ctx)
+}
+
+// Close indexes the current batch
+func (s *elasticsearchSink) Close() error {
+	return s.Flush(context.Background())
+}
+
+// send indexes the current batch. Failed documents are retried on their own
+// if the failure is temporary; documents rejected for good or still failing
+// after the retries are written to the dead-letter file, if one is
+// configured, and otherwise lost.
+func (s *elasticsearchSink) send(ctx context.Context) error {
+	pending := s.batch
+	s.batch = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	total := len(pending)
+
+	var rejected []bulkDoc
+	var rejectErr error
+	attempts, err := s.sender.retry(ctx, func() (bool, time.Duration, error) {
+		respBody, retry, wait, err := s.sender.postOnce(ctx, "application/x-ndjson", bulkBody(pending))
+		if err != nil {
+			return retry, wait, err
+		}
+
+		failed, permanent, itemErr, err := parseBulkResponse(respBody, pending)
+		if err != nil {
+			return false, 0, err
+		}
+		if len(permanent) > 0 {
+			rejected = append(rejected, permanent...)
+			rejectErr = fmt.Errorf("%d documents rejected: %w", len(permanent), itemErr)
+		}
+		pending = failed
+		if len(pending) == 0 {
+			return false, 0, nil
+		}
+		return true, 0, fmt.Errorf("%d documents failed: %w", len(pending), itemErr)
+	})
+	if err != nil {
+		rejected = append(rejected, pending...)
+		rejectErr = err
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	err = fmt.Errorf("failed to index %d of %d documents after %d attempts: %w", len(rejected), total, attempts, rejectErr)
+	if s.cfg.DeadLetterPath == "" {
+		return err
+	}
+	lines := make([][]byte, 0, 2*len(rejected))
+	for _, doc := range rejected {
+		lines = append(lines, doc.action, doc.source)
+	}
+	if dlErr := appendDeadLetter(s.cfg.DeadLetterPath, lines); dlErr != nil {
+		return fmt.Errorf("%w; %v", err, dlErr)
+	}
+	return fmt.Errorf("%w; written to %s", err, s.cfg.DeadLetterPath)
+}
+
+// bulkBody renders documents as a _bulk request body
+func bulkBody(docs []bulkDoc) []byte {
+	var buf bytes.Buffer
+	for _, doc := range docs {
+		buf.Write(doc.action)
+		buf.WriteByte('\n')
+		buf.Write(doc.source)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// parseBulkResponse splits the documents of a _bulk request into those that
+// failed temporarily and may be retried, and those that were rejected for
+// good, and returns the error of the first failed item. It fails if the
+// response does not say which documents failed.
+func parseBulkResponse(body []byte, docs []bulkDoc) (failed, rejected []bulkDoc, itemErr error, err error) {
+	var resp bulkResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil, nil, nil, nil
+	}
+	if len(resp.Items) != len(docs) {
+		return nil, nil, nil, fmt.Errorf("bulk response has %d items for %d documents", len(resp.Items), len(docs))
+	}
+
+	for i, result := range resp.Items {
+		for _, item := range result {
+			if item.Status >= 200 && item.Status < 300 {
+				continue
+			}
+			if itemErr == nil {
+				itemErr = fmt.Errorf("status %d", item.Status)
+				if item.Error != nil {
+					itemErr = fmt.Errorf("status %d: %s: %s", item.Status, item.Error.Type, item.Error.Reason)
+				}
+			}
+			if retryableStatus(item.Status) {
+				failed = append(failed, docs[i])
+			} else {
+				rejected = append(rejected, docs[i])
+			}
+		}
+	}
+	return failed, rejected, itemErr, nil
+}
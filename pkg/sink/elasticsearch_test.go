@@ -0,0 +1,306 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// fakeBulk is an in-process _bulk endpoint. reject decides the status of each
+// document by CID and attempt; documents it accepts are stored by index and ID.
+type fakeBulk struct {
+	mu       sync.Mutex
+	reject   func(cid string, attempt int) int
+	attempts map[string]int
+	requests []int
+	auth     string
+	docs     map[string]Record
+}
+
+func newFakeBulk(reject func(cid string, attempt int) int) *fakeBulk {
+	return &fakeBulk{reject: reject, attempts: map[string]int{}, docs: map[string]Record{}}
+}
+
+func (f *fakeBulk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = r.Header.Get("Authorization")
+
+	type item struct {
+		Status int            `json:"status"`
+		Error  map[string]any `json:"error,omitempty"`
+	}
+	var items []map[string]item
+	errors := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		json.Unmarshal(scanner.Bytes(), &action)
+		scanner.Scan()
+		var doc Record
+		json.Unmarshal(scanner.Bytes(), &doc)
+
+		meta := action["index"]
+		f.attempts[doc.ExtractedCID]++
+		status := http.StatusCreated
+		if f.reject != nil {
+			if s := f.reject(doc.ExtractedCID, f.attempts[doc.ExtractedCID]); s != 0 {
+				status = s
+			}
+		}
+		result := item{Status: status}
+		if status >= 300 {
+			errors = true
+			result.Error = map[string]any{"type": "test_exception", "reason": "rejected by test"}
+		} else {
+			f.docs[meta.Index+"/"+meta.ID] = doc
+		}
+		items = append(items, map[string]item{"index": result})
+	}
+	f.requests = append(f.requests, len(items))
+
+	json.NewEncoder(w).Encode(map[string]any{"took": 3, "errors": errors, "items": items})
+}
+
+// newTestElasticsearch creates an Elasticsearch sink that retries without
+// sleeping
+func newTestElasticsearch(t *testing.T, cfg models.ElasticsearchSinkConfig) *elasticsearchSink {
+	t.Helper()
+	s, err := newElasticsearchSink(testEncoder(t, FormatJSON), cfg)
+	if err != nil {
+		t.Fatalf("newElasticsearchSink() error = %v", err)
+	}
+	s.sender.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	return s
+}
+
+// esRecord returns record n logged on the given day of March 2024
+func esRecord(n, day int) models.CIDRecord {
+	record := testRecord(n, "standard_cid")
+	record.Timestamp = time.Date(2024, 3, day, 23, 30, 0, 0, time.UTC)
+	record.Offset = int64(n * 100)
+	return record
+}
+
+func TestElasticsearch_IndexesDailyIndices(t *testing.T) {
+	bulk := newFakeBulk(nil)
+	server := httptest.NewServer(bulk)
+	defer server.Close()
+
+	s := newTestElasticsearch(t, models.ElasticsearchSinkConfig{URL: server.URL + "/", Username: "elastic", Password: "changeme"})
+	ctx := context.Background()
+
+	s.Write(ctx, esRecord(1, 4))
+	s.Write(ctx, esRecord(2, 5))
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	for key, record := range map[string]models.CIDRecord{
+		"cidtracker-2024.03.04/" + documentID(esRecord(1, 4)): esRecord(1, 4),
+		"cidtracker-2024.03.05/" + documentID(esRecord(2, 5)): esRecord(2, 5),
+	} {
+		if doc, ok := bulk.docs[key]; !ok || doc.ExtractedCID != record.CID {
+			t.Errorf("document %s = %+v, want CID %s", key, doc, record.CID)
+		}
+	}
+	if bulk.auth != "Basic ZWxhc3RpYzpjaGFuZ2VtZQ==" {
+		t.Errorf("Authorization = %q, want basic credentials", bulk.auth)
+	}
+}
+
+func TestElasticsearch_RetriesOnlyFailedDocuments(t *testing.T) {
+	flaky, broken := esRecord(2, 5).CID, esRecord(3, 5).CID
+	bulk := newFakeBulk(func(cid string, attempt int) int {
+		switch {
+		case cid == flaky && attempt < 3:
+			return http.StatusTooManyRequests
+		case cid == broken:
+			return http.StatusBadRequest
+		}
+		return 0
+	})
+	server := httptest.NewServer(bulk)
+	defer server.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "es-failed.ndjson")
+	s := newTestElasticsearch(t, models.ElasticsearchSinkConfig{
+		URL:            server.URL,
+		BatchSize:      4,
+		DeadLetterPath: deadLetter,
+	})
+	ctx := context.Background()
+
+	var err error
+	for i := 1; i <= 4; i++ {
+		err = s.Write(ctx, esRecord(i, 5))
+	}
+	if err == nil || !strings.Contains(err.Error(), "1 of 4 documents") {
+		t.Fatalf("Write() error = %v, want one rejected document", err)
+	}
+
+	// The full batch, then only the flaky document until it succeeds
+	if want := []int{4, 1, 1}; !reflect.DeepEqual(bulk.requests, want) {
+		t.Errorf("request sizes = %v, want %v", bulk.requests, want)
+	}
+	if len(bulk.docs) != 3 {
+		t.Errorf("indexed %d documents, want 3", len(bulk.docs))
+	}
+
+	// The dead letter is a _bulk body holding the rejected document
+	lines := readLines(t, deadLetter)
+	if len(lines) != 2 {
+		t.Fatalf("dead letter has %d lines, want 2", len(lines))
+	}
+	if !strings.Contains(lines[0], documentID(esRecord(3, 5))) || !strings.Contains(lines[1], broken) {
+		t.Errorf("dead letter = %v, want the action and source of the rejected document", lines)
+	}
+}
+
+func TestElasticsearch_RetriesExhausted(t *testing.T) {
+	bulk := newFakeBulk(func(cid string, attempt int) int { return http.StatusServiceUnavailable })
+	server := httptest.NewServer(bulk)
+	defer server.Close()
+
+	s := newTestElasticsearch(t, models.ElasticsearchSinkConfig{URL: server.URL, MaxRetries: retries(2)})
+	ctx := context.Background()
+
+	s.Write(ctx, esRecord(1, 5))
+	err := s.Flush(ctx)
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("Flush() error = %v, want failure after 3 attempts", err)
+	}
+}
+
+func TestElasticsearch_RequestFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr bool
+	}{
+		{"server error then success", func() http.HandlerFunc {
+			calls := 0
+			return func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.Write([]byte(`{"errors":false}`))
+			}
+		}(), false},
+		{"mismatched items", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"errors":true,"items":[]}`))
+		}, true},
+		{"invalid response", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`<html>`))
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			s := newTestElasticsearch(t, models.ElasticsearchSinkConfig{URL: server.URL})
+			s.Write(context.Background(), esRecord(1, 5))
+			if err := s.Flush(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Flush() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDocumentID(t *testing.T) {
+	record := esRecord(1, 5)
+	if documentID(record) != documentID(esRecord(1, 6)) {
+		t.Error("documentID() depends on more than CID, file and offset")
+	}
+
+	for name, change := range map[string]func(*models.CIDRecord){
+		"cid":    func(r *models.CIDRecord) { r.CID = esRecord(2, 5).CID },
+		"file":   func(r *models.CIDRecord) { r.Source = "/var/log/app/other.log" },
+		"offset": func(r *models.CIDRecord) { r.Offset++ },
+	} {
+		other := record
+		change(&other)
+		if documentID(other) == documentID(record) {
+			t.Errorf("documentID() ignores the %s", name)
+		}
+	}
+}
+
+func TestExpandIndex(t *testing.T) {
+	at := time.Date(2024, 3, 5, 23, 30, 0, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{DefaultIndex, "cidtracker-2024.03.05", false},
+		{"cids-%Y-%m", "cids-2024-03", false},
+		{"cids", "cids", false},
+		{"cids-100%%", "cids-100%", false},
+		{"cids-%H", "", true},
+		{"cids-%", "", true},
+		{"CIDs-%Y", "", true},
+		{"_cids", "", true},
+		{"cids/%Y", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := expandIndex(tt.pattern, at)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("expandIndex(%q) = %q, %v; want %q, wantErr %v", tt.pattern, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestValidate_ElasticsearchSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		es      *models.ElasticsearchSinkConfig
+		wantErr bool
+	}{
+		{"valid", FormatJSON, &models.ElasticsearchSinkConfig{URL: "https://es:9200", APIKey: "key"}, false},
+		{"missing options", FormatJSON, nil, true},
+		{"missing url", FormatJSON, &models.ElasticsearchSinkConfig{}, true},
+		{"structured format", FormatStructured, &models.ElasticsearchSinkConfig{URL: "http://es:9200"}, true},
+		{"bad index", FormatJSON, &models.ElasticsearchSinkConfig{URL: "http://es:9200", Index: "CIDs"}, true},
+		{"two credentials", FormatJSON, &models.ElasticsearchSinkConfig{URL: "http://es:9200", APIKey: "key", Username: "elastic"}, true},
+		{"negative retries", FormatJSON, &models.ElasticsearchSinkConfig{URL: "http://es:9200", MaxRetries: retries(-1)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(models.SinkConfig{Name: "es", Type: TypeElasticsearch, Format: tt.format, Elasticsearch: tt.es})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,461 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"cidtracker/pkg/models"
+)
+
+// Encoder renders records for a sink. Encode returns one record without a
+// trailing newline. Header returns the line an output starts with, such as
+// the column names of CSV, or nil. ContentType is the media type of a body
+// holding encoded records one per line.
+type Encoder interface {
+	Encode(record models.CIDRecord) ([]byte, error)
+	Header() []byte
+	ContentType() string
+}
+
+// encoderFactory creates an encoder tuned by cfg
+type encoderFactory func(cfg models.EncoderConfig) (Encoder, error)
+
+var encoders = map[string]encoderFactory{
+	FormatJSON:       newJSONEncoder,
+	FormatStructured: newStructuredEncoder,
+	FormatLogfmt:     newLogfmtEncoder,
+	FormatCSV:        newCSVEncoder,
+	FormatTemplate:   newTemplateEncoder,
+}
+
+// recordFields are the fields of Record, written by the json format when
+// fields are renamed but not selected
+var recordFields = []string{
+	FieldSchemaVersion, FieldTimestamp, FieldSourceFile, FieldLineNumber, FieldOffset,
+	FieldOriginalLog, FieldExtractedCID, FieldCIDType, FieldLogTimestamp, FieldCorrelationID,
+	FieldUUID, FieldPattern, FieldSource, FieldProcessedAt, FieldMetadata,
+}
+
+// legacyFields are the fields of CIDEntry, written instead of recordFields
+// by the legacy schema
+var legacyFields = []string{
+	FieldCID, FieldUUID, FieldTimestamp, FieldLogFile, FieldRawMessage, FieldProcessedAt,
+}
+
+// defaultTextFields are the fields written by the logfmt and csv formats
+// unless selected otherwise
+var defaultTextFields = []string{
+	FieldTimestamp, FieldCID, FieldUUID, FieldPattern, FieldLogFile, FieldRawMessage,
+}
+
+// templateFields are the fields available to templates
+var templateFields = []string{
+	FieldCID, FieldUUID, FieldPattern, FieldSource, FieldFile, FieldLogFile, FieldPath,
+	FieldOffset, FieldTimestamp, FieldTimestampParsed, FieldRawMessage, FieldProcessedAt,
+	FieldMetadata, FieldSchemaVersion, FieldSourceFile, FieldLineNumber, FieldOriginalLog,
+	FieldExtractedCID, FieldCIDType, FieldLogTimestamp, FieldCorrelationID,
+}
+
+// NewEncoder creates the encoder for an output format, tuned by cfg, which
+// may be nil
+func NewEncoder(format string, cfg *models.EncoderConfig) (Encoder, error) {
+	if err := ValidateFormat(format); err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = &models.EncoderConfig{}
+	}
+
+	if format != FormatCSV && (cfg.Delimiter != "" || cfg.NoHeader) {
+		return nil, fmt.Errorf("encoder delimiter and no_header apply only to the %s format", FormatCSV)
+	}
+	if format != FormatTemplate && cfg.Template != "" {
+		return nil, fmt.Errorf("encoder template requires the %s format", FormatTemplate)
+	}
+	if err := ValidateSchema(cfg.Schema); err != nil {
+		return nil, err
+	}
+	if format != FormatJSON && cfg.Schema != "" {
+		return nil, fmt.Errorf("encoder schema applies only to the %s format", FormatJSON)
+	}
+	return encoders[format](*cfg)
+}
+
+// Formats returns the names of the supported output formats
+func Formats() []string {
+	formats := make([]string, 0, len(encoders))
+	for format := range encoders {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// formatOf returns the output format of a built-in encoder
+func formatOf(enc Encoder) string {
+	switch enc.(type) {
+	case *jsonEncoder:
+		return FormatJSON
+	case *structuredEncoder:
+		return FormatStructured
+	case *logfmtEncoder:
+		return FormatLogfmt
+	case *csvEncoder:
+		return FormatCSV
+	case *templateEncoder:
+		return FormatTemplate
+	default:
+		return ""
+	}
+}
+
+// column is a selected field and the name it is written under
+type column struct {
+	field string
+	name  string
+}
+
+// newColumns returns the fields selected by cfg, or defaults, under their
+// configured names
+func newColumns(cfg models.EncoderConfig, defaults []string) ([]column, error) {
+	fields := cfg.Fields
+	if len(fields) == 0 {
+		fields = defaults
+	}
+
+	columns := make([]column, 0, len(fields))
+	selected := make(map[string]bool, len(fields))
+	names := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if field == "" {
+			return nil, fmt.Errorf("encoder field names must not be empty")
+		}
+		name := field
+		if renamed, ok := cfg.Rename[field]; ok {
+			if renamed == "" {
+				return nil, fmt.Errorf("encoder field '%s' must not be renamed to an empty name", field)
+			}
+			name = renamed
+		}
+		if names[name] {
+			return nil, fmt.Errorf("encoder writes two fields as '%s'", name)
+		}
+		selected[field] = true
+		names[name] = true
+		columns = append(columns, column{field: field, name: name})
+	}
+
+	for field := range cfg.Rename {
+		if !selected[field] {
+			return nil, fmt.Errorf("encoder renames field '%s', which is not written", field)
+		}
+	}
+	return columns, nil
+}
+
+// noColumns rejects field selection for formats with a fixed layout
+func noColumns(format string, cfg models.EncoderConfig) error {
+	if len(cfg.Fields) > 0 || len(cfg.Rename) > 0 {
+		return fmt.Errorf("encoder fields and rename do not apply to the %s format", format)
+	}
+	return nil
+}
+
+// jsonEncoder writes one JSON document per record: the Record, the CIDEntry
+// of the legacy schema, or the selected fields in order
+type jsonEncoder struct {
+	legacy  bool
+	columns []column
+}
+
+func newJSONEncoder(cfg models.EncoderConfig) (Encoder, error) {
+	legacy := cfg.Schema == SchemaLegacy
+	if len(cfg.Fields) == 0 && len(cfg.Rename) == 0 {
+		return &jsonEncoder{legacy: legacy}, nil
+	}
+	defaults := recordFields
+	if legacy {
+		defaults = legacyFields
+	}
+	columns, err := newColumns(cfg, defaults)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonEncoder{legacy: legacy, columns: columns}, nil
+}
+
+func (e *jsonEncoder) Encode(record models.CIDRecord) ([]byte, error) {
+	if e.columns == nil {
+		var doc any = NewRecord(record)
+		if e.legacy {
+			doc = NewCIDEntry(record)
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode CID entry: %w", err)
+		}
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, c := range e.columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(c.name)
+		value, err := json.Marshal(fieldData(record, c.field))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode CID entry: %w", err)
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (e *jsonEncoder) Header() []byte {
+	return nil
+}
+
+func (e *jsonEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// structuredEncoder writes the timestamp, CID and file name of a record as
+// one line of text
+type structuredEncoder struct{}
+
+func newStructuredEncoder(cfg models.EncoderConfig) (Encoder, error) {
+	if err := noColumns(FormatStructured, cfg); err != nil {
+		return nil, err
+	}
+	return &structuredEncoder{}, nil
+}
+
+func (e *structuredEncoder) Encode(record models.CIDRecord) ([]byte, error) {
+	entry := NewCIDEntry(record)
+	return []byte(fmt.Sprintf("[%s] CID:%s FILE:%s",
+		entry.Timestamp.Format(time.RFC3339),
+		entry.CID,
+		entry.LogFile)), nil
+}
+
+func (e *structuredEncoder) Header() []byte {
+	return nil
+}
+
+func (e *structuredEncoder) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// logfmtEncoder writes the selected fields as key=value pairs, leaving out
+// empty values and quoting values that need it
+type logfmtEncoder struct {
+	columns []column
+}
+
+func newLogfmtEncoder(cfg models.EncoderConfig) (Encoder, error) {
+	columns, err := newColumns(cfg, defaultTextFields)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range columns {
+		if !validLogfmtKey(c.name) {
+			return nil, fmt.Errorf("invalid logfmt key '%s': must not contain spaces, quotes, '=' or backslashes", c.name)
+		}
+	}
+	return &logfmtEncoder{columns: columns}, nil
+}
+
+func (e *logfmtEncoder) Encode(record models.CIDRecord) ([]byte, error) {
+	var b []byte
+	for _, c := range e.columns {
+		value := fieldValue(record, c.field)
+		if value == "" {
+			continue
+		}
+		if len(b) > 0 {
+			b = append(b, ' ')
+		}
+		b = append(b, c.name...)
+		b = append(b, '=')
+		if logfmtNeedsQuotes(value) {
+			b = strconv.AppendQuote(b, value)
+		} else {
+			b = append(b, value...)
+		}
+	}
+	return b, nil
+}
+
+func (e *logfmtEncoder) Header() []byte {
+	return nil
+}
+
+func (e *logfmtEncoder) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// validLogfmtKey reports whether key can be written unquoted
+func validLogfmtKey(key string) bool {
+	return key != "" && !logfmtNeedsQuotes(key)
+}
+
+// logfmtNeedsQuotes reports whether value must be quoted to stay one value
+func logfmtNeedsQuotes(value string) bool {
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// csvEncoder writes the selected fields as one CSV row per record, after a
+// header row of their names
+type csvEncoder struct {
+	columns []column
+	comma   rune
+	header  []byte
+}
+
+func newCSVEncoder(cfg models.EncoderConfig) (Encoder, error) {
+	columns, err := newColumns(cfg, defaultTextFields)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &csvEncoder{columns: columns, comma: ','}
+	if cfg.Delimiter != "" {
+		r, size := utf8.DecodeRuneInString(cfg.Delimiter)
+		if size != len(cfg.Delimiter) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+			return nil, fmt.Errorf("invalid csv delimiter '%s': must be one character other than a quote or line break", cfg.Delimiter)
+		}
+		e.comma = r
+	}
+
+	if !cfg.NoHeader {
+		names := make([]string, len(columns))
+		for i, c := range columns {
+			names[i] = c.name
+		}
+		if e.header, err = e.row(names); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (e *csvEncoder) Encode(record models.CIDRecord) ([]byte, error) {
+	values := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		values[i] = fieldValue(record, c.field)
+	}
+	return e.row(values)
+}
+
+// row renders one CSV row without the line break
+func (e *csvEncoder) row(values []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = e.comma
+	if err := w.Write(values); err != nil {
+		return nil, fmt.Errorf("failed to encode CSV row: %w", err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to encode CSV row: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+func (e *csvEncoder) Header() []byte {
+	return e.header
+}
+
+func (e *csvEncoder) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+// builtinTemplates are templates of the template format selected by name in
+// place of the template text. structured_kv is the key=value layout that
+// earlier documentation gave for the structured format.
+var builtinTemplates = map[string]string{
+	"structured_kv": `[{{.timestamp.UTC.Format "2006-01-02T15:04:05.000Z07:00"}}] CID={{.extracted_cid}} FILE={{.source_file}}{{with .line_number}} LINE={{.}}{{end}} TYPE={{.cid_type}}`,
+}
+
+// templateEncoder renders each record with a text/template. The template
+// sees every field by name, e.g. {{.cid}} or {{index .metadata "pod"}}, and
+// can encode a value as JSON with {{json .raw_message}}.
+type templateEncoder struct {
+	tmpl *template.Template
+}
+
+func newTemplateEncoder(cfg models.EncoderConfig) (Encoder, error) {
+	if err := noColumns(FormatTemplate, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Template == "" {
+		return nil, fmt.Errorf("the %s format requires an encoder template", FormatTemplate)
+	}
+	text := cfg.Template
+	if builtin, ok := builtinTemplates[text]; ok {
+		text = builtin
+	}
+
+	tmpl, err := template.New("record").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"json": templateJSON}).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encoder template: %w", err)
+	}
+
+	// Rendering an empty record finds misspelt fields before the first record
+	e := &templateEncoder{tmpl: tmpl}
+	if _, err := e.Encode(models.CIDRecord{}); err != nil {
+		return nil, fmt.Errorf("invalid encoder template: %w", err)
+	}
+	return e, nil
+}
+
+func (e *templateEncoder) Encode(record models.CIDRecord) ([]byte, error) {
+	data := make(map[string]any, len(templateFields))
+	for _, field := range templateFields {
+		data[field] = fieldData(record, field)
+	}
+
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render encoder template: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+func (e *templateEncoder) Header() []byte {
+	return nil
+}
+
+func (e *templateEncoder) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// templateJSON encodes v as JSON for templates
+func templateJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
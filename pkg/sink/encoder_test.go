@@ -0,0 +1,265 @@
+package sink
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// testEncoder returns the encoder for format with its default options
+func testEncoder(t *testing.T, format string) Encoder {
+	t.Helper()
+	enc, err := NewEncoder(format, nil)
+	if err != nil {
+		t.Fatalf("NewEncoder(%q) error = %v", format, err)
+	}
+	return enc
+}
+
+// encoderRecord is a record with every field set
+func encoderRecord() models.CIDRecord {
+	return models.CIDRecord{
+		CID:             "req-550e8400-e29b-51d4-a716-446655440000",
+		UUID:            "550e8400-e29b-51d4-a716-446655440000",
+		Timestamp:       time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC),
+		TimestampParsed: true,
+		RawLogLine:      `ERROR payment failed, code="card_declined" CID:req-550e8400-e29b-51d4-a716-446655440000`,
+		IsValid:         true,
+		PatternName:     "standard_cid",
+		ExtractedAt:     time.Date(2024, 3, 5, 10, 11, 13, 0, time.UTC),
+		Metadata:        map[string]string{"pod": "api-0", "stream": "stderr"},
+		Source:          "/var/log/app/payments.log",
+		SourceName:      "app",
+		Offset:          4096,
+		LineNumber:      87,
+	}
+}
+
+func TestEncoder_JSON(t *testing.T) {
+	record := encoderRecord()
+
+	tests := []struct {
+		name string
+		cfg  *models.EncoderConfig
+		want string
+	}{
+		{
+			name: "fields",
+			cfg:  &models.EncoderConfig{Fields: []string{"cid", "offset", "timestamp_parsed", "pod"}},
+			want: `{"cid":"req-550e8400-e29b-51d4-a716-446655440000","offset":4096,"timestamp_parsed":true,"pod":"api-0"}`,
+		},
+		{
+			name: "renamed",
+			cfg: &models.EncoderConfig{
+				Fields: []string{"timestamp", "cid", "metadata"},
+				Rename: map[string]string{"timestamp": "@timestamp", "cid": "correlation_id"},
+			},
+			want: `{"@timestamp":"2024-03-05T10:11:12Z","correlation_id":"req-550e8400-e29b-51d4-a716-446655440000","metadata":{"pod":"api-0","stream":"stderr"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := NewEncoder(FormatJSON, tt.cfg)
+			if err != nil {
+				t.Fatalf("NewEncoder() error = %v", err)
+			}
+			data, err := enc.Encode(record)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Encode() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+
+	// Renaming without selecting fields keeps the fields of the default document
+	enc, _ := NewEncoder(FormatJSON, &models.EncoderConfig{Rename: map[string]string{"source_file": "file_name"}})
+	data, _ := enc.Encode(record)
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Encode() = %s, not JSON: %v", data, err)
+	}
+	if doc["file_name"] != record.Source || doc["source_file"] != nil || doc["original_log"] != record.RawLogLine || doc["schema_version"] != 1.0 {
+		t.Errorf("Encode() = %s, want the default document with source_file renamed", data)
+	}
+
+	// Without options the output is the Record document
+	want, _ := json.Marshal(NewRecord(record))
+	if data, _ := testEncoder(t, FormatJSON).Encode(record); string(data) != string(want) {
+		t.Errorf("Encode() = %s, want %s", data, want)
+	}
+
+	// The legacy schema writes the CIDEntry document
+	enc, _ = NewEncoder(FormatJSON, &models.EncoderConfig{Schema: SchemaLegacy})
+	want, _ = json.Marshal(NewCIDEntry(record))
+	if data, _ := enc.Encode(record); string(data) != string(want) {
+		t.Errorf("Encode() = %s, want %s", data, want)
+	}
+	enc, _ = NewEncoder(FormatJSON, &models.EncoderConfig{Schema: SchemaLegacy, Rename: map[string]string{"log_file": "file_name"}})
+	data, _ = enc.Encode(record)
+	doc = nil
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Encode() = %s, not JSON: %v", data, err)
+	}
+	if doc["file_name"] != "payments.log" || doc["log_file"] != nil || doc["raw_message"] != record.RawLogLine {
+		t.Errorf("Encode() = %s, want the legacy document with log_file renamed", data)
+	}
+}
+
+func TestEncoder_Logfmt(t *testing.T) {
+	record := encoderRecord()
+	record.UUID = ""
+
+	enc := testEncoder(t, FormatLogfmt)
+	data, err := enc.Encode(record)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := `timestamp=2024-03-05T10:11:12Z cid=req-550e8400-e29b-51d4-a716-446655440000 pattern=standard_cid ` +
+		`log_file=payments.log raw_message="ERROR payment failed, code=\"card_declined\" CID:req-550e8400-e29b-51d4-a716-446655440000"`
+	if string(data) != want {
+		t.Errorf("Encode() =\n%s\nwant\n%s", data, want)
+	}
+
+	enc, err = NewEncoder(FormatLogfmt, &models.EncoderConfig{
+		Fields: []string{"cid", "pod", "container", "path"},
+		Rename: map[string]string{"cid": "trace_id"},
+	})
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	data, _ = enc.Encode(record)
+	if want := "trace_id=req-550e8400-e29b-51d4-a716-446655440000 pod=api-0 path=/var/log/app/payments.log"; string(data) != want {
+		t.Errorf("Encode() = %s, want %s", data, want)
+	}
+}
+
+func TestEncoder_CSV(t *testing.T) {
+	record := encoderRecord()
+
+	enc, err := NewEncoder(FormatCSV, &models.EncoderConfig{
+		Fields:    []string{"timestamp", "cid", "raw_message", "offset"},
+		Rename:    map[string]string{"raw_message": "line"},
+		Delimiter: ";",
+	})
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	if got := string(enc.Header()); got != "timestamp;cid;line;offset" {
+		t.Errorf("Header() = %s, want timestamp;cid;line;offset", got)
+	}
+	if enc.ContentType() != "text/csv; charset=utf-8" {
+		t.Errorf("ContentType() = %s, want text/csv", enc.ContentType())
+	}
+
+	record.RawLogLine = "first line\n\tat second line; \"quoted\""
+	data, err := enc.Encode(record)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	r := csv.NewReader(strings.NewReader(string(data)))
+	r.Comma = ';'
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("Encode() = %q is not CSV: %v", data, err)
+	}
+	want := []string{"2024-03-05T10:11:12Z", record.CID, record.RawLogLine, "4096"}
+	if len(rows) != 1 || strings.Join(rows[0], "|") != strings.Join(want, "|") {
+		t.Errorf("Encode() = %q, want one row %q", rows, want)
+	}
+
+	enc, _ = NewEncoder(FormatCSV, &models.EncoderConfig{NoHeader: true})
+	if enc.Header() != nil {
+		t.Errorf("Header() = %s, want none", enc.Header())
+	}
+}
+
+func TestEncoder_Template(t *testing.T) {
+	enc, err := NewEncoder(FormatTemplate, &models.EncoderConfig{
+		Template: `{{.timestamp.Format "15:04:05"}} {{.cid}} {{index .metadata "pod"}} {{json .raw_message}} {{.source_file}}:{{.line_number}}` + "\n",
+	})
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	data, err := enc.Encode(encoderRecord())
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := `10:11:12 req-550e8400-e29b-51d4-a716-446655440000 api-0 "ERROR payment failed, code=\"card_declined\" CID:req-550e8400-e29b-51d4-a716-446655440000" /var/log/app/payments.log:87`
+	if string(data) != want {
+		t.Errorf("Encode() = %s, want %s", data, want)
+	}
+}
+
+func TestEncoder_BuiltinTemplate(t *testing.T) {
+	enc, err := NewEncoder(FormatTemplate, &models.EncoderConfig{Template: "structured_kv"})
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+
+	record := encoderRecord()
+	record.Timestamp = time.Date(2024, 3, 5, 11, 11, 12, 123000000, time.FixedZone("CET", 3600))
+	data, err := enc.Encode(record)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := "[2024-03-05T10:11:12.123Z] CID=req-550e8400-e29b-51d4-a716-446655440000 FILE=/var/log/app/payments.log LINE=87 TYPE=uuid_v5"
+	if string(data) != want {
+		t.Errorf("Encode() = %s, want %s", data, want)
+	}
+
+	// LINE is left out when the line number is unknown
+	record.LineNumber = 0
+	data, _ = enc.Encode(record)
+	want = "[2024-03-05T10:11:12.123Z] CID=req-550e8400-e29b-51d4-a716-446655440000 FILE=/var/log/app/payments.log TYPE=uuid_v5"
+	if string(data) != want {
+		t.Errorf("Encode() = %s, want %s", data, want)
+	}
+}
+
+func TestNewEncoder_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		cfg    *models.EncoderConfig
+	}{
+		{"unknown format", "xml", nil},
+		{"empty field", FormatLogfmt, &models.EncoderConfig{Fields: []string{"cid", ""}}},
+		{"duplicate name", FormatCSV, &models.EncoderConfig{Fields: []string{"cid", "uuid"}, Rename: map[string]string{"uuid": "cid"}}},
+		{"rename unselected", FormatJSON, &models.EncoderConfig{Fields: []string{"cid"}, Rename: map[string]string{"uuid": "id"}}},
+		{"rename to empty", FormatJSON, &models.EncoderConfig{Rename: map[string]string{"cid": ""}}},
+		{"logfmt key with space", FormatLogfmt, &models.EncoderConfig{Rename: map[string]string{"cid": "trace id"}}},
+		{"structured fields", FormatStructured, &models.EncoderConfig{Fields: []string{"cid"}}},
+		{"template fields", FormatTemplate, &models.EncoderConfig{Template: "{{.cid}}", Fields: []string{"cid"}}},
+		{"missing template", FormatTemplate, nil},
+		{"template syntax", FormatTemplate, &models.EncoderConfig{Template: "{{.cid"}},
+		{"template unknown field", FormatTemplate, &models.EncoderConfig{Template: "{{.trace_id}}"}},
+		{"template for json", FormatJSON, &models.EncoderConfig{Template: "{{.cid}}"}},
+		{"unknown schema", FormatJSON, &models.EncoderConfig{Schema: "v2"}},
+		{"schema for csv", FormatCSV, &models.EncoderConfig{Schema: SchemaLegacy}},
+		{"delimiter for logfmt", FormatLogfmt, &models.EncoderConfig{Delimiter: ";"}},
+		{"long delimiter", FormatCSV, &models.EncoderConfig{Delimiter: ";;"}},
+		{"quote delimiter", FormatCSV, &models.EncoderConfig{Delimiter: `"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewEncoder(tt.format, tt.cfg); err == nil {
+				t.Error("NewEncoder() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	want := "csv, json, logfmt, structured, template"
+	if got := strings.Join(Formats(), ", "); got != want {
+		t.Errorf("Formats() = %s, want %s", got, want)
+	}
+}
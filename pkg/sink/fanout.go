@@ -0,0 +1,345 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"cidtracker/pkg/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// closeTimeout is how long Close waits for queued records to be written before
+// cancelling the writes still in progress, e.g. retries against a dead endpoint
+const closeTimeout = 30 * time.Second
+
+// Policies for records that arrive while a sink's queue is full
+const (
+	// OverflowBlock waits until the queue has room, holding up the pipeline
+	OverflowBlock = "block"
+	// OverflowDrop discards the record and counts it as dropped
+	OverflowDrop = "drop"
+)
+
+// Stats holds the counters of a single sink
+type Stats struct {
+	Name    string
+	Type    string
+	Written int64
+	Errors  int64
+	Dropped int64
+	Queued  int
+}
+
+// output is a sink together with its filter, queue and counters
+type output struct {
+	name   string
+	typ    string
+	sink   Sink
+	filter *Filter
+	// flushInterval overrides the interval passed to Start when set
+	flushInterval time.Duration
+	// dropOnFull discards records while the queue is full instead of waiting
+	dropOnFull bool
+
+	queue   chan models.CIDRecord
+	flushes chan chan error
+	done    chan struct{}
+	closed  error
+
+	written atomic.Int64
+	errors  atomic.Int64
+	dropped atomic.Int64
+	// dropping is set while the queue is full, so the first dropped record
+	// is logged rather than every one
+	dropping atomic.Bool
+}
+
+// Fanout writes each record to every sink whose filter accepts it. Once
+// started, every sink has its own queue and goroutine, so a slow or failing
+// sink only holds up the others once its queue is full. Then Write waits for
+// room, or drops and counts the record if the sink's overflow policy is
+// OverflowDrop. Until then, records are written on the calling goroutine.
+type Fanout struct {
+	outputs []*output
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started bool
+}
+
+// NewFanout creates a fanout without sinks
+func NewFanout() *Fanout {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Fanout{ctx: ctx, cancel: cancel}
+}
+
+// NewFanoutFromConfig builds every configured sink and its filter
+func NewFanoutFromConfig(configs []models.SinkConfig) (*Fanout, error) {
+	f := NewFanout()
+	for _, cfg := range configs {
+		filter, err := NewFilter(cfg.Filter)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("sink '%s': %w", cfg.Name, err)
+		}
+		s, err := New(cfg)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		f.Add(cfg.Name, cfg.Type, s, filter)
+		o := f.outputs[len(f.outputs)-1]
+		o.flushInterval = cfg.FlushInterval
+		o.dropOnFull = cfg.Overflow == OverflowDrop
+	}
+	return f, nil
+}
+
+// Add registers a sink under name. A nil filter accepts every record. Add
+// must be called before Start.
+func (f *Fanout) Add(name, typ string, s Sink, filter *Filter) {
+	f.outputs = append(f.outputs, &output{
+		name:   name,
+		typ:    typ,
+		sink:   s,
+		filter: filter,
+	})
+}
+
+// Names returns the names of the sinks in the order they were added
+func (f *Fanout) Names() []string {
+	names := make([]string, 0, len(f.outputs))
+	for _, o := range f.outputs {
+		names = append(names, o.name)
+	}
+	return names
+}
+
+// Start gives every sink a queue of queueSize records and a goroutine that
+// writes them and flushes the sink every flushInterval, or at the sink's own
+// configured interval
+func (f *Fanout) Start(queueSize int, flushInterval time.Duration) {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	for _, o := range f.outputs {
+		interval := flushInterval
+		if o.flushInterval > 0 {
+			interval = o.flushInterval
+		}
+		o.queue = make(chan models.CIDRecord, queueSize)
+		o.flushes = make(chan chan error)
+		o.done = make(chan struct{})
+		go o.run(f.ctx, interval)
+	}
+	f.started = true
+}
+
+// Write hands a record to every sink whose filter accepts it. Before Start it
+// returns the errors of the sinks; afterwards, failures are only counted in
+// the sinks' statistics. A full queue blocks Write until there is room or ctx
+// is done, unless the sink drops records on overflow. Write must not be
+// called after Close.
+func (f *Fanout) Write(ctx context.Context, record models.CIDRecord) error {
+	var errs []error
+	for _, o := range f.outputs {
+		if !o.filter.Match(record) {
+			continue
+		}
+
+		if !f.started {
+			if err := o.write(ctx, record); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if !o.dropOnFull {
+			select {
+			case o.queue <- record:
+			case <-ctx.Done():
+				errs = append(errs, fmt.Errorf("sink '%s': %w", o.name, ctx.Err()))
+				return errors.Join(errs...)
+			}
+			continue
+		}
+
+		select {
+		case o.queue <- record:
+			o.dropping.Store(false)
+		default:
+			o.dropped.Add(1)
+			if !o.dropping.Swap(true) {
+				log.WithField("sink", o.name).Warn("Sink queue is full, dropping records")
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush writes out the records queued for every sink, then asks each sink to
+// write out the records it has buffered. Once it returns nil, every record
+// handed to Write before the call has been written, or dropped on overflow.
+func (f *Fanout) Flush(ctx context.Context) error {
+	var errs []error
+	for _, o := range f.outputs {
+		if !f.started {
+			if err := o.flush(ctx); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		reply := make(chan error, 1)
+		select {
+		case o.flushes <- reply:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case err := <-reply:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close writes out the queued records, then flushes and closes every sink.
+// Writes still running after a grace period are cancelled.
+func (f *Fanout) Close() error {
+	defer f.cancel()
+
+	var errs []error
+	if !f.started {
+		for _, o := range f.outputs {
+			if err := o.close(f.ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, o := range f.outputs {
+		close(o.queue)
+	}
+
+	timeout := time.NewTimer(closeTimeout)
+	defer timeout.Stop()
+	for _, o := range f.outputs {
+		select {
+		case <-o.done:
+		case <-timeout.C:
+			log.WithField("sink", o.name).Warn("Timed out writing queued records, cancelling")
+			f.cancel()
+			<-o.done
+		}
+		if o.closed != nil {
+			errs = append(errs, o.closed)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns the counters of every sink in the order they were added
+func (f *Fanout) Stats() []Stats {
+	stats := make([]Stats, 0, len(f.outputs))
+	for _, o := range f.outputs {
+		stats = append(stats, Stats{
+			Name:    o.name,
+			Type:    o.typ,
+			Written: o.written.Load(),
+			Errors:  o.errors.Load(),
+			Dropped: o.dropped.Load(),
+			Queued:  len(o.queue),
+		})
+	}
+	return stats
+}
+
+// run writes queued records until the queue is closed, flushing the sink
+// every flushInterval and on request
+func (o *output) run(ctx context.Context, flushInterval time.Duration) {
+	defer close(o.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case record, ok := <-o.queue:
+			if !ok {
+				o.closed = o.close(ctx)
+				return
+			}
+			o.writeQueued(ctx, record)
+		case reply := <-o.flushes:
+			o.drain(ctx)
+			reply <- o.flush(ctx)
+		case <-ticker.C:
+			if err := o.flush(ctx); err != nil {
+				log.WithError(err).WithField("sink", o.name).Warn("Failed to flush sink")
+			}
+		}
+	}
+}
+
+// drain writes the records waiting in the queue without blocking for more
+func (o *output) drain(ctx context.Context) {
+	for {
+		select {
+		case record, ok := <-o.queue:
+			if !ok {
+				return
+			}
+			o.writeQueued(ctx, record)
+		default:
+			return
+		}
+	}
+}
+
+// writeQueued writes a record taken from the queue, logging a failure
+func (o *output) writeQueued(ctx context.Context, record models.CIDRecord) {
+	if err := o.write(ctx, record); err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"sink": o.name,
+			"cid":  record.CID,
+		}).Warn("Failed to write CID record")
+	}
+}
+
+// write passes a record to the sink and counts the outcome
+func (o *output) write(ctx context.Context, record models.CIDRecord) error {
+	if err := o.sink.Write(ctx, record); err != nil {
+		o.errors.Add(1)
+		return fmt.Errorf("sink '%s': %w", o.name, err)
+	}
+	o.written.Add(1)
+	return nil
+}
+
+// flush flushes the sink and counts a failure
+func (o *output) flush(ctx context.Context) error {
+	if err := o.sink.Flush(ctx); err != nil {
+		o.errors.Add(1)
+		return fmt.Errorf("sink '%s': %w", o.name, err)
+	}
+	return nil
+}
+
+// close flushes and closes the sink
+func (o *output) close(ctx context.Context) error {
+	flushErr := o.flush(ctx)
+	if err := o.sink.Close(); err != nil {
+		o.errors.Add(1)
+		return errors.Join(flushErr, fmt.Errorf("sink '%s': %w", o.name, err))
+	}
+	return flushErr
+}
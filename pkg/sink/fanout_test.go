@@ -0,0 +1,276 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// memorySink records what it receives. Writes block while block is open.
+type memorySink struct {
+	mu      sync.Mutex
+	records []models.CIDRecord
+	flushes int
+	closed  bool
+	err     error
+	block   chan struct{}
+}
+
+func (s *memorySink) Write(ctx context.Context, record models.CIDRecord) error {
+	if s.block != nil {
+		select {
+		case <-s.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memorySink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushes++
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *memorySink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+func testRecord(n int, pattern string) models.CIDRecord {
+	return models.CIDRecord{
+		CID:         fmt.Sprintf("550e8400-e29b-51d4-a716-%012d", n),
+		PatternName: pattern,
+		Source:      "/var/log/app/app.log",
+	}
+}
+
+func TestFanout_Inline(t *testing.T) {
+	all, failing, jsonOnly := &memorySink{}, &memorySink{err: errors.New("disk full")}, &memorySink{}
+	jsonFilter, _ := NewFilter(&models.SinkFilter{Patterns: []string{"json_cid"}})
+
+	f := NewFanout()
+	f.Add("all", "memory", all, nil)
+	f.Add("failing", "memory", failing, nil)
+	f.Add("json", "memory", jsonOnly, jsonFilter)
+
+	err := f.Write(context.Background(), testRecord(1, "standard_cid"))
+	if err == nil {
+		t.Error("Write() error = nil, want the failing sink's error")
+	}
+	if err := f.Write(context.Background(), testRecord(2, "json_cid")); err == nil {
+		t.Error("Write() error = nil, want the failing sink's error")
+	}
+
+	if all.count() != 2 {
+		t.Errorf("all received %d records, want 2", all.count())
+	}
+	if jsonOnly.count() != 1 || jsonOnly.records[0].PatternName != "json_cid" {
+		t.Errorf("json received %+v, want only the json_cid record", jsonOnly.records)
+	}
+
+	stats := f.Stats()
+	if stats[0].Written != 2 || stats[0].Errors != 0 {
+		t.Errorf("all stats = %+v, want 2 written", stats[0])
+	}
+	if stats[1].Written != 0 || stats[1].Errors != 2 {
+		t.Errorf("failing stats = %+v, want 2 errors", stats[1])
+	}
+
+	if err := f.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if !all.closed || !failing.closed || !jsonOnly.closed {
+		t.Error("Close() did not close every sink")
+	}
+}
+
+func TestFanout_SlowSinkDoesNotBlockOthers(t *testing.T) {
+	fast, slow := &memorySink{}, &memorySink{block: make(chan struct{})}
+
+	f := NewFanout()
+	f.Add("fast", "memory", fast, nil)
+	f.Add("slow", "memory", slow, nil)
+	f.outputs[1].dropOnFull = true
+	f.Start(10, time.Hour)
+
+	// Each record reaches the fast sink while the slow one has not written any
+	for i := 0; i < 100; i++ {
+		if err := f.Write(context.Background(), testRecord(i, "standard_cid")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for fast.count() < i+1 {
+			if time.Now().After(deadline) {
+				t.Fatalf("fast received %d records, want %d", fast.count(), i+1)
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}
+
+	close(slow.block)
+	if err := f.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	stats := f.Stats()
+	if stats[1].Dropped == 0 {
+		t.Error("slow sink dropped no records despite its full queue")
+	}
+	if got := stats[1].Written + stats[1].Dropped; got != 100 {
+		t.Errorf("slow written + dropped = %d, want 100", got)
+	}
+	if !slow.closed {
+		t.Error("Close() did not close the slow sink")
+	}
+}
+
+func TestFanout_FullQueueBlocks(t *testing.T) {
+	s := &memorySink{block: make(chan struct{})}
+	f := NewFanout()
+	f.Add("slow", "memory", s, nil)
+	f.Start(1, time.Hour)
+
+	// One record is being written and one is queued, so the third waits
+	written := make(chan error, 1)
+	go func() {
+		for i := 0; i < 3; i++ {
+			if err := f.Write(context.Background(), testRecord(i, "standard_cid")); err != nil {
+				written <- err
+				return
+			}
+		}
+		written <- nil
+	}()
+	select {
+	case err := <-written:
+		t.Fatalf("Write() = %v with a full queue, want it to wait", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A cancelled write gives up without waiting for room
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.Write(ctx, testRecord(3, "standard_cid")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Write() error = %v, want context.Canceled", err)
+	}
+
+	close(s.block)
+	if err := <-written; err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if stats := f.Stats(); stats[0].Written != 3 || stats[0].Dropped != 0 {
+		t.Errorf("stats = %+v, want 3 written and none dropped", stats[0])
+	}
+}
+
+func TestFanout_CloseDrainsQueue(t *testing.T) {
+	s := &memorySink{}
+	f := NewFanout()
+	f.Add("memory", "memory", s, nil)
+	f.Start(100, time.Hour)
+
+	for i := 0; i < 50; i++ {
+		f.Write(context.Background(), testRecord(i, "standard_cid"))
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if s.count() != 50 {
+		t.Errorf("sink received %d records, want 50", s.count())
+	}
+	for i, record := range s.records {
+		if want := testRecord(i, "").CID; record.CID != want {
+			t.Fatalf("record %d = %s, want %s", i, record.CID, want)
+		}
+	}
+	if s.flushes == 0 || !s.closed {
+		t.Errorf("flushes = %d, closed = %v, want the sink flushed and closed", s.flushes, s.closed)
+	}
+}
+
+func TestFanout_Flush(t *testing.T) {
+	s := &memorySink{}
+	f := NewFanout()
+	f.Add("memory", "memory", s, nil)
+	f.Start(10, time.Hour)
+	defer f.Close()
+
+	if err := f.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	s.mu.Lock()
+	flushes := s.flushes
+	s.mu.Unlock()
+	if flushes != 1 {
+		t.Errorf("flushes = %d, want 1", flushes)
+	}
+}
+
+func TestFanout_FlushInterval(t *testing.T) {
+	s := &memorySink{}
+	f := NewFanout()
+	f.Add("memory", "memory", s, nil)
+	f.Start(10, 10*time.Millisecond)
+	defer f.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		flushes := s.flushes
+		s.mu.Unlock()
+		if flushes > 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("sink was not flushed within the flush interval")
+}
+
+func TestNewFanoutFromConfig(t *testing.T) {
+	f, err := NewFanoutFromConfig([]models.SinkConfig{
+		{Name: "json", Type: TypeStdout, Format: FormatJSON},
+		{Name: "text", Type: TypeStdout, Format: FormatStructured, Filter: &models.SinkFilter{Patterns: []string{"standard_cid"}}, Overflow: OverflowDrop},
+	})
+	if err != nil {
+		t.Fatalf("NewFanoutFromConfig() error = %v", err)
+	}
+	defer f.Close()
+
+	if names := f.Names(); len(names) != 2 || names[0] != "json" || names[1] != "text" {
+		t.Errorf("Names() = %v, want [json text]", names)
+	}
+	if f.outputs[0].dropOnFull || !f.outputs[1].dropOnFull {
+		t.Error("only the sink with the drop overflow policy should drop records")
+	}
+
+	if _, err := NewFanoutFromConfig([]models.SinkConfig{{Name: "bad", Type: "unknown"}}); err == nil {
+		t.Error("NewFanoutFromConfig() error = nil for an unknown type")
+	}
+}
@@ -0,0 +1,156 @@
+package sink
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// Record fields that sinks can select by name, e.g. as Loki labels
+const (
+	// FieldCID is the CID value
+	FieldCID = "cid"
+	// FieldUUID is the UUID found in the CID
+	FieldUUID = "uuid"
+	// FieldPattern is the name of the pattern that matched the CID
+	FieldPattern = "pattern"
+	// FieldSource is the name of the log source the line was read from
+	FieldSource = "source"
+	// FieldFile is the name of the log file the line was read from
+	FieldFile = "file"
+	// FieldLogFile is FieldFile under its name in JSON output
+	FieldLogFile = "log_file"
+	// FieldPath is the full path of the log file the line was read from
+	FieldPath = "path"
+	// FieldOffset is the byte offset of the line in its log file
+	FieldOffset = "offset"
+	// FieldTimestamp is the time the line was logged, or read if unknown
+	FieldTimestamp = "timestamp"
+	// FieldTimestampParsed tells whether the timestamp was parsed from the line
+	FieldTimestampParsed = "timestamp_parsed"
+	// FieldRawMessage is the log line the CID was found in
+	FieldRawMessage = "raw_message"
+	// FieldProcessedAt is the time the line was read
+	FieldProcessedAt = "processed_at"
+	// FieldMetadata is all metadata set by the decoder
+	FieldMetadata = "metadata"
+)
+
+// Fields of Record that are not named above
+const (
+	// FieldSchemaVersion is SchemaVersion
+	FieldSchemaVersion = "schema_version"
+	// FieldSourceFile is FieldPath under its name in Record
+	FieldSourceFile = "source_file"
+	// FieldLineNumber is the 1-based number of the line in its log file
+	FieldLineNumber = "line_number"
+	// FieldOriginalLog is FieldRawMessage under its name in Record
+	FieldOriginalLog = "original_log"
+	// FieldExtractedCID is FieldCID under its name in Record
+	FieldExtractedCID = "extracted_cid"
+	// FieldCIDType is the kind of CID, e.g. uuid_v5
+	FieldCIDType = "cid_type"
+	// FieldLogTimestamp is the time the line was logged, or empty if unknown
+	FieldLogTimestamp = "log_timestamp"
+	// FieldCorrelationID is the correlation ID derived from the UUID
+	FieldCorrelationID = "correlation_id"
+)
+
+// fieldData returns the value of the named record field, keeping times,
+// booleans, numbers and the metadata map typed for structured encoders. Any
+// other name is looked up in the record's metadata, e.g. pod or namespace.
+func fieldData(record models.CIDRecord, name string) any {
+	switch name {
+	case FieldSchemaVersion:
+		return SchemaVersion
+	case FieldOffset:
+		return record.Offset
+	case FieldLineNumber:
+		return record.LineNumber
+	case FieldLogTimestamp:
+		if !record.TimestampParsed {
+			return nil
+		}
+		return record.Timestamp
+	case FieldTimestamp:
+		return record.Timestamp
+	case FieldTimestampParsed:
+		return record.TimestampParsed
+	case FieldProcessedAt:
+		return record.ExtractedAt
+	case FieldMetadata:
+		return record.Metadata
+	default:
+		return fieldValue(record, name)
+	}
+}
+
+// fieldValue returns the value of the named record field as text. Times are
+// given in RFC 3339 and, like unknown line numbers, left empty if unset;
+// metadata is a JSON object. Any other name is looked up in the record's
+// metadata, e.g. pod or namespace.
+func fieldValue(record models.CIDRecord, name string) string {
+	switch name {
+	case FieldSchemaVersion:
+		return strconv.Itoa(SchemaVersion)
+	case FieldCID, FieldExtractedCID:
+		return record.CID
+	case FieldUUID:
+		return record.UUID
+	case FieldPattern:
+		return record.PatternName
+	case FieldSource:
+		return record.SourceName
+	case FieldFile, FieldLogFile:
+		if record.Source == "" {
+			return ""
+		}
+		return filepath.Base(record.Source)
+	case FieldPath, FieldSourceFile:
+		return record.Source
+	case FieldRawMessage, FieldOriginalLog:
+		return record.RawLogLine
+	case FieldCIDType:
+		return cidType(record.UUID)
+	case FieldCorrelationID:
+		return correlationID(record.UUID)
+	case FieldOffset:
+		return strconv.FormatInt(record.Offset, 10)
+	case FieldLineNumber:
+		if record.LineNumber <= 0 {
+			return ""
+		}
+		return strconv.FormatInt(record.LineNumber, 10)
+	case FieldTimestamp:
+		return formatFieldTime(record.Timestamp)
+	case FieldTimestampParsed:
+		return strconv.FormatBool(record.TimestampParsed)
+	case FieldLogTimestamp:
+		if !record.TimestampParsed {
+			return ""
+		}
+		return formatFieldTime(record.Timestamp)
+	case FieldProcessedAt:
+		return formatFieldTime(record.ExtractedAt)
+	case FieldMetadata:
+		if len(record.Metadata) == 0 {
+			return ""
+		}
+		data, _ := json.Marshal(record.Metadata)
+		return string(data)
+	default:
+		return record.Metadata[name]
+	}
+}
+
+// formatFieldTime formats t as RFC 3339 with as many fractional digits as
+// needed, or returns an empty string for the zero time
+func formatFieldTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,53 @@
+package sink
+
+import (
+	"testing"
+
+	"cidtracker/pkg/models"
+)
+
+func TestFieldValue(t *testing.T) {
+	record := models.CIDRecord{
+		CID:         "req-550e8400-e29b-51d4-a716-446655440000",
+		UUID:        "550e8400-e29b-51d4-a716-446655440000",
+		PatternName: "json_cid",
+		RawLogLine:  "msg=ok",
+		LineNumber:  12,
+		Source:      "/var/log/pods/payments_api-0_1/api/0.log",
+		SourceName:  "pods",
+		Metadata:    map[string]string{"pod": "api-0", "namespace": "payments"},
+	}
+
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{FieldCID, record.CID},
+		{FieldUUID, record.UUID},
+		{FieldPattern, "json_cid"},
+		{FieldSource, "pods"},
+		{FieldFile, "0.log"},
+		{FieldPath, record.Source},
+		{FieldSourceFile, record.Source},
+		{FieldExtractedCID, record.CID},
+		{FieldOriginalLog, "msg=ok"},
+		{FieldLineNumber, "12"},
+		{FieldCIDType, "uuid_v5"},
+		{FieldCorrelationID, "cid_550e8400_e29b_51d4_a716_446655440000"},
+		{FieldLogTimestamp, ""},
+		{FieldSchemaVersion, "1"},
+		{"pod", "api-0"},
+		{"namespace", "payments"},
+		{"container", ""},
+	}
+
+	for _, tt := range tests {
+		if got := fieldValue(record, tt.field); got != tt.want {
+			t.Errorf("fieldValue(%q) = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+
+	if got := fieldValue(models.CIDRecord{}, FieldFile); got != "" {
+		t.Errorf("fieldValue(file) without a source = %q, want empty", got)
+	}
+}
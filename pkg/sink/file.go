@@ -0,0 +1,462 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// TypeFile is the sink type writing to a local file
+const TypeFile = "file"
+
+// DefaultMaxFileSize is the size in bytes at which a file sink rotates unless
+// configured otherwise
+const DefaultMaxFileSize = 100 << 20
+
+// backupTimeFormat is the rotation time in the names of rotated files, e.g.
+// cid-tracker-20240305T101112.000.json.gz
+const backupTimeFormat = "20060102T150405.000"
+
+// errClosed is returned when writing to a sink after Close
+var errClosed = errors.New("sink is closed")
+
+func init() {
+	Register(TypeFile, func(cfg models.SinkConfig) (Sink, error) {
+		enc, err := NewEncoder(cfg.Format, cfg.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		return NewFile(enc, *cfg.File)
+	}, validateFile)
+}
+
+// validateFile checks the options of a file sink
+func validateFile(cfg models.SinkConfig) error {
+	if cfg.File == nil || cfg.File.Path == "" {
+		return fmt.Errorf("file sink requires a path")
+	}
+	if cfg.File.MaxSize < 0 || cfg.File.RotateInterval < 0 || cfg.File.MaxBackups < 0 || cfg.File.MaxAge < 0 {
+		return fmt.Errorf("file sink rotation and retention limits must not be negative")
+	}
+	return nil
+}
+
+// fileSink appends records to a file, rotating and compressing it. The file
+// is only ever appended to, so a shipper can tail it; rotated files are
+// renamed away before compression and never reappear under the live name.
+// Rotated files are compressed in the background so that writes carry on
+// meanwhile.
+type fileSink struct {
+	cfg     models.FileSinkConfig
+	encoder Encoder
+	now     func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	writer   *bufio.Writer
+	size     int64
+	buffered int
+	openedAt time.Time
+
+	// compressing tracks the rotated files being compressed in the
+	// background, which compressMu lets through one at a time
+	compressing sync.WaitGroup
+	compressMu  sync.Mutex
+	// compressErr holds compression failures until Flush or Close reports
+	// them
+	errMu       sync.Mutex
+	compressErr error
+}
+
+// NewFile creates a sink appending records encoded by enc to the configured
+// file, creating it and its directory if needed. Every new file starts with
+// the encoder's header. Rotated files beyond the retention limits are removed
+// right away.
+func NewFile(enc Encoder, cfg models.FileSinkConfig) (Sink, error) {
+	return newFileSink(enc, cfg, time.Now)
+}
+
+// newFileSink creates a file sink reading the time from now
+func newFileSink(enc Encoder, cfg models.FileSinkConfig, now func() time.Time) (*fileSink, error) {
+	if err := validateFile(models.SinkConfig{File: &cfg}); err != nil {
+		return nil, err
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = DefaultMaxFileSize
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 1
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	s := &fileSink{cfg: cfg, encoder: enc, now: now}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	if err := s.removeOldBackups(now()); err != nil {
+		s.file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write appends a record, rotating the file first if it is full or due. The
+// record reaches the file once BufferSize records are buffered or on Flush.
+func (s *fileSink) Write(ctx context.Context, record models.CIDRecord) error {
+	data, err := s.encoder.Encode(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errClosed
+	}
+
+	if s.size > 0 && (s.size+int64(len(data)) > s.cfg.MaxSize || s.intervalElapsed()) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if s.size == 0 {
+		// The rotation interval runs from the first record of a file
+		s.openedAt = s.now()
+		if header := s.encoder.Header(); header != nil {
+			data = bytes.Join([][]byte{header, data}, []byte{'\n'})
+		}
+	}
+
+	n, err := s.writer.Write(data)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	s.buffered++
+	if s.buffered >= s.cfg.BufferSize {
+		return s.flush()
+	}
+	return nil
+}
+
+// Flush writes buffered records to the file, and rotates it if its interval
+// has elapsed so that rotation does not wait for the next record. It also
+// reports rotated files that failed to compress since the last call.
+func (s *fileSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	if s.size > 0 && s.intervalElapsed() {
+		return errors.Join(s.rotate(), s.takeCompressErr())
+	}
+	return errors.Join(s.flush(), s.takeCompressErr())
+}
+
+// Close writes buffered records, syncs the file to disk and closes it, then
+// waits for rotated files to be compressed
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.closeFile()
+	s.file = nil
+	s.compressing.Wait()
+	return errors.Join(err, s.takeCompressErr())
+}
+
+// open opens the live file for appending
+func (s *fileSink) open() error {
+	file, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat output file: %w", err)
+	}
+
+	s.file = file
+	s.writer = bufio.NewWriterSize(file, 64*1024)
+	s.size = info.Size()
+	s.buffered = 0
+	s.openedAt = s.now()
+	return nil
+}
+
+// flush writes the buffered records to the file
+func (s *fileSink) flush() error {
+	s.buffered = 0
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+// closeFile flushes, syncs and closes the live file
+func (s *fileSink) closeFile() error {
+	if err := s.flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("failed to sync output file: %w", err)
+	}
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+	return nil
+}
+
+// intervalElapsed reports whether the live file is due for time-based rotation
+func (s *fileSink) intervalElapsed() bool {
+	return s.cfg.RotateInterval > 0 && s.now().Sub(s.openedAt) >= s.cfg.RotateInterval
+}
+
+// rotate syncs and renames the live file and starts a new one. The rotated
+// file is then compressed and the retention limits applied in the background.
+func (s *fileSink) rotate() error {
+	if err := s.closeFile(); err != nil {
+		// Keep writing to the same file rather than losing every record
+		return errors.Join(err, s.open())
+	}
+
+	rotatedAt := s.now()
+	backup := s.backupName(rotatedAt)
+	if err := os.Rename(s.cfg.Path, backup); err != nil {
+		return errors.Join(fmt.Errorf("failed to rotate output file: %w", err), s.open())
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(s.cfg.Path))
+
+	s.compressing.Add(1)
+	go s.compressBackup(backup, rotatedAt)
+	return nil
+}
+
+// compressBackup compresses a file rotated at rotatedAt, then removes the
+// rotated files beyond the retention limits. Failures are kept for Flush or
+// Close.
+func (s *fileSink) compressBackup(path string, rotatedAt time.Time) {
+	defer s.compressing.Done()
+
+	s.compressMu.Lock()
+	defer s.compressMu.Unlock()
+	if !exists(path) {
+		// Already removed by the retention limits applied after a newer file
+		return
+	}
+	err := compressFile(path)
+	if err == nil {
+		err = s.removeOldBackups(rotatedAt)
+	}
+
+	if err != nil {
+		s.errMu.Lock()
+		s.compressErr = errors.Join(s.compressErr, err)
+		s.errMu.Unlock()
+	}
+}
+
+// takeCompressErr returns and clears the failures of background compression
+func (s *fileSink) takeCompressErr() error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+
+	err := s.compressErr
+	s.compressErr = nil
+	return err
+}
+
+// backupName returns an unused name for the live file rotated at t
+func (s *fileSink) backupName(t time.Time) string {
+	prefix, ext := s.nameParts()
+	stamp := t.UTC().Format(backupTimeFormat)
+
+	name := prefix + "-" + stamp + ext
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s-%d%s", prefix, stamp, i, ext)
+	}
+	return name
+}
+
+// nameParts splits the live file path into the part before its extension and
+// the extension, e.g. "/out/cid-tracker" and ".json"
+func (s *fileSink) nameParts() (string, string) {
+	ext := filepath.Ext(s.cfg.Path)
+	return strings.TrimSuffix(s.cfg.Path, ext), ext
+}
+
+// backup is a rotated file and the time it was rotated
+type backup struct {
+	path      string
+	rotatedAt time.Time
+}
+
+// removeOldBackups deletes the rotated files beyond MaxBackups or older than
+// MaxAge at now
+func (s *fileSink) removeOldBackups(now time.Time) error {
+	if s.cfg.MaxBackups == 0 && s.cfg.MaxAge == 0 {
+		return nil
+	}
+
+	backups, err := s.backups()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	cutoff := now.Add(-s.cfg.MaxAge)
+	for i, b := range backups {
+		tooMany := s.cfg.MaxBackups > 0 && i >= s.cfg.MaxBackups
+		tooOld := s.cfg.MaxAge > 0 && b.rotatedAt.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("failed to remove rotated output file: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backups lists the rotated files of the sink, newest first. Files that
+// failed to compress are included.
+func (s *fileSink) backups() ([]backup, error) {
+	prefix, ext := s.nameParts()
+	dir := filepath.Dir(s.cfg.Path)
+	base := filepath.Base(prefix) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list output directory: %w", err)
+	}
+
+	var backups []backup
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		rotatedAt, ok := parseBackupName(entry.Name(), base, ext)
+		if !ok {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, entry.Name()), rotatedAt: rotatedAt})
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].rotatedAt.After(backups[j].rotatedAt)
+	})
+	return backups, nil
+}
+
+// parseBackupName returns the rotation time of a file named like the names
+// backupName gives, <base><stamp>[-<n>]<ext>, optionally followed by .gz.
+// Other names, such as the temporary files of compression, are rejected.
+func parseBackupName(name, base, ext string) (time.Time, bool) {
+	rest, ok := strings.CutPrefix(name, base)
+	if !ok {
+		return time.Time{}, false
+	}
+	rest = strings.TrimSuffix(rest, ".gz")
+	if rest, ok = strings.CutSuffix(rest, ext); !ok || len(rest) < len(backupTimeFormat) {
+		return time.Time{}, false
+	}
+
+	stamp, n := rest[:len(backupTimeFormat)], rest[len(backupTimeFormat):]
+	if n != "" {
+		digits, ok := strings.CutPrefix(n, "-")
+		if !ok || digits == "" || strings.Trim(digits, "0123456789") != "" {
+			return time.Time{}, false
+		}
+	}
+	rotatedAt, err := time.Parse(backupTimeFormat, stamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return rotatedAt, true
+}
+
+// compressFile replaces a file with a gzip-compressed copy named path.gz. The
+// copy is synced and renamed into place before the original is removed, so a
+// crash never leaves a truncated archive as the only copy.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open rotated output file: %w", err)
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create compressed output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(zw, in); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compress rotated output file: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compress rotated output file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync compressed output file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close compressed output file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path+".gz"); err != nil {
+		return fmt.Errorf("failed to rename compressed output file: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove rotated output file: %w", err)
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// syncDir makes renames in dir durable. Errors are ignored as not every file
+// system supports syncing a directory.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// exists reports whether a file exists at path
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,461 @@
+package sink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// fakeClock is a settable time source
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)}
+}
+
+// readLines returns the lines of a plain or gzip-compressed file
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var r interface{ Read([]byte) (int, error) } = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("failed to read gzip %s: %v", path, err)
+		}
+		r = zr
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// rotatedFiles returns the compressed rotated files in dir, sorted by name
+func rotatedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "cids-*.json.gz"))
+	if err != nil {
+		t.Fatalf("glob failed: %v", err)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func TestFileSink_BuffersRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "cids.json")
+	s, err := NewFile(testEncoder(t, FormatJSON), models.FileSinkConfig{Path: path, BufferSize: 3})
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := s.Write(ctx, testRecord(i, "standard_cid")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if lines := readLines(t, path); len(lines) != 0 {
+		t.Errorf("file has %d lines before the buffer filled, want 0", len(lines))
+	}
+
+	s.Write(ctx, testRecord(2, "standard_cid"))
+	if lines := readLines(t, path); len(lines) != 3 {
+		t.Errorf("file has %d lines once the buffer filled, want 3", len(lines))
+	}
+
+	s.Write(ctx, testRecord(3, "standard_cid"))
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	lines := readLines(t, path)
+	if len(lines) != 4 {
+		t.Fatalf("file has %d lines after Flush, want 4", len(lines))
+	}
+	if !strings.Contains(lines[3], testRecord(3, "").CID) {
+		t.Errorf("last line = %s, want record 3", lines[3])
+	}
+}
+
+func TestFileSink_AppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cids.json")
+	if err := os.WriteFile(path, []byte("{\"cid\":\"earlier\"}\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	s, err := NewFile(testEncoder(t, FormatJSON), models.FileSinkConfig{Path: path})
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	s.Write(context.Background(), testRecord(1, "standard_cid"))
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	lines := readLines(t, path)
+	if len(lines) != 2 || !strings.Contains(lines[0], "earlier") {
+		t.Errorf("lines = %v, want the earlier line kept", lines)
+	}
+}
+
+func TestFileSink_HeaderPerFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cids.csv")
+	if err := os.WriteFile(path, []byte("cid\nearlier\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	clock := newTestClock()
+
+	enc, err := NewEncoder(FormatCSV, &models.EncoderConfig{Fields: []string{"cid"}})
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	s, err := newFileSink(enc, models.FileSinkConfig{Path: path, RotateInterval: time.Hour}, clock.Now)
+	if err != nil {
+		t.Fatalf("newFileSink() error = %v", err)
+	}
+
+	ctx := context.Background()
+	s.Write(ctx, testRecord(1, "standard_cid"))
+	clock.now = clock.now.Add(2 * time.Hour)
+	s.Write(ctx, testRecord(2, "standard_cid"))
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// The existing file already has its header; the new one gets its own
+	rotated, _ := filepath.Glob(filepath.Join(dir, "cids-*.csv.gz"))
+	if len(rotated) != 1 {
+		t.Fatalf("rotated files = %v, want 1", rotated)
+	}
+	if got := strings.Join(readLines(t, rotated[0]), "|"); got != "cid|earlier|"+testRecord(1, "").CID {
+		t.Errorf("rotated file = %s, want one header", got)
+	}
+	if got := strings.Join(readLines(t, path), "|"); got != "cid|"+testRecord(2, "").CID {
+		t.Errorf("live file = %s, want a header and record 2", got)
+	}
+}
+
+func TestFileSink_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cids.json")
+	clock := newTestClock()
+
+	s, err := newFileSink(testEncoder(t, FormatJSON), models.FileSinkConfig{Path: path, MaxSize: 600}, clock.Now)
+	if err != nil {
+		t.Fatalf("newFileSink() error = %v", err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		clock.now = clock.now.Add(time.Second)
+		if err := s.Write(ctx, testRecord(i, "standard_cid")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	rotated := rotatedFiles(t, dir)
+	if len(rotated) == 0 {
+		t.Fatal("no rotated files after exceeding the maximum size")
+	}
+
+	// Every record is kept exactly once, oldest first
+	var all []string
+	for _, file := range rotated {
+		all = append(all, readLines(t, file)...)
+	}
+	all = append(all, readLines(t, path)...)
+	if len(all) != 10 {
+		t.Fatalf("found %d records across files, want 10", len(all))
+	}
+	for i, line := range all {
+		if !strings.Contains(line, testRecord(i, "").CID) {
+			t.Errorf("record %d = %s, want %s", i, line, testRecord(i, "").CID)
+		}
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Size() > 600 {
+		t.Errorf("live file size = %v (%v), want at most 600", info.Size(), err)
+	}
+	if uncompressed, _ := filepath.Glob(filepath.Join(dir, "cids-*.json")); len(uncompressed) != 0 {
+		t.Errorf("uncompressed rotated files left behind: %v", uncompressed)
+	}
+}
+
+func TestFileSink_RotatesByInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cids.json")
+	clock := newTestClock()
+
+	s, err := newFileSink(testEncoder(t, FormatJSON), models.FileSinkConfig{Path: path, RotateInterval: time.Hour}, clock.Now)
+	if err != nil {
+		t.Fatalf("newFileSink() error = %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	// An empty file is not rotated
+	clock.now = clock.now.Add(2 * time.Hour)
+	s.Flush(ctx)
+	if rotated := rotatedFiles(t, dir); len(rotated) != 0 {
+		t.Fatalf("rotated an empty file: %v", rotated)
+	}
+
+	s.Write(ctx, testRecord(1, "standard_cid"))
+	clock.now = clock.now.Add(30 * time.Minute)
+	s.Flush(ctx)
+	if rotated := rotatedFiles(t, dir); len(rotated) != 0 {
+		t.Fatalf("rotated before the interval elapsed: %v", rotated)
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	s.compressing.Wait()
+	rotated := rotatedFiles(t, dir)
+	if len(rotated) != 1 {
+		t.Fatalf("rotated files = %v, want 1 after the interval", rotated)
+	}
+	if want := "cids-20240305T133000.000.json.gz"; filepath.Base(rotated[0]) != want {
+		t.Errorf("rotated file = %s, want %s", filepath.Base(rotated[0]), want)
+	}
+	if lines := readLines(t, rotated[0]); len(lines) != 1 {
+		t.Errorf("rotated file has %d lines, want 1", len(lines))
+	}
+	if lines := readLines(t, path); len(lines) != 0 {
+		t.Errorf("live file has %d lines after rotation, want 0", len(lines))
+	}
+}
+
+func TestFileSink_Retention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cids.json")
+	clock := newTestClock()
+
+	// Left over from an earlier run, and older than MaxAge
+	stale := filepath.Join(dir, "cids-20240301T000000.000.json.gz")
+	if err := os.WriteFile(stale, nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	unrelated := filepath.Join(dir, "other-20240301T000000.000.json.gz")
+	if err := os.WriteFile(unrelated, nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	s, err := newFileSink(testEncoder(t, FormatJSON), models.FileSinkConfig{
+		Path:           path,
+		RotateInterval: time.Minute,
+		MaxBackups:     2,
+		MaxAge:         24 * time.Hour,
+	}, clock.Now)
+	if err != nil {
+		t.Fatalf("newFileSink() error = %v", err)
+	}
+	defer s.Close()
+
+	if exists(stale) {
+		t.Error("rotated file older than MaxAge was kept at startup")
+	}
+	if !exists(unrelated) {
+		t.Error("file of another sink was removed")
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		s.Write(ctx, testRecord(i, "standard_cid"))
+		clock.now = clock.now.Add(time.Minute)
+		if err := s.Flush(ctx); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+	}
+	s.compressing.Wait()
+
+	rotated := rotatedFiles(t, dir)
+	if len(rotated) != 2 {
+		t.Fatalf("rotated files = %v, want the 2 newest", rotated)
+	}
+	if lines := readLines(t, rotated[1]); len(lines) != 1 || !strings.Contains(lines[0], testRecord(4, "").CID) {
+		t.Errorf("newest rotated file = %v, want record 4", lines)
+	}
+}
+
+func TestFileSink_CompressesInBackground(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cids.json")
+	clock := newTestClock()
+
+	s, err := newFileSink(testEncoder(t, FormatJSON), models.FileSinkConfig{Path: path, MaxSize: 1}, clock.Now)
+	if err != nil {
+		t.Fatalf("newFileSink() error = %v", err)
+	}
+
+	// Writes that rotate do not wait while another rotated file is compressed
+	s.compressMu.Lock()
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		clock.now = clock.now.Add(time.Second)
+		if err := s.Write(ctx, testRecord(i, "standard_cid")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if rotated := rotatedFiles(t, dir); len(rotated) != 0 {
+		t.Errorf("rotated files = %v compressed while compression was held", rotated)
+	}
+	s.compressMu.Unlock()
+
+	// Close waits for the rotated files to be compressed
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if rotated := rotatedFiles(t, dir); len(rotated) != 2 {
+		t.Errorf("rotated files = %v after Close(), want 2", rotated)
+	}
+	if uncompressed, _ := filepath.Glob(filepath.Join(dir, "cids-*.json")); len(uncompressed) != 0 {
+		t.Errorf("uncompressed rotated files left behind: %v", uncompressed)
+	}
+}
+
+func TestFileSink_BackupNameCollision(t *testing.T) {
+	dir := t.TempDir()
+	clock := newTestClock()
+	s, err := newFileSink(testEncoder(t, FormatJSON), models.FileSinkConfig{Path: filepath.Join(dir, "cids.json"), MaxSize: 1}, clock.Now)
+	if err != nil {
+		t.Fatalf("newFileSink() error = %v", err)
+	}
+
+	// Every write rotates, all at the same instant
+	for i := 0; i < 3; i++ {
+		s.Write(context.Background(), testRecord(i, "standard_cid"))
+	}
+	s.Close()
+
+	if rotated := rotatedFiles(t, dir); len(rotated) != 2 {
+		t.Errorf("rotated files = %v, want 2 distinct files", rotated)
+	}
+}
+
+func TestFileSink_BackupsMatchExactNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		files map[string]bool
+	}{
+		{
+			name: "with extension",
+			path: "cids.json",
+			files: map[string]bool{
+				"cids-20240301T000000.000.json":          true,
+				"cids-20240301T000000.000-2.json.gz":     true,
+				"cids-20240301T000000.000.json.1234.tmp": false,
+				"cids-20240301T000000.000.json.gz.bak":   false,
+				"cids-20240301T000000.000-x.json":        false,
+				"other-20240301T000000.000.json.gz":      false,
+			},
+		},
+		{
+			name: "without extension",
+			path: "cids",
+			files: map[string]bool{
+				"cids-20240301T000000.000":          true,
+				"cids-20240301T000000.000.gz":       true,
+				"cids-20240301T000000.000-2":        true,
+				"cids-20240301T000000.000.1234.tmp": false,
+				"cids-20240301T000000.000-.gz":      false,
+				"cids-20240301T000000.000.json":     false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+			s, err := newFileSink(testEncoder(t, FormatJSON), models.FileSinkConfig{Path: filepath.Join(dir, tt.path)}, newTestClock().Now)
+			if err != nil {
+				t.Fatalf("newFileSink() error = %v", err)
+			}
+			defer s.Close()
+
+			backups, err := s.backups()
+			if err != nil {
+				t.Fatalf("backups() error = %v", err)
+			}
+			got := map[string]bool{}
+			for _, b := range backups {
+				got[filepath.Base(b.path)] = true
+			}
+			for name, want := range tt.files {
+				if got[name] != want {
+					t.Errorf("%s counted as a backup = %v, want %v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileSink_WriteAfterClose(t *testing.T) {
+	s, err := NewFile(testEncoder(t, FormatJSON), models.FileSinkConfig{Path: filepath.Join(t.TempDir(), "cids.json")})
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	s.Close()
+
+	if err := s.Write(context.Background(), testRecord(1, "standard_cid")); err == nil {
+		t.Error("Write() after Close() error = nil")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestValidate_FileSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    *models.FileSinkConfig
+		wantErr bool
+	}{
+		{name: "path", file: &models.FileSinkConfig{Path: "/var/output/cids.json"}},
+		{name: "no options", wantErr: true},
+		{name: "no path", file: &models.FileSinkConfig{MaxSize: 10}, wantErr: true},
+		{name: "negative size", file: &models.FileSinkConfig{Path: "/out.json", MaxSize: -1}, wantErr: true},
+		{name: "negative age", file: &models.FileSinkConfig{Path: "/out.json", MaxAge: -time.Hour}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(models.SinkConfig{Name: "file", Type: TypeFile, Format: FormatJSON, File: tt.file})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
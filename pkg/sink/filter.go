@@ -0,0 +1,74 @@
+package sink
+
+import (
+	"cidtracker/pkg/models"
+	"cidtracker/pkg/source"
+)
+
+// Filter decides which records a sink receives. A nil Filter accepts every
+// record.
+type Filter struct {
+	patterns map[string]bool
+	files    []string
+	metadata map[string]string
+}
+
+// NewFilter compiles a filter configuration. It returns nil when cfg is nil
+// or sets no conditions.
+func NewFilter(cfg *models.SinkFilter) (*Filter, error) {
+	if cfg == nil || (len(cfg.Patterns) == 0 && len(cfg.Files) == 0 && len(cfg.Metadata) == 0) {
+		return nil, nil
+	}
+
+	for _, pattern := range cfg.Files {
+		if err := source.ValidateGlob(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	f := &Filter{
+		files:    cfg.Files,
+		metadata: cfg.Metadata,
+	}
+	if len(cfg.Patterns) > 0 {
+		f.patterns = make(map[string]bool, len(cfg.Patterns))
+		for _, name := range cfg.Patterns {
+			f.patterns[name] = true
+		}
+	}
+	return f, nil
+}
+
+// Match reports whether record satisfies every condition of the filter: its
+// pattern is listed, its source file matches one of the globs, and its
+// metadata holds each of the given values
+func (f *Filter) Match(record models.CIDRecord) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.patterns != nil && !f.patterns[record.PatternName] {
+		return false
+	}
+
+	if len(f.files) > 0 {
+		matched := false
+		for _, pattern := range f.files {
+			if source.MatchGlob(pattern, record.Source) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for key, value := range f.metadata {
+		if record.Metadata[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
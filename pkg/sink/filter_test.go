@@ -0,0 +1,80 @@
+package sink
+
+import (
+	"testing"
+
+	"cidtracker/pkg/models"
+)
+
+func TestNewFilter_Empty(t *testing.T) {
+	for _, cfg := range []*models.SinkFilter{nil, {}} {
+		filter, err := NewFilter(cfg)
+		if err != nil {
+			t.Fatalf("NewFilter(%v) error = %v", cfg, err)
+		}
+		if filter != nil {
+			t.Errorf("NewFilter(%v) = %v, want nil", cfg, filter)
+		}
+		if !filter.Match(models.CIDRecord{}) {
+			t.Error("nil filter rejected a record")
+		}
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	record := models.CIDRecord{
+		PatternName: "json_cid",
+		Source:      "/var/log/pods/payments_api-0_uid/api/0.log",
+		Metadata:    map[string]string{"namespace": "payments", "container": "api"},
+	}
+
+	tests := []struct {
+		name string
+		cfg  models.SinkFilter
+		want bool
+	}{
+		{name: "pattern listed", cfg: models.SinkFilter{Patterns: []string{"standard_cid", "json_cid"}}, want: true},
+		{name: "pattern not listed", cfg: models.SinkFilter{Patterns: []string{"standard_cid"}}, want: false},
+		{name: "base name glob", cfg: models.SinkFilter{Files: []string{"*.log"}}, want: true},
+		{name: "path glob", cfg: models.SinkFilter{Files: []string{"/var/log/pods/payments_*/**"}}, want: true},
+		{name: "no glob matches", cfg: models.SinkFilter{Files: []string{"*.txt", "/var/log/app/**"}}, want: false},
+		{name: "metadata matches", cfg: models.SinkFilter{Metadata: map[string]string{"namespace": "payments"}}, want: true},
+		{name: "metadata differs", cfg: models.SinkFilter{Metadata: map[string]string{"namespace": "default"}}, want: false},
+		{name: "metadata missing", cfg: models.SinkFilter{Metadata: map[string]string{"pod": "api-0"}}, want: false},
+		{
+			name: "all conditions",
+			cfg: models.SinkFilter{
+				Patterns: []string{"json_cid"},
+				Files:    []string{"*.log"},
+				Metadata: map[string]string{"container": "api"},
+			},
+			want: true,
+		},
+		{
+			name: "one condition fails",
+			cfg: models.SinkFilter{
+				Patterns: []string{"json_cid"},
+				Files:    []string{"*.txt"},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewFilter(&tt.cfg)
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+			if got := filter.Match(record); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFilter_InvalidGlob(t *testing.T) {
+	if _, err := NewFilter(&models.SinkFilter{Files: []string{"app[.log"}}); err == nil {
+		t.Error("NewFilter() error = nil for an invalid glob")
+	}
+}
@@ -0,0 +1,153 @@
+package sink
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// Output formats
+const (
+	// FormatJSON writes one JSON document per record
+	FormatJSON = "json"
+	// FormatStructured writes one line of text per record
+	FormatStructured = "structured"
+	// FormatLogfmt writes one line of key=value pairs per record
+	FormatLogfmt = "logfmt"
+	// FormatCSV writes one CSV row per record after a header row
+	FormatCSV = "csv"
+	// FormatTemplate renders each record with a text/template
+	FormatTemplate = "template"
+)
+
+// SchemaVersion is the version of Record, written as its schema_version
+const SchemaVersion = 1
+
+// Output schemas of the json format
+const (
+	// SchemaV1 writes Record, the versioned output record
+	SchemaV1 = "v1"
+	// SchemaLegacy writes CIDEntry, the document written before the output
+	// record was versioned
+	SchemaLegacy = "legacy"
+)
+
+// Record is the versioned document written for each valid CID. Timestamp is
+// the time the line was logged if known, otherwise the ingest time, and
+// LogTimestamp is only set if the time was found in the line or its runtime
+// envelope. Any change to the fields that could break a parser comes with a
+// new SchemaVersion. The description tags document the fields in the JSON
+// Schema generated by RecordSchema.
+type Record struct {
+	SchemaVersion int               `json:"schema_version" description:"Version of the output record schema"`
+	Timestamp     time.Time         `json:"timestamp" description:"Time the line was logged if known, otherwise the time it was read"`
+	SourceFile    string            `json:"source_file" description:"Full path of the log file the line was read from"`
+	LineNumber    int64             `json:"line_number,omitempty" description:"1-based number of the line in the log file, absent if unknown"`
+	Offset        int64             `json:"offset" description:"Byte offset at which the line starts in the log file"`
+	OriginalLog   string            `json:"original_log" description:"Log line the CID was found in, without its runtime envelope"`
+	ExtractedCID  string            `json:"extracted_cid" description:"CID as matched by the pattern"`
+	CIDType       string            `json:"cid_type" description:"Kind of CID: uuid_v1 to uuid_v8 for the version of its UUID, or unknown"`
+	LogTimestamp  *time.Time        `json:"log_timestamp,omitempty" description:"Time found in the line or its runtime envelope, absent if none was found"`
+	CorrelationID string            `json:"correlation_id,omitempty" description:"Identifier derived from the UUID, the same for every line with that UUID"`
+	UUID          string            `json:"uuid,omitempty" description:"UUID found in the CID"`
+	Pattern       string            `json:"pattern,omitempty" description:"Name of the pattern that matched the CID"`
+	Source        string            `json:"source,omitempty" description:"Name of the log source the file belongs to"`
+	ProcessedAt   time.Time         `json:"processed_at" description:"Time the line was read"`
+	Metadata      map[string]string `json:"metadata,omitempty" description:"Metadata of the line, such as the stream or Kubernetes pod"`
+}
+
+// NewRecord converts a record into the versioned output record
+func NewRecord(record models.CIDRecord) Record {
+	out := Record{
+		SchemaVersion: SchemaVersion,
+		Timestamp:     record.Timestamp,
+		SourceFile:    record.Source,
+		LineNumber:    record.LineNumber,
+		Offset:        record.Offset,
+		OriginalLog:   record.RawLogLine,
+		ExtractedCID:  record.CID,
+		CIDType:       cidType(record.UUID),
+		CorrelationID: correlationID(record.UUID),
+		UUID:          record.UUID,
+		Pattern:       record.PatternName,
+		Source:        record.SourceName,
+		ProcessedAt:   record.ExtractedAt,
+		Metadata:      record.Metadata,
+	}
+	if record.TimestampParsed {
+		logged := record.Timestamp
+		out.LogTimestamp = &logged
+	}
+	return out
+}
+
+// cidType names the kind of CID after the version of its UUID
+func cidType(uuid string) string {
+	if len(uuid) != 36 || uuid[14] < '1' || uuid[14] > '8' {
+		return "unknown"
+	}
+	return "uuid_v" + uuid[14:15]
+}
+
+// correlationID derives the correlation ID of a UUID, e.g. cid_550e8400_e29b_...
+func correlationID(uuid string) string {
+	if uuid == "" {
+		return ""
+	}
+	return "cid_" + strings.ToLower(strings.ReplaceAll(uuid, "-", "_"))
+}
+
+// CIDEntry is the document written by the legacy schema, with exactly the
+// fields of releases before the schema was versioned. Timestamp is the time
+// the line was logged if known, otherwise the ingest time.
+type CIDEntry struct {
+	CID         string    `json:"cid"`
+	UUID        string    `json:"uuid,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	LogFile     string    `json:"log_file"`
+	RawMessage  string    `json:"raw_message"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// NewCIDEntry converts a record into the legacy output document
+func NewCIDEntry(record models.CIDRecord) CIDEntry {
+	return CIDEntry{
+		CID:         record.CID,
+		UUID:        record.UUID,
+		Timestamp:   record.Timestamp,
+		LogFile:     filepath.Base(record.Source),
+		RawMessage:  record.RawLogLine,
+		ProcessedAt: record.ExtractedAt,
+	}
+}
+
+// ValidateFormat checks that format is a known output format
+func ValidateFormat(format string) error {
+	if _, ok := encoders[format]; !ok {
+		return fmt.Errorf("invalid format '%s': must be one of %s", format, strings.Join(Formats(), ", "))
+	}
+	return nil
+}
+
+// ValidateSchema checks that schema is a known output schema; empty selects
+// SchemaV1
+func ValidateSchema(schema string) error {
+	switch schema {
+	case "", SchemaV1, SchemaLegacy:
+		return nil
+	}
+	return fmt.Errorf("invalid output schema '%s': must be %s or %s", schema, SchemaV1, SchemaLegacy)
+}
+
+// Encode renders a record in the given format with the default encoder
+// options, without a trailing newline
+func Encode(format string, record models.CIDRecord) ([]byte, error) {
+	enc, err := NewEncoder(format, nil)
+	if err != nil {
+		return nil, err
+	}
+	return enc.Encode(record)
+}
@@ -0,0 +1,208 @@
+package sink
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+func TestNewRecord(t *testing.T) {
+	logged := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	record := models.CIDRecord{
+		CID:             "req-12345678-1234-5abc-9def-123456789012",
+		UUID:            "12345678-1234-5abc-9def-123456789012",
+		Timestamp:       logged,
+		TimestampParsed: true,
+		RawLogLine:      "2024-01-15 10:30:00 INFO [req-12345678-1234-5abc-9def-123456789012] Processing user request",
+		IsValid:         true,
+		PatternName:     "standard_cid",
+		ExtractedAt:     logged.Add(123 * time.Millisecond),
+		Source:          "/var/log/app/application.log",
+		SourceName:      "app",
+		Offset:          98304,
+		LineNumber:      1234,
+	}
+
+	want := Record{
+		SchemaVersion: SchemaVersion,
+		Timestamp:     logged,
+		SourceFile:    "/var/log/app/application.log",
+		LineNumber:    1234,
+		Offset:        98304,
+		OriginalLog:   record.RawLogLine,
+		ExtractedCID:  record.CID,
+		CIDType:       "uuid_v5",
+		LogTimestamp:  &logged,
+		CorrelationID: "cid_12345678_1234_5abc_9def_123456789012",
+		UUID:          record.UUID,
+		Pattern:       "standard_cid",
+		Source:        "app",
+		ProcessedAt:   record.ExtractedAt,
+	}
+	if got := NewRecord(record); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewRecord() = %+v, want %+v", got, want)
+	}
+
+	// Without a UUID or a time from the line, the derived fields are left out
+	record.UUID, record.TimestampParsed = "", false
+	data, err := json.Marshal(NewRecord(record))
+	if err != nil {
+		t.Fatalf("failed to marshal Record: %v", err)
+	}
+	for _, field := range []string{`"log_timestamp"`, `"correlation_id"`, `"uuid"`} {
+		if strings.Contains(string(data), field) {
+			t.Errorf("Record = %s, want no %s", data, field)
+		}
+	}
+	if !strings.Contains(string(data), `"cid_type":"unknown"`) {
+		t.Errorf("Record = %s, want cid_type unknown", data)
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	for _, schema := range []string{"", SchemaV1, SchemaLegacy} {
+		if err := ValidateSchema(schema); err != nil {
+			t.Errorf("ValidateSchema(%q) error = %v", schema, err)
+		}
+	}
+	if err := ValidateSchema("v2"); err == nil {
+		t.Error("ValidateSchema(v2) error = nil, want error")
+	}
+}
+
+func TestNewCIDEntry(t *testing.T) {
+	now := time.Now()
+	record := models.CIDRecord{
+		CID:             "550e8400-e29b-51d4-a716-446655440000",
+		UUID:            "550e8400-e29b-51d4-a716-446655440000",
+		Timestamp:       now.Add(-time.Minute),
+		TimestampParsed: true,
+		RawLogLine:      "CID:550e8400-e29b-51d4-a716-446655440000 done",
+		IsValid:         true,
+		PatternName:     "standard_cid",
+		ExtractedAt:     now,
+		Metadata:        map[string]string{"pod": "web-0"},
+		Source:          "/var/log/app/test.log",
+	}
+
+	entry := NewCIDEntry(record)
+	want := CIDEntry{
+		CID:         record.CID,
+		UUID:        record.UUID,
+		Timestamp:   record.Timestamp,
+		LogFile:     "test.log",
+		RawMessage:  record.RawLogLine,
+		ProcessedAt: now,
+	}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("NewCIDEntry() = %+v, want %+v", entry, want)
+	}
+}
+
+func TestCIDEntry_LegacyFields(t *testing.T) {
+	// Strict consumers of the legacy document must see no new fields, even
+	// for a record that has every field set
+	record := models.CIDRecord{
+		CID:             "550e8400-e29b-51d4-a716-446655440000",
+		UUID:            "550e8400-e29b-51d4-a716-446655440000",
+		Timestamp:       time.Now(),
+		TimestampParsed: true,
+		RawLogLine:      "CID:550e8400-e29b-51d4-a716-446655440000 done",
+		PatternName:     "standard_cid",
+		ExtractedAt:     time.Now(),
+		Metadata:        map[string]string{"pod": "web-0"},
+		Source:          "/var/log/app/test.log",
+		LineNumber:      7,
+	}
+
+	data, err := json.Marshal(NewCIDEntry(record))
+	if err != nil {
+		t.Fatalf("failed to marshal CIDEntry: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("failed to unmarshal CIDEntry: %v", err)
+	}
+
+	var keys []string
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	want := []string{"cid", "log_file", "processed_at", "raw_message", "timestamp", "uuid"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("legacy fields = %v, want %v", keys, want)
+	}
+}
+
+func TestCIDEntry_JSON(t *testing.T) {
+	entry := CIDEntry{
+		CID:         "test-cid",
+		UUID:        "550e8400-e29b-41d4-a716-446655440000",
+		Timestamp:   time.Now(),
+		LogFile:     "test.log",
+		RawMessage:  "test message",
+		ProcessedAt: time.Now(),
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatalf("failed to marshal CIDEntry: %v", err)
+	}
+
+	var decoded CIDEntry
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal CIDEntry: %v", err)
+	}
+
+	if decoded.CID != entry.CID {
+		t.Errorf("CID = %v, want %v", decoded.CID, entry.CID)
+	}
+	if decoded.UUID != entry.UUID {
+		t.Errorf("UUID = %v, want %v", decoded.UUID, entry.UUID)
+	}
+	if decoded.LogFile != entry.LogFile {
+		t.Errorf("LogFile = %v, want %v", decoded.LogFile, entry.LogFile)
+	}
+	if decoded.RawMessage != entry.RawMessage {
+		t.Errorf("RawMessage = %v, want %v", decoded.RawMessage, entry.RawMessage)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	record := models.CIDRecord{
+		CID:       "550e8400-e29b-51d4-a716-446655440000",
+		Timestamp: time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC),
+		Source:    "/var/log/app/test.log",
+	}
+
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{format: FormatStructured, want: "[2024-03-05T10:11:12Z] CID:550e8400-e29b-51d4-a716-446655440000 FILE:test.log"},
+		{format: FormatJSON, want: `"source_file":"/var/log/app/test.log"`},
+		{format: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, err := Encode(tt.format, record)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Encode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("Encode() = %s, want it to contain %s", data, tt.want)
+			}
+			if strings.HasSuffix(string(data), "\n") {
+				t.Errorf("Encode() = %q, want no trailing newline", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxResponseBody bounds how much of a response body is read
+const maxResponseBody = 1 << 20
+
+// httpSender posts request bodies to one endpoint, retrying failed requests
+// with backoff. Sinks sending over HTTP share it so that they retry alike.
+type httpSender struct {
+	retrier
+	client  *http.Client
+	url     string
+	headers http.Header
+	now     func() time.Time
+}
+
+// newHTTPSender creates a sender for endpoint, using the defaults for unset
+// timeout and retry settings. A bearer token is sent in the Authorization
+// header.
+func newHTTPSender(endpoint string, headers map[string]string, bearerToken string, timeout time.Duration, maxRetries *int, initialBackoff, maxBackoff time.Duration) *httpSender {
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	h := make(http.Header)
+	h.Set("User-Agent", "cidtracker")
+	for name, value := range headers {
+		h.Set(name, value)
+	}
+	if bearerToken != "" {
+		h.Set("Authorization", "Bearer "+bearerToken)
+	}
+
+	return &httpSender{
+		retrier: newRetrier(endpoint, maxRetries, initialBackoff, maxBackoff),
+		client:  &http.Client{Timeout: timeout},
+		url:     endpoint,
+		headers: h,
+		now:     time.Now,
+	}
+}
+
+// basicAuth returns the Authorization header value for HTTP basic
+// authentication
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// validateURL checks that endpoint is an absolute http or https URL
+func validateURL(endpoint string) error {
+	if endpoint == "" {
+		return fmt.Errorf("url is required")
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid url '%s': %w", endpoint, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url '%s': must be an http or https URL", endpoint)
+	}
+	return nil
+}
+
+// post sends body until the endpoint accepts it or the retries are used up,
+// and returns the body of the accepted response and the number of attempts.
+// Network errors, 408, 429 and 5xx responses are retried; a Retry-After
+// header replaces the backoff delay, up to the maximum backoff.
+func (s *httpSender) post(ctx context.Context, contentType string, body []byte) ([]byte, int, error) {
+	var respBody []byte
+	attempts, err := s.retry(ctx, func() (bool, time.Duration, error) {
+		var retry bool
+		var wait time.Duration
+		var err error
+		respBody, retry, wait, err = s.postOnce(ctx, contentType, body)
+		return retry, wait, err
+	})
+	return respBody, attempts, err
+}
+
+// postOnce sends a single request. It reports whether a failure is worth
+// retrying and how long the server asked to wait.
+func (s *httpSender) postOnce(ctx context.Context, contentType string, body []byte) ([]byte, bool, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header = s.headers.Clone()
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, 0, fmt.Errorf("request to %s failed: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return nil, true, 0, fmt.Errorf("failed to read response from %s: %w", s.url, err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return respBody, false, 0, nil
+	}
+
+	wait, _ := retryAfter(resp.Header.Get("Retry-After"), s.now())
+	return nil, retryableStatus(resp.StatusCode), wait,
+		fmt.Errorf("%s returned %s: %s", s.url, resp.Status, bytes.TrimSpace(respBody))
+}
+
+// appendDeadLetter appends lines to the dead-letter file at path and syncs it,
+// so records that could not be delivered survive a restart and can be
+// replayed later
+func appendDeadLetter(path string, lines [][]byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync dead-letter file: %w", err)
+	}
+	return f.Close()
+}
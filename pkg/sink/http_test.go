@@ -0,0 +1,195 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// retries returns a configured number of retries
+func retries(n int) *int {
+	return &n
+}
+
+func TestHTTPSender_Post(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{"accepted", []int{202}, false, 1},
+		{"retried", []int{503, 429, 200}, false, 3},
+		{"timeout retried", []int{408, 200}, false, 2},
+		{"permanent", []int{401}, true, 1},
+		{"exhausted", []int{500, 500, 500, 500}, true, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[requests]
+				requests++
+				w.WriteHeader(status)
+				w.Write([]byte("ok"))
+			}))
+			defer server.Close()
+
+			s := newHTTPSender(server.URL, nil, "", time.Second, retries(2), time.Millisecond, time.Millisecond)
+			body, attempts, err := s.post(context.Background(), "text/plain", []byte("x"))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("post() attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if !tt.wantErr && string(body) != "ok" {
+				t.Errorf("post() body = %q, want ok", body)
+			}
+		})
+	}
+}
+
+func TestHTTPSender_PostCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newHTTPSender(server.URL, nil, "", time.Second, retries(5), time.Hour, time.Hour)
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return sleepContext(ctx, d)
+	}
+
+	_, attempts, err := s.post(ctx, "text/plain", []byte("x"))
+	if err == nil || attempts != 1 {
+		t.Errorf("post() = %d attempts, error %v; want 1 attempt and an error", attempts, err)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"http://collector:8080/cids", false},
+		{"https://collector.internal", false},
+		{"", true},
+		{"collector:8080", true},
+		{"file:///tmp/cids", true},
+		{"http://%zz", true},
+	}
+
+	for _, tt := range tests {
+		if err := validateURL(tt.url); (err != nil) != tt.wantErr {
+			t.Errorf("validateURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAppendDeadLetter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead", "letter.ndjson")
+
+	if err := appendDeadLetter(path, [][]byte{[]byte(`{"cid":"a"}`)}); err != nil {
+		t.Fatalf("appendDeadLetter() error = %v", err)
+	}
+	if err := appendDeadLetter(path, [][]byte{[]byte(`{"cid":"b"}`), []byte(`{"cid":"c"}`)}); err != nil {
+		t.Fatalf("appendDeadLetter() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read dead letter: %v", err)
+	}
+	want := "{\"cid\":\"a\"}\n{\"cid\":\"b\"}\n{\"cid\":\"c\"}\n"
+	if string(data) != want {
+		t.Errorf("dead letter = %q, want %q", data, want)
+	}
+
+	blocked := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(blocked, nil, 0644)
+	if err := appendDeadLetter(filepath.Join(blocked, "letter"), [][]byte{[]byte("x")}); err == nil {
+		t.Error("appendDeadLetter() under a file error = nil, want an error")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"Tue, 05 Mar 2024 10:00:30 GMT", 30 * time.Second, true},
+		{"Tue, 05 Mar 2024 09:00:00 GMT", 0, true},
+		{"soon", 0, false},
+		{"-5", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.header, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("retryAfter(%q) = %v, %v; want %v, %v", tt.header, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNewRetrier_MaxRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries *int
+		want       int
+	}{
+		{"default", nil, DefaultMaxRetries},
+		{"disabled", retries(0), 0},
+		{"configured", retries(2), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRetrier("collector", tt.maxRetries, time.Millisecond, time.Millisecond)
+			r.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+			attempts, err := r.retry(context.Background(), func() (bool, time.Duration, error) {
+				return true, 0, errors.New("unavailable")
+			})
+			if err == nil || attempts != tt.want+1 {
+				t.Errorf("retry() = %d attempts, error %v; want %d attempts and an error", attempts, err, tt.want+1)
+			}
+		})
+	}
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	b := newBackoff(100*time.Millisecond, time.Second)
+	for attempt, base := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		base *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			d := b.delay(attempt)
+			if d < base/2 || d > base {
+				t.Fatalf("delay(%d) = %v, want between %v and %v", attempt, d, base/2, base)
+			}
+		}
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("sleepContext() error = %v, want context.Canceled", err)
+	}
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("sleepContext() error = %v", err)
+	}
+}
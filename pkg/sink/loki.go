@@ -0,0 +1,349 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// TypeLoki is the sink type pushing records to Grafana Loki
+const TypeLoki = "loki"
+
+// Loki push encodings
+const (
+	// LokiEncodingJSON sends JSON push requests
+	LokiEncodingJSON = "json"
+	// LokiEncodingProtobuf sends snappy-compressed protobuf push requests
+	LokiEncodingProtobuf = "protobuf"
+)
+
+// lokiPushPath is the path of Loki's push API
+const lokiPushPath = "/loki/api/v1/push"
+
+// defaultLokiLabels maps the stream labels used unless configured otherwise
+// to record fields
+var defaultLokiLabels = map[string]string{
+	"source":    FieldSource,
+	"namespace": "namespace",
+	"pod":       "pod",
+	"pattern":   FieldPattern,
+}
+
+// defaultLokiStaticLabels are added to every stream unless configured otherwise
+var defaultLokiStaticLabels = map[string]string{"job": "cidtracker"}
+
+// lokiLabelName matches valid Loki label names
+var lokiLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func init() {
+	Register(TypeLoki, func(cfg models.SinkConfig) (Sink, error) {
+		enc, err := NewEncoder(cfg.Format, cfg.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		return NewLoki(enc, *cfg.Loki)
+	}, validateLoki)
+}
+
+// validateLoki checks the options of a Loki sink
+func validateLoki(cfg models.SinkConfig) error {
+	if cfg.Loki == nil {
+		return fmt.Errorf("loki sink requires a url")
+	}
+	l := cfg.Loki
+	if err := validateURL(l.URL); err != nil {
+		return fmt.Errorf("loki sink: %w", err)
+	}
+
+	switch l.Encoding {
+	case "", LokiEncodingJSON, LokiEncodingProtobuf:
+	default:
+		return fmt.Errorf("invalid loki encoding '%s': must be %s or %s", l.Encoding, LokiEncodingJSON, LokiEncodingProtobuf)
+	}
+
+	for name, field := range l.Labels {
+		if !lokiLabelName.MatchString(name) {
+			return fmt.Errorf("invalid loki label name '%s'", name)
+		}
+		switch field {
+		case FieldCID, FieldUUID, FieldExtractedCID, FieldCorrelationID:
+			// Every CID would start a stream of its own
+			return fmt.Errorf("loki label '%s' must not use the %s field; CIDs are sent as structured metadata", name, field)
+		case FieldRawMessage, FieldOriginalLog, FieldOffset, FieldLineNumber, FieldTimestamp, FieldLogTimestamp, FieldProcessedAt:
+			return fmt.Errorf("loki label '%s' must not use the %s field, which differs for every line", name, field)
+		}
+		if field == "" {
+			return fmt.Errorf("loki label '%s' has no field", name)
+		}
+	}
+	for name := range l.StaticLabels {
+		if !lokiLabelName.MatchString(name) {
+			return fmt.Errorf("invalid loki label name '%s'", name)
+		}
+	}
+
+	if l.BatchSize < 0 || l.Timeout < 0 || !validRetries(l.MaxRetries) || l.InitialBackoff < 0 || l.MaxBackoff < 0 {
+		return fmt.Errorf("loki batch size, timeout and retry settings must not be negative")
+	}
+	return nil
+}
+
+// lokiEntry is one log line of a stream
+type lokiEntry struct {
+	timestamp time.Time
+	line      string
+	cid       string
+}
+
+// lokiStream is the entries of one label set in a batch
+type lokiStream struct {
+	labels  map[string]string
+	entries []lokiEntry
+}
+
+// lokiSink groups records into streams by their labels and pushes batches of
+// them to Loki. The CID is sent as structured metadata of each entry rather
+// than as a label, so the number of streams does not grow with the CIDs.
+type lokiSink struct {
+	cfg     models.LokiSinkConfig
+	encoder Encoder
+	sender  *httpSender
+
+	mu      sync.Mutex
+	streams map[string]*lokiStream
+	order   []string
+	entries int
+}
+
+// NewLoki creates a sink pushing records encoded by enc to the configured
+// Loki
+func NewLoki(enc Encoder, cfg models.LokiSinkConfig) (Sink, error) {
+	return newLokiSink(enc, cfg)
+}
+
+// newLokiSink creates a Loki sink with its defaults applied
+func newLokiSink(enc Encoder, cfg models.LokiSinkConfig) (*lokiSink, error) {
+	if err := validateLoki(models.SinkConfig{Format: formatOf(enc), Loki: &cfg}); err != nil {
+		return nil, err
+	}
+	if cfg.Encoding == "" {
+		cfg.Encoding = LokiEncodingJSON
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Labels == nil {
+		cfg.Labels = defaultLokiLabels
+	}
+	if cfg.StaticLabels == nil {
+		cfg.StaticLabels = defaultLokiStaticLabels
+	}
+
+	headers := make(map[string]string, len(cfg.Headers)+2)
+	for name, value := range cfg.Headers {
+		headers[name] = value
+	}
+	if cfg.TenantID != "" {
+		headers["X-Scope-OrgID"] = cfg.TenantID
+	}
+	if cfg.Username != "" {
+		headers["Authorization"] = basicAuth(cfg.Username, cfg.Password)
+	}
+
+	endpoint := strings.TrimSuffix(cfg.URL, "/")
+	if !strings.HasSuffix(endpoint, lokiPushPath) {
+		endpoint += lokiPushPath
+	}
+	return &lokiSink{
+		cfg:     cfg,
+		encoder: enc,
+		sender: newHTTPSender(endpoint, headers, "", cfg.Timeout,
+			cfg.MaxRetries, cfg.InitialBackoff, cfg.MaxBackoff),
+		streams: make(map[string]*lokiStream),
+	}, nil
+}
+
+// Write adds a record to the stream of its labels and pushes the batch once
+// it holds BatchSize entries
+func (s *lokiSink) Write(ctx context.Context, record models.CIDRecord) error {
+	line, err := s.encoder.Encode(record)
+	if err != nil {
+		return err
+	}
+
+	labels := make(map[string]string, len(s.cfg.Labels)+len(s.cfg.StaticLabels))
+	for name, value := range s.cfg.StaticLabels {
+		labels[name] = value
+	}
+	for name, field := range s.cfg.Labels {
+		// Loki drops labels with empty values
+		if value := fieldValue(record, field); value != "" {
+			labels[name] = value
+		}
+	}
+	key := lokiLabelString(labels)
+
+	timestamp := record.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, ok := s.streams[key]
+	if !ok {
+		stream = &lokiStream{labels: labels}
+		s.streams[key] = stream
+		s.order = append(s.order, key)
+	}
+	stream.entries = append(stream.entries, lokiEntry{
+		timestamp: timestamp,
+		line:      string(line),
+		cid:       record.CID,
+	})
+	s.entries++
+
+	if s.entries < s.cfg.BatchSize {
+		return nil
+	}
+	return s.send(ctx)
+}
+
+// Flush pushes the current batch, however small
+func (s *lokiSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.send(ctx)
+}
+
+// Close pushes the current batch
+func (s *lokiSink) Close() error {
+	return s.Flush(context.Background())
+}
+
+// send pushes the current batch with the entries of every stream in
+// timestamp order, as Loki rejects out-of-order entries unless configured to
+// accept them. A batch that cannot be pushed is written to the dead-letter
+// file, if one is configured, and otherwise lost.
+func (s *lokiSink) send(ctx context.Context) error {
+	if s.entries == 0 {
+		return nil
+	}
+	streams := make([]*lokiStream, 0, len(s.order))
+	for _, key := range s.order {
+		stream := s.streams[key]
+		sort.SliceStable(stream.entries, func(i, j int) bool {
+			return stream.entries[i].timestamp.Before(stream.entries[j].timestamp)
+		})
+		streams = append(streams, stream)
+	}
+	entries := s.entries
+	s.streams = make(map[string]*lokiStream)
+	s.order = nil
+	s.entries = 0
+
+	contentType, body := "application/json", lokiPushJSON(streams)
+	if s.cfg.Encoding == LokiEncodingProtobuf {
+		contentType, body = "application/x-protobuf", snappyEncode(lokiPushProtobuf(streams))
+	}
+
+	_, attempts, err := s.sender.post(ctx, contentType, body)
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf("failed to push %d entries after %d attempts: %w", entries, attempts, err)
+	if s.cfg.DeadLetterPath == "" {
+		return err
+	}
+	if dlErr := appendDeadLetter(s.cfg.DeadLetterPath, [][]byte{lokiPushJSON(streams)}); dlErr != nil {
+		return fmt.Errorf("%w; %v", err, dlErr)
+	}
+	return fmt.Errorf("%w; written to %s", err, s.cfg.DeadLetterPath)
+}
+
+// lokiPushJSON encodes streams as a JSON push request on a single line. Each
+// value is a timestamp in nanoseconds, the line and its structured metadata.
+func lokiPushJSON(streams []*lokiStream) []byte {
+	type pushStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][]any           `json:"values"`
+	}
+	push := struct {
+		Streams []pushStream `json:"streams"`
+	}{Streams: make([]pushStream, 0, len(streams))}
+
+	for _, stream := range streams {
+		values := make([][]any, 0, len(stream.entries))
+		for _, entry := range stream.entries {
+			values = append(values, []any{
+				strconv.FormatInt(entry.timestamp.UnixNano(), 10),
+				entry.line,
+				map[string]string{FieldCID: entry.cid},
+			})
+		}
+		push.Streams = append(push.Streams, pushStream{Stream: stream.labels, Values: values})
+	}
+
+	// Only strings and maps of strings are encoded, which cannot fail
+	data, _ := json.Marshal(push)
+	return data
+}
+
+// lokiPushProtobuf encodes streams as a logproto.PushRequest message
+func lokiPushProtobuf(streams []*lokiStream) []byte {
+	var b []byte
+	for _, stream := range streams {
+		b = appendMessageField(b, 1, func(b []byte) []byte {
+			b = appendStringField(b, 1, lokiLabelString(stream.labels))
+			for _, entry := range stream.entries {
+				b = appendMessageField(b, 2, func(b []byte) []byte {
+					b = appendMessageField(b, 1, func(b []byte) []byte {
+						b = appendVarintField(b, 1, uint64(entry.timestamp.Unix()))
+						return appendVarintField(b, 2, uint64(entry.timestamp.Nanosecond()))
+					})
+					b = appendStringField(b, 2, entry.line)
+					return appendMessageField(b, 3, func(b []byte) []byte {
+						b = appendStringField(b, 1, FieldCID)
+						return appendStringField(b, 2, entry.cid)
+					})
+				})
+			}
+			return b
+		})
+	}
+	return b
+}
+
+// lokiLabelString renders labels as a sorted label selector, e.g.
+// {job="cidtracker", pod="web-0"}
+func lokiLabelString(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,285 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// pushedEntry is an entry received by the fake push endpoint
+type pushedEntry struct {
+	timestamp time.Time
+	line      string
+	metadata  map[string]string
+}
+
+// fakeLoki is an in-process push endpoint accepting JSON and snappy-protobuf
+// requests. It answers the first requests with the statuses in failures.
+type fakeLoki struct {
+	t        *testing.T
+	mu       sync.Mutex
+	failures []int
+	requests int
+	tenant   string
+	streams  map[string][]pushedEntry
+}
+
+func newFakeLoki(t *testing.T, failures ...int) *fakeLoki {
+	return &fakeLoki{t: t, failures: failures, streams: map[string][]pushedEntry{}}
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != lokiPushPath {
+		http.NotFound(w, r)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	f.tenant = r.Header.Get("X-Scope-OrgID")
+	if len(f.failures) > 0 {
+		status := f.failures[0]
+		f.failures = f.failures[1:]
+		http.Error(w, "push failed", status)
+		return
+	}
+
+	switch r.Header.Get("Content-Type") {
+	case "application/json":
+		f.decodeJSON(body)
+	case "application/x-protobuf":
+		f.decodeProtobuf(snappyDecode(f.t, body))
+	default:
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeLoki) decodeJSON(body []byte) {
+	var push struct {
+		Streams []struct {
+			Stream map[string]string   `json:"stream"`
+			Values [][]json.RawMessage `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(body, &push); err != nil {
+		f.t.Errorf("invalid JSON push request: %v", err)
+		return
+	}
+	for _, stream := range push.Streams {
+		key := lokiLabelString(stream.Stream)
+		for _, value := range stream.Values {
+			var ts, line string
+			var metadata map[string]string
+			json.Unmarshal(value[0], &ts)
+			json.Unmarshal(value[1], &line)
+			json.Unmarshal(value[2], &metadata)
+			ns, _ := strconv.ParseInt(ts, 10, 64)
+			f.streams[key] = append(f.streams[key], pushedEntry{time.Unix(0, ns).UTC(), line, metadata})
+		}
+	}
+}
+
+func (f *fakeLoki) decodeProtobuf(body []byte) {
+	for _, stream := range protoGet(protoFields(f.t, body), 1) {
+		fields := protoFields(f.t, stream.bytes)
+		key := string(protoGet(fields, 1)[0].bytes)
+		for _, entry := range protoGet(fields, 2) {
+			entryFields := protoFields(f.t, entry.bytes)
+			ts := protoFields(f.t, protoGet(entryFields, 1)[0].bytes)
+			var seconds, nanos uint64
+			if s := protoGet(ts, 1); len(s) > 0 {
+				seconds = s[0].value
+			}
+			if n := protoGet(ts, 2); len(n) > 0 {
+				nanos = n[0].value
+			}
+			metadata := map[string]string{}
+			for _, pair := range protoGet(entryFields, 3) {
+				p := protoFields(f.t, pair.bytes)
+				metadata[string(protoGet(p, 1)[0].bytes)] = string(protoGet(p, 2)[0].bytes)
+			}
+			f.streams[key] = append(f.streams[key], pushedEntry{
+				timestamp: time.Unix(int64(seconds), int64(nanos)).UTC(),
+				line:      string(protoGet(entryFields, 2)[0].bytes),
+				metadata:  metadata,
+			})
+		}
+	}
+}
+
+// newTestLoki creates a Loki sink that retries without sleeping
+func newTestLoki(t *testing.T, format string, cfg models.LokiSinkConfig) *lokiSink {
+	t.Helper()
+	s, err := newLokiSink(testEncoder(t, format), cfg)
+	if err != nil {
+		t.Fatalf("newLokiSink() error = %v", err)
+	}
+	s.sender.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	return s
+}
+
+// lokiRecord returns record n from pod, logged seconds after a fixed time
+func lokiRecord(n int, pod string, seconds int) models.CIDRecord {
+	record := testRecord(n, "json_cid")
+	record.SourceName = "pods"
+	record.Metadata = map[string]string{"namespace": "payments", "pod": pod}
+	record.Timestamp = time.Date(2024, 3, 5, 10, 0, seconds, 500, time.UTC)
+	return record
+}
+
+func TestLoki_PushesStreams(t *testing.T) {
+	for _, encoding := range []string{LokiEncodingJSON, LokiEncodingProtobuf} {
+		t.Run(encoding, func(t *testing.T) {
+			loki := newFakeLoki(t)
+			server := httptest.NewServer(loki)
+			defer server.Close()
+
+			s := newTestLoki(t, FormatStructured, models.LokiSinkConfig{
+				URL:      server.URL,
+				Encoding: encoding,
+				TenantID: "team-a",
+			})
+			ctx := context.Background()
+
+			// Out of order within the api-0 stream
+			s.Write(ctx, lokiRecord(1, "api-0", 30))
+			s.Write(ctx, lokiRecord(2, "api-1", 10))
+			s.Write(ctx, lokiRecord(3, "api-0", 20))
+			if err := s.Flush(ctx); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if loki.tenant != "team-a" {
+				t.Errorf("X-Scope-OrgID = %q, want team-a", loki.tenant)
+			}
+			if len(loki.streams) != 2 {
+				t.Fatalf("got %d streams, want 2: %v", len(loki.streams), loki.streams)
+			}
+
+			key := `{job="cidtracker", namespace="payments", pattern="json_cid", pod="api-0", source="pods"}`
+			entries := loki.streams[key]
+			if len(entries) != 2 {
+				t.Fatalf("stream %s has %d entries, want 2 (streams: %v)", key, len(entries), loki.streams)
+			}
+			if !entries[0].timestamp.Equal(lokiRecord(3, "", 20).Timestamp) || !entries[1].timestamp.Equal(lokiRecord(1, "", 30).Timestamp) {
+				t.Errorf("entries are not in timestamp order: %v, %v", entries[0].timestamp, entries[1].timestamp)
+			}
+			if entries[0].metadata[FieldCID] != testRecord(3, "").CID {
+				t.Errorf("structured metadata = %v, want the CID", entries[0].metadata)
+			}
+			if want := "[2024-03-05T10:00:20Z] CID:" + testRecord(3, "").CID + " FILE:app.log"; entries[0].line != want {
+				t.Errorf("line = %q, want %q", entries[0].line, want)
+			}
+		})
+	}
+}
+
+func TestLoki_LabelMapping(t *testing.T) {
+	loki := newFakeLoki(t)
+	server := httptest.NewServer(loki)
+	defer server.Close()
+
+	s := newTestLoki(t, FormatJSON, models.LokiSinkConfig{
+		URL:          server.URL + lokiPushPath,
+		Labels:       map[string]string{"app": FieldSource, "k8s_pod": "pod", "container": "container"},
+		StaticLabels: map[string]string{"cluster": "eu-1"},
+		BatchSize:    1,
+	})
+
+	// A full batch is pushed without waiting for Flush
+	if err := s.Write(context.Background(), lokiRecord(1, "api-0", 0)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// The empty container label is left out
+	key := `{app="pods", cluster="eu-1", k8s_pod="api-0"}`
+	if len(loki.streams[key]) != 1 {
+		t.Errorf("streams = %v, want one entry in %s", loki.streams, key)
+	}
+}
+
+func TestLoki_RetriesAndDeadLetter(t *testing.T) {
+	loki := newFakeLoki(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	server := httptest.NewServer(loki)
+	defer server.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "loki-failed.ndjson")
+	s := newTestLoki(t, FormatJSON, models.LokiSinkConfig{URL: server.URL, DeadLetterPath: deadLetter})
+	ctx := context.Background()
+
+	s.Write(ctx, lokiRecord(1, "api-0", 0))
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if loki.requests != 3 || len(loki.streams) != 1 {
+		t.Errorf("requests = %d, streams = %d; want the push accepted on the third request", loki.requests, len(loki.streams))
+	}
+
+	loki.failures = []int{http.StatusBadRequest}
+	s.Write(ctx, lokiRecord(2, "api-0", 1))
+	if err := s.Flush(ctx); err == nil {
+		t.Fatal("Flush() error = nil, want the rejected push")
+	}
+
+	lines := readLines(t, deadLetter)
+	if len(lines) != 1 {
+		t.Fatalf("dead letter has %d lines, want 1 push request", len(lines))
+	}
+	replay := newFakeLoki(t)
+	replay.decodeJSON([]byte(lines[0]))
+	for _, entries := range replay.streams {
+		if len(entries) != 1 || entries[0].metadata[FieldCID] != testRecord(2, "").CID {
+			t.Errorf("dead letter entries = %v, want record 2", entries)
+		}
+	}
+}
+
+func TestLokiLabelString(t *testing.T) {
+	got := lokiLabelString(map[string]string{"pod": `web-"0"`, "job": "cidtracker"})
+	if want := `{job="cidtracker", pod="web-\"0\""}`; got != want {
+		t.Errorf("lokiLabelString() = %s, want %s", got, want)
+	}
+}
+
+func TestValidate_LokiSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		loki    *models.LokiSinkConfig
+		wantErr bool
+	}{
+		{"valid", &models.LokiSinkConfig{URL: "http://loki:3100", Labels: map[string]string{"pod": "pod"}}, false},
+		{"protobuf", &models.LokiSinkConfig{URL: "http://loki:3100", Encoding: LokiEncodingProtobuf}, false},
+		{"missing options", nil, true},
+		{"missing url", &models.LokiSinkConfig{}, true},
+		{"unknown encoding", &models.LokiSinkConfig{URL: "http://loki:3100", Encoding: "msgpack"}, true},
+		{"cid label", &models.LokiSinkConfig{URL: "http://loki:3100", Labels: map[string]string{"cid": FieldCID}}, true},
+		{"raw message label", &models.LokiSinkConfig{URL: "http://loki:3100", Labels: map[string]string{"line": FieldRawMessage}}, true},
+		{"uuid label", &models.LokiSinkConfig{URL: "http://loki:3100", Labels: map[string]string{"id": FieldUUID}}, true},
+		{"invalid label name", &models.LokiSinkConfig{URL: "http://loki:3100", Labels: map[string]string{"k8s-pod": "pod"}}, true},
+		{"invalid static label", &models.LokiSinkConfig{URL: "http://loki:3100", StaticLabels: map[string]string{"1job": "x"}}, true},
+		{"empty field", &models.LokiSinkConfig{URL: "http://loki:3100", Labels: map[string]string{"pod": ""}}, true},
+		{"negative batch", &models.LokiSinkConfig{URL: "http://loki:3100", BatchSize: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(models.SinkConfig{Name: "loki", Type: TypeLoki, Format: FormatJSON, Loki: tt.loki})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,538 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cidtracker/pkg/decoder"
+	"cidtracker/pkg/models"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// TypeOTLP is the sink type exporting records as OpenTelemetry logs
+const TypeOTLP = "otlp"
+
+// OTLP encodings
+const (
+	// OTLPEncodingProtobuf sends binary protobuf export requests
+	OTLPEncodingProtobuf = "protobuf"
+	// OTLPEncodingJSON sends OTLP/JSON export requests
+	OTLPEncodingJSON = "json"
+)
+
+// otlpLogsPath is the path of the OTLP/HTTP logs endpoint
+const otlpLogsPath = "/v1/logs"
+
+// otlpScopeName is the instrumentation scope of the exported log records
+const otlpScopeName = "cidtracker"
+
+// Attribute keys of exported log records
+const (
+	otlpAttrCID         = "cidtracker.cid"
+	otlpAttrUUID        = "cidtracker.uuid"
+	otlpAttrUUIDVersion = "cidtracker.uuid.version"
+	otlpAttrPattern     = "cidtracker.pattern"
+	otlpAttrSource      = "cidtracker.source"
+	otlpAttrFileName    = "log.file.name"
+	otlpAttrFilePath    = "log.file.path"
+	otlpAttrStream      = "log.iostream"
+)
+
+// otlpResourceAttributes maps record metadata set by the decoders to
+// resource attributes
+var otlpResourceAttributes = map[string]string{
+	decoder.MetaNamespace:    "k8s.namespace.name",
+	decoder.MetaPod:          "k8s.pod.name",
+	decoder.MetaPodUID:       "k8s.pod.uid",
+	decoder.MetaContainer:    "k8s.container.name",
+	decoder.MetaRestartCount: "k8s.container.restart_count",
+	decoder.MetaContainerID:  "container.id",
+}
+
+func init() {
+	Register(TypeOTLP, func(cfg models.SinkConfig) (Sink, error) {
+		// The body is the original log line unless an encoder is configured
+		var enc Encoder
+		if cfg.Encoder != nil {
+			var err error
+			if enc, err = NewEncoder(cfg.Format, cfg.Encoder); err != nil {
+				return nil, err
+			}
+		}
+		return NewOTLP(enc, *cfg.OTLP)
+	}, validateOTLP)
+}
+
+// validateOTLP checks the options of an OTLP sink
+func validateOTLP(cfg models.SinkConfig) error {
+	if cfg.OTLP == nil {
+		return fmt.Errorf("otlp sink requires a url")
+	}
+	o := cfg.OTLP
+	if err := validateURL(o.URL); err != nil {
+		return fmt.Errorf("otlp sink: %w", err)
+	}
+
+	switch o.Encoding {
+	case "", OTLPEncodingProtobuf, OTLPEncodingJSON:
+	default:
+		return fmt.Errorf("invalid otlp encoding '%s': must be %s or %s", o.Encoding, OTLPEncodingProtobuf, OTLPEncodingJSON)
+	}
+
+	for key := range o.ResourceAttributes {
+		if key == "" {
+			return fmt.Errorf("otlp resource attribute names must not be empty")
+		}
+	}
+	if o.BatchSize < 0 || o.Timeout < 0 || !validRetries(o.MaxRetries) || o.InitialBackoff < 0 || o.MaxBackoff < 0 {
+		return fmt.Errorf("otlp batch size, timeout and retry settings must not be negative")
+	}
+	return nil
+}
+
+// otlpAttribute is a string or integer attribute
+type otlpAttribute struct {
+	key      string
+	value    string
+	intValue int64
+	isInt    bool
+}
+
+// otlpLog is one log record
+type otlpLog struct {
+	time       time.Time
+	observed   time.Time
+	body       string
+	traceID    []byte
+	attributes []otlpAttribute
+}
+
+// otlpResource is the log records of one resource in a batch
+type otlpResource struct {
+	attributes []otlpAttribute
+	logs       []otlpLog
+}
+
+// otlpSink groups records by the pod or host they were logged on and exports
+// batches of them to an OTLP/HTTP receiver such as the OpenTelemetry
+// Collector. A CID holding a UUID becomes the trace ID of its log record, so
+// the logs can be joined with the traces of the same ID.
+type otlpSink struct {
+	cfg      models.OTLPSinkConfig
+	encoder  Encoder
+	hostname string
+	sender   *httpSender
+
+	mu        sync.Mutex
+	resources map[string]*otlpResource
+	order     []string
+	logs      int
+}
+
+// NewOTLP creates a sink exporting records to the configured OTLP receiver.
+// The body of each log record is the record encoded by enc, or the original
+// log line if enc is nil.
+func NewOTLP(enc Encoder, cfg models.OTLPSinkConfig) (Sink, error) {
+	return newOTLPSink(enc, cfg)
+}
+
+// newOTLPSink creates an OTLP sink with its defaults applied
+func newOTLPSink(enc Encoder, cfg models.OTLPSinkConfig) (*otlpSink, error) {
+	if err := validateOTLP(models.SinkConfig{OTLP: &cfg}); err != nil {
+		return nil, err
+	}
+	if cfg.Encoding == "" {
+		cfg.Encoding = OTLPEncodingProtobuf
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	// The host.name attribute is left out if the name is unknown
+	hostname, _ := os.Hostname()
+
+	endpoint := strings.TrimSuffix(cfg.URL, "/")
+	if !strings.HasSuffix(endpoint, otlpLogsPath) {
+		endpoint += otlpLogsPath
+	}
+	return &otlpSink{
+		cfg:      cfg,
+		encoder:  enc,
+		hostname: hostname,
+		sender: newHTTPSender(endpoint, cfg.Headers, "", cfg.Timeout,
+			cfg.MaxRetries, cfg.InitialBackoff, cfg.MaxBackoff),
+		resources: make(map[string]*otlpResource),
+	}, nil
+}
+
+// Write adds a record to the current batch and exports the batch once full
+func (s *otlpSink) Write(ctx context.Context, record models.CIDRecord) error {
+	resource := s.resourceAttributes(record)
+	key := otlpAttributeKey(resource)
+	entry := newOTLPLog(record)
+	if s.encoder != nil {
+		body, err := s.encoder.Encode(record)
+		if err != nil {
+			return err
+		}
+		entry.body = string(body)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.resources[key]
+	if !ok {
+		r = &otlpResource{attributes: resource}
+		s.resources[key] = r
+		s.order = append(s.order, key)
+	}
+	r.logs = append(r.logs, entry)
+	s.logs++
+
+	if s.logs < s.cfg.BatchSize {
+		return nil
+	}
+	return s.send(ctx)
+}
+
+// Flush exports the current batch, however small
+func (s *otlpSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.send(ctx)
+}
+
+// Close exports the current batch
+func (s *otlpSink) Close() error {
+	return s.Flush(context.Background())
+}
+
+// send exports the current batch. A batch that cannot be exported is written
+// to the dead-letter file, if one is configured, and otherwise lost. Records
+// the receiver accepted only in part are not retried.
+func (s *otlpSink) send(ctx context.Context) error {
+	if s.logs == 0 {
+		return nil
+	}
+	resources := make([]*otlpResource, 0, len(s.order))
+	for _, key := range s.order {
+		resources = append(resources, s.resources[key])
+	}
+	logs := s.logs
+	s.resources = make(map[string]*otlpResource)
+	s.order = nil
+	s.logs = 0
+
+	contentType, body := "application/x-protobuf", otlpExportProtobuf(resources)
+	if s.cfg.Encoding == OTLPEncodingJSON {
+		contentType, body = "application/json", otlpExportJSON(resources)
+	}
+
+	respBody, attempts, err := s.sender.post(ctx, contentType, body)
+	if err == nil {
+		return otlpPartialSuccess(s.cfg.Encoding, respBody)
+	}
+
+	err = fmt.Errorf("failed to export %d log records after %d attempts: %w", logs, attempts, err)
+	if s.cfg.DeadLetterPath == "" {
+		return err
+	}
+	if dlErr := appendDeadLetter(s.cfg.DeadLetterPath, [][]byte{otlpExportJSON(resources)}); dlErr != nil {
+		return fmt.Errorf("%w; %v", err, dlErr)
+	}
+	return fmt.Errorf("%w; written to %s", err, s.cfg.DeadLetterPath)
+}
+
+// resourceAttributes returns the sorted resource attributes of a record: the
+// host name, the Kubernetes metadata decoded from its log file path and the
+// configured attributes
+func (s *otlpSink) resourceAttributes(record models.CIDRecord) []otlpAttribute {
+	attrs := make(map[string]string, len(s.cfg.ResourceAttributes)+len(otlpResourceAttributes)+1)
+	if s.hostname != "" {
+		attrs["host.name"] = s.hostname
+	}
+	for key, attr := range otlpResourceAttributes {
+		if value := record.Metadata[key]; value != "" {
+			attrs[attr] = value
+		}
+	}
+	for key, value := range s.cfg.ResourceAttributes {
+		attrs[key] = value
+	}
+
+	resource := make([]otlpAttribute, 0, len(attrs))
+	for key, value := range attrs {
+		attr := otlpAttribute{key: key, value: value}
+		if key == "k8s.container.restart_count" {
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				attr = otlpAttribute{key: key, intValue: n, isInt: true}
+			}
+		}
+		resource = append(resource, attr)
+	}
+	sort.Slice(resource, func(i, j int) bool { return resource[i].key < resource[j].key })
+	return resource
+}
+
+// newOTLPLog converts a record into a log record. The body is the original
+// log line. The time is only set when it was parsed from the line.
+func newOTLPLog(record models.CIDRecord) otlpLog {
+	entry := otlpLog{
+		observed: record.ExtractedAt,
+		body:     record.RawLogLine,
+	}
+	if record.TimestampParsed {
+		entry.time = record.Timestamp
+	}
+	if entry.observed.IsZero() {
+		entry.observed = time.Now()
+	}
+
+	add := func(key, value string) {
+		if value != "" {
+			entry.attributes = append(entry.attributes, otlpAttribute{key: key, value: value})
+		}
+	}
+	add(otlpAttrCID, record.CID)
+	add(otlpAttrUUID, record.UUID)
+	if id, err := uuid.Parse(record.UUID); err == nil && id != uuid.Nil {
+		entry.traceID = id[:]
+		entry.attributes = append(entry.attributes, otlpAttribute{
+			key: otlpAttrUUIDVersion, intValue: int64(id.Version()), isInt: true,
+		})
+	}
+	add(otlpAttrPattern, record.PatternName)
+	add(otlpAttrSource, record.SourceName)
+	add(otlpAttrFileName, fieldValue(record, FieldFile))
+	add(otlpAttrFilePath, record.Source)
+	add(otlpAttrStream, record.Metadata[decoder.MetaStream])
+	return entry
+}
+
+// otlpAttributeKey identifies a resource by its sorted attributes
+func otlpAttributeKey(attrs []otlpAttribute) string {
+	var b strings.Builder
+	for _, attr := range attrs {
+		b.WriteString(attr.key)
+		b.WriteByte('=')
+		if attr.isInt {
+			b.WriteString(strconv.FormatInt(attr.intValue, 10))
+		} else {
+			b.WriteString(attr.value)
+		}
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// otlpExportProtobuf encodes resources as an ExportLogsServiceRequest message
+func otlpExportProtobuf(resources []*otlpResource) []byte {
+	var b []byte
+	for _, resource := range resources {
+		// ResourceLogs
+		b = appendMessageField(b, 1, func(b []byte) []byte {
+			// Resource
+			b = appendMessageField(b, 1, func(b []byte) []byte {
+				return appendOTLPAttributes(b, 1, resource.attributes)
+			})
+			// ScopeLogs
+			return appendMessageField(b, 2, func(b []byte) []byte {
+				b = appendMessageField(b, 1, func(b []byte) []byte {
+					return appendStringField(b, 1, otlpScopeName)
+				})
+				for _, entry := range resource.logs {
+					b = appendMessageField(b, 2, func(b []byte) []byte {
+						return appendOTLPLog(b, entry)
+					})
+				}
+				return b
+			})
+		})
+	}
+	return b
+}
+
+// appendOTLPLog appends the fields of a LogRecord message
+func appendOTLPLog(b []byte, entry otlpLog) []byte {
+	if !entry.time.IsZero() {
+		b = appendFixed64Field(b, 1, uint64(entry.time.UnixNano()))
+	}
+	b = appendMessageField(b, 5, func(b []byte) []byte {
+		return appendStringField(b, 1, entry.body)
+	})
+	b = appendOTLPAttributes(b, 6, entry.attributes)
+	b = appendBytesField(b, 9, entry.traceID)
+	return appendFixed64Field(b, 11, uint64(entry.observed.UnixNano()))
+}
+
+// appendOTLPAttributes appends attributes as repeated KeyValue messages
+func appendOTLPAttributes(b []byte, field int, attrs []otlpAttribute) []byte {
+	for _, attr := range attrs {
+		b = appendMessageField(b, field, func(b []byte) []byte {
+			b = appendStringField(b, 1, attr.key)
+			// AnyValue
+			return appendMessageField(b, 2, func(b []byte) []byte {
+				if attr.isInt {
+					b = appendTag(b, 3, wireVarint)
+					return appendUvarint(b, uint64(attr.intValue))
+				}
+				b = appendTag(b, 1, wireBytes)
+				b = appendUvarint(b, uint64(len(attr.value)))
+				return append(b, attr.value...)
+			})
+		})
+	}
+	return b
+}
+
+// otlpExportJSON encodes resources as an OTLP/JSON export request on a single
+// line. As the OTLP/JSON mapping requires, trace IDs are hex-encoded and
+// 64-bit integers are strings.
+func otlpExportJSON(resources []*otlpResource) []byte {
+	type anyValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    string  `json:"intValue,omitempty"`
+	}
+	type keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	attributes := func(attrs []otlpAttribute) []keyValue {
+		kvs := make([]keyValue, 0, len(attrs))
+		for _, attr := range attrs {
+			kv := keyValue{Key: attr.key}
+			if attr.isInt {
+				kv.Value.IntValue = strconv.FormatInt(attr.intValue, 10)
+			} else {
+				value := attr.value
+				kv.Value.StringValue = &value
+			}
+			kvs = append(kvs, kv)
+		}
+		return kvs
+	}
+
+	type logRecord struct {
+		TimeUnixNano         string     `json:"timeUnixNano,omitempty"`
+		ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+		Body                 anyValue   `json:"body"`
+		Attributes           []keyValue `json:"attributes"`
+		TraceID              string     `json:"traceId,omitempty"`
+	}
+	type scopeLogs struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		LogRecords []logRecord `json:"logRecords"`
+	}
+	type resourceLogs struct {
+		Resource struct {
+			Attributes []keyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []scopeLogs `json:"scopeLogs"`
+	}
+
+	request := struct {
+		ResourceLogs []resourceLogs `json:"resourceLogs"`
+	}{ResourceLogs: make([]resourceLogs, 0, len(resources))}
+
+	for _, resource := range resources {
+		scope := scopeLogs{LogRecords: make([]logRecord, 0, len(resource.logs))}
+		scope.Scope.Name = otlpScopeName
+		for _, entry := range resource.logs {
+			body := entry.body
+			record := logRecord{
+				ObservedTimeUnixNano: strconv.FormatInt(entry.observed.UnixNano(), 10),
+				Body:                 anyValue{StringValue: &body},
+				Attributes:           attributes(entry.attributes),
+				TraceID:              hex.EncodeToString(entry.traceID),
+			}
+			if !entry.time.IsZero() {
+				record.TimeUnixNano = strconv.FormatInt(entry.time.UnixNano(), 10)
+			}
+			scope.LogRecords = append(scope.LogRecords, record)
+		}
+
+		rl := resourceLogs{ScopeLogs: []scopeLogs{scope}}
+		rl.Resource.Attributes = attributes(resource.attributes)
+		request.ResourceLogs = append(request.ResourceLogs, rl)
+	}
+
+	// Only strings are encoded, which cannot fail
+	data, _ := json.Marshal(request)
+	return data
+}
+
+// otlpPartialSuccess returns an error if an export response reports log
+// records the receiver rejected. Such records are not retried, as the
+// receiver does not say which ones they were.
+func otlpPartialSuccess(encoding string, body []byte) error {
+	var rejected int64
+	var message string
+
+	if encoding == OTLPEncodingJSON {
+		var resp struct {
+			PartialSuccess struct {
+				RejectedLogRecords json.RawMessage `json:"rejectedLogRecords"`
+				ErrorMessage       string          `json:"errorMessage"`
+			} `json:"partialSuccess"`
+		}
+		if len(bytes.TrimSpace(body)) > 0 && json.Unmarshal(body, &resp) == nil {
+			rejected, _ = strconv.ParseInt(strings.Trim(string(resp.PartialSuccess.RejectedLogRecords), `"`), 10, 64)
+			message = resp.PartialSuccess.ErrorMessage
+		}
+	} else {
+		rejected, message = otlpPartialSuccessProtobuf(body)
+	}
+
+	if rejected == 0 {
+		if message != "" {
+			log.WithField("message", message).Warn("OTLP receiver returned a warning")
+		}
+		return nil
+	}
+	return fmt.Errorf("receiver rejected %d log records: %s", rejected, message)
+}
+
+// otlpPartialSuccessProtobuf reads the partial_success field of an
+// ExportLogsServiceResponse message. Malformed responses are ignored, as the
+// export itself succeeded.
+func otlpPartialSuccessProtobuf(body []byte) (rejected int64, message string) {
+	for len(body) > 0 {
+		num, _, _, data, rest, err := consumeField(body)
+		if err != nil {
+			return rejected, message
+		}
+		body = rest
+		if num != 1 {
+			continue
+		}
+
+		for len(data) > 0 {
+			num, _, value, text, rest, err := consumeField(data)
+			if err != nil {
+				return rejected, message
+			}
+			data = rest
+			switch num {
+			case 1:
+				rejected = int64(value)
+			case 2:
+				message = string(text)
+			}
+		}
+	}
+	return rejected, message
+}
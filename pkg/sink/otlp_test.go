@@ -0,0 +1,433 @@
+package sink
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// receivedLog is a log record received by the OTLP receiver stub, with its
+// attributes and those of its resource rendered as strings
+type receivedLog struct {
+	resource   map[string]string
+	scope      string
+	time       uint64
+	observed   uint64
+	body       string
+	traceID    string
+	attributes map[string]string
+}
+
+// otlpReceiver is an OTLP/HTTP logs receiver stub accepting protobuf and JSON
+// export requests. It answers the first requests with the statuses in
+// failures, and accepted requests with response.
+type otlpReceiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	failures []int
+	response []byte
+	requests int
+	header   http.Header
+	logs     []receivedLog
+}
+
+func (r *otlpReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != otlpLogsPath {
+		http.NotFound(w, req)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	r.header = req.Header.Clone()
+	if len(r.failures) > 0 {
+		status := r.failures[0]
+		r.failures = r.failures[1:]
+		http.Error(w, "unavailable", status)
+		return
+	}
+
+	switch req.Header.Get("Content-Type") {
+	case "application/x-protobuf":
+		r.decodeProtobuf(body)
+	case "application/json":
+		r.decodeJSON(body)
+	default:
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", req.Header.Get("Content-Type"))
+	w.Write(r.response)
+}
+
+// protoAttributes decodes repeated KeyValue messages
+func (r *otlpReceiver) protoAttributes(fields []protoField) map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range fields {
+		kvFields := protoFields(r.t, kv.bytes)
+		key := string(protoGet(kvFields, 1)[0].bytes)
+		value := protoFields(r.t, protoGet(kvFields, 2)[0].bytes)
+		if s := protoGet(value, 1); len(s) > 0 {
+			attrs[key] = string(s[0].bytes)
+		} else if i := protoGet(value, 3); len(i) > 0 {
+			attrs[key] = strconv.FormatInt(int64(i[0].value), 10)
+		} else {
+			attrs[key] = ""
+		}
+	}
+	return attrs
+}
+
+func (r *otlpReceiver) decodeProtobuf(body []byte) {
+	for _, rl := range protoGet(protoFields(r.t, body), 1) {
+		rlFields := protoFields(r.t, rl.bytes)
+		resource := r.protoAttributes(protoGet(protoFields(r.t, protoGet(rlFields, 1)[0].bytes), 1))
+		for _, sl := range protoGet(rlFields, 2) {
+			slFields := protoFields(r.t, sl.bytes)
+			scope := string(protoGet(protoFields(r.t, protoGet(slFields, 1)[0].bytes), 1)[0].bytes)
+			for _, lr := range protoGet(slFields, 2) {
+				fields := protoFields(r.t, lr.bytes)
+				entry := receivedLog{
+					resource:   resource,
+					scope:      scope,
+					attributes: r.protoAttributes(protoGet(fields, 6)),
+				}
+				if f := protoGet(fields, 1); len(f) > 0 {
+					entry.time = f[0].value
+				}
+				if f := protoGet(fields, 11); len(f) > 0 {
+					entry.observed = f[0].value
+				}
+				if f := protoGet(protoFields(r.t, protoGet(fields, 5)[0].bytes), 1); len(f) > 0 {
+					entry.body = string(f[0].bytes)
+				}
+				if f := protoGet(fields, 9); len(f) > 0 {
+					entry.traceID = hex.EncodeToString(f[0].bytes)
+				}
+				r.logs = append(r.logs, entry)
+			}
+		}
+	}
+}
+
+func (r *otlpReceiver) decodeJSON(body []byte) {
+	type keyValue struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue *string `json:"stringValue"`
+			IntValue    string  `json:"intValue"`
+		} `json:"value"`
+	}
+	attributes := func(kvs []keyValue) map[string]string {
+		attrs := map[string]string{}
+		for _, kv := range kvs {
+			if kv.Value.StringValue != nil {
+				attrs[kv.Key] = *kv.Value.StringValue
+			} else {
+				attrs[kv.Key] = kv.Value.IntValue
+			}
+		}
+		return attrs
+	}
+
+	var request struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []keyValue `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				LogRecords []struct {
+					TimeUnixNano         string `json:"timeUnixNano"`
+					ObservedTimeUnixNano string `json:"observedTimeUnixNano"`
+					Body                 struct {
+						StringValue string `json:"stringValue"`
+					} `json:"body"`
+					Attributes []keyValue `json:"attributes"`
+					TraceID    string     `json:"traceId"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		r.t.Errorf("invalid OTLP/JSON request: %v", err)
+		return
+	}
+
+	for _, rl := range request.ResourceLogs {
+		resource := attributes(rl.Resource.Attributes)
+		for _, sl := range rl.ScopeLogs {
+			for _, lr := range sl.LogRecords {
+				entry := receivedLog{
+					resource:   resource,
+					scope:      sl.Scope.Name,
+					body:       lr.Body.StringValue,
+					traceID:    lr.TraceID,
+					attributes: attributes(lr.Attributes),
+				}
+				entry.time, _ = strconv.ParseUint(lr.TimeUnixNano, 10, 64)
+				entry.observed, _ = strconv.ParseUint(lr.ObservedTimeUnixNano, 10, 64)
+				r.logs = append(r.logs, entry)
+			}
+		}
+	}
+}
+
+// newTestOTLP creates an OTLP sink that retries without sleeping
+func newTestOTLP(t *testing.T, cfg models.OTLPSinkConfig) *otlpSink {
+	t.Helper()
+	s, err := newOTLPSink(nil, cfg)
+	if err != nil {
+		t.Fatalf("newOTLPSink() error = %v", err)
+	}
+	s.sender.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	return s
+}
+
+// otlpRecord returns record n read from a pod's log file
+func otlpRecord(n int, pod string) models.CIDRecord {
+	return models.CIDRecord{
+		CID:             "req-" + testRecord(n, "").CID,
+		UUID:            testRecord(n, "").CID,
+		Timestamp:       time.Date(2024, 3, 5, 10, 0, n, 0, time.UTC),
+		TimestampParsed: true,
+		RawLogLine:      "payment failed CID:req-" + testRecord(n, "").CID,
+		IsValid:         true,
+		PatternName:     "standard_cid",
+		ExtractedAt:     time.Date(2024, 3, 5, 10, 1, 0, 0, time.UTC),
+		Metadata: map[string]string{
+			"namespace":     "payments",
+			"pod":           pod,
+			"container":     "api",
+			"restart_count": "0",
+			"stream":        "stderr",
+		},
+		Source:     "/var/log/pods/payments_" + pod + "_0b7c/api/0.log",
+		SourceName: "pods",
+	}
+}
+
+func TestOTLP_Export(t *testing.T) {
+	hostname, _ := os.Hostname()
+
+	for _, encoding := range []string{OTLPEncodingProtobuf, OTLPEncodingJSON} {
+		t.Run(encoding, func(t *testing.T) {
+			receiver := &otlpReceiver{t: t}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			s := newTestOTLP(t, models.OTLPSinkConfig{
+				URL:                server.URL,
+				Encoding:           encoding,
+				Headers:            map[string]string{"Authorization": "Bearer token"},
+				ResourceAttributes: map[string]string{"service.name": "checkout"},
+			})
+			ctx := context.Background()
+
+			s.Write(ctx, otlpRecord(1, "api-0"))
+			s.Write(ctx, otlpRecord(2, "api-1"))
+			s.Write(ctx, otlpRecord(3, "api-0"))
+			if err := s.Flush(ctx); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if got := receiver.header.Get("Authorization"); got != "Bearer token" {
+				t.Errorf("Authorization = %q, want the configured header", got)
+			}
+			if len(receiver.logs) != 3 {
+				t.Fatalf("received %d log records, want 3", len(receiver.logs))
+			}
+			// Records are grouped by resource: both api-0 records come first
+			if receiver.logs[1].resource["k8s.pod.name"] != "api-0" || receiver.logs[2].resource["k8s.pod.name"] != "api-1" {
+				t.Errorf("log records are not grouped by pod")
+			}
+
+			got := receiver.logs[0]
+			wantResource := map[string]string{
+				"host.name":                   hostname,
+				"service.name":                "checkout",
+				"k8s.namespace.name":          "payments",
+				"k8s.pod.name":                "api-0",
+				"k8s.container.name":          "api",
+				"k8s.container.restart_count": "0",
+			}
+			for key, want := range wantResource {
+				if got.resource[key] != want {
+					t.Errorf("resource %s = %q, want %q", key, got.resource[key], want)
+				}
+			}
+
+			wantAttributes := map[string]string{
+				otlpAttrCID:         otlpRecord(1, "").CID,
+				otlpAttrUUID:        otlpRecord(1, "").UUID,
+				otlpAttrUUIDVersion: "5",
+				otlpAttrPattern:     "standard_cid",
+				otlpAttrSource:      "pods",
+				otlpAttrFileName:    "0.log",
+				otlpAttrFilePath:    "/var/log/pods/payments_api-0_0b7c/api/0.log",
+				otlpAttrStream:      "stderr",
+			}
+			for key, want := range wantAttributes {
+				if got.attributes[key] != want {
+					t.Errorf("attribute %s = %q, want %q", key, got.attributes[key], want)
+				}
+			}
+
+			if want := strings.ReplaceAll(otlpRecord(1, "").UUID, "-", ""); got.traceID != want {
+				t.Errorf("trace ID = %s, want %s", got.traceID, want)
+			}
+			if got.scope != otlpScopeName || got.body != otlpRecord(1, "").RawLogLine {
+				t.Errorf("scope = %q, body = %q; want the scope name and the log line", got.scope, got.body)
+			}
+			if got.time != uint64(otlpRecord(1, "").Timestamp.UnixNano()) || got.observed != uint64(otlpRecord(1, "").ExtractedAt.UnixNano()) {
+				t.Errorf("time = %d, observed = %d; want the record's timestamps", got.time, got.observed)
+			}
+		})
+	}
+}
+
+func TestOTLP_WithoutUUID(t *testing.T) {
+	receiver := &otlpReceiver{t: t}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	s := newTestOTLP(t, models.OTLPSinkConfig{URL: server.URL + otlpLogsPath})
+	record := otlpRecord(1, "api-0")
+	record.UUID = "00000000-0000-0000-0000-000000000000"
+	record.TimestampParsed = false
+	s.Write(context.Background(), record)
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	got := receiver.logs[0]
+	if got.traceID != "" {
+		t.Errorf("trace ID = %s, want none for the nil UUID", got.traceID)
+	}
+	if _, ok := got.attributes[otlpAttrUUIDVersion]; ok {
+		t.Error("UUID version set without a UUID")
+	}
+	if got.time != 0 {
+		t.Errorf("time = %d, want unset for an unparsed timestamp", got.time)
+	}
+}
+
+func TestOTLP_EncodedBody(t *testing.T) {
+	receiver := &otlpReceiver{t: t}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	enc, err := NewEncoder(FormatLogfmt, &models.EncoderConfig{Fields: []string{"cid", "pod"}})
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	s, err := newOTLPSink(enc, models.OTLPSinkConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("newOTLPSink() error = %v", err)
+	}
+	s.Write(context.Background(), otlpRecord(1, "api-0"))
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := "cid=" + otlpRecord(1, "").CID + " pod=api-0"
+	if got := receiver.logs[0].body; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestOTLP_Failures(t *testing.T) {
+	partialProtobuf := appendMessageField(nil, 1, func(b []byte) []byte {
+		b = appendVarintField(b, 1, 2)
+		return appendStringField(b, 2, "attribute limit exceeded")
+	})
+
+	tests := []struct {
+		name       string
+		encoding   string
+		failures   []int
+		response   []byte
+		wantErr    string
+		deadLetter bool
+	}{
+		{"retried", OTLPEncodingProtobuf, []int{503, 502}, nil, "", false},
+		{"partial success", OTLPEncodingProtobuf, nil, partialProtobuf, "rejected 2 log records: attribute limit exceeded", false},
+		{"partial success json", OTLPEncodingJSON, nil, []byte(`{"partialSuccess":{"rejectedLogRecords":"1","errorMessage":"too big"}}`), "rejected 1 log records: too big", false},
+		{"warning only", OTLPEncodingJSON, nil, []byte(`{"partialSuccess":{"errorMessage":"deprecated field"}}`), "", false},
+		{"permanent", OTLPEncodingProtobuf, []int{400}, nil, "after 1 attempts", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &otlpReceiver{t: t, failures: tt.failures, response: tt.response}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			deadLetter := filepath.Join(t.TempDir(), "otlp-failed.ndjson")
+			s := newTestOTLP(t, models.OTLPSinkConfig{URL: server.URL, Encoding: tt.encoding, DeadLetterPath: deadLetter})
+			s.Write(context.Background(), otlpRecord(1, "api-0"))
+			err := s.Flush(context.Background())
+
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Flush() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Flush() error = %v, want %q", err, tt.wantErr)
+			}
+
+			if !tt.deadLetter {
+				return
+			}
+			lines := readLines(t, deadLetter)
+			if len(lines) != 1 {
+				t.Fatalf("dead letter has %d lines, want 1 export request", len(lines))
+			}
+			replay := &otlpReceiver{t: t}
+			replay.decodeJSON([]byte(lines[0]))
+			if len(replay.logs) != 1 || replay.logs[0].attributes[otlpAttrCID] != otlpRecord(1, "").CID {
+				t.Errorf("dead letter logs = %+v, want record 1", replay.logs)
+			}
+		})
+	}
+}
+
+func TestValidate_OTLPSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		otlp    *models.OTLPSinkConfig
+		wantErr bool
+	}{
+		{"valid", &models.OTLPSinkConfig{URL: "http://otel-collector:4318"}, false},
+		{"json", &models.OTLPSinkConfig{URL: "http://otel-collector:4318", Encoding: OTLPEncodingJSON}, false},
+		{"missing options", nil, true},
+		{"missing url", &models.OTLPSinkConfig{}, true},
+		{"grpc", &models.OTLPSinkConfig{URL: "http://otel-collector:4317", Encoding: "grpc"}, true},
+		{"empty attribute", &models.OTLPSinkConfig{URL: "http://otel-collector:4318", ResourceAttributes: map[string]string{"": "x"}}, true},
+		{"negative timeout", &models.OTLPSinkConfig{URL: "http://otel-collector:4318", Timeout: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(models.SinkConfig{Name: "otel", Type: TypeOTLP, Format: FormatJSON, OTLP: tt.otlp})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
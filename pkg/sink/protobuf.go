@@ -0,0 +1,115 @@
+package sink
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// errProtobuf is returned for malformed protocol buffer messages
+var errProtobuf = errors.New("malformed protobuf message")
+
+// Protocol buffer wire types used by the encoders in this package
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// appendTag appends the key of a field
+func appendTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// appendUvarint appends v as a varint
+func appendUvarint(b []byte, v uint64) []byte {
+	return binary.AppendUvarint(b, v)
+}
+
+// appendVarintField appends an integer field, omitting the zero value
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendFixed64Field appends a fixed64 field, omitting the zero value
+func appendFixed64Field(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireFixed64)
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+// appendBytesField appends a length-delimited field, such as bytes or an
+// embedded message, omitting it when empty
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	if len(data) == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendStringField appends a string field, omitting the empty string
+func appendStringField(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendMessageField appends an embedded message built by encode. Unlike
+// appendBytesField it keeps an empty message, which marks a present field.
+func appendMessageField(b []byte, field int, encode func([]byte) []byte) []byte {
+	msg := encode(nil)
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+// consumeField reads the first field of b and returns its number and wire
+// type, its value for varint and fixed64 fields or its data for
+// length-delimited ones, and the rest of b
+func consumeField(b []byte) (num, wireType int, value uint64, data, rest []byte, err error) {
+	key, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, 0, 0, nil, nil, errProtobuf
+	}
+	b = b[n:]
+	num, wireType = int(key>>3), int(key&7)
+
+	switch wireType {
+	case wireVarint:
+		value, n = binary.Uvarint(b)
+		if n <= 0 {
+			return 0, 0, 0, nil, nil, errProtobuf
+		}
+		return num, wireType, value, nil, b[n:], nil
+	case wireFixed64:
+		if len(b) < 8 {
+			return 0, 0, 0, nil, nil, errProtobuf
+		}
+		return num, wireType, binary.LittleEndian.Uint64(b), nil, b[8:], nil
+	case wireBytes:
+		length, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < length {
+			return 0, 0, 0, nil, nil, errProtobuf
+		}
+		end := n + int(length)
+		return num, wireType, 0, b[n:end], b[end:], nil
+	case wireFixed32:
+		if len(b) < 4 {
+			return 0, 0, 0, nil, nil, errProtobuf
+		}
+		return num, wireType, uint64(binary.LittleEndian.Uint32(b)), nil, b[4:], nil
+	default:
+		return 0, 0, 0, nil, nil, errProtobuf
+	}
+}
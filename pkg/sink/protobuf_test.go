@@ -0,0 +1,103 @@
+package sink
+
+import (
+	"bytes"
+	"testing"
+)
+
+// protoField is one decoded field of a protocol buffer message
+type protoField struct {
+	num   int
+	wire  int
+	value uint64
+	bytes []byte
+}
+
+// protoFields decodes the fields of a message, failing the test on
+// malformed input
+func protoFields(t *testing.T, b []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(b) > 0 {
+		num, wire, value, data, rest, err := consumeField(b)
+		if err != nil {
+			t.Fatalf("consumeField() error = %v", err)
+		}
+		fields = append(fields, protoField{num: num, wire: wire, value: value, bytes: data})
+		b = rest
+	}
+	return fields
+}
+
+// protoGet returns the fields numbered num
+func protoGet(fields []protoField, num int) []protoField {
+	var matches []protoField
+	for _, f := range fields {
+		if f.num == num {
+			matches = append(matches, f)
+		}
+	}
+	return matches
+}
+
+func TestProtobufAppend(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want []byte
+	}{
+		{"varint", appendVarintField(nil, 1, 150), []byte{0x08, 0x96, 0x01}},
+		{"zero varint", appendVarintField(nil, 1, 0), nil},
+		{"string", appendStringField(nil, 2, "testing"), []byte{0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}},
+		{"empty string", appendStringField(nil, 2, ""), nil},
+		{"bytes", appendBytesField(nil, 3, []byte{1, 2}), []byte{0x1a, 0x02, 1, 2}},
+		{"fixed64", appendFixed64Field(nil, 1, 1), []byte{0x09, 1, 0, 0, 0, 0, 0, 0, 0}},
+		{"message", appendMessageField(nil, 3, func(b []byte) []byte {
+			return appendVarintField(b, 1, 150)
+		}), []byte{0x1a, 0x03, 0x08, 0x96, 0x01}},
+		{"empty message", appendMessageField(nil, 4, func(b []byte) []byte { return b }), []byte{0x22, 0x00}},
+	}
+
+	for _, tt := range tests {
+		if !bytes.Equal(tt.got, tt.want) {
+			t.Errorf("%s = % x, want % x", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestConsumeField(t *testing.T) {
+	var msg []byte
+	msg = appendVarintField(msg, 1, 150)
+	msg = appendFixed64Field(msg, 2, 1<<40)
+	msg = appendStringField(msg, 3, "cid")
+	msg = append(msg, 0x25, 1, 0, 0, 0) // field 4, fixed32
+
+	fields := protoFields(t, msg)
+	want := []protoField{
+		{num: 1, wire: wireVarint, value: 150},
+		{num: 2, wire: wireFixed64, value: 1 << 40},
+		{num: 3, wire: wireBytes, bytes: []byte("cid")},
+		{num: 4, wire: wireFixed32, value: 1},
+	}
+	if len(fields) != len(want) {
+		t.Fatalf("decoded %d fields, want %d", len(fields), len(want))
+	}
+	for i, f := range fields {
+		w := want[i]
+		if f.num != w.num || f.wire != w.wire || f.value != w.value || !bytes.Equal(f.bytes, w.bytes) {
+			t.Errorf("field %d = %+v, want %+v", i, f, w)
+		}
+	}
+
+	for _, malformed := range [][]byte{
+		{0x80},            // truncated key
+		{0x08, 0x80},      // truncated varint
+		{0x09, 1, 2},      // truncated fixed64
+		{0x1a, 0x05, 'a'}, // length beyond the message
+		{0x0b},            // group wire type
+	} {
+		if _, _, _, _, _, err := consumeField(malformed); err == nil {
+			t.Errorf("consumeField(% x) error = nil, want an error", malformed)
+		}
+	}
+}
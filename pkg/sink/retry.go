@@ -0,0 +1,155 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Retry defaults for sinks sending over the network
+const (
+	DefaultMaxRetries     = 5
+	DefaultInitialBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff     = 30 * time.Second
+	DefaultRequestTimeout = 10 * time.Second
+)
+
+// backoff computes the delay before a retry: it doubles from initial with
+// every attempt up to max, and a random half of it is added as jitter so that
+// many trackers do not retry against one endpoint in lockstep
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+// newBackoff creates a backoff, using the defaults for unset durations
+func newBackoff(initial, max time.Duration) backoff {
+	if initial <= 0 {
+		initial = DefaultInitialBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+	if max < initial {
+		max = initial
+	}
+	return backoff{initial: initial, max: max}
+}
+
+// delay returns the wait before retry number attempt, counting from zero
+func (b backoff) delay(attempt int) time.Duration {
+	d := b.initial
+	for i := 0; i < attempt && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retrier repeats failed attempts to reach one target with backoff. Sinks
+// sending over the network share it so that they retry alike.
+type retrier struct {
+	target     string
+	maxRetries int
+	backoff    backoff
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// newRetrier creates a retrier for target, using the defaults for unset retry
+// settings. A nil maxRetries selects DefaultMaxRetries, while zero disables
+// retries.
+func newRetrier(target string, maxRetries *int, initialBackoff, maxBackoff time.Duration) retrier {
+	retries := DefaultMaxRetries
+	if maxRetries != nil {
+		retries = *maxRetries
+	}
+	return retrier{
+		target:     target,
+		maxRetries: retries,
+		backoff:    newBackoff(initialBackoff, maxBackoff),
+		sleep:      sleepContext,
+	}
+}
+
+// retry calls attempt until it succeeds, reports a failure as permanent or
+// the retries are used up, and returns the number of attempts. Between
+// attempts it waits as long as attempt asks, or the backoff delay, but never
+// longer than the maximum backoff. Only cancelling ctx ends a wait early.
+func (r *retrier) retry(ctx context.Context, attempt func() (retry bool, wait time.Duration, err error)) (int, error) {
+	for n := 0; ; n++ {
+		retry, wait, err := attempt()
+		if err == nil {
+			return n + 1, nil
+		}
+		if !retry || n >= r.maxRetries {
+			return n + 1, err
+		}
+
+		if wait <= 0 {
+			wait = r.backoff.delay(n)
+		}
+		// A server asking for a day would otherwise park the sink for one
+		if wait > r.backoff.max {
+			wait = r.backoff.max
+		}
+		log.WithError(err).WithFields(log.Fields{
+			"target":  r.target,
+			"attempt": n + 1,
+			"wait":    wait.String(),
+		}).Debug("Attempt failed, retrying")
+
+		if sleepErr := r.sleep(ctx, wait); sleepErr != nil {
+			return n + 1, fmt.Errorf("%w (retry cancelled: %v)", err, sleepErr)
+		}
+	}
+}
+
+// validRetries reports whether a configured number of retries is unset or
+// not negative
+func validRetries(maxRetries *int) bool {
+	return maxRetries == nil || *maxRetries >= 0
+}
+
+// retryableStatus reports whether a request that failed with an HTTP status
+// may succeed if sent again
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// retryAfter parses a Retry-After header given as seconds or as an HTTP date
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d or until ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
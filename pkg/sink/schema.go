@@ -0,0 +1,135 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// jsonSchema is the subset of JSON Schema needed to describe Record
+type jsonSchema struct {
+	Schema               string           `json:"$schema,omitempty"`
+	Title                string           `json:"title,omitempty"`
+	Description          string           `json:"description,omitempty"`
+	Type                 string           `json:"type"`
+	Format               string           `json:"format,omitempty"`
+	Const                any              `json:"const,omitempty"`
+	Properties           schemaProperties `json:"properties,omitempty"`
+	Required             []string         `json:"required,omitempty"`
+	AdditionalProperties *jsonSchema      `json:"additionalProperties,omitempty"`
+}
+
+// schemaProperty is a named property of an object schema
+type schemaProperty struct {
+	name   string
+	schema *jsonSchema
+}
+
+// schemaProperties keeps the properties of an object schema in field order
+type schemaProperties []schemaProperty
+
+func (p schemaProperties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, prop := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(prop.name)
+		schema, err := json.Marshal(prop.schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(schema)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// RecordSchema returns the JSON Schema of Record, generated from its fields
+// and their json and description tags. Fields without omitempty are required.
+func RecordSchema() ([]byte, error) {
+	schema, err := schemaOf(reflect.TypeOf(Record{}))
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = "https://json-schema.org/draft/2020-12/schema"
+	schema.Title = fmt.Sprintf("CID Tracker output record, schema version %d", SchemaVersion)
+	schema.Description = "Document written by the json output format for each valid CID"
+	for _, prop := range schema.Properties {
+		if prop.name == FieldSchemaVersion {
+			prop.schema.Const = SchemaVersion
+		}
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaOf describes a Go type as a JSON Schema
+func schemaOf(t reflect.Type) (*jsonSchema, error) {
+	if t == timeType {
+		return &jsonSchema{Type: "string", Format: "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return &jsonSchema{Type: "string"}, nil
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return &jsonSchema{Type: "integer"}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("record schema: map keys of %s must be strings", t)
+		}
+		values, err := schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &jsonSchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return nil, fmt.Errorf("record schema: unsupported type %s", t)
+	}
+}
+
+// structSchema describes the exported fields of a struct as an object
+func structSchema(t reflect.Type) (*jsonSchema, error) {
+	schema := &jsonSchema{Type: "object"}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		prop, err := schemaOf(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("record schema: field %s: %w", field.Name, err)
+		}
+		prop.Description = field.Tag.Get("description")
+		schema.Properties = append(schema.Properties, schemaProperty{name: name, schema: prop})
+		if options != "omitempty" {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema, nil
+}
@@ -0,0 +1,80 @@
+package sink
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// recordSchemaFile is the published schema, regenerated with -print-schema
+const recordSchemaFile = "../../docs/schema/record-v1.json"
+
+func TestRecordSchema_MatchesPublished(t *testing.T) {
+	got, err := RecordSchema()
+	if err != nil {
+		t.Fatalf("RecordSchema() error = %v", err)
+	}
+	want, err := os.ReadFile(recordSchemaFile)
+	if err != nil {
+		t.Fatalf("failed to read published schema: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("RecordSchema() differs from %s; regenerate it with cidtracker -print-schema:\n%s", recordSchemaFile, got)
+	}
+}
+
+func TestRecordSchema_DescribesEncodedRecords(t *testing.T) {
+	data, err := RecordSchema()
+	if err != nil {
+		t.Fatalf("RecordSchema() error = %v", err)
+	}
+	var schema struct {
+		Properties map[string]struct {
+			Type  string `json:"type"`
+			Const any    `json:"const"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("RecordSchema() = %s, not JSON: %v", data, err)
+	}
+
+	encoded, err := testEncoder(t, FormatJSON).Encode(encoderRecord())
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		t.Fatalf("Encode() = %s, not JSON: %v", encoded, err)
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := doc[name]; !ok {
+			t.Errorf("record %s lacks required field %s", encoded, name)
+		}
+	}
+	for name, value := range doc {
+		prop, ok := schema.Properties[name]
+		if !ok {
+			t.Errorf("field %s is not in the schema", name)
+			continue
+		}
+		var typ string
+		switch value.(type) {
+		case string:
+			typ = "string"
+		case float64:
+			typ = "integer"
+		case map[string]any:
+			typ = "object"
+		case bool:
+			typ = "boolean"
+		}
+		if typ != prop.Type {
+			t.Errorf("field %s = %v, want schema type %s", name, value, prop.Type)
+		}
+	}
+	if v := schema.Properties[FieldSchemaVersion].Const; v != float64(SchemaVersion) || doc[FieldSchemaVersion] != v {
+		t.Errorf("schema_version const = %v, record = %v, want %d", v, doc[FieldSchemaVersion], SchemaVersion)
+	}
+}
@@ -0,0 +1,112 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"cidtracker/pkg/models"
+)
+
+// Sink receives valid CID records. Records of one log file arrive in order,
+// but Write may be called concurrently for different files. Sinks that buffer
+// records write them out on Flush, and Close flushes and releases the sink.
+type Sink interface {
+	Write(ctx context.Context, record models.CIDRecord) error
+	Flush(ctx context.Context) error
+	Close() error
+}
+
+// Factory builds a sink from its configuration
+type Factory func(cfg models.SinkConfig) (Sink, error)
+
+// Validator checks the type-specific options of a sink configuration without
+// side effects such as opening files or connections
+type Validator func(cfg models.SinkConfig) error
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]Factory)
+	validators = make(map[string]Validator)
+)
+
+// Register makes a sink type available to New. validate may be nil for types
+// without options of their own. Register panics if the type is already
+// registered, as two packages claiming one name is a programming error.
+func Register(typ string, factory Factory, validate Validator) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := factories[typ]; exists {
+		panic(fmt.Sprintf("sink type '%s' registered twice", typ))
+	}
+	factories[typ] = factory
+	if validate != nil {
+		validators[typ] = validate
+	}
+}
+
+// Types returns the registered sink types in sorted order
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(factories))
+	for typ := range factories {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New builds a sink of the configured type
+func New(cfg models.SinkConfig) (Sink, error) {
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+
+	registryMu.RLock()
+	factory := factories[cfg.Type]
+	registryMu.RUnlock()
+
+	s, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("sink '%s': %w", cfg.Name, err)
+	}
+	return s, nil
+}
+
+// Validate checks that a sink's type is registered and that its format and
+// encoder options, filter, overflow policy and type-specific options are
+// valid, without building it
+func Validate(cfg models.SinkConfig) error {
+	registryMu.RLock()
+	_, ok := factories[cfg.Type]
+	validate := validators[cfg.Type]
+	registryMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("sink '%s': unknown type '%s' (available: %v)", cfg.Name, cfg.Type, Types())
+	}
+
+	if _, err := NewEncoder(cfg.Format, cfg.Encoder); err != nil {
+		return fmt.Errorf("sink '%s': %w", cfg.Name, err)
+	}
+	if _, err := NewFilter(cfg.Filter); err != nil {
+		return fmt.Errorf("sink '%s': %w", cfg.Name, err)
+	}
+	if cfg.FlushInterval < 0 {
+		return fmt.Errorf("sink '%s': flush interval must not be negative", cfg.Name)
+	}
+	switch cfg.Overflow {
+	case "", OverflowBlock, OverflowDrop:
+	default:
+		return fmt.Errorf("sink '%s': invalid overflow policy '%s': must be %s or %s", cfg.Name, cfg.Overflow, OverflowBlock, OverflowDrop)
+	}
+	if validate != nil {
+		if err := validate(cfg); err != nil {
+			return fmt.Errorf("sink '%s': %w", cfg.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package sink
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cidtracker/pkg/models"
+)
+
+func TestTypes(t *testing.T) {
+	found := false
+	for _, typ := range Types() {
+		if typ == TypeStdout {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Types() = %v, want it to include %s", Types(), TypeStdout)
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() did not panic for a duplicate type")
+		}
+	}()
+	Register(TypeStdout, func(models.SinkConfig) (Sink, error) { return nil, nil }, nil)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     models.SinkConfig
+		wantErr string
+	}{
+		{
+			name: "stdout",
+			cfg:  models.SinkConfig{Name: "out", Type: TypeStdout, Format: FormatJSON},
+		},
+		{
+			name:    "unknown type",
+			cfg:     models.SinkConfig{Name: "out", Type: "carrier-pigeon", Format: FormatJSON},
+			wantErr: "unknown type",
+		},
+		{
+			name:    "unknown format",
+			cfg:     models.SinkConfig{Name: "out", Type: TypeStdout, Format: "xml"},
+			wantErr: "invalid format",
+		},
+		{
+			name:    "invalid encoder",
+			cfg:     models.SinkConfig{Name: "out", Type: TypeStdout, Format: FormatTemplate, Encoder: &models.EncoderConfig{Template: "{{.cid"}},
+			wantErr: "invalid encoder template",
+		},
+		{
+			name: "invalid filter",
+			cfg: models.SinkConfig{Name: "out", Type: TypeStdout, Format: FormatJSON,
+				Filter: &models.SinkFilter{Files: []string{"[.log"}}},
+			wantErr: "invalid glob",
+		},
+		{
+			name:    "invalid overflow",
+			cfg:     models.SinkConfig{Name: "out", Type: TypeStdout, Format: FormatJSON, Overflow: "spill"},
+			wantErr: "invalid overflow policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "sink 'out'") {
+				t.Errorf("Validate() error = %v, want it to name the sink", err)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	s, err := New(models.SinkConfig{Name: "out", Type: TypeStdout, Format: FormatStructured})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := s.Flush(context.Background()); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	if _, err := New(models.SinkConfig{Name: "out", Type: "unknown", Format: FormatJSON}); err == nil {
+		t.Error("New() error = nil for an unknown type")
+	}
+}
@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"encoding/binary"
+)
+
+// Snappy block format constants, see
+// https://github.com/google/snappy/blob/main/format_description.txt
+const (
+	snappyTagLiteral = 0x00
+	snappyTagCopy1   = 0x01
+	snappyTagCopy2   = 0x02
+
+	// snappyBlockSize bounds the distance of back references, which are
+	// searched within blocks of this size
+	snappyBlockSize = 1 << 16
+	snappyTableBits = 14
+	snappyMinMatch  = 4
+)
+
+// snappyEncode compresses src in the snappy block format, as expected by
+// push APIs such as Loki's. It finds matches with a single hash table probe,
+// trading some compression for simplicity.
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(make([]byte, 0, len(src)/2+16), uint64(len(src)))
+
+	var table [1 << snappyTableBits]int32
+	for len(src) > 0 {
+		block := src
+		if len(block) > snappyBlockSize {
+			block = block[:snappyBlockSize]
+		}
+		src = src[len(block):]
+
+		for i := range table {
+			table[i] = -1
+		}
+		dst = snappyEncodeBlock(dst, block, &table)
+	}
+	return dst
+}
+
+// snappyEncodeBlock appends the literals and copies encoding one block
+func snappyEncodeBlock(dst, block []byte, table *[1 << snappyTableBits]int32) []byte {
+	literal := 0
+	for i := 0; i+snappyMinMatch <= len(block); {
+		v := binary.LittleEndian.Uint32(block[i:])
+		h := (v * 0x1e35a7bd) >> (32 - snappyTableBits)
+		candidate := int(table[h])
+		table[h] = int32(i)
+
+		if candidate < 0 || binary.LittleEndian.Uint32(block[candidate:]) != v {
+			i++
+			continue
+		}
+
+		length := snappyMinMatch
+		for i+length < len(block) && block[candidate+length] == block[i+length] {
+			length++
+		}
+		dst = snappyAppendLiteral(dst, block[literal:i])
+		dst = snappyAppendCopy(dst, i-candidate, length)
+		i += length
+		literal = i
+	}
+	return snappyAppendLiteral(dst, block[literal:])
+}
+
+// snappyAppendLiteral appends a literal element holding lit
+func snappyAppendLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := uint32(len(lit) - 1)
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|snappyTagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|snappyTagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|snappyTagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|snappyTagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|snappyTagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// snappyAppendCopy appends copy elements repeating length bytes from offset
+// bytes back. A copy element holds at most 64 bytes.
+func snappyAppendCopy(dst []byte, offset, length int) []byte {
+	for length >= 68 {
+		dst = append(dst, 63<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		// Leave at least 4 bytes for the final element
+		dst = append(dst, 59<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		return append(dst, byte(length-1)<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|snappyTagCopy1, byte(offset))
+}
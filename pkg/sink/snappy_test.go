@@ -0,0 +1,194 @@
+package sink
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// snappyDecode decompresses a snappy block, failing the test on malformed input
+func snappyDecode(t *testing.T, src []byte) []byte {
+	t.Helper()
+	n, read := binary.Uvarint(src)
+	if read <= 0 {
+		t.Fatal("snappy: invalid length")
+	}
+	src = src[read:]
+
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 0x03 {
+		case snappyTagLiteral:
+			length := int(tag>>2) + 1
+			src = src[1:]
+			if extra := int(tag>>2) - 59; extra > 0 {
+				length = 1
+				for i := 0; i < extra; i++ {
+					length += int(src[i]) << (8 * i)
+				}
+				src = src[extra:]
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+		case snappyTagCopy1:
+			length := int(tag>>2&0x07) + 4
+			offset := int(tag>>5)<<8 | int(src[1])
+			dst = snappyCopy(t, dst, offset, length)
+			src = src[2:]
+		case snappyTagCopy2:
+			length := int(tag>>2) + 1
+			offset := int(src[1]) | int(src[2])<<8
+			dst = snappyCopy(t, dst, offset, length)
+			src = src[3:]
+		default:
+			t.Fatal("snappy: unexpected 4-byte copy")
+		}
+	}
+	if uint64(len(dst)) != n {
+		t.Fatalf("snappy: decoded %d bytes, header says %d", len(dst), n)
+	}
+	return dst
+}
+
+func snappyCopy(t *testing.T, dst []byte, offset, length int) []byte {
+	t.Helper()
+	if offset <= 0 || offset > len(dst) {
+		t.Fatalf("snappy: invalid copy offset %d", offset)
+	}
+	for i := 0; i < length; i++ {
+		dst = append(dst, dst[len(dst)-offset])
+	}
+	return dst
+}
+
+func TestSnappyEncode(t *testing.T) {
+	random := make([]byte, 200000)
+	rand.New(rand.NewSource(1)).Read(random)
+	repetitive := bytes.Repeat([]byte(`{"cid":"550e8400-e29b-51d4-a716-446655440000","pattern":"json_cid"}`+"\n"), 3000)
+
+	tests := []struct {
+		name         string
+		input        []byte
+		compressible bool
+	}{
+		{"empty", nil, false},
+		{"short", []byte("abc"), false},
+		{"random", random, false},
+		{"repetitive", repetitive, true},
+		{"runs", append(bytes.Repeat([]byte("a"), 70000), bytes.Repeat([]byte("xyz"), 5000)...), true},
+		{"long literal", append(random[:70000:70000], repetitive[:5000]...), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := snappyEncode(tt.input)
+			if decoded := snappyDecode(t, encoded); !bytes.Equal(decoded, tt.input) {
+				t.Fatal("decoded data differs from the input")
+			}
+			if tt.compressible && len(encoded) > len(tt.input)/4 {
+				t.Errorf("compressed %d bytes to %d, want at least 4:1", len(tt.input), len(encoded))
+			}
+		})
+	}
+}
+
+// snappyFarInput returns about 2 KiB of distinct numbers followed by a repeat
+// of its first 20 bytes, so the encoding needs a copy offset above 2047
+func snappyFarInput() []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < 2100; i++ {
+		b.WriteString(strconv.Itoa(i * 7919))
+		b.WriteByte(',')
+	}
+	return append(b.Bytes(), b.Bytes()[:20]...)
+}
+
+// TestSnappyEncode_ReferenceDecoder pins the encoding of fixed inputs. Each
+// want was checked to decode back to its input with github.com/golang/snappy
+// v0.0.1, the implementation Loki decodes push requests with, so a change to
+// the encoder that alters them has to be checked against it again. Short
+// encodings are given in hex, longer ones as their SHA-256.
+func TestSnappyEncode_ReferenceDecoder(t *testing.T) {
+	random := make([]byte, 200000)
+	rand.New(rand.NewSource(1)).Read(random)
+	line := `{"cid":"550e8400-e29b-51d4-a716-446655440000","pattern":"json_cid"}` + "\n"
+
+	tests := []struct {
+		name    string
+		input   []byte
+		wantHex string
+		wantSum string
+	}{
+		{name: "literal", input: []byte("abc"), wantHex: "0308616263"},
+		{name: "run", input: bytes.Repeat([]byte("a"), 100), wantHex: "640061fe01008a0100"},
+		{
+			name:    "repeated lines",
+			input:   bytes.Repeat([]byte(line), 4),
+			wantHex: "9002f03d7b22636964223a2235353065383430302d653239622d353164342d613731362d343436363535343430303030222c227061747465726e223a226a736f6e5f013c047d0afe4400fe4400fe44002e4400",
+		},
+		{name: "far copy", input: snappyFarInput(), wantSum: "c225049215f1a7646118dfee8bc689fd189827d97b9c1cc49bc8ed36a2ce7b08"},
+		{
+			name:    "blocks",
+			input:   append(bytes.Repeat([]byte("a"), 70000), bytes.Repeat([]byte("xyz"), 5000)...),
+			wantSum: "3d9c58a4005432fa5a094419d57819b9fb5ceb7e774990bdbd25391ed8298c32",
+		},
+		{
+			name:    "long literal",
+			input:   append(random[:70000:70000], bytes.Repeat([]byte(line), 74)[:5000]...),
+			wantSum: "ffd280bcdb540ef2310877100fca5b5b158fd464ae0d69f4769f9dd2cf3c4ab3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := snappyEncode(tt.input)
+			if tt.wantHex != "" {
+				if got := hex.EncodeToString(encoded); got != tt.wantHex {
+					t.Errorf("snappyEncode() = %s, want %s", got, tt.wantHex)
+				}
+				return
+			}
+			sum := sha256.Sum256(encoded)
+			if got := hex.EncodeToString(sum[:]); got != tt.wantSum {
+				t.Errorf("SHA-256 of snappyEncode() = %s, want %s", got, tt.wantSum)
+			}
+		})
+	}
+}
+
+// TestSnappyDecode_ReferenceEncoder checks the test decoder against
+// encodings made by github.com/golang/snappy v0.0.1, so the round trips above
+// are decoded the way Loki would
+func TestSnappyDecode_ReferenceEncoder(t *testing.T) {
+	line := `{"cid":"550e8400-e29b-51d4-a716-446655440000","pattern":"json_cid"}` + "\n"
+	tests := []struct {
+		name    string
+		encoded string
+		want    []byte
+	}{
+		{"literal", "0308616263", []byte("abc")},
+		{"run", "640061fe01008a0100", bytes.Repeat([]byte("a"), 100)},
+		{
+			"repeated lines",
+			"9002f0437b22636964223a2235353065383430302d653239622d353164342d613731362d343436363535343430303030222c227061747465726e223a226a736f6e5f636964227d0afe4400fe4400fe44002e4400",
+			bytes.Repeat([]byte(line), 4),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := hex.DecodeString(tt.encoded)
+			if err != nil {
+				t.Fatalf("invalid vector: %v", err)
+			}
+			if got := snappyDecode(t, encoded); !bytes.Equal(got, tt.want) {
+				t.Errorf("snappyDecode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
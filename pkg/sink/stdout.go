@@ -0,0 +1,69 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"cidtracker/pkg/models"
+)
+
+// TypeStdout is the sink type writing to standard output
+const TypeStdout = "stdout"
+
+func init() {
+	Register(TypeStdout, func(cfg models.SinkConfig) (Sink, error) {
+		enc, err := NewEncoder(cfg.Format, cfg.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		return NewStdout(enc), nil
+	}, nil)
+}
+
+// stdoutSink writes records to standard output
+type stdoutSink struct {
+	encoder Encoder
+
+	mu      sync.Mutex
+	started bool
+}
+
+// NewStdout creates a sink writing one record per line, encoded by enc
+func NewStdout(enc Encoder) Sink {
+	return &stdoutSink{encoder: enc}
+}
+
+// Write prints a record, after the encoder's header if it is the first
+func (s *stdoutSink) Write(ctx context.Context, record models.CIDRecord) error {
+	data, err := s.encoder.Encode(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		if header := s.encoder.Header(); header != nil {
+			if _, err := fmt.Fprintln(os.Stdout, string(header)); err != nil {
+				return err
+			}
+		}
+		s.started = true
+	}
+
+	_, err = fmt.Fprintln(os.Stdout, string(data))
+	return err
+}
+
+// Flush does nothing as standard output is not buffered
+func (s *stdoutSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close does nothing; standard output stays open for the process
+func (s *stdoutSink) Close() error {
+	return nil
+}
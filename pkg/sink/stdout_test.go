@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+func TestStdout_JSON(t *testing.T) {
+	record := models.CIDRecord{
+		CID:         "test-cid",
+		UUID:        "test-uuid",
+		Timestamp:   time.Now(),
+		RawLogLine:  "test message",
+		ExtractedAt: time.Now(),
+		Source:      "/var/log/test.log",
+	}
+
+	output := captureStdout(t, func() {
+		if err := NewStdout(testEncoder(t, FormatJSON)).Write(context.Background(), record); err != nil {
+			t.Errorf("Write() error = %v", err)
+		}
+	})
+
+	var decoded Record
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &decoded); err != nil {
+		t.Fatalf("failed to parse JSON output: %v", err)
+	}
+
+	if decoded.ExtractedCID != record.CID {
+		t.Errorf("ExtractedCID = %v, want %v", decoded.ExtractedCID, record.CID)
+	}
+	if decoded.SourceFile != "/var/log/test.log" {
+		t.Errorf("SourceFile = %v, want /var/log/test.log", decoded.SourceFile)
+	}
+}
+
+func TestStdout_Structured(t *testing.T) {
+	record := models.CIDRecord{
+		CID:         "test-cid",
+		UUID:        "test-uuid",
+		Timestamp:   time.Now(),
+		RawLogLine:  "test message",
+		ExtractedAt: time.Now(),
+		Source:      "/var/log/test.log",
+	}
+
+	output := captureStdout(t, func() {
+		if err := NewStdout(testEncoder(t, FormatStructured)).Write(context.Background(), record); err != nil {
+			t.Errorf("Write() error = %v", err)
+		}
+	})
+
+	if !strings.Contains(output, "CID:test-cid") {
+		t.Errorf("expected output to contain CID, got: %v", output)
+	}
+	if !strings.Contains(output, "FILE:test.log") {
+		t.Errorf("expected output to contain FILE, got: %v", output)
+	}
+}
+
+func TestStdout_CSVHeaderOnce(t *testing.T) {
+	s := NewStdout(testEncoder(t, FormatCSV))
+	output := captureStdout(t, func() {
+		for i := 1; i <= 2; i++ {
+			if err := s.Write(context.Background(), testRecord(i, "standard_cid")); err != nil {
+				t.Errorf("Write() error = %v", err)
+			}
+		}
+	})
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 3 || lines[0] != "timestamp,cid,uuid,pattern,log_file,raw_message" {
+		t.Errorf("output = %q, want a header and two rows", lines)
+	}
+}
+
+// captureStdout returns what fn printed to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	old := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		buf.ReadFrom(r)
+		done <- buf.String()
+	}()
+
+	fn()
+
+	w.Close()
+	os.Stdout = old
+	return <-done
+}
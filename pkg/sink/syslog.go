@@ -0,0 +1,458 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cidtracker/pkg/decoder"
+	"cidtracker/pkg/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// TypeSyslog is the sink type sending records as RFC 5424 syslog messages
+const TypeSyslog = "syslog"
+
+// Syslog transports
+const (
+	// SyslogUDP sends one message per datagram
+	SyslogUDP = "udp"
+	// SyslogTCP sends octet-counted messages over TCP
+	SyslogTCP = "tcp"
+	// SyslogTLS sends octet-counted messages over TLS
+	SyslogTLS = "tls"
+)
+
+// Syslog defaults
+const (
+	DefaultSyslogFacility = "user"
+	DefaultSyslogSeverity = "info"
+	DefaultSyslogAppName  = "cidtracker"
+)
+
+// syslogSDID is the ID of the structured-data element carrying the CID. The
+// enterprise number is the one RFC 5424 reserves for documentation.
+const syslogSDID = "cid@32473"
+
+// syslogMsgID is the MSGID of every message
+const syslogMsgID = "cid"
+
+// syslogTimestamp is the RFC 5424 timestamp layout, at microsecond precision
+const syslogTimestamp = "2006-01-02T15:04:05.000000Z07:00"
+
+// Header field limits of RFC 5424
+const (
+	maxSyslogHostname = 255
+	maxSyslogAppName  = 48
+)
+
+// syslogFacilities maps facility names to their codes
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// syslogSeverities maps severity names, including the common aliases, to
+// their codes
+var syslogSeverities = map[string]int{
+	"emerg":   0,
+	"alert":   1,
+	"crit":    2,
+	"err":     3,
+	"error":   3,
+	"warning": 4,
+	"warn":    4,
+	"notice":  5,
+	"info":    6,
+	"debug":   7,
+}
+
+func init() {
+	Register(TypeSyslog, func(cfg models.SinkConfig) (Sink, error) {
+		enc, err := NewEncoder(cfg.Format, cfg.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		return NewSyslog(enc, *cfg.Syslog)
+	}, validateSyslog)
+}
+
+// validateSyslog checks the options of a syslog sink
+func validateSyslog(cfg models.SinkConfig) error {
+	if cfg.Syslog == nil {
+		return fmt.Errorf("syslog sink requires an address")
+	}
+	o := cfg.Syslog
+
+	switch o.Network {
+	case "", SyslogUDP, SyslogTCP, SyslogTLS:
+	default:
+		return fmt.Errorf("invalid syslog network '%s': must be %s, %s or %s", o.Network, SyslogUDP, SyslogTCP, SyslogTLS)
+	}
+	if o.Address == "" {
+		return fmt.Errorf("syslog sink requires an address")
+	}
+	if _, port, err := net.SplitHostPort(o.Address); err != nil || port == "" {
+		return fmt.Errorf("invalid syslog address '%s': must be host:port", o.Address)
+	}
+
+	if _, ok := syslogFacilities[strings.ToLower(o.Facility)]; o.Facility != "" && !ok {
+		return fmt.Errorf("unknown syslog facility '%s'", o.Facility)
+	}
+	if _, ok := syslogSeverities[strings.ToLower(o.Severity)]; o.Severity != "" && !ok {
+		return fmt.Errorf("unknown syslog severity '%s'", o.Severity)
+	}
+	for value, severity := range o.Severities {
+		if _, ok := syslogSeverities[strings.ToLower(severity)]; !ok {
+			return fmt.Errorf("unknown syslog severity '%s' for '%s'", severity, value)
+		}
+	}
+
+	if o.AppName != "" && !validSyslogHeader(o.AppName, maxSyslogAppName) {
+		return fmt.Errorf("invalid syslog app name '%s': must be at most %d printable ASCII characters without spaces", o.AppName, maxSyslogAppName)
+	}
+	if o.Hostname != "" && !validSyslogHeader(o.Hostname, maxSyslogHostname) {
+		return fmt.Errorf("invalid syslog hostname '%s': must be at most %d printable ASCII characters without spaces", o.Hostname, maxSyslogHostname)
+	}
+
+	usesTLS := o.CAFile != "" || o.CertFile != "" || o.KeyFile != "" || o.ServerName != "" || o.InsecureSkipVerify
+	if usesTLS && o.Network != SyslogTLS {
+		return fmt.Errorf("syslog TLS options require the %s network", SyslogTLS)
+	}
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return fmt.Errorf("syslog cert file and key file must be set together")
+	}
+	if o.Timeout < 0 || !validRetries(o.MaxRetries) || o.InitialBackoff < 0 || o.MaxBackoff < 0 {
+		return fmt.Errorf("syslog timeout and retry settings must not be negative")
+	}
+	return nil
+}
+
+// validSyslogHeader reports whether value fits a header field of at most max
+// printable ASCII characters
+func validSyslogHeader(value string, max int) bool {
+	if len(value) > max {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < 33 || value[i] > 126 {
+			return false
+		}
+	}
+	return true
+}
+
+// syslogConn is a connection to the syslog server. done is closed once the
+// server closes a stream connection.
+type syslogConn struct {
+	net.Conn
+	done chan struct{}
+}
+
+// closed reports whether the server has closed the connection
+func (c *syslogConn) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// syslogSink sends every record as an RFC 5424 message to a syslog server,
+// e.g. the collector of a SIEM. The CID, UUID, file and pattern are carried in
+// a structured-data element, and the message is the encoded record. The
+// connection is opened with the first record and reopened whenever writing
+// fails or the server closes it.
+type syslogSink struct {
+	encoder   Encoder
+	cfg       models.SyslogSinkConfig
+	facility  int
+	severity  int
+	hostname  string
+	procID    string
+	tlsConfig *tls.Config
+	retrier   retrier
+	now       func() time.Time
+
+	mu   sync.Mutex
+	conn *syslogConn
+}
+
+// NewSyslog creates a sink sending records encoded by enc to the configured
+// syslog server
+func NewSyslog(enc Encoder, cfg models.SyslogSinkConfig) (Sink, error) {
+	return newSyslogSink(enc, cfg)
+}
+
+// newSyslogSink creates a syslog sink with its defaults applied. The TLS
+// certificates are loaded here, so that a bad file fails at startup.
+func newSyslogSink(enc Encoder, cfg models.SyslogSinkConfig) (*syslogSink, error) {
+	if err := validateSyslog(models.SinkConfig{Syslog: &cfg}); err != nil {
+		return nil, err
+	}
+	if cfg.Network == "" {
+		cfg.Network = SyslogUDP
+	}
+	if cfg.Facility == "" {
+		cfg.Facility = DefaultSyslogFacility
+	}
+	if cfg.Severity == "" {
+		cfg.Severity = DefaultSyslogSeverity
+	}
+	if cfg.SeverityField == "" {
+		cfg.SeverityField = decoder.MetaStream
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = DefaultSyslogAppName
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultRequestTimeout
+	}
+
+	hostname := cfg.Hostname
+	if hostname == "" {
+		// The nil value "-" is sent if the name is unknown
+		hostname, _ = os.Hostname()
+		if !validSyslogHeader(hostname, maxSyslogHostname) {
+			hostname = ""
+		}
+	}
+
+	s := &syslogSink{
+		encoder:  enc,
+		cfg:      cfg,
+		facility: syslogFacilities[strings.ToLower(cfg.Facility)],
+		severity: syslogSeverities[strings.ToLower(cfg.Severity)],
+		hostname: hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+		retrier:  newRetrier(cfg.Address, cfg.MaxRetries, cfg.InitialBackoff, cfg.MaxBackoff),
+		now:      time.Now,
+	}
+
+	if cfg.Network == SyslogTLS {
+		tlsConfig, err := syslogTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s.tlsConfig = tlsConfig
+	}
+	return s, nil
+}
+
+// syslogTLSConfig builds the TLS client configuration. It trusts only the
+// certificates in CAFile when that is set, and the system roots otherwise.
+func syslogTLSConfig(cfg models.SyslogSinkConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read syslog CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("syslog CA file '%s' holds no PEM certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load syslog client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Write sends a record, reconnecting and retrying until the server takes it
+// or the retries are used up
+func (s *syslogSink) Write(ctx context.Context, record models.CIDRecord) error {
+	msg, err := s.message(record)
+	if err != nil {
+		return err
+	}
+	if s.cfg.Network != SyslogUDP {
+		// Octet-counting framing of RFC 6587
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts, err := s.retrier.retry(ctx, func() (bool, time.Duration, error) {
+		err := s.send(ctx, msg)
+		// A server whose certificate is rejected will not be trusted later
+		var certErr *tls.CertificateVerificationError
+		return ctx.Err() == nil && !errors.As(err, &certErr), 0, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send syslog message after %d attempts: %w", attempts, err)
+	}
+	return nil
+}
+
+// send writes msg on the current connection, connecting first if there is
+// none. A connection that fails is closed, so the next attempt reconnects.
+func (s *syslogSink) send(ctx context.Context, msg []byte) error {
+	if s.conn != nil && s.conn.closed() {
+		s.closeConn()
+	}
+	if s.conn == nil {
+		conn, err := s.connect(ctx)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	if err := s.conn.SetWriteDeadline(s.now().Add(s.cfg.Timeout)); err != nil {
+		s.closeConn()
+		return fmt.Errorf("failed to write to %s: %w", s.cfg.Address, err)
+	}
+	if _, err := s.conn.Write(msg); err != nil {
+		s.closeConn()
+		return fmt.Errorf("failed to write to %s: %w", s.cfg.Address, err)
+	}
+	return nil
+}
+
+// connect opens a connection to the server. Syslog servers never answer, so
+// reading from a stream connection only returns once the server closes it.
+func (s *syslogSink) connect(ctx context.Context) (*syslogConn, error) {
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+
+	var conn net.Conn
+	var err error
+	if s.cfg.Network == SyslogTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tlsConfig}).DialContext(ctx, "tcp", s.cfg.Address)
+	} else {
+		conn, err = dialer.DialContext(ctx, s.cfg.Network, s.cfg.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", s.cfg.Address, err)
+	}
+
+	c := &syslogConn{Conn: conn, done: make(chan struct{})}
+	if s.cfg.Network != SyslogUDP {
+		go func() {
+			io.Copy(io.Discard, conn)
+			close(c.done)
+		}()
+	}
+
+	log.WithFields(log.Fields{
+		"network": s.cfg.Network,
+		"address": s.cfg.Address,
+	}).Debug("Connected to syslog server")
+	return c, nil
+}
+
+// closeConn closes the current connection
+func (s *syslogSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// message renders a record as an RFC 5424 message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [cid@32473 ...] MSG
+func (s *syslogSink) message(record models.CIDRecord) ([]byte, error) {
+	body, err := s.encoder.Encode(record)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := record.Timestamp
+	if timestamp.IsZero() {
+		timestamp = s.now()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s [%s",
+		s.facility*8+s.severityOf(record),
+		timestamp.UTC().Format(syslogTimestamp),
+		syslogNil(s.hostname), s.cfg.AppName, s.procID, syslogMsgID, syslogSDID)
+	for _, name := range []string{FieldCID, FieldUUID, FieldFile, FieldPattern} {
+		if value := fieldValue(record, name); value != "" || name == FieldCID {
+			fmt.Fprintf(&b, ` %s="%s"`, name, escapeSDParam(value))
+		}
+	}
+	b.WriteString("] ")
+	b.Write(body)
+	return []byte(b.String()), nil
+}
+
+// severityOf returns the severity mapped from the record's severity field,
+// or the default severity
+func (s *syslogSink) severityOf(record models.CIDRecord) int {
+	if name, ok := s.cfg.Severities[fieldValue(record, s.cfg.SeverityField)]; ok {
+		return syslogSeverities[strings.ToLower(name)]
+	}
+	return s.severity
+}
+
+// syslogNil returns value, or the nil value "-" if it is empty
+func syslogNil(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// sdParamEscaper escapes the characters RFC 5424 reserves in parameter values
+var sdParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// escapeSDParam makes value safe for a structured-data parameter
+func escapeSDParam(value string) string {
+	return sdParamEscaper.Replace(strings.ToValidUTF8(value, "�"))
+}
+
+// Flush does nothing, as every record is sent on Write
+func (s *syslogSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the connection to the server
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closeConn()
+	return nil
+}
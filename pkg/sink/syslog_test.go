@@ -0,0 +1,348 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// syslogServer accepts stream connections and collects the octet-counted
+// messages sent on them. With closeAfter set, it closes every connection
+// after that many messages.
+type syslogServer struct {
+	listener   net.Listener
+	closeAfter int
+
+	mu          sync.Mutex
+	connections int
+	messages    chan string
+}
+
+func newSyslogServer(t *testing.T, listener net.Listener, closeAfter int) *syslogServer {
+	t.Helper()
+	srv := &syslogServer{listener: listener, closeAfter: closeAfter, messages: make(chan string, 16)}
+	go srv.serve()
+	t.Cleanup(func() { listener.Close() })
+	return srv
+}
+
+func (srv *syslogServer) serve() {
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			return
+		}
+		srv.mu.Lock()
+		srv.connections++
+		srv.mu.Unlock()
+		go srv.read(conn)
+	}
+}
+
+func (srv *syslogServer) read(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for n := 1; ; n++ {
+		frame, err := readSyslogFrame(r)
+		if err != nil {
+			return
+		}
+		srv.messages <- frame
+		if n == srv.closeAfter {
+			return
+		}
+	}
+}
+
+// next returns the next message received
+func (srv *syslogServer) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case msg := <-srv.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no syslog message received")
+		return ""
+	}
+}
+
+// readSyslogFrame reads one octet-counted message
+func readSyslogFrame(r *bufio.Reader) (string, error) {
+	length, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+	if err != nil {
+		return "", fmt.Errorf("bad frame length %q", length)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+// newTestSyslog creates a syslog sink that does not sleep between retries
+func newTestSyslog(t *testing.T, format string, cfg models.SyslogSinkConfig) *syslogSink {
+	t.Helper()
+	s, err := newSyslogSink(testEncoder(t, format), cfg)
+	if err != nil {
+		t.Fatalf("newSyslogSink() error = %v", err)
+	}
+	s.retrier.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSyslog_Message(t *testing.T) {
+	s := newTestSyslog(t, FormatStructured, models.SyslogSinkConfig{
+		Address:  "127.0.0.1:514",
+		Facility: "local4",
+		Hostname: "node-1",
+	})
+	s.procID = "42"
+
+	record := testRecord(7, "standard_cid")
+	record.UUID = record.CID
+	record.Timestamp = time.Date(2024, 3, 5, 10, 0, 1, 500000000, time.FixedZone("AEST", 10*3600))
+	record.Source = `/var/log/app/odd"name].log`
+
+	msg, err := s.message(record)
+	if err != nil {
+		t.Fatalf("message() error = %v", err)
+	}
+	want := `<166>1 2024-03-05T00:00:01.500000Z node-1 cidtracker 42 cid ` +
+		`[cid@32473 cid="550e8400-e29b-51d4-a716-000000000007" uuid="550e8400-e29b-51d4-a716-000000000007" ` +
+		`file="odd\"name\].log" pattern="standard_cid"] ` +
+		`[2024-03-05T10:00:01+10:00] CID:550e8400-e29b-51d4-a716-000000000007 FILE:odd"name].log`
+	if string(msg) != want {
+		t.Errorf("message() =\n%s\nwant\n%s", msg, want)
+	}
+}
+
+func TestSyslog_Severity(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    models.SyslogSinkConfig
+		record models.CIDRecord
+		want   string
+	}{
+		{"defaults", models.SyslogSinkConfig{}, testRecord(1, ""), "<14>"},
+		{"configured", models.SyslogSinkConfig{Facility: "local0", Severity: "notice"}, testRecord(1, ""), "<133>"},
+		{"stream mapped", models.SyslogSinkConfig{Severities: map[string]string{"stderr": "err"}},
+			models.CIDRecord{CID: "a", Metadata: map[string]string{"stream": "stderr"}}, "<11>"},
+		{"stream unmapped", models.SyslogSinkConfig{Severities: map[string]string{"stderr": "err"}},
+			models.CIDRecord{CID: "a", Metadata: map[string]string{"stream": "stdout"}}, "<14>"},
+		{"pattern mapped", models.SyslogSinkConfig{SeverityField: FieldPattern, Severities: map[string]string{"json_cid": "WARNING"}},
+			testRecord(1, "json_cid"), "<12>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Address = "127.0.0.1:514"
+			s := newTestSyslog(t, FormatJSON, tt.cfg)
+			msg, err := s.message(tt.record)
+			if err != nil {
+				t.Fatalf("message() error = %v", err)
+			}
+			if !strings.HasPrefix(string(msg), tt.want+"1 ") {
+				t.Errorf("message() = %s, want priority %s", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslog_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	defer conn.Close()
+
+	s := newTestSyslog(t, FormatJSON, models.SyslogSinkConfig{Address: conn.LocalAddr().String()})
+	if err := s.Write(context.Background(), testRecord(1, "standard_cid")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	buf := make([]byte, 64*1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	msg := string(buf[:n])
+	// A datagram holds exactly one message, without a length prefix
+	if !strings.HasPrefix(msg, "<14>1 ") {
+		t.Errorf("datagram = %s, want an unframed RFC 5424 message", msg)
+	}
+	if !strings.Contains(msg, `[cid@32473 cid="550e8400-e29b-51d4-a716-000000000001" file="app.log" pattern="standard_cid"] {"schema_version":1,`) {
+		t.Errorf("datagram = %s, want the structured data followed by the JSON record", msg)
+	}
+}
+
+func TestSyslog_TCPReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	srv := newSyslogServer(t, listener, 1)
+
+	s := newTestSyslog(t, FormatJSON, models.SyslogSinkConfig{Network: SyslogTCP, Address: listener.Addr().String()})
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		if err := s.Write(ctx, testRecord(i, "standard_cid")); err != nil {
+			t.Fatalf("Write(%d) error = %v", i, err)
+		}
+		msg := srv.next(t)
+		if !strings.Contains(msg, testRecord(i, "").CID) {
+			t.Errorf("message %d = %s, want record %d", i, msg, i)
+		}
+
+		// Wait until the sink sees that the server closed the connection
+		deadline := time.Now().Add(5 * time.Second)
+		for !s.conn.closed() && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.connections != 3 {
+		t.Errorf("server accepted %d connections, want 3", srv.connections)
+	}
+}
+
+func TestSyslog_RetriesUntilServerIsUp(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	s := newTestSyslog(t, FormatJSON, models.SyslogSinkConfig{Network: SyslogTCP, Address: addr, MaxRetries: retries(3)})
+	var srv *syslogServer
+	retries := 0
+	s.retrier.sleep = func(ctx context.Context, d time.Duration) error {
+		retries++
+		if retries == 2 {
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+			srv = newSyslogServer(t, listener, 0)
+		}
+		return nil
+	}
+
+	if err := s.Write(context.Background(), testRecord(1, "standard_cid")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if retries != 2 {
+		t.Errorf("retried %d times, want 2", retries)
+	}
+	if msg := srv.next(t); !strings.Contains(msg, testRecord(1, "").CID) {
+		t.Errorf("message = %s, want record 1", msg)
+	}
+
+	// Without a server, Write fails once the retries are used up
+	srv.listener.Close()
+	s.Close()
+	retries = 10
+	err = s.Write(context.Background(), testRecord(2, "standard_cid"))
+	if err == nil || !strings.Contains(err.Error(), "after 4 attempts") {
+		t.Errorf("Write() error = %v, want failure after 4 attempts", err)
+	}
+}
+
+func TestSyslog_TLS(t *testing.T) {
+	// The test server's certificate is valid for example.com
+	certServer := httptest.NewTLSServer(nil)
+	cert := certServer.TLS.Certificates[0]
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certServer.Certificate().Raw})
+	certServer.Close()
+	if err := os.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	srv := newSyslogServer(t, listener, 0)
+
+	s := newTestSyslog(t, FormatJSON, models.SyslogSinkConfig{
+		Network:    SyslogTLS,
+		Address:    listener.Addr().String(),
+		CAFile:     caFile,
+		ServerName: "example.com",
+	})
+	for i := 1; i <= 2; i++ {
+		if err := s.Write(context.Background(), testRecord(i, "standard_cid")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if msg := srv.next(t); !strings.Contains(msg, `cid="`+testRecord(i, "").CID+`"`) {
+			t.Errorf("message = %s, want record %d", msg, i)
+		}
+	}
+
+	// An untrusted server is rejected
+	untrusted := newTestSyslog(t, FormatJSON, models.SyslogSinkConfig{
+		Network:    SyslogTLS,
+		Address:    listener.Addr().String(),
+		ServerName: "example.com",
+	})
+	err = untrusted.Write(context.Background(), testRecord(3, "standard_cid"))
+	if err == nil || !strings.Contains(err.Error(), "after 1 attempts") {
+		t.Errorf("Write() error = %v, want certificate verification failure without retries", err)
+	}
+}
+
+func TestValidate_SyslogSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		syslog  *models.SyslogSinkConfig
+		wantErr bool
+	}{
+		{"udp", &models.SyslogSinkConfig{Address: "siem.internal:514"}, false},
+		{"tls", &models.SyslogSinkConfig{Network: SyslogTLS, Address: "siem.internal:6514", CAFile: "/etc/ca.pem", ServerName: "siem"}, false},
+		{"mapped severity", &models.SyslogSinkConfig{Address: "siem:514", Facility: "local3", Severities: map[string]string{"stderr": "warning"}}, false},
+		{"missing options", nil, true},
+		{"missing address", &models.SyslogSinkConfig{}, true},
+		{"missing port", &models.SyslogSinkConfig{Address: "siem.internal"}, true},
+		{"unknown network", &models.SyslogSinkConfig{Network: "unix", Address: "siem:514"}, true},
+		{"unknown facility", &models.SyslogSinkConfig{Address: "siem:514", Facility: "local9"}, true},
+		{"unknown severity", &models.SyslogSinkConfig{Address: "siem:514", Severity: "loud"}, true},
+		{"unknown mapped severity", &models.SyslogSinkConfig{Address: "siem:514", Severities: map[string]string{"stderr": "loud"}}, true},
+		{"app name with space", &models.SyslogSinkConfig{Address: "siem:514", AppName: "cid tracker"}, true},
+		{"tls options over tcp", &models.SyslogSinkConfig{Network: SyslogTCP, Address: "siem:514", CAFile: "/etc/ca.pem"}, true},
+		{"cert without key", &models.SyslogSinkConfig{Network: SyslogTLS, Address: "siem:6514", CertFile: "/etc/client.pem"}, true},
+		{"negative retries", &models.SyslogSinkConfig{Address: "siem:514", MaxRetries: retries(-1)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(models.SinkConfig{Name: "siem", Type: TypeSyslog, Format: FormatJSON, Syslog: tt.syslog})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
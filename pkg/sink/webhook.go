@@ -0,0 +1,218 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"cidtracker/pkg/models"
+)
+
+// TypeWebhook is the sink type POSTing batches of records to an HTTP endpoint
+const TypeWebhook = "webhook"
+
+// Webhook batch encodings
+const (
+	// EncodingNDJSON sends one record per line
+	EncodingNDJSON = "ndjson"
+	// EncodingArray sends the records as a JSON array
+	EncodingArray = "array"
+)
+
+// DefaultBatchSize is the number of records sent per request unless
+// configured otherwise
+const DefaultBatchSize = 100
+
+func init() {
+	Register(TypeWebhook, func(cfg models.SinkConfig) (Sink, error) {
+		enc, err := NewEncoder(cfg.Format, cfg.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		return NewWebhook(enc, *cfg.Webhook)
+	}, validateWebhook)
+}
+
+// validateWebhook checks the options of a webhook sink
+func validateWebhook(cfg models.SinkConfig) error {
+	if cfg.Webhook == nil {
+		return fmt.Errorf("webhook sink requires a url")
+	}
+	if err := validateURL(cfg.Webhook.URL); err != nil {
+		return fmt.Errorf("webhook sink: %w", err)
+	}
+
+	switch cfg.Webhook.Encoding {
+	case "", EncodingNDJSON:
+	case EncodingArray:
+		if cfg.Format != FormatJSON {
+			return fmt.Errorf("webhook encoding '%s' requires the %s format", EncodingArray, FormatJSON)
+		}
+	default:
+		return fmt.Errorf("invalid webhook encoding '%s': must be %s or %s", cfg.Webhook.Encoding, EncodingNDJSON, EncodingArray)
+	}
+
+	w := cfg.Webhook
+	if w.BatchSize < 0 || w.Timeout < 0 || !validRetries(w.MaxRetries) || w.InitialBackoff < 0 || w.MaxBackoff < 0 {
+		return fmt.Errorf("webhook batch size, timeout and retry settings must not be negative")
+	}
+	return nil
+}
+
+// webhookSink collects records into batches and POSTs each batch once it is
+// full or the sink is flushed. Batches are sent in order, one at a time.
+type webhookSink struct {
+	cfg     models.WebhookSinkConfig
+	encoder Encoder
+	sender  *httpSender
+	// deadLetter encodes the records of a failed batch for the dead-letter
+	// file when the sink's own format is not JSON, so the file is always
+	// NDJSON that can be replayed
+	deadLetter Encoder
+
+	mu      sync.Mutex
+	batch   [][]byte
+	records []models.CIDRecord
+}
+
+// NewWebhook creates a sink POSTing records encoded by enc to the configured
+// URL
+func NewWebhook(enc Encoder, cfg models.WebhookSinkConfig) (Sink, error) {
+	return newWebhookSink(enc, cfg)
+}
+
+// newWebhookSink creates a webhook sink with its defaults applied
+func newWebhookSink(enc Encoder, cfg models.WebhookSinkConfig) (*webhookSink, error) {
+	if err := validateWebhook(models.SinkConfig{Format: formatOf(enc), Webhook: &cfg}); err != nil {
+		return nil, err
+	}
+	if cfg.Encoding == "" {
+		cfg.Encoding = EncodingNDJSON
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	s := &webhookSink{
+		cfg:     cfg,
+		encoder: enc,
+		sender: newHTTPSender(cfg.URL, cfg.Headers, cfg.BearerToken, cfg.Timeout,
+			cfg.MaxRetries, cfg.InitialBackoff, cfg.MaxBackoff),
+	}
+	if cfg.DeadLetterPath != "" && formatOf(enc) != FormatJSON {
+		s.deadLetter = &jsonEncoder{}
+	}
+	return s, nil
+}
+
+// Write adds a record to the current batch and sends the batch once full
+func (s *webhookSink) Write(ctx context.Context, record models.CIDRecord) error {
+	data, err := s.encoder.Encode(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.batch = append(s.batch, data)
+	if s.deadLetter != nil {
+		s.records = append(s.records, record)
+	}
+	if len(s.batch) < s.cfg.BatchSize {
+		return nil
+	}
+	return s.send(ctx)
+}
+
+// Flush sends the current batch, however small
+func (s *webhookSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.send(ctx)
+}
+
+// Close sends the current batch
+func (s *webhookSink) Close() error {
+	return s.Flush(context.Background())
+}
+
+// send POSTs the current batch. A batch that cannot be delivered is written
+// to the dead-letter file as NDJSON, if one is configured, and otherwise lost.
+func (s *webhookSink) send(ctx context.Context) error {
+	batch, records := s.batch, s.records
+	s.batch, s.records = nil, nil
+	if len(batch) == 0 {
+		return nil
+	}
+
+	_, attempts, err := s.sender.post(ctx, s.contentType(), s.encodeBatch(batch))
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf("failed to deliver %d records after %d attempts: %w", len(batch), attempts, err)
+	if s.cfg.DeadLetterPath == "" {
+		return err
+	}
+	lines, dlErr := s.deadLetterLines(batch, records)
+	if dlErr == nil {
+		dlErr = appendDeadLetter(s.cfg.DeadLetterPath, lines)
+	}
+	if dlErr != nil {
+		return fmt.Errorf("%w; %v", err, dlErr)
+	}
+	return fmt.Errorf("%w; written to %s", err, s.cfg.DeadLetterPath)
+}
+
+// deadLetterLines returns the lines written to the dead-letter file for a
+// failed batch: the batch itself if the sink writes JSON, otherwise its
+// records encoded as JSON
+func (s *webhookSink) deadLetterLines(batch [][]byte, records []models.CIDRecord) ([][]byte, error) {
+	if s.deadLetter == nil {
+		return batch, nil
+	}
+
+	lines := make([][]byte, 0, len(records))
+	for _, record := range records {
+		data, err := s.deadLetter.Encode(record)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, data)
+	}
+	return lines, nil
+}
+
+// encodeBatch renders a batch as the request body, which starts with the
+// encoder's header unless it is a JSON array
+func (s *webhookSink) encodeBatch(batch [][]byte) []byte {
+	if s.cfg.Encoding == EncodingArray {
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		buf.Write(bytes.Join(batch, []byte{','}))
+		buf.WriteByte(']')
+		return buf.Bytes()
+	}
+
+	var buf bytes.Buffer
+	if header := s.encoder.Header(); header != nil {
+		buf.Write(header)
+		buf.WriteByte('\n')
+	}
+	for _, line := range batch {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// contentType returns the media type of the request body
+func (s *webhookSink) contentType() string {
+	if s.cfg.Encoding == EncodingArray {
+		return "application/json"
+	}
+	return s.encoder.ContentType()
+}
@@ -0,0 +1,355 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/models"
+)
+
+// collector is a webhook endpoint that answers the first requests with
+// failures and records the bodies of the requests it accepts
+type collector struct {
+	mu       sync.Mutex
+	failures []int
+	header   http.Header
+	requests int
+	records  []string
+	types    []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests++
+	c.header = r.Header.Clone()
+	if len(c.failures) > 0 {
+		status := c.failures[0]
+		c.failures = c.failures[1:]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "7")
+		}
+		http.Error(w, "try again", status)
+		return
+	}
+
+	c.types = append(c.types, r.Header.Get("Content-Type"))
+	if bytes.HasPrefix(body, []byte{'['}) {
+		var records []json.RawMessage
+		json.Unmarshal(body, &records)
+		for _, record := range records {
+			c.records = append(c.records, string(record))
+		}
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		c.records = append(c.records, scanner.Text())
+	}
+}
+
+func (c *collector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.records...)
+}
+
+// newTestWebhook creates a webhook sink that records its retry delays instead
+// of sleeping
+func newTestWebhook(t *testing.T, cfg models.WebhookSinkConfig) (*webhookSink, *[]time.Duration) {
+	t.Helper()
+	s, err := newWebhookSink(testEncoder(t, FormatJSON), cfg)
+	if err != nil {
+		t.Fatalf("newWebhookSink() error = %v", err)
+	}
+	var delays []time.Duration
+	s.sender.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return s, &delays
+}
+
+func TestWebhook_RetriesIntermittentFailures(t *testing.T) {
+	c := &collector{failures: []int{503, 502}}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	s, delays := newTestWebhook(t, models.WebhookSinkConfig{URL: server.URL, BatchSize: 2})
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if err := s.Write(ctx, testRecord(i, "standard_cid")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if i == 2 {
+			// Fail the second batch once as well
+			c.mu.Lock()
+			c.failures = []int{503}
+			c.mu.Unlock()
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	got := c.received()
+	if len(got) != 5 {
+		t.Fatalf("received %d records, want 5: %v", len(got), got)
+	}
+	for i, line := range got {
+		var entry Record
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("record %d is not JSON: %v", i, err)
+		}
+		if entry.ExtractedCID != testRecord(i, "").CID {
+			t.Errorf("record %d CID = %s, want %s", i, entry.ExtractedCID, testRecord(i, "").CID)
+		}
+	}
+	if len(*delays) != 3 {
+		t.Errorf("retried %d times, want 3", len(*delays))
+	}
+	for _, d := range *delays {
+		if d <= 0 || d > DefaultMaxBackoff {
+			t.Errorf("retry delay %v out of range", d)
+		}
+	}
+	if c.types[0] != "application/x-ndjson" {
+		t.Errorf("Content-Type = %s, want application/x-ndjson", c.types[0])
+	}
+}
+
+func TestWebhook_HonoursRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBackoff time.Duration
+		want       time.Duration
+	}{
+		{"asked delay", 0, 7 * time.Second},
+		{"capped at max backoff", 2 * time.Second, 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &collector{failures: []int{http.StatusTooManyRequests}}
+			server := httptest.NewServer(c)
+			defer server.Close()
+
+			s, delays := newTestWebhook(t, models.WebhookSinkConfig{URL: server.URL, MaxBackoff: tt.maxBackoff})
+			ctx := context.Background()
+
+			s.Write(ctx, testRecord(1, "standard_cid"))
+			if err := s.Flush(ctx); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if len(*delays) != 1 || (*delays)[0] != tt.want {
+				t.Errorf("delays = %v, want [%v]", *delays, tt.want)
+			}
+			if len(c.received()) != 1 {
+				t.Errorf("received %d records, want 1", len(c.received()))
+			}
+		})
+	}
+}
+
+func TestWebhook_DeadLetter(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []int
+		requests int
+	}{
+		{"permanent error", []int{400}, 1},
+		{"retries exhausted", []int{500, 500, 500, 500}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &collector{failures: tt.failures}
+			server := httptest.NewServer(c)
+			defer server.Close()
+
+			deadLetter := filepath.Join(t.TempDir(), "failed", "webhook.ndjson")
+			s, _ := newTestWebhook(t, models.WebhookSinkConfig{
+				URL:            server.URL,
+				MaxRetries:     retries(2),
+				DeadLetterPath: deadLetter,
+			})
+			ctx := context.Background()
+
+			s.Write(ctx, testRecord(1, "standard_cid"))
+			s.Write(ctx, testRecord(2, "standard_cid"))
+			err := s.Flush(ctx)
+			if err == nil {
+				t.Fatal("Flush() error = nil, want delivery failure")
+			}
+			if !strings.Contains(err.Error(), deadLetter) {
+				t.Errorf("Flush() error = %v, want it to name the dead-letter file", err)
+			}
+			if c.requests != tt.requests {
+				t.Errorf("sent %d requests, want %d", c.requests, tt.requests)
+			}
+
+			lines := readLines(t, deadLetter)
+			if len(lines) != 2 {
+				t.Fatalf("dead letter has %d records, want 2", len(lines))
+			}
+			var entry Record
+			if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil || entry.ExtractedCID != testRecord(2, "").CID {
+				t.Errorf("dead letter record = %s, want record 2", lines[1])
+			}
+
+			// The sink keeps going with the next batch
+			c.failures = nil
+			s.Write(ctx, testRecord(3, "standard_cid"))
+			if err := s.Flush(ctx); err != nil {
+				t.Errorf("Flush() after recovery error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWebhook_DeadLetterIsNDJSON(t *testing.T) {
+	c := &collector{failures: []int{400, 400}}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "webhook.ndjson")
+	s, err := newWebhookSink(testEncoder(t, FormatCSV), models.WebhookSinkConfig{
+		URL:            server.URL,
+		DeadLetterPath: deadLetter,
+	})
+	if err != nil {
+		t.Fatalf("newWebhookSink() error = %v", err)
+	}
+	ctx := context.Background()
+
+	// Two failed CSV batches still give one JSON record per line, without
+	// header rows
+	for i := 1; i <= 2; i++ {
+		s.Write(ctx, testRecord(i, "standard_cid"))
+		if err := s.Flush(ctx); err == nil {
+			t.Fatal("Flush() error = nil, want delivery failure")
+		}
+	}
+
+	lines := readLines(t, deadLetter)
+	if len(lines) != 2 {
+		t.Fatalf("dead letter has %d lines, want 2: %v", len(lines), lines)
+	}
+	for i, line := range lines {
+		var entry Record
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.ExtractedCID != testRecord(i+1, "").CID {
+			t.Errorf("dead letter line %d = %s, want record %d as JSON", i, line, i+1)
+		}
+	}
+}
+
+func TestWebhook_HeadersAndArrayEncoding(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	s, _ := newTestWebhook(t, models.WebhookSinkConfig{
+		URL:         server.URL,
+		Encoding:    EncodingArray,
+		BatchSize:   3,
+		Headers:     map[string]string{"X-Tenant": "payments"},
+		BearerToken: "secret",
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		s.Write(ctx, testRecord(i, "standard_cid"))
+	}
+	// A full batch is sent without waiting for Flush
+	if got := len(c.received()); got != 3 {
+		t.Fatalf("received %d records before Flush, want 3", got)
+	}
+
+	if got := c.header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want Bearer secret", got)
+	}
+	if got := c.header.Get("X-Tenant"); got != "payments" {
+		t.Errorf("X-Tenant = %q, want payments", got)
+	}
+	if c.types[0] != "application/json" {
+		t.Errorf("Content-Type = %s, want application/json", c.types[0])
+	}
+}
+
+func TestWebhook_CSVBatches(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	enc, err := NewEncoder(FormatCSV, &models.EncoderConfig{Fields: []string{"cid", "pattern"}})
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+	s, err := newWebhookSink(enc, models.WebhookSinkConfig{URL: server.URL, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("newWebhookSink() error = %v", err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		s.Write(ctx, testRecord(i, "standard_cid"))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Every request body is a CSV document with its own header
+	want := []string{
+		"cid,pattern", testRecord(0, "").CID + ",standard_cid", testRecord(1, "").CID + ",standard_cid",
+		"cid,pattern", testRecord(2, "").CID + ",standard_cid",
+	}
+	if got := c.received(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("received %v, want %v", got, want)
+	}
+	if c.types[0] != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %s, want text/csv", c.types[0])
+	}
+}
+
+func TestValidate_WebhookSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		webhook *models.WebhookSinkConfig
+		wantErr bool
+	}{
+		{"valid", FormatJSON, &models.WebhookSinkConfig{URL: "https://collector.internal/cids"}, false},
+		{"array", FormatJSON, &models.WebhookSinkConfig{URL: "http://collector/cids", Encoding: EncodingArray}, false},
+		{"structured ndjson", FormatStructured, &models.WebhookSinkConfig{URL: "http://collector/cids"}, false},
+		{"missing options", FormatJSON, nil, true},
+		{"missing url", FormatJSON, &models.WebhookSinkConfig{}, true},
+		{"not http", FormatJSON, &models.WebhookSinkConfig{URL: "ftp://collector/cids"}, true},
+		{"relative url", FormatJSON, &models.WebhookSinkConfig{URL: "/cids"}, true},
+		{"unknown encoding", FormatJSON, &models.WebhookSinkConfig{URL: "http://collector", Encoding: "xml"}, true},
+		{"structured array", FormatStructured, &models.WebhookSinkConfig{URL: "http://collector", Encoding: EncodingArray}, true},
+		{"negative batch", FormatJSON, &models.WebhookSinkConfig{URL: "http://collector", BatchSize: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(models.SinkConfig{Name: "hook", Type: TypeWebhook, Format: tt.format, Webhook: tt.webhook})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package source
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ValidateGlob reports whether pattern is a well-formed glob
+func ValidateGlob(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty glob pattern")
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// MatchGlob matches a slash-separated relative path against a glob pattern.
+// Each segment is matched with path.Match, and a "**" segment matches zero or
+// more whole segments. A pattern without a slash matches the base name at any
+// depth, so "*.log" behaves like "**/*.log".
+func MatchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
@@ -0,0 +1,63 @@
+package source
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.log", "app.log", true},
+		{"*.log", "nested/deep/app.log", true},
+		{"*.log", "app.log.1", false},
+		{"*.log", "app.txt", false},
+		{"app-?.log", "app-1.log", true},
+		{"app-[0-9].log", "app-x.log", false},
+		{"*/*/*.log", "ns_pod_uid/container/0.log", true},
+		{"*/*/*.log", "ns_pod_uid/0.log", false},
+		{"*/*/*.log", "a/b/c/0.log", false},
+		{"**/*.log", "app.log", true},
+		{"**/*.log", "a/b/c/app.log", true},
+		{"logs/**", "logs/a/b.log", true},
+		{"logs/**/*.log", "logs/app.log", true},
+		{"logs/**/*.log", "logs/x/y/app.log", true},
+		{"logs/**/*.log", "other/app.log", false},
+		{"a/**/b/*.log", "a/x/y/b/app.log", true},
+		{"a/**/b/*.log", "a/b/app.log", true},
+		{"a/**/b/*.log", "a/x/c/app.log", false},
+		{"archive", "archive", true},
+		{"archive", "x/archive", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"*.log", false},
+		{"**/*.log", false},
+		{"*/*/*.log", false},
+		{"[a-z]*.log", false},
+		{"", true},
+		{"[unclosed.log", true},
+		{"logs/**/[bad", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if err := ValidateGlob(tt.pattern); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateGlob(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cidtracker/pkg/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultPatterns are used for a source that configures no include patterns
+var DefaultPatterns = []string{"*.log"}
+
+// Matcher decides which files below a log source root are followed
+type Matcher struct {
+	source  models.LogSource
+	root    string
+	include []string
+	exclude []string
+}
+
+// NewMatcher creates a matcher for a log source's include and exclude patterns
+func NewMatcher(source models.LogSource) (*Matcher, error) {
+	include := source.Patterns
+	if len(include) == 0 {
+		include = DefaultPatterns
+	}
+
+	for _, pattern := range append(append([]string{}, include...), source.Exclude...) {
+		if err := ValidateGlob(pattern); err != nil {
+			return nil, fmt.Errorf("log source '%s': %w", source.Name, err)
+		}
+	}
+
+	return &Matcher{
+		source:  source,
+		root:    filepath.Clean(source.Path),
+		include: include,
+		exclude: source.Exclude,
+	}, nil
+}
+
+// Source returns the log source the matcher was built from
+func (m *Matcher) Source() models.LogSource {
+	return m.source
+}
+
+// Root returns the directory the source is rooted at
+func (m *Matcher) Root() string {
+	return m.root
+}
+
+// relative returns path relative to the root with slash separators. A root
+// that is itself a file is matched by its base name.
+func (m *Matcher) relative(path string) (string, bool) {
+	path = filepath.Clean(path)
+	if path == m.root {
+		return filepath.Base(path), true
+	}
+
+	rel, err := filepath.Rel(m.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Contains reports whether path is the root or lies below it
+func (m *Matcher) Contains(path string) bool {
+	_, ok := m.relative(path)
+	return ok
+}
+
+// Match reports whether a file below the root matches an include pattern and
+// no exclude pattern
+func (m *Matcher) Match(path string) bool {
+	rel, ok := m.relative(path)
+	if !ok {
+		return false
+	}
+
+	if matchAny(m.exclude, rel) {
+		return false
+	}
+	return matchAny(m.include, rel)
+}
+
+// SkipDir reports whether a directory below the root is excluded and should
+// be neither walked nor watched
+func (m *Matcher) SkipDir(path string) bool {
+	rel, ok := m.relative(path)
+	if !ok {
+		return true
+	}
+	if filepath.Clean(path) == m.root {
+		return false
+	}
+	return matchAny(m.exclude, rel)
+}
+
+// Walk calls fn for every matching file below dir, which is the root or one of
+// its subdirectories, skipping excluded directories and those that cannot be
+// read
+func (m *Matcher) Walk(dir string, fn func(path string)) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return walkError(dir, path, info, err)
+		}
+		if info.IsDir() {
+			if m.SkipDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if m.Match(path) {
+			fn(path)
+		}
+		return nil
+	})
+}
+
+// Dirs returns dir and every directory below it that is not excluded, leaving
+// out the contents of those that cannot be read
+func (m *Matcher) Dirs(dir string) ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return walkError(dir, path, info, err)
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if m.SkipDir(path) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs, err
+}
+
+// walkError handles an error walking below dir. Only an error for dir itself
+// stops the walk; an unreadable subdirectory or file is logged and skipped so
+// the rest of the tree is still found.
+func walkError(dir, path string, info os.FileInfo, err error) error {
+	if path == dir {
+		return err
+	}
+	log.WithError(err).WithField("path", path).Warn("Skipping unreadable path below log source")
+	if info != nil && info.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"cidtracker/pkg/models"
+)
+
+func createFiles(t *testing.T, root string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+}
+
+func TestNewMatcher_DefaultPatterns(t *testing.T) {
+	m, err := NewMatcher(models.LogSource{Name: "app", Path: "/var/log/app/"})
+	if err != nil {
+		t.Fatalf("NewMatcher() error = %v", err)
+	}
+
+	if m.Root() != "/var/log/app" {
+		t.Errorf("Root() = %v, want /var/log/app", m.Root())
+	}
+	if !m.Match("/var/log/app/nested/app.log") {
+		t.Error("default patterns should match .log files at any depth")
+	}
+}
+
+func TestNewMatcher_InvalidPattern(t *testing.T) {
+	tests := []struct {
+		name   string
+		source models.LogSource
+	}{
+		{name: "include", source: models.LogSource{Name: "app", Path: "/logs", Patterns: []string{"[bad"}}},
+		{name: "exclude", source: models.LogSource{Name: "app", Path: "/logs", Exclude: []string{"[bad"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMatcher(tt.source); err == nil {
+				t.Error("expected error for invalid pattern")
+			}
+		})
+	}
+}
+
+func TestMatcher_Match(t *testing.T) {
+	m, err := NewMatcher(models.LogSource{
+		Name:     "pods",
+		Path:     "/var/log/pods",
+		Patterns: []string{"*/*/*.log"},
+		Exclude:  []string{"kube-system_*/**"},
+	})
+	if err != nil {
+		t.Fatalf("NewMatcher() error = %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/var/log/pods/default_web_123/nginx/0.log", true},
+		{"/var/log/pods/default_web_123/nginx/0.log.20240101", false},
+		{"/var/log/pods/default_web_123/0.log", false},
+		{"/var/log/pods/kube-system_dns_456/coredns/0.log", false},
+		{"/var/log/other/default_web_123/nginx/0.log", false},
+		{"/var/log/pods", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := m.Match(tt.path); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_ContainsAndSkipDir(t *testing.T) {
+	m, _ := NewMatcher(models.LogSource{Name: "app", Path: "/logs", Exclude: []string{"archive"}})
+
+	if !m.Contains("/logs/a/b") {
+		t.Error("Contains() should be true below the root")
+	}
+	if m.Contains("/logs-other/a") {
+		t.Error("Contains() should be false for a sibling with a common prefix")
+	}
+	if m.SkipDir("/logs") {
+		t.Error("SkipDir() should never skip the root")
+	}
+	if !m.SkipDir("/logs/x/archive") {
+		t.Error("SkipDir() should skip excluded directories")
+	}
+	if !m.SkipDir("/elsewhere") {
+		t.Error("SkipDir() should skip directories outside the root")
+	}
+}
+
+func TestMatcher_WalkAndDirs(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root,
+		"app.log",
+		"app.log.1",
+		"notes.txt",
+		"api/server.log",
+		"api/archive/old.log",
+		"worker/deep/job.log",
+	)
+
+	m, err := NewMatcher(models.LogSource{Name: "app", Path: root, Exclude: []string{"archive"}})
+	if err != nil {
+		t.Fatalf("NewMatcher() error = %v", err)
+	}
+
+	var files []string
+	if err := m.Walk(root, func(path string) {
+		rel, _ := filepath.Rel(root, path)
+		files = append(files, filepath.ToSlash(rel))
+	}); err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	sort.Strings(files)
+
+	wantFiles := []string{"api/server.log", "app.log", "worker/deep/job.log"}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("Walk() files = %v, want %v", files, wantFiles)
+	}
+
+	dirs, err := m.Dirs(root)
+	if err != nil {
+		t.Fatalf("Dirs() error = %v", err)
+	}
+	var rels []string
+	for _, dir := range dirs {
+		rel, _ := filepath.Rel(root, dir)
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+	sort.Strings(rels)
+
+	wantDirs := []string{".", "api", "worker", "worker/deep"}
+	if !reflect.DeepEqual(rels, wantDirs) {
+		t.Errorf("Dirs() = %v, want %v", rels, wantDirs)
+	}
+}
+
+func TestMatcher_WalkSkipsUnreadableDirs(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	root := t.TempDir()
+	createFiles(t, root, "app.log", "locked/secret.log", "open/api.log")
+	locked := filepath.Join(root, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatalf("failed to lock directory: %v", err)
+	}
+	defer os.Chmod(locked, 0755)
+
+	m, err := NewMatcher(models.LogSource{Name: "app", Path: root})
+	if err != nil {
+		t.Fatalf("NewMatcher() error = %v", err)
+	}
+
+	var files []string
+	if err := m.Walk(root, func(path string) {
+		rel, _ := filepath.Rel(root, path)
+		files = append(files, filepath.ToSlash(rel))
+	}); err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	sort.Strings(files)
+	if want := []string{"app.log", "open/api.log"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("Walk() files = %v, want %v", files, want)
+	}
+	if _, err := m.Dirs(root); err != nil {
+		t.Errorf("Dirs() error = %v", err)
+	}
+}
+
+func TestWalkError(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "app.log", "api/server.log")
+	dirInfo, _ := os.Stat(filepath.Join(root, "api"))
+	fileInfo, _ := os.Stat(filepath.Join(root, "app.log"))
+	denied := os.ErrPermission
+
+	if err := walkError(root, root, nil, denied); err != denied {
+		t.Errorf("walkError(root) = %v, want the error", err)
+	}
+	if err := walkError(root, filepath.Join(root, "api"), dirInfo, denied); err != filepath.SkipDir {
+		t.Errorf("walkError(subdirectory) = %v, want SkipDir", err)
+	}
+	if err := walkError(root, filepath.Join(root, "app.log"), fileInfo, denied); err != nil {
+		t.Errorf("walkError(file) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package tailer
+
+import "os"
+
+// FileID identifies a file by device and inode. It is always zero on
+// platforms without inodes; rotation is still detected via os.SameFile.
+type FileID struct {
+	Dev uint64 `json:"dev"`
+	Ino uint64 `json:"ino"`
+}
+
+func fileIDOf(info os.FileInfo) FileID {
+	return FileID{}
+}
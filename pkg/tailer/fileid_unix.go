@@ -0,0 +1,25 @@
+//go:build unix
+
+package tailer
+
+import (
+	"os"
+	"syscall"
+)
+
+// FileID identifies a file by device and inode
+type FileID struct {
+	Dev uint64 `json:"dev"`
+	Ino uint64 `json:"ino"`
+}
+
+func fileIDOf(info os.FileInfo) FileID {
+	if info == nil {
+		return FileID{}
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileID{}
+	}
+	return FileID{Dev: uint64(st.Dev), Ino: uint64(st.Ino)}
+}
@@ -0,0 +1,351 @@
+package tailer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// FingerprintSize is the number of leading bytes hashed to recognise a file
+// independently of its path and inode
+const FingerprintSize = 1024
+
+// DefaultPartialLineTimeout is how long a final line without a newline is held
+// back waiting for the rest of it
+const DefaultPartialLineTimeout = time.Second
+
+// Change describes what happened to the followed path during a poll
+type Change int
+
+const (
+	// NoChange means the same file is still being read
+	NoChange Change = iota
+	// Rotated means the path now refers to a different file, which is read from offset 0
+	Rotated
+	// Truncated means the file shrank below the read offset and is re-read from offset 0
+	Truncated
+)
+
+func (c Change) String() string {
+	switch c {
+	case Rotated:
+		return "rotated"
+	case Truncated:
+		return "truncated"
+	default:
+		return "none"
+	}
+}
+
+// Follower reads lines from a file by path and keeps reading across log rotation.
+// A rename is detected by comparing device and inode of the open handle with the
+// file currently at the path, and copytruncate by the size dropping below the
+// read offset. Lines longer than the maximum line size are truncated or skipped.
+//
+// A line is emitted once its newline has been read, so a writer caught
+// mid-line never produces half a line. An incomplete final line is emitted
+// after the file has been idle for the partial line timeout, before the
+// follower switches to a rotated or truncated file, or by Flush.
+type Follower struct {
+	path   string
+	file   *os.File
+	info   os.FileInfo
+	lines  *lineReader
+	offset int64
+	// lineOffset is where the line being emitted starts
+	lineOffset int64
+	// lineBase is the number of lines before the offset the line reader
+	// started at
+	lineBase int64
+	// linesUnknown is set when reading started past the beginning of the
+	// file without a line count, so line numbers are not known
+	linesUnknown bool
+
+	partialTimeout time.Duration
+
+	// fingerprint is cached once FingerprintSize bytes have been hashed
+	fingerprint     string
+	fingerprintSize int
+}
+
+// Position records how far a file has been read. Line is the number of lines
+// before Offset, or 0 if unknown. The fingerprint is a hash of the first FingerprintSize bytes,
+// or fewer if the file was shorter.
+type Position struct {
+	Offset          int64  `json:"offset"`
+	Line            int64  `json:"line,omitempty"`
+	FileID          FileID `json:"file_id"`
+	Fingerprint     string `json:"fingerprint"`
+	FingerprintSize int    `json:"fingerprint_size"`
+}
+
+// Open opens path for following. When fromEnd is set, reading starts at the
+// current end of the file, otherwise at the beginning. Lines before the end
+// are not counted, so line numbers are unknown when starting at the end.
+func Open(path string, fromEnd bool) (*Follower, error) {
+	f := &Follower{path: path, partialTimeout: DefaultPartialLineTimeout}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	if fromEnd {
+		offset, err := f.file.Seek(0, io.SeekEnd)
+		if err != nil {
+			f.file.Close()
+			return nil, fmt.Errorf("failed to seek to end of file %s: %w", path, err)
+		}
+		f.offset, f.linesUnknown = offset, offset > 0
+	}
+
+	return f, nil
+}
+
+// open replaces the current handle with a fresh one for the path at offset 0
+func (f *Follower) open() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", f.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat file %s: %w", f.path, err)
+	}
+
+	if f.file != nil {
+		f.file.Close()
+	}
+
+	f.file = file
+	f.info = info
+	if f.lines == nil {
+		f.lines = newLineReader(file, DefaultMaxLineSize, TruncateLines)
+	} else {
+		f.lines.reset(file)
+	}
+	f.offset, f.lineBase, f.linesUnknown = 0, 0, false
+	f.fingerprint, f.fingerprintSize = "", 0
+	return nil
+}
+
+// Resume opens path and continues from a saved position if the file is the one
+// the position was taken from: same fingerprint, same inode where known, and
+// not shorter than the saved offset. Otherwise the file is read from the
+// beginning and resumed is false. Line numbers continue from the saved line
+// count and are unknown for positions saved without one.
+func Resume(path string, pos Position) (f *Follower, resumed bool, err error) {
+	f, err = Open(path, false)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if pos.Offset <= 0 || pos.FingerprintSize <= 0 {
+		return f, false, nil
+	}
+
+	// Device numbers can change when a volume is remounted, so only the inode is compared
+	if id := f.ID(); id.Ino != 0 && pos.FileID.Ino != 0 && id.Ino != pos.FileID.Ino {
+		return f, false, nil
+	}
+
+	if f.info.Size() < pos.Offset {
+		return f, false, nil
+	}
+
+	fingerprint, size, err := fingerprintOf(f.file, pos.FingerprintSize)
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	if size != pos.FingerprintSize || fingerprint != pos.Fingerprint {
+		return f, false, nil
+	}
+
+	if _, err := f.file.Seek(pos.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, false, fmt.Errorf("failed to seek file %s: %w", path, err)
+	}
+	f.lines.reset(f.file)
+	f.offset, f.lineBase, f.linesUnknown = pos.Offset, pos.Line, pos.Line <= 0
+
+	return f, true, nil
+}
+
+// SetLineLimit sets the maximum line size in bytes and whether longer lines are
+// truncated or skipped. A size of zero or less selects DefaultMaxLineSize.
+func (f *Follower) SetLineLimit(maxSize int, policy string) {
+	f.lines.setLimit(maxSize, policy)
+}
+
+// SetPartialLineTimeout sets how long an incomplete final line is held back.
+// A timeout of zero or less selects DefaultPartialLineTimeout.
+func (f *Follower) SetPartialLineTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultPartialLineTimeout
+	}
+	f.partialTimeout = timeout
+}
+
+// HasPartial reports whether an incomplete final line is being held back
+func (f *Follower) HasPartial() bool {
+	return f.lines.hasPartial()
+}
+
+// Flush emits an incomplete final line that is being held back, e.g. before
+// the follower is closed
+func (f *Follower) Flush(emit func(line string)) {
+	line, consumed, ok := f.lines.flush()
+	f.lineOffset = f.offset
+	f.offset += consumed
+	if ok {
+		emit(line)
+	}
+}
+
+// TakeOversized returns the number of lines truncated and skipped since the
+// previous call
+func (f *Follower) TakeOversized() (truncated, skipped int) {
+	truncated, skipped = f.lines.truncated, f.lines.skipped
+	f.lines.truncated, f.lines.skipped = 0, 0
+	return truncated, skipped
+}
+
+// Position returns the current read position of the open file
+func (f *Follower) Position() (Position, error) {
+	if f.fingerprintSize < FingerprintSize {
+		fingerprint, size, err := fingerprintOf(f.file, FingerprintSize)
+		if err != nil {
+			return Position{}, err
+		}
+		f.fingerprint, f.fingerprintSize = fingerprint, size
+	}
+
+	return Position{
+		Offset:          f.offset,
+		Line:            f.LineNumber(),
+		FileID:          f.ID(),
+		Fingerprint:     f.fingerprint,
+		FingerprintSize: f.fingerprintSize,
+	}, nil
+}
+
+// fingerprintOf hashes up to n leading bytes of file without moving its offset
+func fingerprintOf(file *os.File, n int) (string, int, error) {
+	buf := make([]byte, n)
+	read, err := file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", 0, fmt.Errorf("failed to fingerprint file %s: %w", file.Name(), err)
+	}
+
+	sum := sha256.Sum256(buf[:read])
+	return hex.EncodeToString(sum[:]), read, nil
+}
+
+// Path returns the followed path
+func (f *Follower) Path() string {
+	return f.path
+}
+
+// Offset returns the byte offset of the next unread line in the open file. The
+// bytes of an incomplete final line are not included until it is emitted.
+func (f *Follower) Offset() int64 {
+	return f.offset
+}
+
+// LineOffset returns the byte offset at which the line passed to the emit
+// function of Poll or Flush starts in its file. It is only meaningful while
+// that function runs.
+func (f *Follower) LineOffset() int64 {
+	return f.lineOffset
+}
+
+// LineNumber returns the 1-based number of the line passed to the emit
+// function of Poll or Flush, counting skipped oversized lines, or 0 if line
+// numbers are unknown. It is only meaningful while that function runs.
+func (f *Follower) LineNumber() int64 {
+	if f.linesUnknown {
+		return 0
+	}
+	return f.lineBase + f.lines.ended
+}
+
+// ID returns the device and inode of the open file
+func (f *Follower) ID() FileID {
+	return fileIDOf(f.info)
+}
+
+// Poll emits every complete line available in the open file, then checks
+// whether the path was rotated or truncated. After a rename the old handle is
+// drained to EOF before the new file is opened and read from offset 0. A path
+// that no longer exists is not an error; the old handle is kept until a new
+// file appears.
+func (f *Follower) Poll(emit func(line string)) (Change, error) {
+	if err := f.readAvailable(emit); err != nil {
+		return NoChange, err
+	}
+	if f.lines.hasPartial() && f.lines.idle() >= f.partialTimeout {
+		f.Flush(emit)
+	}
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NoChange, nil
+		}
+		return NoChange, fmt.Errorf("failed to stat file %s: %w", f.path, err)
+	}
+
+	if !os.SameFile(f.info, info) {
+		// Nothing more will be appended to the old file
+		f.Flush(emit)
+		if err := f.open(); err != nil {
+			return NoChange, err
+		}
+		return Rotated, f.readAvailable(emit)
+	}
+
+	if info.Size() < f.offset {
+		f.Flush(emit)
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return NoChange, fmt.Errorf("failed to rewind truncated file %s: %w", f.path, err)
+		}
+		f.lines.reset(f.file)
+		f.offset, f.lineBase, f.linesUnknown = 0, 0, false
+		f.fingerprint, f.fingerprintSize = "", 0
+		return Truncated, f.readAvailable(emit)
+	}
+
+	return NoChange, nil
+}
+
+// readAvailable emits complete lines until EOF
+func (f *Follower) readAvailable(emit func(line string)) error {
+	for {
+		line, consumed, ok, err := f.lines.next()
+		f.offset += consumed
+		// Skipped oversized lines are counted in consumed before this one
+		f.lineOffset = f.offset - f.lines.lineSize
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", f.path, err)
+		}
+		if !ok {
+			return nil
+		}
+		emit(line)
+	}
+}
+
+// Exists reports whether the followed path still exists
+func (f *Follower) Exists() bool {
+	_, err := os.Stat(f.path)
+	return err == nil
+}
+
+// Close closes the open file
+func (f *Follower) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,551 @@
+package tailer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func poll(t *testing.T, f *Follower) ([]string, Change) {
+	t.Helper()
+	var lines []string
+	change, err := f.Poll(func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	return lines, change
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "existing\n")
+
+	tests := []struct {
+		name       string
+		fromEnd    bool
+		wantLines  []string
+		wantOffset int64
+	}{
+		{name: "from beginning", fromEnd: false, wantLines: []string{"existing"}, wantOffset: 9},
+		{name: "from end", fromEnd: true, wantLines: nil, wantOffset: 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Open(path, tt.fromEnd)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer f.Close()
+
+			lines, change := poll(t, f)
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("lines = %v, want %v", lines, tt.wantLines)
+			}
+			if change != NoChange {
+				t.Errorf("change = %v, want none", change)
+			}
+			if f.Offset() != tt.wantOffset {
+				t.Errorf("Offset() = %d, want %d", f.Offset(), tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestOpen_MissingFile(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.log"), true); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestPoll_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+
+	f, err := Open(path, true)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	appendFile(t, path, "one\r\ntwo\n")
+	lines, _ := poll(t, f)
+	if !reflect.DeepEqual(lines, []string{"one", "two"}) {
+		t.Errorf("lines = %v, want [one two]", lines)
+	}
+
+	appendFile(t, path, "three\n")
+	lines, _ = poll(t, f)
+	if !reflect.DeepEqual(lines, []string{"three"}) {
+		t.Errorf("lines = %v, want [three]", lines)
+	}
+}
+
+func TestPoll_RenameDrainsOldFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "before\n")
+
+	f, err := Open(path, true)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+	oldID := f.ID()
+
+	// The writer still appends to the old inode after logrotate renames it
+	if err := os.Rename(path, filepath.Join(dir, "app.log.1")); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	appendFile(t, filepath.Join(dir, "app.log.1"), "tail of old\n")
+
+	// No file at the path yet: drain the old handle and keep it
+	lines, change := poll(t, f)
+	if !reflect.DeepEqual(lines, []string{"tail of old"}) {
+		t.Errorf("lines = %v, want [tail of old]", lines)
+	}
+	if change != NoChange {
+		t.Errorf("change = %v, want none", change)
+	}
+
+	appendFile(t, filepath.Join(dir, "app.log.1"), "last of old\n")
+	appendFile(t, path, "first of new\n")
+
+	lines, change = poll(t, f)
+	want := []string{"last of old", "first of new"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %v, want %v", lines, want)
+	}
+	if change != Rotated {
+		t.Errorf("change = %v, want rotated", change)
+	}
+	if f.ID() == oldID && oldID != (FileID{}) {
+		t.Error("ID() should change after rotation")
+	}
+	if f.Offset() != int64(len("first of new\n")) {
+		t.Errorf("Offset() = %d, want %d", f.Offset(), len("first of new\n"))
+	}
+}
+
+func TestPoll_RemoveAndRecreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "old line\n")
+
+	f, err := Open(path, true)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if f.Exists() {
+		t.Error("Exists() should be false after remove")
+	}
+
+	appendFile(t, path, "new line\n")
+
+	lines, change := poll(t, f)
+	if !reflect.DeepEqual(lines, []string{"new line"}) {
+		t.Errorf("lines = %v, want [new line]", lines)
+	}
+	if change != Rotated {
+		t.Errorf("change = %v, want rotated", change)
+	}
+}
+
+func TestPoll_CopyTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a fairly long line that was already read\n")
+
+	f, err := Open(path, true)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatalf("truncate failed: %v", err)
+	}
+	appendFile(t, path, "after truncate\n")
+
+	lines, change := poll(t, f)
+	if !reflect.DeepEqual(lines, []string{"after truncate"}) {
+		t.Errorf("lines = %v, want [after truncate]", lines)
+	}
+	if change != Truncated {
+		t.Errorf("change = %v, want truncated", change)
+	}
+	if f.Offset() != int64(len("after truncate\n")) {
+		t.Errorf("Offset() = %d, want %d", f.Offset(), len("after truncate\n"))
+	}
+}
+
+func TestChange_String(t *testing.T) {
+	tests := []struct {
+		change Change
+		want   string
+	}{
+		{NoChange, "none"},
+		{Rotated, "rotated"},
+		{Truncated, "truncated"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.want {
+			t.Errorf("String() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "one\ntwo\n")
+
+	f, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	poll(t, f)
+	pos, err := f.Position()
+	if err != nil {
+		t.Fatalf("Position() error = %v", err)
+	}
+	f.Close()
+
+	if pos.Offset != 8 || pos.Line != 2 || pos.FingerprintSize != 8 || pos.Fingerprint == "" {
+		t.Fatalf("Position() = %+v, want offset 8 after line 2 with an 8 byte fingerprint", pos)
+	}
+
+	appendFile(t, path, "three\n")
+
+	resumed, ok, err := Resume(path, pos)
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	defer resumed.Close()
+
+	if !ok {
+		t.Fatal("Resume() should continue from the saved position")
+	}
+	lines, _ := poll(t, resumed)
+	if !reflect.DeepEqual(lines, []string{"three"}) {
+		t.Errorf("lines = %v, want [three]", lines)
+	}
+}
+
+func TestResume_Mismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(t *testing.T, path string)
+	}{
+		{
+			name: "replaced with different content",
+			modify: func(t *testing.T, path string) {
+				os.Remove(path)
+				appendFile(t, path, "uno\ndos\ntres\n")
+			},
+		},
+		{
+			name: "truncated below offset",
+			modify: func(t *testing.T, path string) {
+				os.Truncate(path, 0)
+				appendFile(t, path, "x\n")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			appendFile(t, path, "one\ntwo\n")
+
+			f, _ := Open(path, true)
+			pos, err := f.Position()
+			if err != nil {
+				t.Fatalf("Position() error = %v", err)
+			}
+			f.Close()
+
+			tt.modify(t, path)
+
+			resumed, ok, err := Resume(path, pos)
+			if err != nil {
+				t.Fatalf("Resume() error = %v", err)
+			}
+			defer resumed.Close()
+
+			if ok {
+				t.Error("Resume() should not continue from a position in another file")
+			}
+			if resumed.Offset() != 0 {
+				t.Errorf("Offset() = %d, want 0", resumed.Offset())
+			}
+		})
+	}
+}
+
+func TestPosition_FingerprintStopsGrowing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "short\n")
+
+	f, _ := Open(path, false)
+	defer f.Close()
+
+	pos, _ := f.Position()
+	if pos.FingerprintSize != 6 {
+		t.Errorf("FingerprintSize = %d, want 6", pos.FingerprintSize)
+	}
+
+	line := make([]byte, FingerprintSize)
+	for i := range line {
+		line[i] = 'x'
+	}
+	appendFile(t, path, string(line)+"\n")
+	poll(t, f)
+
+	pos, _ = f.Position()
+	if pos.FingerprintSize != FingerprintSize {
+		t.Errorf("FingerprintSize = %d, want %d", pos.FingerprintSize, FingerprintSize)
+	}
+}
+
+func TestPoll_LineWrittenInChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+
+	f, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	chunks := []string{
+		"INFO CID:550e8400-e29b",
+		"-51d4-a716-44665544",
+		"0000 done",
+		"\nnext\n",
+	}
+	var lines []string
+	for _, chunk := range chunks {
+		appendFile(t, path, chunk)
+		got, _ := poll(t, f)
+		lines = append(lines, got...)
+	}
+
+	want := []string{"INFO CID:550e8400-e29b-51d4-a716-446655440000 done", "next"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if int(f.Offset()) != len(strings.Join(chunks, "")) {
+		t.Errorf("Offset() = %d, want %d", f.Offset(), len(strings.Join(chunks, "")))
+	}
+}
+
+func TestPoll_PartialLineIdleTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+
+	f, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+	f.SetPartialLineTimeout(time.Second)
+
+	now := time.Now()
+	f.lines.now = func() time.Time { return now }
+
+	appendFile(t, path, "no trailing")
+	if lines, _ := poll(t, f); len(lines) != 0 {
+		t.Fatalf("lines = %q, want none before the timeout", lines)
+	}
+
+	// More data restarts the idle timer
+	now = now.Add(800 * time.Millisecond)
+	appendFile(t, path, " newline")
+	if lines, _ := poll(t, f); len(lines) != 0 {
+		t.Fatalf("lines = %q, want none while the line is growing", lines)
+	}
+	if !f.HasPartial() {
+		t.Error("HasPartial() = false, want true")
+	}
+
+	now = now.Add(800 * time.Millisecond)
+	if lines, _ := poll(t, f); len(lines) != 0 {
+		t.Fatalf("lines = %q, want none before the timeout since the last write", lines)
+	}
+
+	now = now.Add(300 * time.Millisecond)
+	lines, _ := poll(t, f)
+	if want := []string{"no trailing newline"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if f.HasPartial() {
+		t.Error("HasPartial() = true after flush")
+	}
+	if f.Offset() != int64(len("no trailing newline")) {
+		t.Errorf("Offset() = %d, want %d", f.Offset(), len("no trailing newline"))
+	}
+}
+
+func TestFlush_PartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "complete\nincomplete")
+
+	f, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	lines, _ := poll(t, f)
+	if want := []string{"complete"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+
+	lines = nil
+	f.Flush(func(line string) { lines = append(lines, line) })
+	if want := []string{"incomplete"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("Flush() lines = %q, want %q", lines, want)
+	}
+}
+
+func TestPoll_RotationFlushesPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+
+	f, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	appendFile(t, path, "last words")
+	poll(t, f)
+
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	appendFile(t, path, "fresh\n")
+
+	lines, change := poll(t, f)
+	if change != Rotated {
+		t.Errorf("change = %v, want rotated", change)
+	}
+	if want := []string{"last words", "fresh"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestPoll_LineOffset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "one\r\n"+strings.Repeat("x", 40)+"\ntwo\nthree")
+
+	f, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+	f.SetLineLimit(16, SkipLines)
+
+	offsets := map[string]int64{}
+	emit := func(line string) { offsets[line] = f.LineOffset() }
+	if _, err := f.Poll(emit); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	f.Flush(emit)
+
+	want := map[string]int64{"one": 0, "two": 46, "three": 50}
+	if !reflect.DeepEqual(offsets, want) {
+		t.Errorf("line offsets = %v, want %v", offsets, want)
+	}
+}
+
+func TestPoll_LineNumber(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "one\n"+strings.Repeat("x", 40)+"\ntwo\nthree")
+
+	f, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+	f.SetLineLimit(16, SkipLines)
+
+	numbers := map[string]int64{}
+	emit := func(line string) { numbers[line] = f.LineNumber() }
+	if _, err := f.Poll(emit); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	f.Flush(emit)
+
+	want := map[string]int64{"one": 1, "two": 3, "three": 4}
+	if !reflect.DeepEqual(numbers, want) {
+		t.Errorf("line numbers = %v, want %v", numbers, want)
+	}
+
+	// Following from the end does not count the lines already in the file,
+	// so their numbers are unknown
+	appendFile(t, path, "\n")
+	tail, err := Open(path, true)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer tail.Close()
+	pos, err := tail.Position()
+	if err != nil {
+		t.Fatalf("Position() error = %v", err)
+	}
+	if pos.Line != 0 {
+		t.Errorf("Position().Line = %d, want 0", pos.Line)
+	}
+
+	appendFile(t, path, "four\n")
+	tests := []struct {
+		name     string
+		line     int64
+		wantLine int64
+	}{
+		{name: "saved line count", line: 4, wantLine: 5},
+		{name: "no line count", line: 0, wantLine: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := pos
+			saved.Line = tt.line
+			resumed, ok, err := Resume(path, saved)
+			if err != nil || !ok {
+				t.Fatalf("Resume() = %v, %v, want the saved position", ok, err)
+			}
+			defer resumed.Close()
+
+			got := int64(-1)
+			if _, err := resumed.Poll(func(string) { got = resumed.LineNumber() }); err != nil {
+				t.Fatalf("Poll() error = %v", err)
+			}
+			if got != tt.wantLine {
+				t.Errorf("LineNumber() = %d, want %d", got, tt.wantLine)
+			}
+		})
+	}
+}
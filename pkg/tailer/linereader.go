@@ -0,0 +1,176 @@
+package tailer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DefaultMaxLineSize is the longest line emitted in full unless a limit is set
+const DefaultMaxLineSize = 1 << 20
+
+// Policies for lines longer than the maximum line size
+const (
+	// TruncateLines emits the first MaxLineSize bytes of an oversized line
+	TruncateLines = "truncate"
+	// SkipLines drops oversized lines
+	SkipLines = "skip"
+)
+
+// ValidateLinePolicy reports whether policy is a known oversized line policy
+func ValidateLinePolicy(policy string) error {
+	switch policy {
+	case "", TruncateLines, SkipLines:
+		return nil
+	}
+	return fmt.Errorf("invalid oversized line policy '%s': must be %s or %s", policy, TruncateLines, SkipLines)
+}
+
+// lineReader splits a file into lines of bounded size. The remainder of a line
+// longer than maxSize is read and discarded, so reading continues with the
+// next line and memory stays bounded however long the line is. A final line
+// without a newline is held back until the newline arrives or it is flushed.
+type lineReader struct {
+	reader  *bufio.Reader
+	maxSize int
+	policy  string
+	now     func() time.Time
+
+	buf []byte
+	// oversized is set once the current line exceeded maxSize
+	oversized bool
+	// pending counts the bytes read of the incomplete current line; they are
+	// reported as consumed once the line is complete or flushed
+	pending int64
+	// lineSize is the number of bytes of the last complete line returned,
+	// including its line ending
+	lineSize int64
+	// lastRead is when data of the current line last arrived
+	lastRead time.Time
+	// ended counts the lines completed or flushed since the last reset,
+	// including skipped ones
+	ended int64
+
+	truncated int
+	skipped   int
+}
+
+func newLineReader(r io.Reader, maxSize int, policy string) *lineReader {
+	lr := &lineReader{reader: bufio.NewReader(r), now: time.Now}
+	lr.setLimit(maxSize, policy)
+	return lr
+}
+
+// setLimit changes the maximum line size and oversized line policy, using
+// the defaults for zero values
+func (r *lineReader) setLimit(maxSize int, policy string) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxLineSize
+	}
+	if policy == "" {
+		policy = TruncateLines
+	}
+	r.maxSize, r.policy = maxSize, policy
+}
+
+// reset discards buffered data and any partial line and reads from r
+func (r *lineReader) reset(rd io.Reader) {
+	r.reader.Reset(rd)
+	r.buf = r.buf[:0]
+	r.oversized = false
+	r.pending = 0
+	r.ended = 0
+}
+
+// next returns the next complete line without its line ending and the number
+// of bytes consumed from the file, including those of skipped or truncated
+// data. ok is false at EOF, where an incomplete final line is kept pending.
+func (r *lineReader) next() (line string, consumed int64, ok bool, err error) {
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			return "", consumed, false, err
+		}
+		if len(chunk) == 0 {
+			return "", consumed, false, nil
+		}
+
+		r.pending += int64(len(chunk))
+		r.lastRead = r.now()
+
+		complete := err == nil
+		if complete {
+			chunk = chunk[:len(chunk)-1]
+		}
+		r.append(chunk)
+
+		if complete {
+			consumed += r.pending
+			r.lineSize, r.pending = r.pending, 0
+			r.ended++
+			if line, ok := r.finish(); ok {
+				return line, consumed, true, nil
+			}
+			continue
+		}
+		if err == io.EOF {
+			return "", consumed, false, nil
+		}
+	}
+}
+
+// hasPartial reports whether an incomplete final line is pending
+func (r *lineReader) hasPartial() bool {
+	return r.pending > 0
+}
+
+// idle returns how long ago data of the pending line last arrived
+func (r *lineReader) idle() time.Duration {
+	return r.now().Sub(r.lastRead)
+}
+
+// flush returns the pending incomplete line as if it had ended. ok is false
+// if there is none or it is skipped as oversized.
+func (r *lineReader) flush() (line string, consumed int64, ok bool) {
+	if r.pending == 0 {
+		return "", 0, false
+	}
+
+	consumed, r.pending = r.pending, 0
+	r.ended++
+	line, ok = r.finish()
+	return line, consumed, ok
+}
+
+// append adds a chunk of the current line, keeping at most maxSize bytes
+func (r *lineReader) append(chunk []byte) {
+	if r.oversized {
+		return
+	}
+	if room := r.maxSize - len(r.buf); len(chunk) > room {
+		r.buf = append(r.buf, chunk[:room]...)
+		r.oversized = true
+		return
+	}
+	r.buf = append(r.buf, chunk...)
+}
+
+// finish returns the current line and starts the next one. ok is false if the
+// line was oversized and is skipped.
+func (r *lineReader) finish() (string, bool) {
+	buf, oversized := r.buf, r.oversized
+	r.buf, r.oversized = r.buf[:0], false
+
+	if oversized {
+		if r.policy == SkipLines {
+			r.skipped++
+			return "", false
+		}
+		r.truncated++
+	} else if n := len(buf); n > 0 && buf[n-1] == '\r' {
+		buf = buf[:n-1]
+	}
+
+	return string(buf), true
+}
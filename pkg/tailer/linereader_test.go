@@ -0,0 +1,132 @@
+package tailer
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readLines reads every line from input with the given limit
+func readLines(t *testing.T, r *lineReader) ([]string, int64) {
+	t.Helper()
+	var lines []string
+	var total int64
+	for {
+		line, consumed, ok, err := r.next()
+		total += consumed
+		if err != nil {
+			t.Fatalf("next() error = %v", err)
+		}
+		if !ok {
+			return lines, total
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestLineReader_Limits(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+
+	tests := []struct {
+		name      string
+		input     string
+		maxSize   int
+		policy    string
+		want      []string
+		truncated int
+		skipped   int
+	}{
+		{
+			name:    "within limit",
+			input:   "one\r\ntwo\n",
+			maxSize: 16,
+			want:    []string{"one", "two"},
+		},
+		{
+			name:      "truncate",
+			input:     "CID:abc " + long + "\nnext\n",
+			maxSize:   16,
+			policy:    TruncateLines,
+			want:      []string{"CID:abc xxxxxxxx", "next"},
+			truncated: 1,
+		},
+		{
+			name:    "skip",
+			input:   "first\n" + long + "\nnext\n",
+			maxSize: 16,
+			policy:  SkipLines,
+			want:    []string{"first", "next"},
+			skipped: 1,
+		},
+		{
+			name:      "longer than the read buffer",
+			input:     strings.Repeat("y", 100000) + "\nnext\n",
+			maxSize:   50000,
+			want:      []string{strings.Repeat("y", 50000), "next"},
+			truncated: 1,
+		},
+		{
+			name:    "exactly the limit",
+			input:   "0123456789abcdef\n",
+			maxSize: 16,
+			want:    []string{"0123456789abcdef"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newLineReader(strings.NewReader(tt.input), tt.maxSize, tt.policy)
+
+			lines, consumed := readLines(t, r)
+			if !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("lines = %.40q, want %.40q", lines, tt.want)
+			}
+			if consumed != int64(len(tt.input)) {
+				t.Errorf("consumed = %d, want %d", consumed, len(tt.input))
+			}
+			if r.truncated != tt.truncated || r.skipped != tt.skipped {
+				t.Errorf("truncated, skipped = %d, %d, want %d, %d", r.truncated, r.skipped, tt.truncated, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestFollower_OversizedLineAcrossPolls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+
+	f, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+	f.SetLineLimit(8, TruncateLines)
+
+	// An oversized line is held back until its newline arrives
+	appendFile(t, path, "0123456789")
+	lines, _ := poll(t, f)
+	if len(lines) != 0 {
+		t.Errorf("lines = %q, want none before the newline", lines)
+	}
+	if f.Offset() != 0 {
+		t.Errorf("Offset() = %d, want 0 while the line is incomplete", f.Offset())
+	}
+
+	appendFile(t, path, "more of the same line\nnext\n")
+	lines, _ = poll(t, f)
+	if want := []string{"01234567", "next"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+
+	if truncated, skipped := f.TakeOversized(); truncated != 1 || skipped != 0 {
+		t.Errorf("TakeOversized() = %d, %d, want 1, 0", truncated, skipped)
+	}
+	if truncated, skipped := f.TakeOversized(); truncated != 0 || skipped != 0 {
+		t.Errorf("TakeOversized() after reset = %d, %d, want 0, 0", truncated, skipped)
+	}
+
+	if want := int64(len("0123456789more of the same line\nnext\n")); f.Offset() != want {
+		t.Errorf("Offset() = %d, want %d", f.Offset(), want)
+	}
+}
@@ -0,0 +1,262 @@
+package timestamp
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Named layouts accepted in addition to Go reference layouts
+const (
+	// LayoutRFC3339 parses RFC 3339 with optional fractional seconds
+	LayoutRFC3339 = "rfc3339"
+	// LayoutUnix parses epoch seconds, optionally with a fraction
+	LayoutUnix = "unix"
+	// LayoutUnixMilli parses epoch milliseconds
+	LayoutUnixMilli = "unix_ms"
+	// LayoutSyslog parses the BSD syslog "Jan _2 15:04:05" form, inferring the year
+	LayoutSyslog = "syslog"
+)
+
+// DefaultLayouts are tried when a log source configures none. Epoch layouts are
+// not included because any leading number would be taken as a time.
+var DefaultLayouts = []string{
+	LayoutRFC3339,
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+	LayoutSyslog,
+}
+
+// jsonTimeField finds a time field in a JSON log line
+var jsonTimeField = regexp.MustCompile(`"(?:@timestamp|timestamp|time|ts)"\s*:\s*(?:"([^"]*)"|([0-9.]+))`)
+
+type kind int
+
+const (
+	kindGo kind = iota
+	kindUnix
+	kindUnixMilli
+	kindSyslog
+)
+
+// layout is a compiled layout and the number of whitespace separated fields it spans
+type layout struct {
+	kind   kind
+	format string
+	fields int
+}
+
+// Parser finds the timestamp at the start of a log line, or in the time field
+// of a JSON line, by trying each layout in order. Times without a zone are
+// interpreted in the parser's location.
+type Parser struct {
+	layouts  []layout
+	location *time.Location
+	now      func() time.Time
+}
+
+// NewParser creates a parser for layouts, which are named layouts or Go
+// reference layouts. timezone is an IANA name, "Local", or empty for UTC.
+// Without layouts DefaultLayouts are used.
+func NewParser(layouts []string, timezone string) (*Parser, error) {
+	location, err := loadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(layouts) == 0 {
+		layouts = DefaultLayouts
+	}
+
+	p := &Parser{location: location, now: time.Now}
+	for _, name := range layouts {
+		l, err := compileLayout(name)
+		if err != nil {
+			return nil, err
+		}
+		p.layouts = append(p.layouts, l)
+	}
+
+	return p, nil
+}
+
+// Default returns a parser for DefaultLayouts in UTC
+func Default() *Parser {
+	p, _ := NewParser(nil, "")
+	return p
+}
+
+func loadLocation(timezone string) (*time.Location, error) {
+	switch timezone {
+	case "", "UTC":
+		return time.UTC, nil
+	case "Local":
+		return time.Local, nil
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone '%s': %w", timezone, err)
+	}
+	return location, nil
+}
+
+func compileLayout(name string) (layout, error) {
+	switch name {
+	case LayoutRFC3339:
+		return layout{kind: kindGo, format: time.RFC3339Nano, fields: 1}, nil
+	case LayoutUnix:
+		return layout{kind: kindUnix, fields: 1}, nil
+	case LayoutUnixMilli:
+		return layout{kind: kindUnixMilli, fields: 1}, nil
+	case LayoutSyslog:
+		return layout{kind: kindSyslog, format: "Jan _2 15:04:05", fields: 3}, nil
+	}
+
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return layout{}, fmt.Errorf("empty timestamp layout")
+	}
+	// A Go reference layout must mention at least one reference component
+	if !strings.ContainsAny(name, "0123456789") {
+		return layout{}, fmt.Errorf("invalid timestamp layout '%s'", name)
+	}
+
+	return layout{kind: kindGo, format: strings.Join(fields, " "), fields: len(fields)}, nil
+}
+
+// Parse returns the timestamp of a log line and whether one was found
+func (p *Parser) Parse(line string) (time.Time, bool) {
+	trimmed := strings.TrimLeft(line, " \t[")
+
+	if strings.HasPrefix(trimmed, "{") {
+		m := jsonTimeField.FindStringSubmatch(trimmed)
+		if m == nil {
+			return time.Time{}, false
+		}
+		value := m[1] + m[2]
+		for _, l := range p.layouts {
+			if t, ok := p.parseValue(l, value); ok {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	fields := leadingFields(trimmed, p.maxFields())
+	for _, l := range p.layouts {
+		if len(fields) < l.fields {
+			continue
+		}
+		value := strings.TrimRight(strings.Join(fields[:l.fields], " "), "],;|")
+		if t, ok := p.parseValue(l, value); ok {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (p *Parser) maxFields() int {
+	max := 0
+	for _, l := range p.layouts {
+		if l.fields > max {
+			max = l.fields
+		}
+	}
+	return max
+}
+
+// leadingFields returns up to n whitespace separated fields from the start of s
+func leadingFields(s string, n int) []string {
+	var fields []string
+	for len(fields) < n {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		fields = append(fields, s[:end])
+		s = s[end:]
+	}
+	return fields
+}
+
+func (p *Parser) parseValue(l layout, value string) (time.Time, bool) {
+	switch l.kind {
+	case kindUnix:
+		return parseUnix(value)
+	case kindUnixMilli:
+		if !isDigits(value) || len(value) < 12 || len(value) > 13 {
+			return time.Time{}, false
+		}
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.UnixMilli(ms).UTC(), true
+	case kindSyslog:
+		t, err := time.ParseInLocation(l.format, value, p.location)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return p.inferYear(t), true
+	default:
+		t, err := time.ParseInLocation(l.format, value, p.location)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+}
+
+// inferYear places a syslog time in the current year, or the previous one if
+// that would put it more than a day in the future (a December line read in January)
+func (p *Parser) inferYear(t time.Time) time.Time {
+	now := p.now().In(p.location)
+	year := now.Year()
+	withYear := time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), p.location)
+	if withYear.After(now.Add(24 * time.Hour)) {
+		withYear = withYear.AddDate(-1, 0, 0)
+	}
+	return withYear
+}
+
+// parseUnix parses epoch seconds with 9 or 10 integer digits and an optional fraction
+func parseUnix(value string) (time.Time, bool) {
+	whole, frac, _ := strings.Cut(value, ".")
+	if !isDigits(whole) || len(whole) < 9 || len(whole) > 10 {
+		return time.Time{}, false
+	}
+	if frac != "" && (!isDigits(frac) || len(frac) > 9) {
+		return time.Time{}, false
+	}
+
+	sec, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var nsec int64
+	if frac != "" {
+		nsec, _ = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+	}
+	return time.Unix(sec, nsec).UTC(), true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package timestamp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParser_Parse(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		layouts  []string
+		timezone string
+		line     string
+		want     time.Time
+		wantOK   bool
+	}{
+		{
+			name:   "log generator format",
+			line:   "2024-03-05 10:11:12.345 INFO [api] CID:abc request_id=1 login",
+			want:   time.Date(2024, 3, 5, 10, 11, 12, 345000000, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "without milliseconds",
+			line:   "2024-03-05 10:11:12 INFO message",
+			want:   time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "RFC3339 with offset",
+			line:   "2024-03-05T10:11:12.5+02:00 message",
+			want:   time.Date(2024, 3, 5, 8, 11, 12, 500000000, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "bracketed",
+			line:   "[2024-03-05T10:11:12Z] message",
+			want:   time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:     "timezone applies to zoneless layouts",
+			timezone: "Europe/Berlin",
+			line:     "2024-03-05 10:11:12.000 message",
+			want:     time.Date(2024, 3, 5, 10, 11, 12, 0, berlin),
+			wantOK:   true,
+		},
+		{
+			name:     "timezone does not override an explicit offset",
+			timezone: "Europe/Berlin",
+			line:     "2024-03-05T10:11:12Z message",
+			want:     time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			name:    "epoch seconds",
+			layouts: []string{LayoutUnix},
+			line:    "1709633472.25 message",
+			want:    time.Date(2024, 3, 5, 10, 11, 12, 250000000, time.UTC),
+			wantOK:  true,
+		},
+		{
+			name:    "epoch milliseconds",
+			layouts: []string{LayoutUnixMilli},
+			line:    "1709633472250 message",
+			want:    time.Date(2024, 3, 5, 10, 11, 12, 250000000, time.UTC),
+			wantOK:  true,
+		},
+		{
+			name:    "epoch seconds rejects short numbers",
+			layouts: []string{LayoutUnix},
+			line:    "42 message",
+		},
+		{
+			name:    "custom layout",
+			layouts: []string{"02/01/2006 15:04"},
+			line:    "05/03/2024 10:11 message",
+			want:    time.Date(2024, 3, 5, 10, 11, 0, 0, time.UTC),
+			wantOK:  true,
+		},
+		{
+			name:   "JSON time field",
+			line:   `{"level":"info","time":"2024-03-05T10:11:12Z","msg":"x"}`,
+			want:   time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:    "JSON epoch field",
+			layouts: []string{LayoutUnix},
+			line:    `{"ts":1709633472,"msg":"x"}`,
+			want:    time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC),
+			wantOK:  true,
+		},
+		{
+			name: "no timestamp",
+			line: "INFO [api] CID:abc login",
+		},
+		{
+			name: "empty line",
+			line: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewParser(tt.layouts, tt.timezone)
+			if err != nil {
+				t.Fatalf("NewParser() error = %v", err)
+			}
+
+			got, ok := p.Parse(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("Parse() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !got.Equal(tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_SyslogYear(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		line string
+		want time.Time
+	}{
+		{
+			name: "current year",
+			now:  time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			line: "Mar  5 10:11:12 host app[1]: message",
+			want: time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC),
+		},
+		{
+			name: "December line read in January",
+			now:  time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			line: "Dec 31 23:59:59 host app[1]: message",
+			want: time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewParser([]string{LayoutSyslog}, "")
+			if err != nil {
+				t.Fatalf("NewParser() error = %v", err)
+			}
+			p.now = func() time.Time { return tt.now }
+
+			got, ok := p.Parse(tt.line)
+			if !ok {
+				t.Fatal("Parse() found no timestamp")
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewParser_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		layouts  []string
+		timezone string
+	}{
+		{name: "unknown timezone", timezone: "Mars/Olympus"},
+		{name: "empty layout", layouts: []string{" "}},
+		{name: "layout without reference components", layouts: []string{"iso"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewParser(tt.layouts, tt.timezone); err == nil {
+				t.Error("NewParser() expected error")
+			}
+		})
+	}
+}
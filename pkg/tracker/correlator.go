@@ -0,0 +1,118 @@
+package tracker
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCorrelationTTL is how long a CID is remembered when no TTL is set
+const DefaultCorrelationTTL = time.Hour
+
+// MinExpireInterval is the shortest interval between expiry runs, so a tiny
+// TTL neither stops the ticker from starting nor makes it spin
+const MinExpireInterval = time.Second
+
+// Correlation is what is known about a CID seen within the correlation TTL
+type Correlation struct {
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Occurrences int
+	// Files lists the distinct log files the CID appeared in, in the order seen
+	Files []string
+}
+
+// Correlator follows CIDs across log files. A CID is forgotten once it has not
+// been seen for the TTL, which bounds the memory of a long-running tracker.
+type Correlator struct {
+	ttl time.Duration
+
+	mu   sync.Mutex
+	cids map[string]*Correlation
+}
+
+// NewCorrelator creates a correlator remembering CIDs for ttl. A TTL of zero or
+// less selects DefaultCorrelationTTL.
+func NewCorrelator(ttl time.Duration) *Correlator {
+	if ttl <= 0 {
+		ttl = DefaultCorrelationTTL
+	}
+	return &Correlator{ttl: ttl, cids: make(map[string]*Correlation)}
+}
+
+// Observe records an occurrence of cid in file at the given time and returns
+// the updated correlation. newFile is set when the CID had not been seen in
+// file before.
+func (c *Correlator) Observe(cid, file string, at time.Time) (corr Correlation, newFile bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cids[cid]
+	if !ok {
+		entry = &Correlation{FirstSeen: at}
+		c.cids[cid] = entry
+	}
+	entry.LastSeen = at
+	entry.Occurrences++
+
+	newFile = true
+	for _, seen := range entry.Files {
+		if seen == file {
+			newFile = false
+			break
+		}
+	}
+	if newFile {
+		entry.Files = append(entry.Files, file)
+	}
+
+	return entry.copy(), newFile
+}
+
+// Lookup returns the correlation of a CID seen within the TTL
+func (c *Correlator) Lookup(cid string) (Correlation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cids[cid]
+	if !ok {
+		return Correlation{}, false
+	}
+	return entry.copy(), true
+}
+
+// Len returns the number of CIDs currently remembered
+func (c *Correlator) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.cids)
+}
+
+// Expire forgets the CIDs not seen for the TTL before now and returns the
+// number remaining
+func (c *Correlator) Expire(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for cid, entry := range c.cids {
+		if now.Sub(entry.LastSeen) >= c.ttl {
+			delete(c.cids, cid)
+		}
+	}
+	return len(c.cids)
+}
+
+// ExpireInterval is how often Expire should run so that CIDs are forgotten at
+// most a tenth of the TTL late, but not more often than MinExpireInterval
+func (c *Correlator) ExpireInterval() time.Duration {
+	if interval := c.ttl / 10; interval > MinExpireInterval {
+		return interval
+	}
+	return MinExpireInterval
+}
+
+// copy returns a correlation that does not share the file list
+func (c *Correlation) copy() Correlation {
+	out := *c
+	out.Files = append([]string(nil), c.Files...)
+	return out
+}
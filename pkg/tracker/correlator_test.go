@@ -0,0 +1,102 @@
+package tracker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCorrelator_Observe(t *testing.T) {
+	c := NewCorrelator(time.Hour)
+	start := time.Now()
+
+	steps := []struct {
+		file        string
+		wantNew     bool
+		wantFiles   []string
+		occurrences int
+	}{
+		{file: "/var/log/a.log", wantNew: true, wantFiles: []string{"/var/log/a.log"}, occurrences: 1},
+		{file: "/var/log/a.log", wantNew: false, wantFiles: []string{"/var/log/a.log"}, occurrences: 2},
+		{file: "/var/log/b.log", wantNew: true, wantFiles: []string{"/var/log/a.log", "/var/log/b.log"}, occurrences: 3},
+	}
+
+	for i, step := range steps {
+		corr, newFile := c.Observe("cid-1", step.file, start.Add(time.Duration(i)*time.Second))
+		if newFile != step.wantNew {
+			t.Errorf("step %d: newFile = %v, want %v", i, newFile, step.wantNew)
+		}
+		if !reflect.DeepEqual(corr.Files, step.wantFiles) {
+			t.Errorf("step %d: Files = %v, want %v", i, corr.Files, step.wantFiles)
+		}
+		if corr.Occurrences != step.occurrences {
+			t.Errorf("step %d: Occurrences = %d, want %d", i, corr.Occurrences, step.occurrences)
+		}
+		if !corr.FirstSeen.Equal(start) {
+			t.Errorf("step %d: FirstSeen = %v, want %v", i, corr.FirstSeen, start)
+		}
+	}
+
+	// Returned correlations do not share state with the correlator
+	corr, _ := c.Lookup("cid-1")
+	corr.Files[0] = "changed"
+	if again, _ := c.Lookup("cid-1"); again.Files[0] != "/var/log/a.log" {
+		t.Errorf("Lookup() Files = %v, modified through a returned copy", again.Files)
+	}
+
+	if _, ok := c.Lookup("cid-2"); ok {
+		t.Error("Lookup() found a CID that was never observed")
+	}
+}
+
+func TestCorrelator_Expire(t *testing.T) {
+	c := NewCorrelator(time.Minute)
+	start := time.Now()
+
+	c.Observe("old", "/var/log/a.log", start)
+	c.Observe("recent", "/var/log/a.log", start.Add(30*time.Second))
+
+	if n := c.Expire(start.Add(59 * time.Second)); n != 2 {
+		t.Errorf("Expire() before the TTL = %d, want 2", n)
+	}
+	if n := c.Expire(start.Add(time.Minute)); n != 1 {
+		t.Errorf("Expire() after the TTL of the first CID = %d, want 1", n)
+	}
+	if _, ok := c.Lookup("old"); ok {
+		t.Error("expired CID is still tracked")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", c.Len())
+	}
+}
+
+func TestNewCorrelator_DefaultTTL(t *testing.T) {
+	c := NewCorrelator(0)
+	if c.ExpireInterval() != DefaultCorrelationTTL/10 {
+		t.Errorf("ExpireInterval() = %v, want %v", c.ExpireInterval(), DefaultCorrelationTTL/10)
+	}
+}
+
+func TestCorrelator_ExpireInterval(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{ttl: time.Minute, want: 6 * time.Second},
+		{ttl: 10 * time.Second, want: time.Second},
+		{ttl: 5 * time.Second, want: MinExpireInterval},
+		// "correlation_ttl": 5 in a config file is 5ns
+		{ttl: 5, want: MinExpireInterval},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ttl.String(), func(t *testing.T) {
+			interval := NewCorrelator(tt.ttl).ExpireInterval()
+			if interval != tt.want {
+				t.Errorf("ExpireInterval() = %v, want %v", interval, tt.want)
+			}
+			// Must not panic
+			time.NewTicker(interval).Stop()
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package tracker
+
+import (
+	"context"
+	"time"
+
+	"cidtracker/pkg/decoder"
+	"cidtracker/pkg/extractor"
+	"cidtracker/pkg/models"
+	"cidtracker/pkg/processor"
+	"cidtracker/pkg/sink"
+	"cidtracker/pkg/timestamp"
+	"cidtracker/pkg/workerpool"
+	log "github.com/sirupsen/logrus"
+)
+
+// Pipeline runs decoded lines through the extractor, validator, correlator and
+// sink stages. The Tracker is its source stage: it tails and decodes the files.
+//
+// Once started, extraction and validation run on a pool of workers, while the
+// correlator and sink receive the records of each file in the order its lines
+// were submitted. Until then, or after Stop, lines are processed on the
+// submitting goroutine.
+type Pipeline struct {
+	processor  *processor.Processor
+	correlator *Correlator
+	sink       sink.Sink
+	pool       *workerpool.Pool
+}
+
+// NewPipeline creates a pipeline that extracts and validates CIDs with
+// cidExtractor, correlates them for ttl and writes the valid ones to output
+func NewPipeline(cidExtractor *extractor.CIDExtractor, ttl time.Duration, output sink.Sink) *Pipeline {
+	return &Pipeline{
+		processor:  processor.NewExtractorProcessor(cidExtractor, nil),
+		correlator: NewCorrelator(ttl),
+		sink:       output,
+	}
+}
+
+// Start processes lines submitted from now on with the given number of
+// workers. At most queueSize lines wait for a worker; Submit blocks beyond that.
+func (p *Pipeline) Start(workers, queueSize int) {
+	p.pool = workerpool.New(workers, queueSize)
+}
+
+// Stop waits until every submitted line has reached the sink and stops the workers
+func (p *Pipeline) Stop() {
+	if p.pool == nil {
+		return
+	}
+	p.pool.Close()
+	p.pool = nil
+}
+
+// Submit queues a decoded line read from filePath for processing, parsing the
+// time it was logged with parser
+func (p *Pipeline) Submit(line decoder.Line, filePath string, parser *timestamp.Parser) {
+	if p.pool == nil {
+		p.Process(line, filePath, parser)
+		return
+	}
+
+	p.pool.Submit(filePath, func() func() {
+		records := p.validRecords(line, filePath, parser)
+		if len(records) == 0 {
+			return nil
+		}
+		return func() { p.deliver(records) }
+	})
+}
+
+// After runs fn once every line submitted for filePath so far has reached the
+// sink, e.g. to record a checkpoint that must not get ahead of the output
+func (p *Pipeline) After(filePath string, fn func()) {
+	if p.pool == nil {
+		fn()
+		return
+	}
+
+	p.pool.Submit(filePath, func() func() { return fn })
+}
+
+// Process runs a decoded line read from filePath through every stage on the
+// calling goroutine, parsing the time it was logged with parser
+func (p *Pipeline) Process(line decoder.Line, filePath string, parser *timestamp.Parser) {
+	p.deliver(p.validRecords(line, filePath, parser))
+}
+
+// validRecords extracts the CIDs of a line and keeps those with a valid UUID
+func (p *Pipeline) validRecords(line decoder.Line, filePath string, parser *timestamp.Parser) []models.CIDRecord {
+	records := p.processor.Records(line, parser)
+
+	valid := records[:0]
+	for _, record := range records {
+		// Require a valid UUID inside the CID value
+		if !record.IsValid {
+			log.WithFields(log.Fields{
+				"cid":     record.CID,
+				"pattern": record.PatternName,
+			}).Debug("No valid UUID in CID")
+			continue
+		}
+		record.Source = filePath
+		valid = append(valid, record)
+	}
+	return valid
+}
+
+// deliver correlates records and writes them to the sink
+func (p *Pipeline) deliver(records []models.CIDRecord) {
+	metrics := p.Metrics()
+
+	for _, record := range records {
+		corr, newFile := p.correlator.Observe(record.CID, record.Source, record.ExtractedAt)
+		if corr.Occurrences == 1 {
+			metrics.SetTrackedCIDs(p.correlator.Len())
+		}
+		if newFile && len(corr.Files) == 2 {
+			metrics.IncrementCorrelated()
+			log.WithFields(log.Fields{
+				"cid":   record.CID,
+				"files": corr.Files,
+			}).Debug("CID seen in multiple log files")
+		}
+
+		// Writes are not cancelled at shutdown: the lines being drained must
+		// still reach the sink, which bounds its own retries
+		if err := p.sink.Write(context.Background(), record); err != nil {
+			metrics.IncrementErrors()
+			log.WithError(err).WithField("cid", record.CID).Warn("Failed to write CID record")
+		}
+	}
+}
+
+// ExpireCorrelations forgets CIDs not seen within the correlation TTL
+func (p *Pipeline) ExpireCorrelations(now time.Time) {
+	p.Metrics().SetTrackedCIDs(p.correlator.Expire(now))
+}
+
+// Correlator returns the correlation stage
+func (p *Pipeline) Correlator() *Correlator {
+	return p.correlator
+}
+
+// SetSink replaces the sink stage. It must not be called while started.
+func (p *Pipeline) SetSink(output sink.Sink) {
+	p.sink = output
+}
+
+// Metrics returns the counters of every stage
+func (p *Pipeline) Metrics() *processor.Metrics {
+	return p.processor.GetMetrics()
+}
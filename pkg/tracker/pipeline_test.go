@@ -0,0 +1,226 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/decoder"
+	"cidtracker/pkg/extractor"
+	"cidtracker/pkg/models"
+	"cidtracker/pkg/timestamp"
+)
+
+// recordingSink collects the records written to it
+type recordingSink struct {
+	mu      sync.Mutex
+	records []models.CIDRecord
+	err     error
+}
+
+func (s *recordingSink) Write(ctx context.Context, record models.CIDRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return s.err
+}
+
+func (s *recordingSink) Flush(ctx context.Context) error { return nil }
+func (s *recordingSink) Close() error                    { return nil }
+
+// discardSink drops every record
+type discardSink struct{}
+
+func (discardSink) Write(context.Context, models.CIDRecord) error { return nil }
+func (discardSink) Flush(context.Context) error                   { return nil }
+func (discardSink) Close() error                                  { return nil }
+
+// testCID returns a distinct version 5 UUID for n
+func testCID(n int) string {
+	return fmt.Sprintf("550e8400-e29b-51d4-a716-%012d", n)
+}
+
+func TestPipeline_Process(t *testing.T) {
+	sink := &recordingSink{}
+	p := NewPipeline(extractor.NewCIDExtractor(), time.Hour, sink)
+
+	line := decoder.Line{
+		Message:  "2024-03-05T10:11:12Z CID:550e8400-e29b-51d4-a716-446655440000 CID[not-a-uuid]",
+		Metadata: map[string]string{"pod": "web-0"},
+	}
+	p.Process(line, "/var/log/app/a.log", timestamp.Default())
+
+	if len(sink.records) != 1 {
+		t.Fatalf("sink received %d records, want only the valid one", len(sink.records))
+	}
+	record := sink.records[0]
+	if record.UUID != "550e8400-e29b-51d4-a716-446655440000" || record.Source != "/var/log/app/a.log" {
+		t.Errorf("record = %+v, want UUID and source set", record)
+	}
+	if want := time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC); !record.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", record.Timestamp, want)
+	}
+	if record.Metadata["pod"] != "web-0" {
+		t.Errorf("Metadata = %v, want pod of the line", record.Metadata)
+	}
+
+	processed, extracted, valid, invalid, _ := p.Metrics().GetStats()
+	if processed != 1 || extracted != 2 || valid != 1 || invalid != 1 {
+		t.Errorf("GetStats() = %d, %d, %d, %d, want 1, 2, 1, 1", processed, extracted, valid, invalid)
+	}
+}
+
+func TestPipeline_CorrelatesAcrossFiles(t *testing.T) {
+	sink := &recordingSink{}
+	p := NewPipeline(extractor.NewCIDExtractor(), time.Hour, sink)
+
+	cid := "550e8400-e29b-51d4-a716-446655440000"
+	for _, file := range []string{"/var/log/a.log", "/var/log/a.log", "/var/log/b.log", "/var/log/c.log"} {
+		p.Process(decoder.Line{Message: "CID:" + cid}, file, timestamp.Default())
+	}
+	p.Process(decoder.Line{Message: "CID:660e8400-e29b-51d4-a716-446655440001"}, "/var/log/a.log", timestamp.Default())
+
+	tracked, correlated := p.Metrics().CorrelationStats()
+	if tracked != 2 {
+		t.Errorf("tracked = %d, want 2", tracked)
+	}
+	if correlated != 1 {
+		t.Errorf("correlated = %d, want 1", correlated)
+	}
+
+	corr, ok := p.Correlator().Lookup(cid)
+	if !ok || corr.Occurrences != 4 || len(corr.Files) != 3 {
+		t.Errorf("Lookup() = %+v, %v, want 4 occurrences in 3 files", corr, ok)
+	}
+
+	p.ExpireCorrelations(time.Now().Add(2 * time.Hour))
+	if tracked, _ := p.Metrics().CorrelationStats(); tracked != 0 {
+		t.Errorf("tracked after expiry = %d, want 0", tracked)
+	}
+}
+
+func TestPipeline_SinkError(t *testing.T) {
+	sink := &recordingSink{err: errors.New("disk full")}
+	p := NewPipeline(extractor.NewCIDExtractor(), time.Hour, sink)
+
+	p.Process(decoder.Line{Message: "CID:550e8400-e29b-51d4-a716-446655440000"}, "/var/log/a.log", timestamp.Default())
+
+	if _, _, _, _, errs := p.Metrics().GetStats(); errs != 1 {
+		t.Errorf("errors = %d, want 1", errs)
+	}
+}
+
+func TestTracker_SetSink(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+	sink := &recordingSink{}
+	tracker.SetSink(sink)
+
+	output := captureStdout(t, func() {
+		tracker.processLogLine("CID:550e8400-e29b-51d4-a716-446655440000", "/var/log/test.log")
+	})
+
+	if output != "" {
+		t.Errorf("stdout = %q, want nothing with a custom sink", output)
+	}
+	if len(sink.records) != 1 {
+		t.Errorf("sink received %d records, want 1", len(sink.records))
+	}
+}
+
+func TestPipeline_WorkersKeepFileOrder(t *testing.T) {
+	sink := &recordingSink{}
+	p := NewPipeline(extractor.NewCIDExtractor(), time.Hour, sink)
+	p.Start(8, 16)
+
+	files := []string{"/var/log/a.log", "/var/log/b.log", "/var/log/c.log"}
+	for i := 0; i < 600; i++ {
+		p.Submit(decoder.Line{Message: "CID:" + testCID(i)}, files[i%len(files)], timestamp.Default())
+	}
+	p.Stop()
+
+	if len(sink.records) != 600 {
+		t.Fatalf("sink received %d records, want 600", len(sink.records))
+	}
+
+	next := make(map[string]int)
+	for k, file := range files {
+		next[file] = k
+	}
+	for _, record := range sink.records {
+		if want := testCID(next[record.Source]); record.CID != want {
+			t.Fatalf("%s: got %s, want %s next", record.Source, record.CID, want)
+		}
+		next[record.Source] += len(files)
+	}
+}
+
+func TestPipeline_AfterWaitsForQueuedLines(t *testing.T) {
+	sink := &recordingSink{}
+	p := NewPipeline(extractor.NewCIDExtractor(), time.Hour, sink)
+	p.Start(4, 100)
+
+	var written []int
+	for i := 0; i < 50; i++ {
+		p.Submit(decoder.Line{Message: "CID:" + testCID(i)}, "/var/log/a.log", timestamp.Default())
+		p.After("/var/log/a.log", func() {
+			sink.mu.Lock()
+			written = append(written, len(sink.records))
+			sink.mu.Unlock()
+		})
+	}
+	p.Stop()
+
+	for i, n := range written {
+		if n != i+1 {
+			t.Fatalf("After() callback %d saw %d records written, want %d", i, n, i+1)
+		}
+	}
+}
+
+func TestPipeline_SubmitWithoutStart(t *testing.T) {
+	sink := &recordingSink{}
+	p := NewPipeline(extractor.NewCIDExtractor(), time.Hour, sink)
+
+	p.Submit(decoder.Line{Message: "CID:" + testCID(1)}, "/var/log/a.log", timestamp.Default())
+	if len(sink.records) != 1 {
+		t.Errorf("sink received %d records before Submit returned, want 1", len(sink.records))
+	}
+
+	called := false
+	p.After("/var/log/a.log", func() { called = true })
+	if !called {
+		t.Error("After() did not run the callback immediately")
+	}
+}
+
+// BenchmarkPipeline_Workers measures throughput of long lines spread over
+// several files; ns/op falls as workers are added, up to the number of CPUs
+func BenchmarkPipeline_Workers(b *testing.B) {
+	lines := make([]decoder.Line, 64)
+	for i := range lines {
+		payload := strings.Repeat(fmt.Sprintf("field%d=value%d ", i, i), 100)
+		lines[i] = decoder.Line{Message: fmt.Sprintf("2024-03-05T10:11:12Z INFO %s CID:%s", payload, testCID(i))}
+	}
+	files := make([]string, 16)
+	for i := range files {
+		files[i] = fmt.Sprintf("/var/log/pod-%d.log", i)
+	}
+	parser := timestamp.Default()
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			p := NewPipeline(extractor.NewCIDExtractor(), time.Hour, discardSink{})
+			p.Start(workers, 1000)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.Submit(lines[i%len(lines)], files[i%len(files)], parser)
+			}
+			p.Stop()
+		})
+	}
+}
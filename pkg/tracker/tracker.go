@@ -0,0 +1,851 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cidtracker/pkg/checkpoint"
+	"cidtracker/pkg/config"
+	"cidtracker/pkg/decoder"
+	"cidtracker/pkg/extractor"
+	"cidtracker/pkg/multiline"
+	"cidtracker/pkg/processor"
+	"cidtracker/pkg/server"
+	"cidtracker/pkg/sink"
+	"cidtracker/pkg/source"
+	"cidtracker/pkg/tailer"
+	"cidtracker/pkg/timestamp"
+	"cidtracker/pkg/validator"
+	"cidtracker/pkg/watcher"
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkpointSyncTimeout bounds how long the final checkpoint waits for the
+// sinks to write out queued records at shutdown
+const checkpointSyncTimeout = 30 * time.Second
+
+// fileState holds per-file processing state
+type fileState struct {
+	linesProcessed int64
+	decoder        decoder.Decoder
+	timestamps     *timestamp.Parser
+	// multiline is nil unless the file's source groups lines into events
+	multiline *multiline.Aggregator
+	// lineOffset and lineNumber locate the line being decoded, which is the
+	// first raw line of a line the runtime split into chunks
+	lineOffset int64
+	lineNumber int64
+	splitLine  bool
+	// source is the name of the log source the file belongs to
+	source string
+}
+
+// Tracker monitors log files for correlation IDs. It is the source stage of
+// the pipeline: it watches the configured log sources, follows their files
+// across rotation and decodes each line, then hands the lines to the Pipeline
+// for extraction, validation, correlation and output.
+type Tracker struct {
+	cfg          *config.Config
+	logPath      string
+	logPaths     []string
+	sources      []*source.Matcher
+	timestamps   map[*source.Matcher]*timestamp.Parser
+	outputFormat string
+	extractor    *extractor.CIDExtractor
+	pipeline     *Pipeline
+	outputs      *sink.Fanout
+	watcher      *watcher.Watcher
+	fileHandles  map[string]*tailer.Follower
+	fileStates   map[string]*fileState
+	checkpoints  *checkpoint.Store
+	metrics      *processor.Metrics
+	mu           sync.RWMutex
+	running      atomic.Bool
+	httpAddr     string
+	version      string
+}
+
+// NewForDir creates a tracker for a single log directory with the default
+// configuration. It panics if the configuration is invalid, e.g. for an
+// unknown output format.
+func NewForDir(logPath, outputFormat string) *Tracker {
+	cfg := config.DefaultConfig()
+	cfg.SetLogDir(logPath)
+	cfg.OutputFormat = outputFormat
+	if err := cfg.Validate(); err != nil {
+		panic(fmt.Sprintf("tracker: invalid configuration: %v", err))
+	}
+
+	t, err := New(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("tracker: %v", err))
+	}
+	return t
+}
+
+// New creates a tracker from a validated configuration, writing CIDs to the
+// configured sinks
+func New(cfg *config.Config) (*Tracker, error) {
+	cidExtractor, err := extractor.NewPatternExtractor(cfg.CIDPatterns, validator.NewUUIDValidator(cfg.EnableU5Only))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build CID extractor: %w", err)
+	}
+
+	outputs, err := sink.NewFanoutFromConfig(cfg.OutputSinks())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sinks: %w", err)
+	}
+
+	pipeline := NewPipeline(cidExtractor, cfg.CorrelationTTL, outputs)
+
+	t := &Tracker{
+		cfg:          cfg,
+		outputFormat: cfg.OutputFormat,
+		extractor:    cidExtractor,
+		pipeline:     pipeline,
+		outputs:      outputs,
+		fileHandles:  make(map[string]*tailer.Follower),
+		fileStates:   make(map[string]*fileState),
+		timestamps:   make(map[*source.Matcher]*timestamp.Parser),
+		metrics:      pipeline.Metrics(),
+	}
+
+	if cfg.StateDir != "" {
+		t.checkpoints, err = checkpoint.Open(cfg.StateDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, logSource := range cfg.ActiveSources() {
+		matcher, err := source.NewMatcher(logSource)
+		if err != nil {
+			return nil, err
+		}
+		parser, err := timestamp.NewParser(logSource.TimestampLayouts, logSource.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("log source '%s': %w", logSource.Name, err)
+		}
+		t.sources = append(t.sources, matcher)
+		t.timestamps[matcher] = parser
+		t.logPaths = append(t.logPaths, logSource.Path)
+	}
+	if len(t.logPaths) > 0 {
+		t.logPath = t.logPaths[0]
+	}
+
+	return t, nil
+}
+
+// SetSink replaces the configured sinks with a single one, which is closed
+// when the tracker stops. It must be called before Start.
+func (t *Tracker) SetSink(output sink.Sink) {
+	t.outputs.Close()
+	t.outputs = sink.NewFanout()
+	t.outputs.Add("custom", "custom", output, nil)
+	t.pipeline.SetSink(t.outputs)
+}
+
+// Pipeline returns the stages lines pass through after being read
+func (t *Tracker) Pipeline() *Pipeline {
+	return t.pipeline
+}
+
+// EnableHTTPServer serves the admin endpoints on addr while the tracker runs
+func (t *Tracker) EnableHTTPServer(addr, version string) {
+	t.httpAddr = addr
+	t.version = version
+}
+
+// Start begins monitoring log files
+func (t *Tracker) Start(ctx context.Context) error {
+	t.watcher = watcher.New(t.cfg.WatchInterval)
+	defer t.watcher.Close()
+
+	// Watch log directories and all their subdirectories
+	for _, matcher := range t.sources {
+		if err := t.watchDir(matcher, matcher.Root()); err != nil {
+			return fmt.Errorf("failed to watch directory %s: %w", matcher.Root(), err)
+		}
+		t.watchSubdirectories(matcher, matcher.Root())
+	}
+
+	// Give every sink its own queue so a slow one cannot hold up the others.
+	// The sinks are closed after the pipeline below has drained into them.
+	t.outputs.Start(t.cfg.BufferSize, t.cfg.FlushInterval)
+	defer func() {
+		if err := t.outputs.Close(); err != nil {
+			log.WithError(err).Warn("Error closing sinks")
+		}
+	}()
+
+	// Persist read positions periodically and once more at shutdown, after
+	// the pipeline has drained and before the sinks are closed. A position
+	// is only persisted once the sinks have written out the lines before it.
+	if t.checkpoints != nil {
+		go t.checkpoints.Run(ctx, t.cfg.FlushInterval, t.outputs.Flush)
+		defer t.flushCheckpoints()
+	}
+
+	// Extract CIDs on a pool of workers, keeping each file's output in order
+	t.pipeline.Start(t.cfg.Workers, t.cfg.BufferSize)
+	defer t.pipeline.Stop()
+
+	// Start the admin HTTP server before the backfill below, so the health
+	// probes answer while existing files are read and a port that cannot be
+	// bound fails the start before any record is emitted
+	if t.httpAddr != "" {
+		srv := server.NewServer(t.httpAddr, t.version, t)
+		if err := srv.Start(); err != nil {
+			t.cleanup()
+			return fmt.Errorf("failed to start HTTP server: %w", err)
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				log.WithError(err).Warn("Error shutting down HTTP server")
+			}
+		}()
+	}
+
+	t.running.Store(true)
+	defer t.running.Store(false)
+
+	// Process existing log files
+	if err := t.processExistingFiles(); err != nil {
+		log.WithError(err).Warn("Error processing existing files")
+	}
+
+	log.WithField("paths", t.logPaths).Info("Started monitoring log directories")
+
+	// Incomplete lines and pending multiline events are flushed once their
+	// file goes quiet
+	flushTicker := time.NewTicker(t.cfg.WatchInterval)
+	defer flushTicker.Stop()
+
+	expireTicker := time.NewTicker(t.pipeline.Correlator().ExpireInterval())
+	defer expireTicker.Stop()
+
+	// Main event loop
+	for {
+		select {
+		case <-ctx.Done():
+			t.cleanup()
+			return nil
+		case now := <-flushTicker.C:
+			t.pollPartialLines()
+			t.flushExpiredEvents(now)
+		case now := <-expireTicker.C:
+			t.pipeline.ExpireCorrelations(now)
+		case event, ok := <-t.watcher.Events():
+			if !ok {
+				return nil
+			}
+			t.handleFileEvent(event)
+		case err, ok := <-t.watcher.Errors():
+			if !ok {
+				return nil
+			}
+			t.metrics.IncrementErrors()
+			log.WithError(err).Warn("File watcher error")
+		}
+	}
+}
+
+// processExistingFiles processes log files that already exist
+func (t *Tracker) processExistingFiles() error {
+	found := make(map[string]bool)
+	for _, matcher := range t.sources {
+		err := matcher.Walk(matcher.Root(), func(path string) {
+			if found[path] {
+				return
+			}
+			found[path] = true
+			t.startLogFile(path)
+			t.processLogUpdates(path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Forget checkpoints of files that disappeared while we were stopped
+	if t.checkpoints != nil {
+		for _, path := range t.checkpoints.Paths() {
+			if !found[path] {
+				t.checkpoints.Delete(path)
+			}
+		}
+	}
+	return nil
+}
+
+// startLogFile opens a file found at startup according to the start_from setting
+func (t *Tracker) startLogFile(filePath string) {
+	switch t.cfg.StartPosition() {
+	case config.StartFromBeginning:
+		t.followLogFile(filePath, false)
+	case config.StartFromCheckpoint:
+		t.resumeLogFile(filePath)
+	default:
+		t.monitorLogFile(filePath)
+	}
+}
+
+// resumeLogFile continues a file from its checkpoint, or from the beginning
+// when there is none or it belongs to a different file
+func (t *Tracker) resumeLogFile(filePath string) {
+	pos, ok := t.checkpoints.Get(filePath)
+	if !ok {
+		t.followLogFile(filePath, false)
+		return
+	}
+
+	follower, resumed, err := tailer.Resume(filePath, pos)
+	if err != nil {
+		t.metrics.IncrementErrors()
+		log.WithError(err).WithField("file", filePath).Warn("Failed to open log file")
+		return
+	}
+
+	fields := log.Fields{"file": filePath, "offset": follower.Offset()}
+	if resumed {
+		log.WithFields(fields).Info("Resuming log file from checkpoint")
+	} else {
+		log.WithFields(fields).Info("Checkpoint does not match log file, reading from the beginning")
+	}
+
+	t.addFollower(filePath, follower)
+}
+
+// watchSubdirectories adds watches for every directory below dir that is not excluded
+func (t *Tracker) watchSubdirectories(matcher *source.Matcher, dir string) {
+	if t.watcher == nil {
+		return
+	}
+
+	dirs, err := matcher.Dirs(dir)
+	if err != nil {
+		log.WithError(err).WithField("dir", dir).Warn("Failed to list subdirectories")
+	}
+	for _, sub := range dirs {
+		if sub == matcher.Root() {
+			continue
+		}
+		if err := t.watchDir(matcher, sub); err != nil {
+			t.metrics.IncrementErrors()
+			log.WithError(err).WithField("dir", sub).Warn("Failed to watch directory")
+		}
+	}
+}
+
+// watchDir watches a directory in the watch mode of its source. A source in
+// auto mode is polled when fsnotify cannot watch the directory or its file
+// system does not deliver events, such as NFS or FUSE mounts.
+func (t *Tracker) watchDir(matcher *source.Matcher, dir string) error {
+	requested := t.cfg.SourceWatchMode(matcher.Source())
+	mode, err := t.watcher.Add(dir, requested)
+	if err != nil {
+		return err
+	}
+
+	fields := log.Fields{"dir": dir, "watch": mode}
+	if requested == watcher.ModeAuto && mode == watcher.ModePoll {
+		log.WithFields(fields).Info("File system events unavailable, polling directory")
+	} else {
+		log.WithFields(fields).Debug("Watching directory")
+	}
+	return nil
+}
+
+// sourceForFile returns the source whose patterns match a file, or nil
+func (t *Tracker) sourceForFile(path string) *source.Matcher {
+	for _, matcher := range t.sources {
+		if matcher.Match(path) {
+			return matcher
+		}
+	}
+	return nil
+}
+
+// handleNewDirectory watches a directory created below a source and follows
+// the matching files that were created in it before the watch was added
+func (t *Tracker) handleNewDirectory(dir string) {
+	for _, matcher := range t.sources {
+		if !matcher.Contains(dir) || matcher.SkipDir(dir) {
+			continue
+		}
+
+		log.WithField("dir", dir).Debug("New log directory detected")
+		if t.watcher != nil {
+			if err := t.watchDir(matcher, dir); err != nil {
+				t.metrics.IncrementErrors()
+				log.WithError(err).WithField("dir", dir).Warn("Failed to watch directory")
+			}
+		}
+		t.watchSubdirectories(matcher, dir)
+
+		matcher.Walk(dir, func(path string) {
+			t.handleFileEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+		})
+		return
+	}
+}
+
+// handleFileEvent processes file system events
+func (t *Tracker) handleFileEvent(event fsnotify.Event) {
+	if event.Op&fsnotify.Create == fsnotify.Create {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			t.handleNewDirectory(event.Name)
+			return
+		}
+	}
+
+	if t.sourceForFile(event.Name) == nil {
+		return
+	}
+
+	switch {
+	case event.Op&fsnotify.Create == fsnotify.Create:
+		log.WithField("file", event.Name).Debug("New log file detected")
+		t.mu.RLock()
+		_, exists := t.fileHandles[event.Name]
+		t.mu.RUnlock()
+		if !exists {
+			// A file created while running is read from the beginning
+			t.followLogFile(event.Name, false)
+		}
+		t.processLogUpdates(event.Name)
+	case event.Op&fsnotify.Write == fsnotify.Write:
+		t.processLogUpdates(event.Name)
+	case event.Op&fsnotify.Rename == fsnotify.Rename:
+		// Drain what was written before the rename; the handle is kept until
+		// a new file appears at the path
+		log.WithField("file", event.Name).Debug("Log file renamed")
+		t.processLogUpdates(event.Name)
+	case event.Op&fsnotify.Remove == fsnotify.Remove:
+		log.WithField("file", event.Name).Debug("Log file removed")
+		// Keep the handle if the poll already switched to a recreated file
+		if t.processLogUpdates(event.Name) != tailer.Rotated {
+			t.closeFileHandle(event.Name)
+			if t.checkpoints != nil {
+				t.pipeline.After(event.Name, func() {
+					t.checkpoints.Delete(event.Name)
+				})
+			}
+		}
+	}
+}
+
+// monitorLogFile starts monitoring a specific log file from its current end
+func (t *Tracker) monitorLogFile(filePath string) {
+	t.followLogFile(filePath, true)
+}
+
+// followLogFile opens a log file for following, starting at its end or beginning
+func (t *Tracker) followLogFile(filePath string, fromEnd bool) {
+	follower, err := tailer.Open(filePath, fromEnd)
+	if err != nil {
+		t.metrics.IncrementErrors()
+		log.WithError(err).WithField("file", filePath).Warn("Failed to open log file")
+		return
+	}
+
+	t.addFollower(filePath, follower)
+}
+
+// addFollower registers an opened file and records its starting position
+func (t *Tracker) addFollower(filePath string, follower *tailer.Follower) {
+	follower.SetLineLimit(t.cfg.MaxLineSize, t.cfg.OversizedLinePolicy)
+	follower.SetPartialLineTimeout(t.cfg.PartialLineTimeout)
+
+	t.mu.Lock()
+	if previous, exists := t.fileHandles[filePath]; exists {
+		previous.Close()
+	}
+	t.fileHandles[filePath] = follower
+	if _, exists := t.fileStates[filePath]; !exists {
+		t.fileStates[filePath] = &fileState{
+			source:     t.sourceName(filePath),
+			decoder:    t.newDecoder(filePath),
+			timestamps: t.timestampParser(filePath),
+			multiline:  t.newAggregator(filePath),
+		}
+	}
+	t.mu.Unlock()
+
+	t.saveCheckpoint(follower)
+
+	log.WithField("file", filePath).Debug("Started monitoring log file")
+}
+
+// processLogUpdates processes new log entries, following the file across rotation
+func (t *Tracker) processLogUpdates(filePath string) tailer.Change {
+	t.mu.RLock()
+	follower, exists := t.fileHandles[filePath]
+	t.mu.RUnlock()
+	if !exists {
+		if _, err := os.Stat(filePath); err != nil {
+			return tailer.NoChange
+		}
+		t.monitorLogFile(filePath)
+		t.mu.RLock()
+		follower = t.fileHandles[filePath]
+		t.mu.RUnlock()
+		if follower == nil {
+			return tailer.NoChange
+		}
+	}
+
+	t.mu.RLock()
+	state := t.fileStates[filePath]
+	t.mu.RUnlock()
+
+	change, err := follower.Poll(t.rawLineHandler(follower, filePath, state))
+	if err != nil {
+		t.metrics.IncrementErrors()
+		log.WithError(err).WithField("file", filePath).Warn("Failed to read log file")
+	}
+	truncated, skipped := follower.TakeOversized()
+	if limiter, ok := state.decoder.(decoder.LineLimiter); ok {
+		// Lines the runtime split are limited once they are stitched
+		stitchedTruncated, stitchedSkipped := limiter.TakeOversized()
+		truncated, skipped = truncated+stitchedTruncated, skipped+stitchedSkipped
+	}
+	if truncated > 0 || skipped > 0 {
+		t.metrics.AddOversized(truncated, skipped)
+		log.WithFields(log.Fields{
+			"file":          filePath,
+			"truncated":     truncated,
+			"skipped":       skipped,
+			"max_line_size": t.cfg.MaxLineSize,
+		}).Warn("Log lines exceeded the maximum line size")
+	}
+	if change != tailer.NoChange {
+		log.WithFields(log.Fields{
+			"file":   filePath,
+			"change": change.String(),
+		}).Info("Log file rotated, reading from the beginning")
+	}
+
+	t.saveCheckpoint(follower)
+
+	return change
+}
+
+// rawLineHandler returns the function that decodes and processes the raw lines
+// read from a file by follower
+func (t *Tracker) rawLineHandler(follower *tailer.Follower, filePath string, state *fileState) func(raw string) {
+	return func(raw string) {
+		if !state.splitLine {
+			state.lineOffset = follower.LineOffset()
+			state.lineNumber = follower.LineNumber()
+		}
+		line, ok, err := state.decoder.Decode(raw)
+		if err != nil {
+			t.metrics.IncrementErrors()
+			log.WithError(err).WithField("file", filePath).Debug("Failed to decode log line")
+		}
+		state.splitLine = !ok
+		if limiter, stitches := state.decoder.(decoder.LineLimiter); stitches && !ok {
+			// Nothing is left to continue after a skipped oversized line
+			state.splitLine = limiter.Stitching()
+		}
+		if ok {
+			line.Offset = state.lineOffset
+			line.LineNumber = state.lineNumber
+			line.Source = state.source
+			t.processFileLine(state, line, filePath)
+		}
+
+		t.mu.Lock()
+		state.linesProcessed++
+		t.mu.Unlock()
+	}
+}
+
+// pollPartialLines polls the files holding back an incomplete final line, so
+// it is processed once the file has been idle for the partial line timeout
+// even if no further write event arrives
+func (t *Tracker) pollPartialLines() {
+	t.mu.RLock()
+	var paths []string
+	for filePath, follower := range t.fileHandles {
+		if follower.HasPartial() {
+			paths = append(paths, filePath)
+		}
+	}
+	t.mu.RUnlock()
+
+	for _, filePath := range paths {
+		t.processLogUpdates(filePath)
+	}
+}
+
+// saveCheckpoint stages the read position of a file once the lines read so
+// far have reached the sinks. It is committed when the sinks have written
+// them out, so a restart never skips lines that were still queued.
+func (t *Tracker) saveCheckpoint(follower *tailer.Follower) {
+	if t.checkpoints == nil {
+		return
+	}
+
+	pos, err := follower.Position()
+	if err != nil {
+		log.WithError(err).WithField("file", follower.Path()).Warn("Failed to record checkpoint")
+		return
+	}
+	path := follower.Path()
+	t.pipeline.After(path, func() {
+		t.checkpoints.Stage(path, pos)
+	})
+}
+
+// flushCheckpoints waits for the sinks to write out the records queued so
+// far, then persists the read positions of their lines to the state directory
+func (t *Tracker) flushCheckpoints() {
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointSyncTimeout)
+	defer cancel()
+
+	if err := t.checkpoints.Sync(ctx, t.outputs.Flush); err != nil {
+		t.metrics.IncrementErrors()
+		log.WithError(err).Warn("Failed to flush checkpoints")
+		return
+	}
+	log.WithField("path", t.checkpoints.Path()).Debug("Flushed checkpoints")
+}
+
+// newDecoder creates the decoder for a file according to the format of its source
+func (t *Tracker) newDecoder(filePath string) decoder.Decoder {
+	var format string
+	if matcher := t.sourceForFile(filePath); matcher != nil {
+		format = matcher.Source().Format
+	}
+
+	// Formats are checked when the configuration is validated
+	d, err := decoder.New(format, filePath)
+	if err != nil {
+		d, _ = decoder.New(decoder.FormatRaw, filePath)
+	}
+	if limiter, ok := d.(decoder.LineLimiter); ok {
+		limiter.SetLineLimit(t.cfg.MaxLineSize, t.cfg.OversizedLinePolicy == tailer.SkipLines)
+	}
+	return d
+}
+
+// sourceName returns the name of a file's source, or "" for files outside
+// every source
+func (t *Tracker) sourceName(filePath string) string {
+	if matcher := t.sourceForFile(filePath); matcher != nil {
+		return matcher.Source().Name
+	}
+	return ""
+}
+
+// newAggregator creates the multiline aggregator for a file, or nil if its
+// source has no multiline rule
+func (t *Tracker) newAggregator(filePath string) *multiline.Aggregator {
+	matcher := t.sourceForFile(filePath)
+	if matcher == nil || matcher.Source().Multiline == nil {
+		return nil
+	}
+
+	// Rules are checked when the configuration is validated
+	aggregator, err := multiline.New(*matcher.Source().Multiline)
+	if err != nil {
+		log.WithError(err).WithField("file", filePath).Warn("Invalid multiline rule, processing lines individually")
+		return nil
+	}
+	return aggregator
+}
+
+// processFileLine processes a decoded line of a file, first grouping it into
+// an event when the file's source has a multiline rule
+func (t *Tracker) processFileLine(state *fileState, line decoder.Line, filePath string) {
+	if state.multiline == nil {
+		t.processDecodedLine(line, filePath, state.timestamps)
+		return
+	}
+
+	for _, event := range state.multiline.Add(line, time.Now()) {
+		t.processDecodedLine(event, filePath, state.timestamps)
+	}
+}
+
+// flushExpiredEvents processes multiline events that received no line for
+// their flush timeout
+func (t *Tracker) flushExpiredEvents(now time.Time) {
+	t.mu.RLock()
+	paths := make([]string, 0, len(t.fileStates))
+	states := make([]*fileState, 0, len(t.fileStates))
+	for filePath, state := range t.fileStates {
+		if state.multiline != nil {
+			paths = append(paths, filePath)
+			states = append(states, state)
+		}
+	}
+	t.mu.RUnlock()
+
+	for i, state := range states {
+		if event, ok := state.multiline.FlushExpired(now); ok {
+			t.processDecodedLine(event, paths[i], state.timestamps)
+		}
+	}
+}
+
+// timestampParser returns the timestamp parser of a file's source, or the
+// default parser for files outside every source
+func (t *Tracker) timestampParser(filePath string) *timestamp.Parser {
+	if parser, ok := t.timestamps[t.sourceForFile(filePath)]; ok {
+		return parser
+	}
+	return timestamp.Default()
+}
+
+// processLogLine extracts CIDs from a raw log line
+func (t *Tracker) processLogLine(line, filePath string) {
+	t.processDecodedLine(decoder.Line{Message: line}, filePath, t.timestampParser(filePath))
+}
+
+// processDecodedLine submits a decoded line to the pipeline
+func (t *Tracker) processDecodedLine(line decoder.Line, filePath string, parser *timestamp.Parser) {
+	t.pipeline.Submit(line, filePath, parser)
+}
+
+// closeFileHandle closes a file handle, processing any incomplete final line
+// and pending multiline event
+func (t *Tracker) closeFileHandle(filePath string) {
+	t.mu.RLock()
+	follower := t.fileHandles[filePath]
+	state := t.fileStates[filePath]
+	t.mu.RUnlock()
+
+	if follower != nil && state != nil {
+		follower.Flush(t.rawLineHandler(follower, filePath, state))
+	}
+
+	t.mu.Lock()
+	if file, exists := t.fileHandles[filePath]; exists {
+		file.Close()
+		delete(t.fileHandles, filePath)
+		delete(t.fileStates, filePath)
+	}
+	t.mu.Unlock()
+
+	if state != nil && state.multiline != nil {
+		if event, ok := state.multiline.Flush(); ok {
+			t.processDecodedLine(event, filePath, state.timestamps)
+		}
+	}
+}
+
+// cleanup closes all file handles
+func (t *Tracker) cleanup() {
+	t.mu.RLock()
+	paths := make([]string, 0, len(t.fileHandles))
+	for filePath := range t.fileHandles {
+		paths = append(paths, filePath)
+	}
+	t.mu.RUnlock()
+
+	for _, filePath := range paths {
+		t.closeFileHandle(filePath)
+	}
+}
+
+// Healthy reports whether the tracker is actively monitoring
+func (t *Tracker) Healthy() bool {
+	return t.running.Load()
+}
+
+// Statistics returns the processing counters for the status endpoint
+func (t *Tracker) Statistics() server.Statistics {
+	processed, extracted, _, _, errors := t.metrics.GetStats()
+	stats := server.Statistics{
+		LogsProcessed: processed,
+		CIDsExtracted: extracted,
+		Errors:        errors,
+	}
+	if last := t.metrics.LastProcessedAt(); !last.IsZero() {
+		last = last.UTC()
+		stats.LastProcessed = &last
+	}
+	return stats
+}
+
+// Configuration returns the effective configuration for the status endpoints
+func (t *Tracker) Configuration() server.Configuration {
+	var cidPattern string
+	var names []string
+	for _, pattern := range t.extractor.Patterns() {
+		if cidPattern == "" {
+			cidPattern = pattern.Regex.String()
+		}
+		names = append(names, pattern.Name)
+	}
+
+	return server.Configuration{
+		LogDirectory:      t.logPath,
+		OutputFormat:      t.outputFormat,
+		BufferSize:        t.cfg.BufferSize,
+		PollInterval:      t.cfg.WatchInterval.String(),
+		CIDPattern:        cidPattern,
+		CIDPatterns:       names,
+		OutputDestination: strings.Join(t.outputs.Names(), ", "),
+	}
+}
+
+// Sinks returns the counters of every sink for the status endpoints
+func (t *Tracker) Sinks() []server.SinkStatus {
+	stats := t.outputs.Stats()
+	sinks := make([]server.SinkStatus, 0, len(stats))
+	for _, s := range stats {
+		sinks = append(sinks, server.SinkStatus{
+			Name:    s.Name,
+			Type:    s.Type,
+			Written: s.Written,
+			Errors:  s.Errors,
+			Dropped: s.Dropped,
+			Queued:  s.Queued,
+		})
+	}
+	return sinks
+}
+
+// Metrics returns the processing counters backing the /metrics endpoint
+func (t *Tracker) Metrics() *processor.Metrics {
+	return t.metrics
+}
+
+// MonitoredFiles returns the files currently being tailed
+func (t *Tracker) MonitoredFiles() []server.FileStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	files := make([]server.FileStatus, 0, len(t.fileHandles))
+	for filePath := range t.fileHandles {
+		status := server.FileStatus{Path: filePath}
+		if info, err := os.Stat(filePath); err == nil {
+			status.Size = info.Size()
+			status.LastModified = info.ModTime().UTC()
+		}
+		if state, ok := t.fileStates[filePath]; ok {
+			status.LinesProcessed = state.linesProcessed
+		}
+		files = append(files, status)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files
+}
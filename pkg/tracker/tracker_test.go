@@ -0,0 +1,2130 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cidtracker/pkg/config"
+	"cidtracker/pkg/metrics"
+	"cidtracker/pkg/models"
+	"cidtracker/pkg/sink"
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestNewForDir(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	if tracker == nil {
+		t.Fatal("NewForDir() returned nil")
+	}
+
+	if tracker.logPath != "/var/log" {
+		t.Errorf("logPath = %v, want /var/log", tracker.logPath)
+	}
+
+	if tracker.outputFormat != "json" {
+		t.Errorf("outputFormat = %v, want json", tracker.outputFormat)
+	}
+
+	if tracker.extractor == nil || len(tracker.extractor.Patterns()) == 0 {
+		t.Error("extractor should have patterns")
+	}
+
+	if tracker.pipeline == nil {
+		t.Error("pipeline should not be nil")
+	}
+
+	if tracker.fileHandles == nil {
+		t.Error("fileHandles should not be nil")
+	}
+}
+
+func TestNewForDir_StructuredFormat(t *testing.T) {
+	tracker := NewForDir("/var/log", "structured")
+
+	if tracker.outputFormat != "structured" {
+		t.Errorf("outputFormat = %v, want structured", tracker.outputFormat)
+	}
+}
+
+func TestNewForDir_InvalidFormatPanics(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil || !strings.Contains(fmt.Sprint(r), "bogus") {
+			t.Errorf("NewForDir() panic = %v, want the invalid format named", r)
+		}
+	}()
+	NewForDir("/var/log", "bogus")
+}
+
+func TestTracker_CIDPattern(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	tests := []struct {
+		name        string
+		input       string
+		wantCID     string
+		wantPattern string
+		found       bool
+	}{
+		{
+			name:        "standard CID",
+			input:       "CID:550e8400-e29b-51d4-a716-446655440000",
+			wantCID:     "550e8400-e29b-51d4-a716-446655440000",
+			wantPattern: "standard_cid",
+			found:       true,
+		},
+		{
+			name:        "CID in log line",
+			input:       "INFO processing request CID:550e8400-e29b-51d4-a716-446655440001 complete",
+			wantCID:     "550e8400-e29b-51d4-a716-446655440001",
+			wantPattern: "standard_cid",
+			found:       true,
+		},
+		{
+			name:        "CID with equals",
+			input:       "INFO CID=550e8400-e29b-51d4-a716-446655440002 done",
+			wantCID:     "550e8400-e29b-51d4-a716-446655440002",
+			wantPattern: "standard_cid",
+			found:       true,
+		},
+		{
+			name:        "lowercase cid",
+			input:       "INFO cid: 550e8400-e29b-51d4-a716-446655440003 done",
+			wantCID:     "550e8400-e29b-51d4-a716-446655440003",
+			wantPattern: "standard_cid",
+			found:       true,
+		},
+		{
+			name:        "JSON cid field",
+			input:       `{"level":"info","cid":"550e8400-e29b-51d4-a716-446655440004"}`,
+			wantCID:     "550e8400-e29b-51d4-a716-446655440004",
+			wantPattern: "json_cid",
+			found:       true,
+		},
+		{
+			name:        "bracketed CID",
+			input:       "INFO [CID 550e8400-e29b-51d4-a716-446655440005] done",
+			wantCID:     "550e8400-e29b-51d4-a716-446655440005",
+			wantPattern: "standard_cid",
+			found:       true,
+		},
+		{
+			name:  "no CID",
+			input: "regular log line without cid",
+			found: false,
+		},
+		{
+			name:  "empty line",
+			input: "",
+			found: false,
+		},
+		{
+			name:  "short CID - not matched",
+			input: "CID:abc-def-123",
+			found: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := tracker.extractor.FindMatches(tt.input)
+			if tt.found {
+				if len(matches) == 0 {
+					t.Error("expected to find CID but found none")
+					return
+				}
+				if matches[0].Value != tt.wantCID {
+					t.Errorf("ExtractedCID = %v, want %v", matches[0].Value, tt.wantCID)
+				}
+				if matches[0].Pattern != tt.wantPattern {
+					t.Errorf("Pattern = %v, want %v", matches[0].Pattern, tt.wantPattern)
+				}
+			} else {
+				if len(matches) > 0 {
+					t.Errorf("expected no CID but found: %v", matches)
+				}
+			}
+		})
+	}
+}
+
+func TestTracker_ProcessLogLine(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	// Capture stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	tracker.processLogLine("CID:550e8400-e29b-51d4-a716-446655440000 test message", "/var/log/test.log")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	if output == "" {
+		t.Error("expected output for valid CID")
+	}
+
+	// Parse the JSON output
+	var entry sink.Record
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &entry); err != nil {
+		t.Fatalf("failed to parse JSON output: %v", err)
+	}
+
+	if entry.ExtractedCID != "550e8400-e29b-51d4-a716-446655440000" {
+		t.Errorf("ExtractedCID = %v, want 550e8400-e29b-51d4-a716-446655440000", entry.ExtractedCID)
+	}
+}
+
+func TestTracker_ProcessLogLine_InvalidUUID(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	// Capture stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// Invalid UUID format
+	tracker.processLogLine("CID:not-a-valid-uuid test message", "/var/log/test.log")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	// Should not output anything for invalid UUID
+	if output != "" {
+		t.Errorf("expected no output for invalid UUID, got: %v", output)
+	}
+}
+
+func TestTracker_ProcessLogLine_StructuredFormat(t *testing.T) {
+	tracker := NewForDir("/var/log", "structured")
+
+	// Capture stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	tracker.processLogLine("CID:550e8400-e29b-51d4-a716-446655440000 test", "/var/log/test.log")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	if !strings.Contains(output, "CID:550e8400-e29b-51d4-a716-446655440000") {
+		t.Errorf("expected structured output to contain CID, got: %v", output)
+	}
+
+	if !strings.Contains(output, "FILE:test.log") {
+		t.Errorf("expected structured output to contain FILE, got: %v", output)
+	}
+}
+
+func TestTracker_ProcessExistingFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Create a log file
+	logFile := filepath.Join(tmpDir, "test.log")
+	if err := os.WriteFile(logFile, []byte("initial content\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	tracker := NewForDir(tmpDir, "json")
+
+	err := tracker.processExistingFiles()
+	if err != nil {
+		t.Fatalf("processExistingFiles() error = %v", err)
+	}
+
+	// Check that the file is being monitored
+	if _, exists := tracker.fileHandles[logFile]; !exists {
+		t.Error("expected log file to be monitored")
+	}
+}
+
+func TestTracker_ProcessExistingFiles_NoLogFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Create a non-log file
+	txtFile := filepath.Join(tmpDir, "test.txt")
+	if err := os.WriteFile(txtFile, []byte("not a log\n"), 0644); err != nil {
+		t.Fatalf("failed to create txt file: %v", err)
+	}
+
+	tracker := NewForDir(tmpDir, "json")
+
+	err := tracker.processExistingFiles()
+	if err != nil {
+		t.Fatalf("processExistingFiles() error = %v", err)
+	}
+
+	// Should not monitor txt file
+	if len(tracker.fileHandles) != 0 {
+		t.Errorf("expected 0 file handles, got %d", len(tracker.fileHandles))
+	}
+}
+
+func TestTracker_MonitorLogFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "test.log")
+
+	if err := os.WriteFile(logFile, []byte("initial\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	tracker := NewForDir(tmpDir, "json")
+	tracker.monitorLogFile(logFile)
+
+	if _, exists := tracker.fileHandles[logFile]; !exists {
+		t.Error("expected file to be in fileHandles")
+	}
+
+	tracker.cleanup()
+}
+
+func TestTracker_MonitorLogFile_NonExistent(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+	tracker.monitorLogFile("/nonexistent/file.log")
+
+	if len(tracker.fileHandles) != 0 {
+		t.Error("expected no file handles for non-existent file")
+	}
+}
+
+func TestTracker_CloseFileHandle(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "test.log")
+
+	if err := os.WriteFile(logFile, []byte("initial\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	tracker := NewForDir(tmpDir, "json")
+	tracker.monitorLogFile(logFile)
+
+	if _, exists := tracker.fileHandles[logFile]; !exists {
+		t.Fatal("expected file to be in fileHandles")
+	}
+
+	tracker.closeFileHandle(logFile)
+
+	if _, exists := tracker.fileHandles[logFile]; exists {
+		t.Error("expected file to be removed from fileHandles")
+	}
+}
+
+func TestTracker_Cleanup(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Create multiple log files
+	for i := 0; i < 3; i++ {
+		logFile := filepath.Join(tmpDir, "test"+string(rune('0'+i))+".log")
+		if err := os.WriteFile(logFile, []byte("content\n"), 0644); err != nil {
+			t.Fatalf("failed to create log file: %v", err)
+		}
+	}
+
+	tracker := NewForDir(tmpDir, "json")
+	tracker.processExistingFiles()
+
+	if len(tracker.fileHandles) != 3 {
+		t.Fatalf("expected 3 file handles, got %d", len(tracker.fileHandles))
+	}
+
+	tracker.cleanup()
+
+	if len(tracker.fileHandles) != 0 {
+		t.Errorf("expected 0 file handles after cleanup, got %d", len(tracker.fileHandles))
+	}
+}
+
+func TestTracker_Start_InvalidPath(t *testing.T) {
+	tracker := NewForDir("/nonexistent/path", "json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := tracker.Start(ctx)
+	if err == nil {
+		t.Error("expected error for invalid path")
+	}
+}
+
+func TestTracker_Start_ContextCancellation(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tracker := NewForDir(tmpDir, "json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- tracker.Start(ctx)
+	}()
+
+	// Give it time to start
+	time.Sleep(100 * time.Millisecond)
+
+	// Cancel the context
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("timeout waiting for tracker to stop")
+	}
+}
+
+func TestTracker_HandleFileEvent_Create(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+
+	// Simulate file creation event
+	logFile := filepath.Join(tmpDir, "new.log")
+	if err := os.WriteFile(logFile, []byte("content\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	// This would normally be called by the watcher
+	tracker.monitorLogFile(logFile)
+
+	if _, exists := tracker.fileHandles[logFile]; !exists {
+		t.Error("expected new file to be monitored")
+	}
+
+	tracker.cleanup()
+}
+
+func TestTracker_MultipleCIDsInLine(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	// Capture stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// Multiple CIDs in one line - only first should be captured with current pattern
+	tracker.processLogLine("CID:550e8400-e29b-51d4-a716-446655440000 CID:660e8400-e29b-51d4-a716-446655440001", "/var/log/test.log")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 1 {
+		t.Error("expected at least 1 output line")
+	}
+}
+
+func TestTracker_HandleFileEvent_NonLogFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+
+	// Create a non-log file
+	txtFile := filepath.Join(tmpDir, "test.txt")
+	if err := os.WriteFile(txtFile, []byte("content\n"), 0644); err != nil {
+		t.Fatalf("failed to create txt file: %v", err)
+	}
+
+	// Simulate events for non-log files - should be ignored
+	event := fsnotify.Event{Name: txtFile, Op: fsnotify.Create}
+	tracker.handleFileEvent(event)
+
+	if len(tracker.fileHandles) != 0 {
+		t.Error("expected no file handles for non-log file")
+	}
+}
+
+func TestTracker_HandleFileEvent_LogFileCreate(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+
+	// Create a log file
+	logFile := filepath.Join(tmpDir, "test.log")
+	if err := os.WriteFile(logFile, []byte("content\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	// Simulate create event
+	event := fsnotify.Event{Name: logFile, Op: fsnotify.Create}
+	tracker.handleFileEvent(event)
+
+	if _, exists := tracker.fileHandles[logFile]; !exists {
+		t.Error("expected log file to be monitored after create event")
+	}
+
+	tracker.cleanup()
+}
+
+func TestTracker_HandleFileEvent_LogFileRemove(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+
+	// Create and monitor a log file
+	logFile := filepath.Join(tmpDir, "test.log")
+	if err := os.WriteFile(logFile, []byte("content\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	tracker.monitorLogFile(logFile)
+
+	if _, exists := tracker.fileHandles[logFile]; !exists {
+		t.Fatal("expected log file to be monitored")
+	}
+
+	// Simulate remove event
+	event := fsnotify.Event{Name: logFile, Op: fsnotify.Remove}
+	tracker.handleFileEvent(event)
+
+	if _, exists := tracker.fileHandles[logFile]; exists {
+		t.Error("expected log file handle to be closed after remove event")
+	}
+}
+
+func TestTracker_HandleFileEvent_LogFileWrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+
+	// Create a log file but don't monitor it yet
+	logFile := filepath.Join(tmpDir, "test.log")
+	if err := os.WriteFile(logFile, []byte("content\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	// Simulate write event - should start monitoring
+	event := fsnotify.Event{Name: logFile, Op: fsnotify.Write}
+	tracker.handleFileEvent(event)
+
+	// Write event triggers processLogUpdates which may open the file
+	// This tests the write handling path
+	tracker.cleanup()
+}
+
+func TestTracker_ProcessLogUpdates_ExistingFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+
+	logFile := filepath.Join(tmpDir, "test.log")
+	if err := os.WriteFile(logFile, []byte("initial\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	// Monitor the file first
+	tracker.monitorLogFile(logFile)
+
+	// Append a line with CID
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	f.WriteString("CID:550e8400-e29b-51d4-a716-446655440000 test\n")
+	f.Close()
+
+	// Capture stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	tracker.processLogUpdates(logFile)
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	if !strings.Contains(output, "550e8400-e29b-51d4-a716-446655440000") {
+		t.Errorf("expected output to contain CID, got: %v", output)
+	}
+
+	tracker.cleanup()
+}
+
+func TestTracker_ProcessLogUpdates_NewFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+
+	logFile := filepath.Join(tmpDir, "test.log")
+	if err := os.WriteFile(logFile, []byte("CID:550e8400-e29b-51d4-a716-446655440000 initial\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	// Don't monitor - processLogUpdates should start monitoring
+	tracker.processLogUpdates(logFile)
+
+	if _, exists := tracker.fileHandles[logFile]; !exists {
+		t.Error("expected file to be monitored after processLogUpdates")
+	}
+
+	tracker.cleanup()
+}
+
+func TestTracker_ProcessLogLine_NoCID(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	// Capture stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	tracker.processLogLine("regular log line without cid", "/var/log/test.log")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	if output != "" {
+		t.Errorf("expected no output for line without CID, got: %v", output)
+	}
+}
+
+func TestTracker_NestedDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	subDir := filepath.Join(tmpDir, "subdir")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("failed to create subdirectory: %v", err)
+	}
+
+	// Create log files in subdirectory
+	logFile := filepath.Join(subDir, "test.log")
+	if err := os.WriteFile(logFile, []byte("content\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	tracker := NewForDir(tmpDir, "json")
+	err := tracker.processExistingFiles()
+	if err != nil {
+		t.Fatalf("processExistingFiles() error = %v", err)
+	}
+
+	// Should find the nested log file
+	if _, exists := tracker.fileHandles[logFile]; !exists {
+		t.Error("expected nested log file to be monitored")
+	}
+
+	tracker.cleanup()
+}
+
+func TestTracker_FileHandlesMapInitialized(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	if tracker.fileHandles == nil {
+		t.Error("fileHandles should be initialized")
+	}
+
+	if len(tracker.fileHandles) != 0 {
+		t.Error("fileHandles should be empty initially")
+	}
+}
+
+func TestTracker_CloseFileHandle_NonExistent(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	// Should not panic for non-existent file
+	tracker.closeFileHandle("/nonexistent/file.log")
+
+	if len(tracker.fileHandles) != 0 {
+		t.Error("fileHandles should be empty")
+	}
+}
+
+func TestTracker_Statistics(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	// Capture stdout
+	old := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	tracker.processLogLine("CID:550e8400-e29b-51d4-a716-446655440000 valid", "/var/log/test.log")
+	tracker.processLogLine("no cid here", "/var/log/test.log")
+
+	w.Close()
+	os.Stdout = old
+
+	stats := tracker.Statistics()
+	if stats.LogsProcessed != 2 {
+		t.Errorf("LogsProcessed = %d, want 2", stats.LogsProcessed)
+	}
+	if stats.CIDsExtracted != 1 {
+		t.Errorf("CIDsExtracted = %d, want 1", stats.CIDsExtracted)
+	}
+	if stats.LastProcessed == nil {
+		t.Error("LastProcessed should be set")
+	}
+}
+
+func TestTracker_MonitoredFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "test.log")
+
+	if err := os.WriteFile(logFile, []byte("initial\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	tracker := NewForDir(tmpDir, "json")
+	tracker.monitorLogFile(logFile)
+	defer tracker.cleanup()
+
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	f.WriteString("line one\nline two\n")
+	f.Close()
+
+	tracker.processLogUpdates(logFile)
+
+	files := tracker.MonitoredFiles()
+	if len(files) != 1 {
+		t.Fatalf("MonitoredFiles length = %d, want 1", len(files))
+	}
+	if files[0].Path != logFile {
+		t.Errorf("Path = %v, want %v", files[0].Path, logFile)
+	}
+	if files[0].Size != int64(len("initial\nline one\nline two\n")) {
+		t.Errorf("Size = %d, want %d", files[0].Size, len("initial\nline one\nline two\n"))
+	}
+	if files[0].LinesProcessed != 2 {
+		t.Errorf("LinesProcessed = %d, want 2", files[0].LinesProcessed)
+	}
+	if files[0].LastModified.IsZero() {
+		t.Error("LastModified should not be zero")
+	}
+}
+
+func TestTracker_Start_HTTPServer(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	tracker := NewForDir(tmpDir, "json")
+	tracker.EnableHTTPServer(addr, "test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- tracker.Start(ctx)
+	}()
+
+	var resp *http.Response
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err = http.Get("http://" + addr + "/health")
+		if err == nil && resp.StatusCode == http.StatusOK {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("health request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("health status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for tracker to stop")
+	}
+
+	if tracker.Healthy() {
+		t.Error("tracker should not be healthy after stopping")
+	}
+
+	if _, err := http.Get("http://" + addr + "/health"); err == nil {
+		t.Error("expected HTTP server to be shut down")
+	}
+}
+
+func TestTracker_Start_HTTPServerDuringBackfill(t *testing.T) {
+	logDir := t.TempDir()
+	var content strings.Builder
+	for i := 0; i < 50; i++ {
+		content.WriteString("CID:" + testCID(i) + " backfill\n")
+	}
+	if err := os.WriteFile(filepath.Join(logDir, "app.log"), []byte(content.String()), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.SetLogDir(logDir)
+	cfg.StartFrom = config.StartFromBeginning
+	cfg.BufferSize = 1
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	tracker.EnableHTTPServer(addr, "test")
+
+	// The sink holds every record, so reading the existing file blocks on
+	// the full queues until the gate opens
+	output := &gatedSink{gate: make(chan struct{})}
+	tracker.SetSink(output)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- tracker.Start(ctx)
+	}()
+
+	var resp *http.Response
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err = http.Get("http://" + addr + "/health")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("health request during the backfill failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("health status during the backfill = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	close(output.gate)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for tracker to stop")
+	}
+}
+
+func TestTracker_Start_HTTPServerBindFailure(t *testing.T) {
+	logDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(logDir, "app.log"), []byte("CID:"+testCID(1)+" existing\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	// Keep the port bound so the tracker cannot listen on it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	defer listener.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.SetLogDir(logDir)
+	cfg.StartFrom = config.StartFromBeginning
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	tracker.EnableHTTPServer(listener.Addr().String(), "test")
+
+	output := &recordingSink{}
+	tracker.SetSink(output)
+
+	if err := tracker.Start(context.Background()); err == nil {
+		t.Fatal("Start() error = nil with the HTTP port in use")
+	}
+	if len(output.records) != 0 {
+		t.Errorf("sink received %d records before the HTTP server failed, want 0", len(output.records))
+	}
+}
+
+func TestTracker_MetricsFedByProcessLogLine(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	// Capture stdout
+	old := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	tracker.processLogLine("CID:550e8400-e29b-51d4-a716-446655440000 valid", "/var/log/test.log")
+	tracker.processLogLine("regular line without cid", "/var/log/test.log")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	tw := metrics.NewTextWriter(&buf)
+	tracker.Metrics().WritePrometheus(tw)
+	output := buf.String()
+
+	for _, want := range []string{
+		"cidtracker_logs_processed_total 2",
+		"cidtracker_processing_duration_seconds_count 2",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.LogSources = []models.LogSource{
+		{Path: "/var/log/a", Active: true},
+		{Path: "/var/log/b", Active: false},
+		{Path: "/var/log/c", Active: true},
+	}
+	cfg.SetCIDPattern(`REQ=([a-fA-F0-9-]{36})`)
+	cfg.EnableU5Only = false
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if len(tracker.logPaths) != 2 {
+		t.Fatalf("logPaths length = %d, want 2", len(tracker.logPaths))
+	}
+	if tracker.logPath != "/var/log/a" {
+		t.Errorf("logPath = %v, want /var/log/a", tracker.logPath)
+	}
+
+	// Capture stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// Version 4 UUID is accepted when U5 enforcement is disabled
+	tracker.processLogLine("REQ=550e8400-e29b-41d4-a716-446655440000 done", "/var/log/a/test.log")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+
+	if !strings.Contains(buf.String(), "550e8400-e29b-41d4-a716-446655440000") {
+		t.Errorf("expected output for configured pattern, got: %v", buf.String())
+	}
+}
+
+func TestTracker_EnforcesU5ByDefault(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	// Capture stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	tracker.processLogLine("CID:550e8400-e29b-41d4-a716-446655440000 v4", "/var/log/test.log")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+
+	if buf.String() != "" {
+		t.Errorf("expected no output for version 4 UUID, got: %v", buf.String())
+	}
+}
+
+func TestTracker_Configuration(t *testing.T) {
+	tracker := NewForDir("/var/log/app", "structured")
+
+	cfg := tracker.Configuration()
+	if cfg.LogDirectory != "/var/log/app" {
+		t.Errorf("LogDirectory = %v, want /var/log/app", cfg.LogDirectory)
+	}
+	if cfg.OutputFormat != "structured" {
+		t.Errorf("OutputFormat = %v, want structured", cfg.OutputFormat)
+	}
+	if cfg.BufferSize != 1000 {
+		t.Errorf("BufferSize = %d, want 1000", cfg.BufferSize)
+	}
+	if cfg.PollInterval != "100ms" {
+		t.Errorf("PollInterval = %v, want 100ms", cfg.PollInterval)
+	}
+}
+
+func TestNew_InvalidPattern(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.CIDPatterns = []models.CIDPattern{
+		{Name: "broken", RegexString: "[unclosed", Enabled: true},
+	}
+
+	if _, err := New(cfg); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestTracker_ProcessLogLine_PatternName(t *testing.T) {
+	tracker := NewForDir("/var/log", "json")
+
+	// Capture stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	tracker.processLogLine(`{"msg":"done","cid":"550e8400-e29b-51d4-a716-446655440000"}`, "/var/log/test.log")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+
+	var entry sink.Record
+	if err := json.Unmarshal([]byte(strings.TrimSpace(buf.String())), &entry); err != nil {
+		t.Fatalf("failed to parse JSON output: %v", err)
+	}
+
+	if entry.Pattern != "json_cid" {
+		t.Errorf("Pattern = %v, want json_cid", entry.Pattern)
+	}
+	if entry.UUID != "550e8400-e29b-51d4-a716-446655440000" {
+		t.Errorf("UUID = %v, want 550e8400-e29b-51d4-a716-446655440000", entry.UUID)
+	}
+}
+
+// captureStdout returns what fn printed to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	old := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+
+	// Drain concurrently so output larger than the pipe buffer cannot block fn
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		buf.ReadFrom(r)
+		done <- buf.String()
+	}()
+
+	fn()
+
+	w.Close()
+	os.Stdout = old
+	return <-done
+}
+
+func TestTracker_RenameRotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+	defer tracker.cleanup()
+
+	logFile := filepath.Join(tmpDir, "app.log")
+	rotated := filepath.Join(tmpDir, "app.log.1")
+	if err := os.WriteFile(logFile, []byte("initial\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	tracker.monitorLogFile(logFile)
+
+	oldCID := "550e8400-e29b-51d4-a716-446655440001"
+	newCID := "550e8400-e29b-51d4-a716-446655440002"
+
+	output := captureStdout(t, func() {
+		if err := os.Rename(logFile, rotated); err != nil {
+			t.Fatalf("rename failed: %v", err)
+		}
+		// Written to the old inode after the rename
+		f, _ := os.OpenFile(rotated, os.O_APPEND|os.O_WRONLY, 0644)
+		f.WriteString("CID:" + oldCID + " late write\n")
+		f.Close()
+		tracker.handleFileEvent(fsnotify.Event{Name: logFile, Op: fsnotify.Rename})
+
+		if err := os.WriteFile(logFile, []byte("CID:"+newCID+" first line\n"), 0644); err != nil {
+			t.Fatalf("failed to recreate log file: %v", err)
+		}
+		tracker.handleFileEvent(fsnotify.Event{Name: logFile, Op: fsnotify.Create})
+	})
+
+	if !strings.Contains(output, oldCID) {
+		t.Errorf("expected output to contain CID written before rotation, got: %v", output)
+	}
+	if strings.Count(output, `"extracted_cid":"`+newCID) != 1 {
+		t.Errorf("expected output to contain CID from the new file once, got: %v", output)
+	}
+	if _, exists := tracker.fileHandles[logFile]; !exists {
+		t.Error("expected rotated path to still be monitored")
+	}
+}
+
+func TestTracker_CopyTruncate(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+	defer tracker.cleanup()
+
+	logFile := filepath.Join(tmpDir, "app.log")
+	if err := os.WriteFile(logFile, []byte("a long line that was written before copytruncate ran and copied elsewhere\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	tracker.monitorLogFile(logFile)
+
+	cid := "550e8400-e29b-51d4-a716-446655440003"
+	output := captureStdout(t, func() {
+		if err := os.Truncate(logFile, 0); err != nil {
+			t.Fatalf("truncate failed: %v", err)
+		}
+		f, _ := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+		f.WriteString("CID:" + cid + " after truncate\n")
+		f.Close()
+		tracker.handleFileEvent(fsnotify.Event{Name: logFile, Op: fsnotify.Write})
+	})
+
+	if !strings.Contains(output, cid) {
+		t.Errorf("expected output to contain CID written after truncation, got: %v", output)
+	}
+}
+
+func TestTracker_RemoveAfterRecreateKeepsHandle(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+	defer tracker.cleanup()
+
+	logFile := filepath.Join(tmpDir, "app.log")
+	if err := os.WriteFile(logFile, []byte("old\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	tracker.monitorLogFile(logFile)
+
+	cid := "550e8400-e29b-51d4-a716-446655440004"
+	output := captureStdout(t, func() {
+		os.Remove(logFile)
+		os.WriteFile(logFile, []byte("CID:"+cid+" new\n"), 0644)
+		// Events arrive after the file has already been recreated
+		tracker.handleFileEvent(fsnotify.Event{Name: logFile, Op: fsnotify.Remove})
+		tracker.handleFileEvent(fsnotify.Event{Name: logFile, Op: fsnotify.Create})
+	})
+
+	if strings.Count(output, `"extracted_cid":"`+cid) != 1 {
+		t.Errorf("expected CID exactly once, got: %v", output)
+	}
+	if _, exists := tracker.fileHandles[logFile]; !exists {
+		t.Error("expected recreated file to be monitored")
+	}
+}
+
+func TestTracker_ResumesFromCheckpoint(t *testing.T) {
+	logDir := t.TempDir()
+	stateDir := t.TempDir()
+
+	logFile := filepath.Join(logDir, "app.log")
+	first := "550e8400-e29b-51d4-a716-446655440011"
+	second := "550e8400-e29b-51d4-a716-446655440012"
+	if err := os.WriteFile(logFile, []byte("CID:"+first+" before restart\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	newTracker := func() *Tracker {
+		cfg := config.DefaultConfig()
+		cfg.SetLogDir(logDir)
+		cfg.StateDir = stateDir
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		tracker, err := New(cfg)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		return tracker
+	}
+
+	// First run has no checkpoint and reads the file from the beginning
+	tracker := newTracker()
+	output := captureStdout(t, func() {
+		tracker.processExistingFiles()
+	})
+	tracker.flushCheckpoints()
+	tracker.cleanup()
+
+	if !strings.Contains(output, `"extracted_cid":"`+first) {
+		t.Errorf("first run should emit the existing CID, got: %v", output)
+	}
+
+	// Written while the tracker was down
+	f, _ := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("CID:" + second + " during restart\n")
+	f.Close()
+
+	tracker = newTracker()
+	output = captureStdout(t, func() {
+		tracker.processExistingFiles()
+	})
+	tracker.cleanup()
+
+	if strings.Contains(output, `"extracted_cid":"`+first) {
+		t.Errorf("second run should not duplicate the CID before the checkpoint, got: %v", output)
+	}
+	if !strings.Contains(output, `"extracted_cid":"`+second) {
+		t.Errorf("second run should emit the CID written during restart, got: %v", output)
+	}
+}
+
+// gatedSink holds every write until its gate is closed
+type gatedSink struct {
+	recordingSink
+	gate chan struct{}
+}
+
+func (s *gatedSink) Write(ctx context.Context, record models.CIDRecord) error {
+	select {
+	case <-s.gate:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.recordingSink.Write(ctx, record)
+}
+
+func TestTracker_CheckpointWaitsForSinks(t *testing.T) {
+	logDir := t.TempDir()
+	logFile := filepath.Join(logDir, "app.log")
+	cid := "550e8400-e29b-51d4-a716-446655440055"
+	if err := os.WriteFile(logFile, []byte("CID:"+cid+" queued\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.SetLogDir(logDir)
+	cfg.StateDir = t.TempDir()
+	cfg.StartFrom = config.StartFromBeginning
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer tracker.cleanup()
+
+	output := &gatedSink{gate: make(chan struct{})}
+	tracker.SetSink(output)
+	tracker.outputs.Start(10, time.Hour)
+	defer tracker.outputs.Close()
+	tracker.processExistingFiles()
+
+	// The record is still queued, so the position after it must not be saved
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := tracker.checkpoints.Sync(ctx, tracker.outputs.Flush); err == nil {
+		t.Error("Sync() error = nil while the sink had not written the record")
+	}
+	if _, ok := tracker.checkpoints.Get(logFile); ok {
+		t.Error("checkpoint saved before the sink wrote the record")
+	}
+
+	close(output.gate)
+	tracker.flushCheckpoints()
+	pos, ok := tracker.checkpoints.Get(logFile)
+	if !ok || pos.Offset == 0 {
+		t.Errorf("checkpoint = %+v, %v after the sink wrote the record, want the end of the file", pos, ok)
+	}
+	if len(output.records) != 1 || output.records[0].CID != cid {
+		t.Errorf("sink received %+v, want the CID once", output.records)
+	}
+}
+
+func TestTracker_StartFromEndIgnoresExistingLines(t *testing.T) {
+	logDir := t.TempDir()
+	logFile := filepath.Join(logDir, "app.log")
+	cid := "550e8400-e29b-51d4-a716-446655440013"
+	if err := os.WriteFile(logFile, []byte("CID:"+cid+" old\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	tests := []struct {
+		startFrom string
+		wantCID   bool
+	}{
+		{startFrom: config.StartFromEnd, wantCID: false},
+		{startFrom: config.StartFromBeginning, wantCID: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.startFrom, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.SetLogDir(logDir)
+			cfg.StartFrom = tt.startFrom
+			cfg.Validate()
+			tracker, _ := New(cfg)
+			defer tracker.cleanup()
+
+			output := captureStdout(t, func() {
+				tracker.processExistingFiles()
+			})
+
+			if got := strings.Contains(output, cid); got != tt.wantCID {
+				t.Errorf("output contains CID = %v, want %v (output: %v)", got, tt.wantCID, output)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidStateDir(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.StateDir = filepath.Join(blocker, "state")
+	cfg.Validate()
+
+	if _, err := New(cfg); err == nil {
+		t.Error("expected error when the state directory cannot be created")
+	}
+}
+
+// waitForMonitoredFile polls MonitoredFiles until path shows up with at least lines processed
+func waitForMonitoredFile(t *testing.T, tracker *Tracker, path string, lines int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, file := range tracker.MonitoredFiles() {
+			if file.Path == path && file.LinesProcessed >= lines {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %s to be monitored with %d lines", path, lines)
+}
+
+func TestTracker_WatchesNewSubdirectories(t *testing.T) {
+	tmpDir := t.TempDir()
+	tracker := NewForDir(tmpDir, "json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- tracker.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !tracker.Healthy() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A nested directory created after startup, with a file written in it right away
+	nested := filepath.Join(tmpDir, "pod", "container")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("failed to create directories: %v", err)
+	}
+	logFile := filepath.Join(nested, "0.log")
+	if err := os.WriteFile(logFile, []byte("first line\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	waitForMonitoredFile(t, tracker, logFile, 1)
+
+	// Later writes in the new directory are picked up through its watch
+	f, _ := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("second line\n")
+	f.Close()
+	waitForMonitoredFile(t, tracker, logFile, 2)
+}
+
+func TestTracker_SourcePatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{
+		"ns_web_1/nginx/0.log",
+		"ns_web_1/nginx/0.log.20240101",
+		"ns_web_1/top.log",
+		"kube-system_dns_2/coredns/0.log",
+	} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("line\n"), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.LogSources = []models.LogSource{
+		{
+			Name:     "pods",
+			Path:     tmpDir,
+			Patterns: []string{"*/*/*.log"},
+			Exclude:  []string{"kube-system_*"},
+			Active:   true,
+		},
+		{
+			Name:     "disabled",
+			Path:     tmpDir,
+			Patterns: []string{"**"},
+			Active:   false,
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer tracker.cleanup()
+
+	if len(tracker.sources) != 1 {
+		t.Fatalf("expected inactive source to be skipped, got %d sources", len(tracker.sources))
+	}
+
+	if err := tracker.processExistingFiles(); err != nil {
+		t.Fatalf("processExistingFiles() error = %v", err)
+	}
+
+	if len(tracker.fileHandles) != 1 {
+		t.Errorf("expected 1 monitored file, got %d", len(tracker.fileHandles))
+	}
+	if _, exists := tracker.fileHandles[filepath.Join(tmpDir, "ns_web_1", "nginx", "0.log")]; !exists {
+		t.Error("expected pod log file to be monitored")
+	}
+
+	// Events for files outside the patterns are ignored
+	rotated := filepath.Join(tmpDir, "ns_web_1", "nginx", "0.log.20240101")
+	tracker.handleFileEvent(fsnotify.Event{Name: rotated, Op: fsnotify.Create})
+	if _, exists := tracker.fileHandles[rotated]; exists {
+		t.Error("expected rotated file not matching the patterns to be ignored")
+	}
+}
+
+func TestTracker_DockerJSONSource(t *testing.T) {
+	containerID := "3f4e5d6c7b8a9f0e1d2c3b4a5f6e7d8c9b0a1f2e3d4c5b6a7f8e9d0c1b2a3f4e"
+	root := t.TempDir()
+	dir := filepath.Join(root, containerID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create container directory: %v", err)
+	}
+	logFile := filepath.Join(dir, containerID+"-json.log")
+
+	cid := "550e8400-e29b-51d4-a716-446655440021"
+	content := `{"log":"{\"cid\":\"` + cid[:20] + `","stream":"stdout","time":"2024-03-01T12:00:00.5Z"}` + "\n" +
+		`{"log":"` + cid[20:] + `\",\"msg\":\"ok\"}\n","stream":"stdout","time":"2024-03-01T12:00:01Z"}` + "\n"
+	if err := os.WriteFile(logFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.LogSources = []models.LogSource{
+		{Name: "docker", Path: root, Patterns: []string{"*/*-json.log"}, Format: "docker", Active: true},
+	}
+	cfg.StartFrom = config.StartFromBeginning
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer tracker.cleanup()
+
+	output := captureStdout(t, func() {
+		tracker.processExistingFiles()
+	})
+
+	var entry sink.Record
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &entry); err != nil {
+		t.Fatalf("expected one JSON entry, got %q: %v", output, err)
+	}
+
+	if entry.ExtractedCID != cid {
+		t.Errorf("ExtractedCID = %v, want %v", entry.ExtractedCID, cid)
+	}
+	if entry.OriginalLog != `{"cid":"`+cid+`","msg":"ok"}` {
+		t.Errorf("OriginalLog = %v, want unwrapped and reassembled log", entry.OriginalLog)
+	}
+	if want := time.Date(2024, 3, 1, 12, 0, 0, 500000000, time.UTC); !entry.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", entry.Timestamp, want)
+	}
+	if entry.Metadata["stream"] != "stdout" {
+		t.Errorf("stream = %v, want stdout", entry.Metadata["stream"])
+	}
+	if entry.Metadata["container_id"] != containerID {
+		t.Errorf("container_id = %v, want %v", entry.Metadata["container_id"], containerID)
+	}
+}
+
+func TestTracker_CRIPodSource(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "payments_checkout-0_0b7c6a4e", "api")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create pod directory: %v", err)
+	}
+
+	cid := "550e8400-e29b-51d4-a716-446655440031"
+	content := "2024-01-15T10:30:00.25Z stdout P charge CID:" + cid[:10] + "\n" +
+		"2024-01-15T10:30:00.26Z stdout F " + cid[10:] + " ok\n"
+	if err := os.WriteFile(filepath.Join(dir, "2.log"), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.LogSources = []models.LogSource{
+		{Name: "pods", Path: root, Patterns: []string{"*/*/*.log"}, Format: "cri", Active: true},
+	}
+	cfg.StartFrom = config.StartFromBeginning
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer tracker.cleanup()
+
+	output := captureStdout(t, func() {
+		tracker.processExistingFiles()
+	})
+
+	var entry sink.Record
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &entry); err != nil {
+		t.Fatalf("expected one JSON entry, got %q: %v", output, err)
+	}
+
+	if entry.ExtractedCID != cid {
+		t.Errorf("ExtractedCID = %v, want %v", entry.ExtractedCID, cid)
+	}
+	if entry.OriginalLog != "charge CID:"+cid+" ok" {
+		t.Errorf("OriginalLog = %v, want stitched message", entry.OriginalLog)
+	}
+	if want := time.Date(2024, 1, 15, 10, 30, 0, 250000000, time.UTC); !entry.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", entry.Timestamp, want)
+	}
+	want := map[string]string{
+		"stream":        "stdout",
+		"namespace":     "payments",
+		"pod":           "checkout-0",
+		"pod_uid":       "0b7c6a4e",
+		"container":     "api",
+		"restart_count": "2",
+	}
+	for key, value := range want {
+		if entry.Metadata[key] != value {
+			t.Errorf("Metadata[%s] = %v, want %v", key, entry.Metadata[key], value)
+		}
+	}
+}
+
+func TestTracker_SourceTimestampLayouts(t *testing.T) {
+	root := t.TempDir()
+	logFile := filepath.Join(root, "app.log")
+
+	content := "1709633472250 CID:550e8400-e29b-51d4-a716-446655440031 epoch\n" +
+		"2024-03-05 10:11:12.345 CID:550e8400-e29b-51d4-a716-446655440032 not configured\n"
+	if err := os.WriteFile(logFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.LogSources = []models.LogSource{
+		{Name: "app", Path: root, TimestampLayouts: []string{"unix_ms"}, Active: true},
+	}
+	cfg.StartFrom = config.StartFromBeginning
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer tracker.cleanup()
+
+	before := time.Now()
+	output := captureStdout(t, func() {
+		tracker.processExistingFiles()
+	})
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, got %q", output)
+	}
+
+	var parsed, inferred sink.Record
+	if err := json.Unmarshal([]byte(lines[0]), &parsed); err != nil {
+		t.Fatalf("invalid JSON entry %q: %v", lines[0], err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &inferred); err != nil {
+		t.Fatalf("invalid JSON entry %q: %v", lines[1], err)
+	}
+
+	if want := time.UnixMilli(1709633472250); parsed.LogTimestamp == nil || !parsed.LogTimestamp.Equal(want) || !parsed.Timestamp.Equal(want) {
+		t.Errorf("epoch entry Timestamp = %v (logged %v), want %v", parsed.Timestamp, parsed.LogTimestamp, want)
+	}
+	if parsed.ProcessedAt.Before(before) {
+		t.Errorf("ProcessedAt = %v, want ingest time", parsed.ProcessedAt)
+	}
+	if inferred.LogTimestamp != nil || inferred.Timestamp.Before(before) {
+		t.Errorf("unconfigured layout Timestamp = %v (logged %v), want ingest time", inferred.Timestamp, inferred.LogTimestamp)
+	}
+}
+
+func TestNew_InvalidTimezone(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.LogSources[0].Timezone = "Nowhere/City"
+
+	if _, err := New(cfg); err == nil {
+		t.Error("New() expected error for an unknown timezone")
+	}
+}
+
+func TestTracker_MultilineSource(t *testing.T) {
+	root := t.TempDir()
+	logFile := filepath.Join(root, "app.log")
+
+	cid := "550e8400-e29b-51d4-a716-446655440041"
+	content := "2024-03-05 10:11:12.345 ERROR CID:" + cid + " request failed\n" +
+		"java.lang.IllegalStateException: boom\n" +
+		"\tat com.example.Service.run(Service.java:42)\n" +
+		"2024-03-05 10:11:13.000 INFO no correlation id\n" +
+		"2024-03-05 10:11:14.000 ERROR retry failed\n" +
+		"\tcaused by CID:" + cid + "\n"
+	if err := os.WriteFile(logFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.LogSources = []models.LogSource{
+		{
+			Name:      "app",
+			Path:      root,
+			Multiline: &models.MultilineConfig{StartPattern: `^\d{4}-\d{2}-\d{2} `},
+			Active:    true,
+		},
+	}
+	cfg.StartFrom = config.StartFromBeginning
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		tracker.processExistingFiles()
+		// The last event is still pending until its source goes quiet
+		tracker.flushExpiredEvents(time.Now().Add(time.Minute))
+		tracker.cleanup()
+	})
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, got %q", output)
+	}
+
+	want := []string{
+		"2024-03-05 10:11:12.345 ERROR CID:" + cid + " request failed\njava.lang.IllegalStateException: boom\n\tat com.example.Service.run(Service.java:42)",
+		"2024-03-05 10:11:14.000 ERROR retry failed\n\tcaused by CID:" + cid,
+	}
+	for i, line := range lines {
+		var entry sink.Record
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON entry %q: %v", line, err)
+		}
+		if entry.OriginalLog != want[i] {
+			t.Errorf("entry %d OriginalLog = %q, want %q", i, entry.OriginalLog, want[i])
+		}
+		if entry.LogTimestamp == nil {
+			t.Errorf("entry %d timestamp was not parsed from the first line", i)
+		}
+	}
+}
+
+func TestTracker_MultilineFlushOnClose(t *testing.T) {
+	root := t.TempDir()
+	logFile := filepath.Join(root, "app.log")
+
+	content := "ERROR CID:550e8400-e29b-51d4-a716-446655440042 failed\n  at main.go:10\n"
+	if err := os.WriteFile(logFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.LogSources = []models.LogSource{
+		{
+			Name:      "app",
+			Path:      root,
+			Multiline: &models.MultilineConfig{ContinuationPattern: `^\s`, FlushTimeout: time.Hour},
+			Active:    true,
+		},
+	}
+	cfg.StartFrom = config.StartFromBeginning
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		tracker.processExistingFiles()
+		tracker.flushExpiredEvents(time.Now())
+	})
+	if output != "" {
+		t.Fatalf("event flushed before its timeout: %q", output)
+	}
+
+	output = captureStdout(t, func() {
+		tracker.closeFileHandle(logFile)
+	})
+	var entry sink.Record
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &entry); err != nil {
+		t.Fatalf("expected one JSON entry on close, got %q: %v", output, err)
+	}
+	if !strings.HasSuffix(entry.OriginalLog, "\n  at main.go:10") {
+		t.Errorf("OriginalLog = %q, want the whole event", entry.OriginalLog)
+	}
+}
+
+func TestTracker_OversizedLines(t *testing.T) {
+	first := "550e8400-e29b-51d4-a716-446655440051"
+	second := "550e8400-e29b-51d4-a716-446655440052"
+	payload := strings.Repeat("x", 200*1024)
+
+	tests := []struct {
+		policy        string
+		wantCIDs      []string
+		wantTruncated int64
+		wantSkipped   int64
+	}{
+		{policy: "truncate", wantCIDs: []string{first, second}, wantTruncated: 1},
+		{policy: "skip", wantCIDs: []string{second}, wantSkipped: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			root := t.TempDir()
+			content := "CID:" + first + " dump " + payload + "\nCID:" + second + " after\n"
+			if err := os.WriteFile(filepath.Join(root, "app.log"), []byte(content), 0644); err != nil {
+				t.Fatalf("failed to create log file: %v", err)
+			}
+
+			cfg := config.DefaultConfig()
+			cfg.SetLogDir(root)
+			cfg.StartFrom = config.StartFromBeginning
+			cfg.MaxLineSize = 64 * 1024
+			cfg.OversizedLinePolicy = tt.policy
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			tracker, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			defer tracker.cleanup()
+
+			output := captureStdout(t, func() {
+				tracker.processExistingFiles()
+			})
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+				var entry sink.Record
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("invalid JSON entry %.80q: %v", line, err)
+				}
+				if len(entry.OriginalLog) > cfg.MaxLineSize {
+					t.Errorf("OriginalLog has %d bytes, want at most %d", len(entry.OriginalLog), cfg.MaxLineSize)
+				}
+				got = append(got, entry.ExtractedCID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantCIDs, ",") {
+				t.Errorf("CIDs = %v, want %v", got, tt.wantCIDs)
+			}
+
+			truncated, skipped := tracker.metrics.OversizedStats()
+			if truncated != tt.wantTruncated || skipped != tt.wantSkipped {
+				t.Errorf("OversizedStats() = %d, %d, want %d, %d", truncated, skipped, tt.wantTruncated, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestTracker_OversizedSplitLines(t *testing.T) {
+	first := "550e8400-e29b-51d4-a716-446655440053"
+	second := "550e8400-e29b-51d4-a716-446655440054"
+	chunk := strings.Repeat("x", 16*1024)
+
+	tests := []struct {
+		policy        string
+		wantCIDs      []string
+		wantTruncated int64
+		wantSkipped   int64
+	}{
+		{policy: "truncate", wantCIDs: []string{first, second}, wantTruncated: 1},
+		{policy: "skip", wantCIDs: []string{second}, wantSkipped: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			root := t.TempDir()
+			// Every raw line fits the limit, but the stitched line does not
+			var content strings.Builder
+			content.WriteString(`{"log":"CID:` + first + ` ","stream":"stdout","time":"2024-03-01T12:00:00Z"}` + "\n")
+			for i := 0; i < 8; i++ {
+				content.WriteString(`{"log":"` + chunk + `","stream":"stdout","time":"2024-03-01T12:00:00Z"}` + "\n")
+			}
+			content.WriteString(`{"log":"end\n","stream":"stdout","time":"2024-03-01T12:00:00Z"}` + "\n")
+			content.WriteString(`{"log":"CID:` + second + ` after\n","stream":"stdout","time":"2024-03-01T12:00:01Z"}` + "\n")
+			if err := os.WriteFile(filepath.Join(root, "app-json.log"), []byte(content.String()), 0644); err != nil {
+				t.Fatalf("failed to create log file: %v", err)
+			}
+
+			cfg := config.DefaultConfig()
+			cfg.LogSources = []models.LogSource{
+				{Name: "docker", Path: root, Patterns: []string{"*-json.log"}, Format: "docker", Active: true},
+			}
+			cfg.StartFrom = config.StartFromBeginning
+			cfg.MaxLineSize = 64 * 1024
+			cfg.OversizedLinePolicy = tt.policy
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			tracker, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			defer tracker.cleanup()
+
+			recorder := &recordingSink{}
+			tracker.SetSink(recorder)
+			tracker.processExistingFiles()
+
+			var got []string
+			for _, record := range recorder.records {
+				if len(record.RawLogLine) > cfg.MaxLineSize {
+					t.Errorf("RawLogLine has %d bytes, want at most %d", len(record.RawLogLine), cfg.MaxLineSize)
+				}
+				got = append(got, record.CID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantCIDs, ",") {
+				t.Errorf("CIDs = %v, want %v", got, tt.wantCIDs)
+			}
+			// After a skipped line the next one is located by its own raw line
+			if last := recorder.records[len(recorder.records)-1]; last.LineNumber != 11 {
+				t.Errorf("last record LineNumber = %d, want 11", last.LineNumber)
+			}
+
+			truncated, skipped := tracker.metrics.OversizedStats()
+			if truncated != tt.wantTruncated || skipped != tt.wantSkipped {
+				t.Errorf("OversizedStats() = %d, %d, want %d, %d", truncated, skipped, tt.wantTruncated, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestTracker_LineWrittenInChunks(t *testing.T) {
+	root := t.TempDir()
+	logFile := filepath.Join(root, "app.log")
+	if err := os.WriteFile(logFile, nil, 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	tracker := NewForDir(root, "json")
+	defer tracker.cleanup()
+	tracker.followLogFile(logFile, false)
+
+	cid := "550e8400-e29b-51d4-a716-446655440061"
+	chunks := []string{"INFO CID:" + cid[:13], cid[13:30], cid[30:] + " done", "\n"}
+
+	var output string
+	for i, chunk := range chunks {
+		f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatalf("failed to open log file: %v", err)
+		}
+		f.WriteString(chunk)
+		f.Close()
+
+		out := captureStdout(t, func() {
+			tracker.processLogUpdates(logFile)
+		})
+		if i < len(chunks)-1 && out != "" {
+			t.Fatalf("chunk %d produced output before the newline: %q", i, out)
+		}
+		output += out
+	}
+
+	var entry sink.Record
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &entry); err != nil {
+		t.Fatalf("expected one JSON entry, got %q: %v", output, err)
+	}
+	if entry.ExtractedCID != cid {
+		t.Errorf("ExtractedCID = %v, want %v", entry.ExtractedCID, cid)
+	}
+}
+
+func TestTracker_PartialLineFlush(t *testing.T) {
+	root := t.TempDir()
+	logFile := filepath.Join(root, "app.log")
+	other := filepath.Join(root, "other.log")
+	for _, path := range []string{logFile, other} {
+		if err := os.WriteFile(path, []byte("CID:550e8400-e29b-51d4-a716-446655440062 no newline"), 0644); err != nil {
+			t.Fatalf("failed to create log file: %v", err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.SetLogDir(root)
+	cfg.PartialLineTimeout = 50 * time.Millisecond
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer tracker.cleanup()
+
+	output := captureStdout(t, func() {
+		tracker.followLogFile(logFile, false)
+		tracker.followLogFile(other, false)
+		tracker.processLogUpdates(logFile)
+		tracker.processLogUpdates(other)
+		tracker.pollPartialLines()
+	})
+	if output != "" {
+		t.Fatalf("partial line processed before the idle timeout: %q", output)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	output = captureStdout(t, func() {
+		tracker.pollPartialLines()
+	})
+	if n := strings.Count(output, `"extracted_cid":"`); n != 2 {
+		t.Errorf("idle flush produced %d entries, want 2: %q", n, output)
+	}
+
+	// A partial line is also flushed when its file is closed
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	f.WriteString("\nCID:550e8400-e29b-51d4-a716-446655440063 closing")
+	f.Close()
+
+	output = captureStdout(t, func() {
+		tracker.processLogUpdates(logFile)
+		tracker.closeFileHandle(logFile)
+	})
+	if !strings.Contains(output, "550e8400-e29b-51d4-a716-446655440063") {
+		t.Errorf("partial line not flushed on close: %q", output)
+	}
+}
+
+func TestTracker_WatchModes(t *testing.T) {
+	for _, mode := range []string{"poll", "hybrid"} {
+		t.Run(mode, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			cfg := config.DefaultConfig()
+			cfg.SetLogDir(tmpDir)
+			cfg.LogSources[0].Watch = mode
+			cfg.WatchInterval = 20 * time.Millisecond
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			tracker, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				done <- tracker.Start(ctx)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			deadline := time.Now().Add(2 * time.Second)
+			for !tracker.Healthy() && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			nested := filepath.Join(tmpDir, "pod")
+			if err := os.MkdirAll(nested, 0755); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			logFile := filepath.Join(nested, "app.log")
+			if err := os.WriteFile(logFile, []byte("first line\n"), 0644); err != nil {
+				t.Fatalf("failed to create log file: %v", err)
+			}
+			waitForMonitoredFile(t, tracker, logFile, 1)
+
+			f, _ := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+			f.WriteString("second line\n")
+			f.Close()
+			waitForMonitoredFile(t, tracker, logFile, 2)
+		})
+	}
+}
+
+func TestTracker_Start_InvalidWatchPath(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SetLogDir("/nonexistent/path")
+	cfg.WatchMode = "poll"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := tracker.Start(ctx); err == nil {
+		t.Error("expected error for invalid path in poll mode")
+	}
+}
+
+func TestTracker_Sinks(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SetLogDir(t.TempDir())
+	cfg.Sinks = []models.SinkConfig{
+		{Name: "all", Type: "stdout"},
+		{Name: "json-only", Type: "stdout", Filter: &models.SinkFilter{Patterns: []string{"json_cid"}}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		tracker.processLogLine("CID:550e8400-e29b-51d4-a716-446655440000", "/var/log/test.log")
+	})
+	if lines := strings.Count(output, "\n"); lines != 1 {
+		t.Errorf("stdout has %d lines, want 1 from the unfiltered sink", lines)
+	}
+
+	sinks := tracker.Sinks()
+	if len(sinks) != 2 {
+		t.Fatalf("Sinks() = %+v, want 2 sinks", sinks)
+	}
+	if sinks[0].Name != "all" || sinks[0].Written != 1 {
+		t.Errorf("Sinks()[0] = %+v, want 1 record written to all", sinks[0])
+	}
+	if sinks[1].Name != "json-only" || sinks[1].Written != 0 {
+		t.Errorf("Sinks()[1] = %+v, want nothing written to json-only", sinks[1])
+	}
+
+	if dest := tracker.Configuration().OutputDestination; dest != "all, json-only" {
+		t.Errorf("OutputDestination = %q, want %q", dest, "all, json-only")
+	}
+}
+
+func TestTracker_RecordSourceAndPosition(t *testing.T) {
+	root := t.TempDir()
+	logFile := filepath.Join(root, "app-json.log")
+
+	first := `{"log":"CID:` + testCID(1)[:20] + `","stream":"stdout","time":"2024-03-01T12:00:00Z"}` + "\n" +
+		`{"log":"` + testCID(1)[20:] + `\n","stream":"stdout","time":"2024-03-01T12:00:00Z"}` + "\n"
+	second := `{"log":"CID:` + testCID(2) + `\n","stream":"stdout","time":"2024-03-01T12:00:01Z"}` + "\n"
+	if err := os.WriteFile(logFile, []byte(first+second), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.LogSources = []models.LogSource{
+		{Name: "docker", Path: root, Patterns: []string{"*-json.log"}, Format: "docker", Active: true},
+	}
+	cfg.StartFrom = config.StartFromBeginning
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tracker, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer tracker.cleanup()
+
+	recorder := &recordingSink{}
+	tracker.SetSink(recorder)
+	tracker.processExistingFiles()
+
+	if len(recorder.records) != 2 {
+		t.Fatalf("got %d records, want 2", len(recorder.records))
+	}
+	// A line split by the runtime starts at its first chunk
+	if got := recorder.records[0].Offset; got != 0 {
+		t.Errorf("first record Offset = %d, want 0", got)
+	}
+	if got, want := recorder.records[1].Offset, int64(len(first)); got != want {
+		t.Errorf("second record Offset = %d, want %d", got, want)
+	}
+	if first, second := recorder.records[0].LineNumber, recorder.records[1].LineNumber; first != 1 || second != 3 {
+		t.Errorf("record LineNumbers = %d, %d, want 1, 3", first, second)
+	}
+	if got := recorder.records[1].SourceName; got != "docker" {
+		t.Errorf("SourceName = %q, want docker", got)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package watcher
+
+import "syscall"
+
+// File system magic numbers from statfs(2) of file systems whose changes made
+// by other hosts or by a userspace daemon never reach inotify
+const (
+	nfsSuperMagic  = 0x6969
+	smbSuperMagic  = 0x517b
+	cifsMagic      = 0xff534d42
+	smb2Magic      = 0xfe534d42
+	fuseSuperMagic = 0x65735546
+	cephSuperMagic = 0x00c36400
+)
+
+// notifyReliable reports whether fsnotify can be expected to deliver events
+// for path. Paths that cannot be inspected are assumed to be reliable.
+func notifyReliable(path string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return true
+	}
+
+	switch uint32(st.Type) {
+	case nfsSuperMagic, smbSuperMagic, cifsMagic, smb2Magic, fuseSuperMagic, cephSuperMagic:
+		return false
+	}
+	return true
+}
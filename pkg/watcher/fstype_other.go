@@ -0,0 +1,9 @@
+//go:build !linux
+
+package watcher
+
+// notifyReliable reports whether fsnotify can be expected to deliver events
+// for path. File system types are only recognised on Linux.
+func notifyReliable(path string) bool {
+	return true
+}
@@ -0,0 +1,164 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// snapshot maps the paths below a polled path, or the path itself if it is a
+// file, to their stat information
+type snapshot map[string]os.FileInfo
+
+// takeSnapshot stats path and, if it is a directory, each of its entries.
+// Symlinks are followed so that writes to their targets are seen. A path that
+// does not exist has an empty snapshot.
+func takeSnapshot(path string) (snapshot, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return snapshot{path: info}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snap := make(snapshot, len(entries))
+	for _, entry := range entries {
+		name := filepath.Join(path, entry.Name())
+		info, err := os.Stat(name)
+		if err != nil {
+			// Removed since it was listed, or a dangling symlink
+			continue
+		}
+		snap[name] = info
+	}
+	return snap, nil
+}
+
+// diff returns the events that turn the old snapshot into the new one, in
+// path order
+func diff(old, new snapshot) []fsnotify.Event {
+	var events []fsnotify.Event
+	for name, info := range new {
+		previous, ok := old[name]
+		switch {
+		case !ok || !os.SameFile(previous, info):
+			events = append(events, fsnotify.Event{Name: name, Op: fsnotify.Create})
+		case info.IsDir():
+			// Changes inside a subdirectory are reported by its own watch
+		case info.Size() != previous.Size() || !info.ModTime().Equal(previous.ModTime()):
+			events = append(events, fsnotify.Event{Name: name, Op: fsnotify.Write})
+		}
+	}
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			events = append(events, fsnotify.Event{Name: name, Op: fsnotify.Remove})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Name < events[j].Name
+	})
+	return events
+}
+
+// addPoll starts polling path, taking the snapshot later polls are compared
+// against, and starts the poll loop on first use. The path must exist when it
+// is added; later it may disappear and reappear. A path that is already
+// polled keeps its snapshot.
+func (w *Watcher) addPoll(path string) error {
+	if _, ok := w.polled[path]; ok {
+		return nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	snap, err := takeSnapshot(path)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	w.polled[path] = snap
+
+	if !w.polling {
+		w.polling = true
+		w.wg.Add(1)
+		go w.pollLoop()
+	}
+	return nil
+}
+
+// pollLoop polls every watched path each interval until the watcher is closed
+func (w *Watcher) pollLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if !w.poll() {
+				return
+			}
+		}
+	}
+}
+
+// poll compares each polled path with its last snapshot and delivers the
+// differences. Events are sent without holding the lock, so a consumer may
+// add watches while handling them. It returns false once the watcher is closed.
+func (w *Watcher) poll() bool {
+	w.mu.Lock()
+	paths := make([]string, 0, len(w.polled))
+	for path := range w.polled {
+		paths = append(paths, path)
+	}
+	w.mu.Unlock()
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		snap, err := takeSnapshot(path)
+		if err != nil {
+			if !w.sendError(fmt.Errorf("failed to poll %s: %w", path, err)) {
+				return false
+			}
+			continue
+		}
+
+		w.mu.Lock()
+		old, ok := w.polled[path]
+		if ok {
+			w.polled[path] = snap
+		}
+		w.mu.Unlock()
+		if !ok {
+			// Removed while polling
+			continue
+		}
+
+		for _, event := range diff(old, snap) {
+			if !w.sendEvent(event) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func mustSnapshot(t *testing.T, path string) snapshot {
+	t.Helper()
+	snap, err := takeSnapshot(path)
+	if err != nil {
+		t.Fatalf("takeSnapshot() error = %v", err)
+	}
+	return snap
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	app := filepath.Join(dir, "app.log")
+	old := filepath.Join(dir, "old.log")
+	sub := filepath.Join(dir, "sub")
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T)
+		want   []fsnotify.Event
+	}{
+		{
+			name:   "no change",
+			change: func(t *testing.T) {},
+		},
+		{
+			name:   "append",
+			change: func(t *testing.T) { writeFile(t, app, "one\ntwo\n") },
+			want:   []fsnotify.Event{{Name: app, Op: fsnotify.Write}},
+		},
+		{
+			name: "create and remove",
+			change: func(t *testing.T) {
+				writeFile(t, filepath.Join(dir, "new.log"), "")
+				os.Remove(old)
+			},
+			want: []fsnotify.Event{
+				{Name: filepath.Join(dir, "new.log"), Op: fsnotify.Create},
+				{Name: old, Op: fsnotify.Remove},
+			},
+		},
+		{
+			name: "rename",
+			change: func(t *testing.T) {
+				if err := os.Rename(app, app+".1"); err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, app, "")
+			},
+			want: []fsnotify.Event{
+				{Name: app, Op: fsnotify.Create},
+				{Name: app + ".1", Op: fsnotify.Create},
+			},
+		},
+		{
+			name: "subdirectory contents",
+			change: func(t *testing.T) {
+				writeFile(t, filepath.Join(sub, "nested.log"), "line\n")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.RemoveAll(dir)
+			os.MkdirAll(sub, 0755)
+			writeFile(t, app, "one\n")
+			writeFile(t, old, "old\n")
+
+			before := mustSnapshot(t, dir)
+			tt.change(t)
+			after := mustSnapshot(t, dir)
+
+			if got := diff(before, after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTakeSnapshot_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	if snap := mustSnapshot(t, path); len(snap) != 0 {
+		t.Errorf("snapshot of missing file = %v, want empty", snap)
+	}
+
+	writeFile(t, path, "line\n")
+	before := mustSnapshot(t, path)
+	if _, ok := before[path]; !ok || len(before) != 1 {
+		t.Fatalf("snapshot = %v, want only %s", before, path)
+	}
+
+	writeFile(t, path, "line\nmore\n")
+	want := []fsnotify.Event{{Name: path, Op: fsnotify.Write}}
+	if got := diff(before, mustSnapshot(t, path)); !reflect.DeepEqual(got, want) {
+		t.Errorf("diff() = %v, want %v", got, want)
+	}
+}
+
+func TestTakeSnapshot_FollowsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(t.TempDir(), "container.log")
+	link := filepath.Join(dir, "pod.log")
+	writeFile(t, target, "one\n")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	before := mustSnapshot(t, dir)
+	writeFile(t, target, "one\ntwo\n")
+	// Make sure the modification time differs on coarse clocks
+	later := time.Now().Add(time.Second)
+	os.Chtimes(target, later, later)
+
+	want := []fsnotify.Event{{Name: link, Op: fsnotify.Write}}
+	if got := diff(before, mustSnapshot(t, dir)); !reflect.DeepEqual(got, want) {
+		t.Errorf("diff() = %v, want %v", got, want)
+	}
+}
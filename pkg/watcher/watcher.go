@@ -0,0 +1,209 @@
+package watcher
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Modes select how a path is watched
+const (
+	// ModeAuto uses fsnotify unless the path is on a file system known not to
+	// deliver events, or the fsnotify watch cannot be added, and polls otherwise
+	ModeAuto = "auto"
+	// ModeNotify relies on fsnotify events only
+	ModeNotify = "fsnotify"
+	// ModePoll compares stat snapshots every poll interval
+	ModePoll = "poll"
+	// ModeHybrid uses fsnotify and polls as a safety net for missed events
+	ModeHybrid = "hybrid"
+)
+
+// DefaultInterval is the poll interval used when none is set
+const DefaultInterval = 100 * time.Millisecond
+
+// ValidateMode reports whether mode is a known watch mode
+func ValidateMode(mode string) error {
+	switch mode {
+	case "", ModeAuto, ModeNotify, ModePoll, ModeHybrid:
+		return nil
+	}
+	return fmt.Errorf("invalid watch mode '%s': must be %s, %s, %s or %s", mode, ModeAuto, ModeNotify, ModePoll, ModeHybrid)
+}
+
+// Watcher reports changes to files and directories as fsnotify events, using
+// fsnotify, stat polling or both depending on the mode each path is added
+// with. Watching a directory reports changes to its entries, as fsnotify does.
+//
+// Polling reports Create for new entries and for entries replaced by a
+// different file, Write when a file's size or modification time changed, and
+// Remove for entries that disappeared. A rename is reported as Remove of the
+// old name and Create of the new one. In hybrid mode a change may be reported
+// twice, so consumers must treat events as hints to re-check the file.
+type Watcher struct {
+	interval time.Duration
+	events   chan fsnotify.Event
+	errors   chan error
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	notify *fsnotify.Watcher
+	// polled holds the last snapshot of each polled path
+	polled  map[string]snapshot
+	polling bool
+	closed  bool
+}
+
+// New creates a watcher that polls at the given interval. An interval of zero
+// or less selects DefaultInterval.
+func New(interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Watcher{
+		interval: interval,
+		events:   make(chan fsnotify.Event),
+		errors:   make(chan error),
+		done:     make(chan struct{}),
+		polled:   make(map[string]snapshot),
+	}
+}
+
+// Events returns the channel of file system events
+func (w *Watcher) Events() <-chan fsnotify.Event {
+	return w.events
+}
+
+// Errors returns the channel of watch errors
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Add watches path in the given mode and returns the mode in effect, which
+// differs from the requested one when auto mode falls back to polling. An
+// empty mode means ModeAuto.
+func (w *Watcher) Add(path, mode string) (string, error) {
+	if err := ValidateMode(mode); err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return "", fmt.Errorf("watcher is closed")
+	}
+
+	switch mode {
+	case ModeNotify:
+		return ModeNotify, w.addNotify(path)
+	case ModePoll:
+		return ModePoll, w.addPoll(path)
+	case ModeHybrid:
+		if err := w.addNotify(path); err != nil {
+			return "", err
+		}
+		return ModeHybrid, w.addPoll(path)
+	}
+
+	if !notifyReliable(path) || w.addNotify(path) != nil {
+		return ModePoll, w.addPoll(path)
+	}
+	return ModeNotify, nil
+}
+
+// Remove stops watching path. Removing a path that is not watched is not an error.
+func (w *Watcher) Remove(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.polled, path)
+	if w.notify != nil {
+		w.notify.Remove(path)
+	}
+}
+
+// addNotify adds an fsnotify watch, creating the fsnotify watcher on first use
+func (w *Watcher) addNotify(path string) error {
+	if w.notify == nil {
+		notify, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to create file watcher: %w", err)
+		}
+		w.notify = notify
+		w.wg.Add(1)
+		go w.forward(notify)
+	}
+
+	if err := w.notify.Add(path); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	return nil
+}
+
+// forward passes the events and errors of the fsnotify watcher on until it is closed
+func (w *Watcher) forward(notify *fsnotify.Watcher) {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case event, ok := <-notify.Events:
+			if !ok {
+				return
+			}
+			w.sendEvent(event)
+		case err, ok := <-notify.Errors:
+			if !ok {
+				return
+			}
+			w.sendError(err)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// sendEvent delivers an event unless the watcher is closed
+func (w *Watcher) sendEvent(event fsnotify.Event) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// sendError delivers an error unless the watcher is closed
+func (w *Watcher) sendError(err error) bool {
+	select {
+	case w.errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// Close stops watching all paths and closes the event and error channels
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+
+	var err error
+	if w.notify != nil {
+		err = w.notify.Close()
+	}
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	close(w.events)
+	close(w.errors)
+	return err
+}
@@ -0,0 +1,166 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// waitForEvent reads events until one for name with op arrives
+func waitForEvent(t *testing.T, w *Watcher, name string, op fsnotify.Op) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-w.Events():
+			if event.Name == name && event.Op&op == op {
+				return
+			}
+		case err := <-w.Errors():
+			t.Fatalf("watcher error: %v", err)
+		case <-timeout:
+			t.Fatalf("timeout waiting for %v on %s", op, name)
+		}
+	}
+}
+
+func TestValidateMode(t *testing.T) {
+	for _, mode := range []string{"", ModeAuto, ModeNotify, ModePoll, ModeHybrid} {
+		if err := ValidateMode(mode); err != nil {
+			t.Errorf("ValidateMode(%q) error = %v", mode, err)
+		}
+	}
+	if err := ValidateMode("inotify"); err == nil {
+		t.Error("ValidateMode(\"inotify\") expected error")
+	}
+}
+
+func TestWatcher_Modes(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{mode: ModeNotify, want: ModeNotify},
+		{mode: ModePoll, want: ModePoll},
+		{mode: ModeHybrid, want: ModeHybrid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			dir := t.TempDir()
+			w := New(10 * time.Millisecond)
+			defer w.Close()
+
+			mode, err := w.Add(dir, tt.mode)
+			if err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if mode != tt.want {
+				t.Errorf("Add() mode = %q, want %q", mode, tt.want)
+			}
+
+			path := filepath.Join(dir, "app.log")
+			writeFile(t, path, "")
+			waitForEvent(t, w, path, fsnotify.Create)
+
+			writeFile(t, path, "CID:abc\n")
+			waitForEvent(t, w, path, fsnotify.Write)
+
+			os.Remove(path)
+			waitForEvent(t, w, path, fsnotify.Remove)
+		})
+	}
+}
+
+func TestWatcher_Auto(t *testing.T) {
+	w := New(10 * time.Millisecond)
+	defer w.Close()
+
+	mode, err := w.Add(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if mode != ModeNotify && mode != ModePoll {
+		t.Errorf("Add() mode = %q, want %s or %s", mode, ModeNotify, ModePoll)
+	}
+}
+
+func TestWatcher_AddMissingPath(t *testing.T) {
+	w := New(10 * time.Millisecond)
+	defer w.Close()
+
+	for _, mode := range []string{ModeAuto, ModeNotify, ModePoll, ModeHybrid} {
+		if _, err := w.Add("/nonexistent/dir", mode); err == nil {
+			t.Errorf("Add() in %s mode expected error", mode)
+		}
+	}
+	if _, err := w.Add(t.TempDir(), "inotify"); err == nil {
+		t.Error("Add() with invalid mode expected error")
+	}
+}
+
+func TestWatcher_PollPathReappears(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	os.Mkdir(dir, 0755)
+
+	w := New(10 * time.Millisecond)
+	defer w.Close()
+	if _, err := w.Add(dir, ModePoll); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "")
+	waitForEvent(t, w, path, fsnotify.Create)
+
+	os.RemoveAll(dir)
+	waitForEvent(t, w, path, fsnotify.Remove)
+
+	os.Mkdir(dir, 0755)
+	writeFile(t, path, "")
+	waitForEvent(t, w, path, fsnotify.Create)
+}
+
+func TestWatcher_Remove(t *testing.T) {
+	dir := t.TempDir()
+	w := New(10 * time.Millisecond)
+	defer w.Close()
+	if _, err := w.Add(dir, ModePoll); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	w.Remove(dir)
+	writeFile(t, filepath.Join(dir, "app.log"), "")
+
+	select {
+	case event := <-w.Events():
+		t.Errorf("unexpected event after Remove: %v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatcher_Close(t *testing.T) {
+	w := New(10 * time.Millisecond)
+	if _, err := w.Add(t.TempDir(), ModeHybrid); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, ok := <-w.Events(); ok {
+		t.Error("Events() not closed")
+	}
+	if _, ok := <-w.Errors(); ok {
+		t.Error("Errors() not closed")
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if _, err := w.Add(t.TempDir(), ModePoll); err == nil {
+		t.Error("Add() after Close expected error")
+	}
+}
@@ -0,0 +1,111 @@
+package workerpool
+
+import (
+	"sync"
+)
+
+// Pool runs tasks on a fixed number of workers while keeping the results of
+// each key in submission order. A task has two phases: process runs on any
+// free worker, concurrently with every other task, and returns a commit
+// function that runs only after the commit of the previous task with the same
+// key. Expensive work belongs in process and ordered side effects, such as
+// writing output, in commit.
+//
+// The queue holds at most queueSize tasks; Submit blocks while it is full,
+// which slows the producer down to the speed of the workers.
+type Pool struct {
+	queue chan task
+	wg    sync.WaitGroup
+
+	mu sync.Mutex
+	// tails holds, per key, the channel closed once the key's most recently
+	// submitted task has committed
+	tails map[string]chan struct{}
+}
+
+// task is a queued unit of work and its place in the key's sequence
+type task struct {
+	key     string
+	process func() func()
+	prev    <-chan struct{}
+	done    chan struct{}
+}
+
+// closed is a channel that is always ready, used as the predecessor of the
+// first task of a key
+var closed = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// New starts a pool of workers with a queue of queueSize tasks. Values below
+// one are raised to one.
+func New(workers, queueSize int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	p := &Pool{
+		queue: make(chan task, queueSize),
+		tails: make(map[string]chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues a task for key, blocking while the queue is full. A nil
+// commit function returned by process is skipped but still keeps its place in
+// the sequence. Submit must not be called after or concurrently with Close.
+func (p *Pool) Submit(key string, process func() (commit func())) {
+	done := make(chan struct{})
+
+	p.mu.Lock()
+	prev, ok := p.tails[key]
+	if !ok {
+		prev = closed
+	}
+	p.tails[key] = done
+	p.mu.Unlock()
+
+	p.queue <- task{key: key, process: process, prev: prev, done: done}
+}
+
+// Len returns the number of tasks waiting for a worker
+func (p *Pool) Len() int {
+	return len(p.queue)
+}
+
+// Close waits for every submitted task to commit and stops the workers
+func (p *Pool) Close() {
+	close(p.queue)
+	p.wg.Wait()
+}
+
+// work runs tasks until the queue is closed. A task waiting for its
+// predecessor cannot deadlock: the queue is FIFO, so every earlier task of
+// the key has already been taken by a worker.
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for t := range p.queue {
+		commit := t.process()
+		<-t.prev
+		if commit != nil {
+			commit()
+		}
+		close(t.done)
+
+		p.mu.Lock()
+		if p.tails[t.key] == t.done {
+			delete(p.tails, t.key)
+		}
+		p.mu.Unlock()
+	}
+}
@@ -0,0 +1,157 @@
+package workerpool
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool_PerKeyOrder(t *testing.T) {
+	p := New(8, 16)
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+
+	keys := []string{"a.log", "b.log", "c.log"}
+	for i := 0; i < 300; i++ {
+		key, n := keys[i%len(keys)], i
+		p.Submit(key, func() func() {
+			// Later tasks often finish processing first
+			time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+			return func() {
+				mu.Lock()
+				got[key] = append(got[key], n)
+				mu.Unlock()
+			}
+		})
+	}
+	p.Close()
+
+	for k, key := range keys {
+		var want []int
+		for i := k; i < 300; i += len(keys) {
+			want = append(want, i)
+		}
+		if !reflect.DeepEqual(got[key], want) {
+			t.Errorf("%s committed %v, want %v", key, got[key], want)
+		}
+	}
+
+	if len(p.tails) != 0 {
+		t.Errorf("tails = %d entries after Close, want 0", len(p.tails))
+	}
+}
+
+func TestPool_ProcessesConcurrently(t *testing.T) {
+	p := New(4, 4)
+	defer p.Close()
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{}, 4)
+
+	for i := 0; i < 4; i++ {
+		// One key: processing is still parallel, only commits are ordered
+		p.Submit("big.log", func() func() {
+			n := running.Add(1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			started <- struct{}{}
+			<-release
+			running.Add(-1)
+			return nil
+		})
+	}
+
+	for i := 0; i < 4; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for tasks to run concurrently")
+		}
+	}
+	close(release)
+
+	if peak.Load() != 4 {
+		t.Errorf("peak concurrency = %d, want 4", peak.Load())
+	}
+}
+
+func TestPool_Backpressure(t *testing.T) {
+	p := New(1, 2)
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.Submit("a", func() func() {
+		close(started)
+		<-block
+		return nil
+	})
+	<-started
+
+	// The worker holds the first task; two more fill the queue
+	for i := 0; i < 2; i++ {
+		p.Submit("a", func() func() { return nil })
+	}
+
+	submitted := make(chan struct{})
+	go func() {
+		p.Submit("b", func() func() { return nil })
+		close(submitted)
+	}()
+
+	select {
+	case <-submitted:
+		t.Fatal("Submit() did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(block)
+	select {
+	case <-submitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit() still blocked after the queue drained")
+	}
+	p.Close()
+}
+
+func TestPool_CloseDrains(t *testing.T) {
+	p := New(2, 100)
+
+	var committed atomic.Int32
+	for i := 0; i < 50; i++ {
+		p.Submit(fmt.Sprintf("file-%d", i%5), func() func() {
+			return func() { committed.Add(1) }
+		})
+	}
+	p.Close()
+
+	if committed.Load() != 50 {
+		t.Errorf("committed = %d, want 50", committed.Load())
+	}
+}
+
+func TestNew_Bounds(t *testing.T) {
+	p := New(0, 0)
+	defer p.Close()
+
+	if cap(p.queue) != 1 {
+		t.Errorf("queue capacity = %d, want 1", cap(p.queue))
+	}
+
+	done := make(chan struct{})
+	p.Submit("a", func() func() { return func() { close(done) } })
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task did not run with the minimum of one worker")
+	}
+}